@@ -0,0 +1,45 @@
+package function
+
+import (
+	"context"
+	"strconv"
+
+	faasSDK "github.com/openfaas/faas-cli/proxy"
+	"github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+// isCanary is true when a function opted into progressive delivery and
+// a previous version is already serving its traffic.
+func isCanary(annotations map[string]string, previous *types.FunctionStatus) bool {
+	if previous == nil || len(previous.Image) == 0 {
+		return false
+	}
+
+	enabled, _ := strconv.ParseBool(annotations[sdk.CanaryAnnotation])
+	return enabled
+}
+
+// buildCanarySpec copies the deployment spec of a function so that the new
+// version is deployed as a parallel canary function.
+func buildCanarySpec(deploySpec *faasSDK.DeployFunctionSpec) *faasSDK.DeployFunctionSpec {
+	spec := *deploySpec
+	spec.FunctionName = sdk.FormatCanaryName(deploySpec.FunctionName)
+
+	spec.Labels = map[string]string{}
+	for k, v := range deploySpec.Labels {
+		spec.Labels[k] = v
+	}
+
+	spec.Labels["faas_function"] = spec.FunctionName
+	spec.Labels["app"] = spec.FunctionName
+	spec.Labels[sdk.CanaryOfLabel] = deploySpec.FunctionName
+
+	return &spec
+}
+
+// removeCanary deletes a canary function, leaving the previous version to
+// serve all of the traffic.
+func removeCanary(ctx context.Context, client *faasSDK.Client, canaryName string) error {
+	return client.DeleteFunction(ctx, canaryName, namespace)
+}
@@ -0,0 +1,79 @@
+package function
+
+import (
+	"testing"
+
+	faasSDK "github.com/openfaas/faas-cli/proxy"
+	"github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+func Test_isCanary(t *testing.T) {
+	previous := &types.FunctionStatus{Image: "alexellis/fn1:latest-aaa"}
+
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		previous    *types.FunctionStatus
+		want        bool
+	}{
+		{
+			name:        "opted in with a previous version",
+			annotations: map[string]string{sdk.CanaryAnnotation: "true"},
+			previous:    previous,
+			want:        true,
+		},
+		{
+			name:        "opted in for the first deployment",
+			annotations: map[string]string{sdk.CanaryAnnotation: "true"},
+			want:        false,
+		},
+		{
+			name:        "not opted in",
+			annotations: map[string]string{},
+			previous:    previous,
+			want:        false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := isCanary(c.annotations, c.previous)
+			if got != c.want {
+				t.Errorf("want %t, got %t", c.want, got)
+			}
+		})
+	}
+}
+
+func Test_buildCanarySpec(t *testing.T) {
+	deploySpec := &faasSDK.DeployFunctionSpec{
+		FunctionName: "alexellis-fn1",
+		Image:        "alexellis/fn1:latest-bbb",
+		Labels: map[string]string{
+			"faas_function":                     "alexellis-fn1",
+			"app":                               "alexellis-fn1",
+			sdk.FunctionLabelPrefix + "git-sha": "bbb",
+		},
+	}
+
+	spec := buildCanarySpec(deploySpec)
+
+	if spec.FunctionName != "alexellis-fn1-canary" {
+		t.Errorf("want name: %s, got: %s", "alexellis-fn1-canary", spec.FunctionName)
+	}
+	if spec.Labels["faas_function"] != "alexellis-fn1-canary" {
+		t.Errorf("want faas_function label: %s, got: %s", "alexellis-fn1-canary", spec.Labels["faas_function"])
+	}
+	if spec.Labels[sdk.CanaryOfLabel] != "alexellis-fn1" {
+		t.Errorf("want %s label: %s, got: %s", sdk.CanaryOfLabel, "alexellis-fn1", spec.Labels[sdk.CanaryOfLabel])
+	}
+	if deploySpec.Labels["faas_function"] != "alexellis-fn1" {
+		t.Errorf("want original spec to be left unchanged")
+	}
+
+	record := buildDeploymentRecord(&sdk.Event{Service: "fn1"}, spec, deploymentStatusSuccess)
+	if record.Action != sdk.DeploymentActionCanary {
+		t.Errorf("want action: %s, got: %s", sdk.DeploymentActionCanary, record.Action)
+	}
+}
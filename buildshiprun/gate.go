@@ -0,0 +1,84 @@
+package function
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	faasSDK "github.com/openfaas/faas-cli/proxy"
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+// DeployGate holds a deploy until it is approved, its required checks
+// have passed and it is outside of any freeze window
+type DeployGate struct {
+	RequiresApproval bool
+	Approvers        []string
+	RequiredChecks   []string
+	Frozen           bool
+	FreezeWindow     sdk.FreezeWindow
+}
+
+// getDeployGate reads the gate for a function from its annotations, and the
+// freeze_windows and required_checks set by the administrator
+func getDeployGate(annotations map[string]string, now time.Time) (DeployGate, error) {
+	gate := DeployGate{
+		Approvers:      sdk.ParseApprovers(annotations[sdk.DeployApproversAnnotation]),
+		RequiredChecks: sdk.ParseRequiredChecks(os.Getenv("required_checks")),
+	}
+
+	if val, ok := annotations[sdk.DeployRequiredChecksAnnotation]; ok {
+		gate.RequiredChecks = sdk.ParseRequiredChecks(val)
+	}
+
+	gate.RequiresApproval, _ = strconv.ParseBool(annotations[sdk.DeployApprovalAnnotation])
+
+	window, frozen, err := sdk.ActiveFreeze(os.Getenv("freeze_windows"), annotations, now)
+	if err != nil {
+		return gate, err
+	}
+
+	gate.Frozen = frozen
+	gate.FreezeWindow = window
+
+	return gate, nil
+}
+
+// Held is true when the deploy must be queued
+func (g DeployGate) Held() bool {
+	return g.RequiresApproval || g.Frozen || len(g.RequiredChecks) > 0
+}
+
+// Status gives the state and description to report for a held deploy
+func (g DeployGate) Status(functionName string) (string, string) {
+	switch {
+	case g.RequiresApproval && g.Frozen:
+		return sdk.StatusActionRequired, fmt.Sprintf("awaiting approval to deploy %s, queued during freeze: %s", functionName, g.FreezeWindow.Description)
+	case g.RequiresApproval:
+		return sdk.StatusActionRequired, fmt.Sprintf("awaiting approval to deploy %s", functionName)
+	case g.Frozen:
+		return sdk.StatusPending, fmt.Sprintf("deploy of %s queued during freeze: %s", functionName, g.FreezeWindow.Description)
+	}
+	return sdk.StatusPending, fmt.Sprintf("waiting for checks to pass before deploying %s: %s", functionName, strings.Join(g.RequiredChecks, ", "))
+}
+
+// queueDeployment hands a held deploy to the deployment-gate function
+func queueDeployment(gatewayURL, payloadSecret string, event *sdk.Event, deploySpec *faasSDK.DeployFunctionSpec, gate DeployGate) error {
+	pending := sdk.PendingDeployment{
+		Event:            *event,
+		Record:           buildDeploymentRecord(event, deploySpec, ""),
+		FunctionName:     deploySpec.FunctionName,
+		RequiresApproval: gate.RequiresApproval,
+		Approvers:        gate.Approvers,
+		RequiredChecks:   gate.RequiredChecks,
+		Queued:           time.Now().UTC(),
+	}
+
+	_, err := sdk.PostGateRequest(gatewayURL, payloadSecret, sdk.GateRequest{
+		Action:     sdk.GateActionQueue,
+		Deployment: &pending,
+	})
+	return err
+}
@@ -0,0 +1,110 @@
+package function
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+func Test_getDeployGate_NotHeld(t *testing.T) {
+	os.Setenv("freeze_windows", "")
+
+	gate, err := getDeployGate(map[string]string{}, time.Now())
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err.Error())
+	}
+
+	if gate.Held() {
+		t.Errorf("want deploy not to be held")
+	}
+}
+
+func Test_getDeployGate_Approval(t *testing.T) {
+	os.Setenv("freeze_windows", "")
+
+	gate, err := getDeployGate(map[string]string{
+		sdk.DeployApprovalAnnotation:  "true",
+		sdk.DeployApproversAnnotation: "alexellis,rgee0",
+	}, time.Now())
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err.Error())
+	}
+
+	if !gate.Held() {
+		t.Fatalf("want deploy to be held")
+	}
+	if len(gate.Approvers) != 2 {
+		t.Errorf("want 2 approvers, got: %d", len(gate.Approvers))
+	}
+
+	state, desc := gate.Status("alexellis-fn1")
+	if state != sdk.StatusActionRequired {
+		t.Errorf("want state: %s, got: %s", sdk.StatusActionRequired, state)
+	}
+	if desc != "awaiting approval to deploy alexellis-fn1" {
+		t.Errorf("want description: %s, got: %s", "awaiting approval to deploy alexellis-fn1", desc)
+	}
+}
+
+func Test_getDeployGate_AdminFreeze(t *testing.T) {
+	os.Setenv("freeze_windows", "Sat-Sun")
+	defer os.Setenv("freeze_windows", "")
+
+	saturday := time.Date(2020, 4, 18, 12, 0, 0, 0, time.UTC)
+	gate, err := getDeployGate(map[string]string{}, saturday)
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err.Error())
+	}
+
+	if !gate.Held() {
+		t.Fatalf("want deploy to be held during freeze")
+	}
+
+	state, desc := gate.Status("alexellis-fn1")
+	if state != sdk.StatusPending {
+		t.Errorf("want state: %s, got: %s", sdk.StatusPending, state)
+	}
+	if desc != "deploy of alexellis-fn1 queued during freeze: Sat-Sun" {
+		t.Errorf("want description: %s, got: %s", "deploy of alexellis-fn1 queued during freeze: Sat-Sun", desc)
+	}
+}
+
+func Test_getDeployGate_InvalidOwnerFreeze(t *testing.T) {
+	os.Setenv("freeze_windows", "")
+
+	_, err := getDeployGate(map[string]string{sdk.DeployFreezeAnnotation: "Someday"}, time.Now())
+	if err == nil {
+		t.Errorf("want error for invalid freeze window")
+	}
+}
+
+func Test_getDeployGate_RequiredChecks(t *testing.T) {
+	os.Setenv("freeze_windows", "")
+	os.Setenv("required_checks", "test")
+	defer os.Setenv("required_checks", "")
+
+	gate, err := getDeployGate(map[string]string{}, time.Now())
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err.Error())
+	}
+
+	if !gate.Held() {
+		t.Fatalf("want deploy to be held for required checks")
+	}
+
+	state, desc := gate.Status("alexellis-fn1")
+	if state != sdk.StatusPending {
+		t.Errorf("want state: %s, got: %s", sdk.StatusPending, state)
+	}
+	want := "waiting for checks to pass before deploying alexellis-fn1: test"
+	if desc != want {
+		t.Errorf("want description: %s, got: %s", want, desc)
+	}
+
+	gate, _ = getDeployGate(map[string]string{sdk.DeployRequiredChecksAnnotation: ""}, time.Now())
+	if gate.Held() {
+		t.Errorf("want an empty annotation to override required_checks")
+	}
+}
@@ -3,7 +3,9 @@ module github.com/openfaas/openfaas-cloud/buildshiprun
 go 1.13
 
 require (
+	github.com/alexellis/derek v0.0.0-20200824120721-b453a7326b67
 	github.com/alexellis/hmac v0.0.0-20180624210714-d5d71edd7bc7
+	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/drone/envsubst v1.0.2
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/openfaas/faas v0.0.0-20200305154109-326cc7a9b923
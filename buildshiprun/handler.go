@@ -18,6 +18,7 @@ import (
 	"github.com/alexellis/hmac"
 	faasSDK "github.com/openfaas/faas-cli/proxy"
 	"github.com/openfaas/faas-cli/stack"
+	"github.com/openfaas/faas-provider/types"
 	"github.com/openfaas/openfaas-cloud/sdk"
 )
 
@@ -75,6 +76,10 @@ func Handle(req []byte) string {
 		return err.Error()
 	}
 
+	if os.Getenv("Http_"+sdk.GateReleaseHeader) == "true" {
+		return releaseDeployment(req, gatewayURL, payloadSecret)
+	}
+
 	event, eventErr := getEventFromEnv()
 	if eventErr != nil {
 		log.Panic(eventErr)
@@ -89,13 +94,16 @@ func Handle(req []byte) string {
 	serviceValue := sdk.FormatServiceName(event.Owner, event.Service)
 	log.Printf("%d env-vars for %s", len(event.Environment), serviceValue)
 
-	status := sdk.BuildStatus(event, sdk.EmptyAuthToken)
+	// the token used by git-tar is reused for the statuses of the deploy
+	status := sdk.BuildStatus(event, os.Getenv("Http_Auth_Token"))
 
 	reader := bytes.NewBuffer(req)
 
 	xCloudSignature := os.Getenv("Http_X_Cloud_Signature")
 
 	r, _ := http.NewRequest(http.MethodPost, builderURL+"build", reader)
+	buildContext := sdk.BuildFunctionContext(event.Service)
+	buildStage := sdk.StartStage(sdk.StageBuild)
 
 	r.Header.Set(sdk.CloudSignatureHeader, xCloudSignature)
 	r.Header.Set("Content-Type", "application/octet-stream")
@@ -108,6 +116,7 @@ func Handle(req []byte) string {
 		auditEvent.Message = fmt.Sprintf("buildshiprun failure: %s", err.Error())
 		sdk.PostAudit(auditEvent)
 
+		addBuildStages(status, buildContext, nil, buildStage.Complete(), false)
 		status.AddStatus(sdk.StatusFailure, err.Error(), sdk.BuildFunctionContext(event.Service))
 		statusErr := reportStatus(status, event.SCM)
 		if statusErr != nil {
@@ -122,6 +131,7 @@ func Handle(req []byte) string {
 	defer res.Body.Close()
 
 	buildBytes, _ := ioutil.ReadAll(res.Body)
+	buildStage = buildStage.Complete()
 
 	result := sdk.BuildResult{}
 	unmarshalErr := json.Unmarshal(buildBytes, &result)
@@ -132,6 +142,7 @@ func Handle(req []byte) string {
 		auditEvent.Message = fmt.Sprintf("buildshiprun failure reading response: %s, response: %s", unmarshalErr.Error(), string(buildBytes))
 		sdk.PostAudit(auditEvent)
 
+		addBuildStages(status, buildContext, nil, buildStage, false)
 		status.AddStatus(sdk.StatusFailure, unmarshalErr.Error(), sdk.BuildFunctionContext(event.Service))
 		statusErr := reportStatus(status, event.SCM)
 		if statusErr != nil {
@@ -170,8 +181,23 @@ func Handle(req []byte) string {
 		log.Printf("pipeline-log: status: %d", logStatus)
 	}
 
+	if res.StatusCode == http.StatusConflict {
+		msg := supersededMessage(serviceValue, "")
+		addBuildStages(status, buildContext, result.Stages, buildStage, false)
+		status.AddStatus(sdk.StatusSuperseded, msg, sdk.BuildFunctionContext(event.Service))
+		statusErr := reportStatus(status, event.SCM)
+		if statusErr != nil {
+			log.Printf(statusErr.Error())
+		}
+
+		auditEvent.Message = fmt.Sprintf("buildshiprun %s", msg)
+		sdk.PostAudit(auditEvent)
+		return auditEvent.Message
+	}
+
 	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusAccepted {
 		msg := "Unable to build image, check builder logs"
+		addBuildStages(status, buildContext, result.Stages, buildStage, false)
 		status.AddStatus(sdk.StatusFailure, msg, sdk.BuildFunctionContext(event.Service))
 		statusErr := reportStatus(status, event.SCM)
 		if statusErr != nil {
@@ -186,10 +212,61 @@ func Handle(req []byte) string {
 		log.Fatal(msg)
 		return msg
 	}
+
+	// The stage statuses are sent with the next status to be reported
+	addBuildStages(status, buildContext, result.Stages, buildStage, true)
+
+	if len(imageName) > 0 {
+		if size, sizeErr := getImageSize(imageName); sizeErr != nil {
+			log.Printf("unable to get the size of %s: %s", imageName, sizeErr.Error())
+		} else {
+			event.ImageSize = size
+			status.EventInfo.ImageSize = size
+		}
+	}
+
+	if result.Test != nil {
+		testState := sdk.StatusSuccess
+		if !result.Test.Succeeded() {
+			testState = sdk.StatusFailure
+		}
+
+		status.AddStatusWithSummary(testState, result.Test.Description(), result.Test.Markdown(), sdk.BuildTestContext(event.Service))
+
+		if testState == sdk.StatusFailure {
+			msg := fmt.Sprintf("tests failed, not deploying %s", serviceValue)
+			status.AddStatus(sdk.StatusFailure, msg, sdk.BuildFunctionContext(event.Service))
+			statusErr := reportStatus(status, event.SCM)
+			if statusErr != nil {
+				log.Printf(statusErr.Error())
+			}
+
+			auditEvent.Message = fmt.Sprintf("buildshiprun %s: %s", msg, result.Test.Description())
+			sdk.PostAudit(auditEvent)
+			return auditEvent.Message
+		}
+	}
+
+	// A newer commit may have been pushed while the function was built
+	if newerSHA := getSupersedingSHA(builderURL, event); len(newerSHA) > 0 {
+		msg := supersededMessage(serviceValue, newerSHA)
+		status.AddStatus(sdk.StatusSuperseded, msg, sdk.BuildFunctionContext(event.Service))
+		statusErr := reportStatus(status, event.SCM)
+		if statusErr != nil {
+			log.Printf(statusErr.Error())
+		}
+
+		auditEvent.Message = fmt.Sprintf("buildshiprun %s", msg)
+		sdk.PostAudit(auditEvent)
+		return auditEvent.Message
+	}
+
 	// Initializing the client and context
 	client := faasSDK.NewClient(&FaaSAuth{}, gatewayURL, nil, &timeout)
 	ctx := context.Background()
 
+	deployedMessage := fmt.Sprintf("deployed: %s", serviceValue)
+
 	if len(imageName) > 0 {
 		// Replace image name for "localhost" for deployment
 		imageName = getImageName(repositoryURL, pushRepositoryURL, imageName)
@@ -228,6 +305,14 @@ func Handle(req []byte) string {
 			"schedule",
 			"com.openfaas.health.http.path",
 			"com.openfaas.health.http.initialDelay",
+			sdk.CanaryAnnotation,
+			sdk.CanaryStepsAnnotation,
+			sdk.CanaryIntervalAnnotation,
+			sdk.CanaryMaxErrorRateAnnotation,
+			sdk.CanaryMaxLatencyAnnotation,
+			sdk.DeployApprovalAnnotation,
+			sdk.DeployApproversAnnotation,
+			sdk.DeployFreezeAnnotation,
 		}
 
 		userAnnotations := buildAnnotations(annotationWhitelist, event.Annotations)
@@ -249,6 +334,7 @@ func Handle(req []byte) string {
 				sdk.FunctionLabelPrefix + "git-owner":      event.Owner,
 				sdk.FunctionLabelPrefix + "git-owner-id":   fmt.Sprintf("%d", event.OwnerID),
 				sdk.FunctionLabelPrefix + "git-repo":       event.Repository,
+				sdk.FunctionLabelPrefix + "git-repo-id":    fmt.Sprintf("%d", event.RepositoryID),
 				sdk.FunctionLabelPrefix + "git-deploytime": strconv.FormatInt(time.Now().Unix(), 10), //Unix Epoch string
 				sdk.FunctionLabelPrefix + "git-sha":        event.SHA,
 				sdk.FunctionLabelPrefix + "git-private":    fmt.Sprintf("%d", private),
@@ -285,27 +371,79 @@ func Handle(req []byte) string {
 			deploy.RegistryAuth = registryAuth
 		}
 
-		deployResult, err := deployFunction(ctx, client, deploy, gatewayURL)
-		log.Println(deployResult)
+		var previous *types.FunctionStatus
+		if info, infoErr := client.GetFunctionInfo(ctx, serviceValue, namespace); infoErr == nil {
+			previous = &info
+		}
 
-		if err != nil {
-			status.AddStatus(sdk.StatusFailure, err.Error(), sdk.BuildFunctionContext(event.Service))
+		// The new version runs alongside the current one until the canary
+		// function promotes it or rolls it back.
+		deployName := serviceValue
+		canary := isCanary(userAnnotations, previous)
+		if canary {
+			deploy = buildCanarySpec(deploy)
+			deployName = deploy.FunctionName
+			deployedMessage = fmt.Sprintf("deployed canary: %s", deployName)
+		}
+
+		gate, gateErr := getDeployGate(userAnnotations, time.Now())
+		if gateErr != nil {
+			status.AddStatus(sdk.StatusFailure, gateErr.Error(), sdk.BuildFunctionContext(event.Service))
 			statusErr := reportStatus(status, event.SCM)
 			if statusErr != nil {
 				log.Printf(statusErr.Error())
 			}
-			log.Fatal(err.Error())
-			auditEvent.Message = fmt.Sprintf("buildshiprun failure: %s", err.Error())
+
+			auditEvent.Message = fmt.Sprintf("buildshiprun failure: %s", gateErr.Error())
 			sdk.PostAudit(auditEvent)
-			log.Fatalf("buildshiprun failure: %s", err.Error())
-		} else {
-			auditEvent.Message = fmt.Sprintf("buildshiprun succeeded: deployed %s", imageName)
+			return auditEvent.Message
+		}
+
+		if gate.Held() {
+			// The status is reported before queueing as the deployment-gate
+			// may deploy straight away when the required checks have passed
+			state, msg := gate.Status(deployName)
+			status.AddStatus(state, msg, sdk.BuildFunctionContext(event.Service))
+			statusErr := reportStatus(status, event.SCM)
+			if statusErr != nil {
+				log.Printf(statusErr.Error())
+			}
+
+			queueErr := queueDeployment(gatewayURL, payloadSecret, event, deploy, gate)
+			if queueErr != nil {
+				msg = fmt.Sprintf("unable to queue deploy of %s: %s", deployName, queueErr.Error())
+
+				status.AddStatus(sdk.StatusFailure, msg, sdk.BuildFunctionContext(event.Service))
+				statusErr := reportStatus(status, event.SCM)
+				if statusErr != nil {
+					log.Printf(statusErr.Error())
+				}
+			}
+
+			auditEvent.Message = fmt.Sprintf("buildshiprun %s", msg)
 			sdk.PostAudit(auditEvent)
+			return auditEvent.Message
+		}
+
+		rollout := &Rollout{
+			Client:          client,
+			Status:          status,
+			Event:           event,
+			Deploy:          deploy,
+			Previous:        previous,
+			Canary:          canary,
+			DeployedMessage: deployedMessage,
+			GatewayURL:      gatewayURL,
+			PayloadSecret:   payloadSecret,
 		}
 
+		if msg, ready := rollout.Run(ctx); !ready {
+			return msg
+		}
+		return fmt.Sprintf("buildStatus %s %s", imageName, res.Status)
 	}
 
-	status.AddStatus(sdk.StatusSuccess, fmt.Sprintf("deployed: %s", serviceValue), sdk.BuildFunctionContext(event.Service))
+	status.AddStatus(sdk.StatusSuccess, deployedMessage, sdk.BuildFunctionContext(event.Service))
 	statusErr := reportStatus(status, event.SCM)
 	if statusErr != nil {
 		log.Printf(statusErr.Error())
@@ -414,11 +552,17 @@ func getEventFromEnv() (*sdk.Event, error) {
 	info.SCM = os.Getenv("Http_Scm")
 	info.Private, _ = strconv.ParseBool(os.Getenv("Http_Private"))
 	info.RepoURL = os.Getenv("Http_Repo_Url")
+	info.Actor = os.Getenv("Http_Actor")
+	info.Handler = os.Getenv("Http_Handler")
 
 	if len(os.Getenv("Http_Owner_Id")) > 0 {
 		info.OwnerID, _ = strconv.Atoi(os.Getenv("Http_Owner_Id"))
 	}
 
+	if len(os.Getenv("Http_Repo_Id")) > 0 {
+		info.RepositoryID, _ = strconv.ParseInt(os.Getenv("Http_Repo_Id"), 10, 64)
+	}
+
 	if len(os.Getenv("Http_Installation_id")) > 0 {
 		info.InstallationID, err = strconv.Atoi(os.Getenv("Http_Installation_id"))
 	}
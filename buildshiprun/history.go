@@ -0,0 +1,63 @@
+package function
+
+import (
+	"log"
+	"time"
+
+	faasSDK "github.com/openfaas/faas-cli/proxy"
+	"github.com/openfaas/faas-cli/stack"
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+// Status values stored with each record in the deployment history
+const (
+	deploymentStatusSuccess    = "success"
+	deploymentStatusFailure    = "failure"
+	deploymentStatusRolledBack = "rolled-back"
+)
+
+// recordDeployment stores the deployment in the deployment-history function
+// and will fail silently if unavailable.
+func recordDeployment(gatewayURL, payloadSecret string, event *sdk.Event, deploySpec *faasSDK.DeployFunctionSpec, status string) {
+	record := buildDeploymentRecord(event, deploySpec, status)
+
+	if err := sdk.PostDeploymentRecord(gatewayURL, payloadSecret, record); err != nil {
+		log.Printf("deployment-history: error: %s", err.Error())
+	}
+}
+
+func buildDeploymentRecord(event *sdk.Event, deploySpec *faasSDK.DeployFunctionSpec, status string) sdk.DeploymentRecord {
+	action := sdk.DeploymentActionDeploy
+	if _, ok := deploySpec.Labels[sdk.CanaryOfLabel]; ok {
+		action = sdk.DeploymentActionCanary
+	}
+
+	return sdk.DeploymentRecord{
+		Owner:       event.Owner,
+		Repo:        event.Repository,
+		Function:    event.Service,
+		Image:       deploySpec.Image,
+		SHA:         event.SHA,
+		Actor:       event.Actor,
+		Status:      status,
+		Action:      action,
+		Time:        time.Now().UTC(),
+		Environment: deploySpec.EnvVars,
+		Secrets:     deploySpec.Secrets,
+		Labels:      deploySpec.Labels,
+		Annotations: deploySpec.Annotations,
+		Limits:      toDeploymentResources(deploySpec.FunctionResourceRequest.Limits),
+		Requests:    toDeploymentResources(deploySpec.FunctionResourceRequest.Requests),
+	}
+}
+
+func toDeploymentResources(resources *stack.FunctionResources) *sdk.DeploymentResources {
+	if resources == nil || (len(resources.Memory) == 0 && len(resources.CPU) == 0) {
+		return nil
+	}
+
+	return &sdk.DeploymentResources{
+		Memory: resources.Memory,
+		CPU:    resources.CPU,
+	}
+}
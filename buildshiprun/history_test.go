@@ -0,0 +1,54 @@
+package function
+
+import (
+	"testing"
+
+	faasSDK "github.com/openfaas/faas-cli/proxy"
+	"github.com/openfaas/faas-cli/stack"
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+func Test_buildDeploymentRecord(t *testing.T) {
+	event := &sdk.Event{
+		Owner:      "alexellis",
+		Repository: "super-pancake",
+		Service:    "slack-fn",
+		SHA:        "af6db1234567",
+		Actor:      "rgee0",
+	}
+
+	deploySpec := &faasSDK.DeployFunctionSpec{
+		FunctionName: "alexellis-slack-fn",
+		Image:        "registry:5000/alexellis/slack-fn:latest-af6db12",
+		EnvVars:      map[string]string{"debug": "true"},
+		Secrets:      []string{"alexellis-token"},
+		FunctionResourceRequest: faasSDK.FunctionResourceRequest{
+			Limits:   &stack.FunctionResources{Memory: "128m"},
+			Requests: &stack.FunctionResources{},
+		},
+	}
+
+	record := buildDeploymentRecord(event, deploySpec, deploymentStatusSuccess)
+
+	if record.Function != "slack-fn" {
+		t.Errorf("want function: %s, got: %s", "slack-fn", record.Function)
+	}
+	if record.Image != deploySpec.Image {
+		t.Errorf("want image: %s, got: %s", deploySpec.Image, record.Image)
+	}
+	if record.Actor != "rgee0" {
+		t.Errorf("want actor: %s, got: %s", "rgee0", record.Actor)
+	}
+	if record.Action != sdk.DeploymentActionDeploy {
+		t.Errorf("want action: %s, got: %s", sdk.DeploymentActionDeploy, record.Action)
+	}
+	if record.Limits == nil || record.Limits.Memory != "128m" {
+		t.Errorf("want memory limit: %s, got: %v", "128m", record.Limits)
+	}
+	if record.Requests != nil {
+		t.Errorf("want no requests, got: %v", record.Requests)
+	}
+	if len(record.Secrets) != 1 {
+		t.Errorf("want 1 secret, got: %d", len(record.Secrets))
+	}
+}
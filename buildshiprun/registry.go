@@ -0,0 +1,176 @@
+package function
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	dockerHubRegistry = "registry-1.docker.io"
+	manifestMediaType = "application/vnd.docker.distribution.manifest.v2+json, application/vnd.oci.image.manifest.v1+json"
+)
+
+var registryClient = &http.Client{Timeout: 10 * time.Second}
+
+type imageManifest struct {
+	Config struct {
+		Size int64 `json:"size"`
+	} `json:"config"`
+	Layers []struct {
+		Size int64 `json:"size"`
+	} `json:"layers"`
+}
+
+// getImageSize returns the compressed size of an image from its manifest
+// in the registry, only registries which allow anonymous pulls or which
+// hand out anonymous tokens are supported
+func getImageSize(image string) (int64, error) {
+	host, repository, reference := parseImageName(image)
+
+	manifestURL := fmt.Sprintf("%s://%s/v2/%s/manifests/%s", registryScheme(host), host, repository, reference)
+
+	res, err := getManifest(manifestURL, "")
+	if err != nil {
+		return 0, err
+	}
+
+	if res.StatusCode == http.StatusUnauthorized {
+		res.Body.Close()
+
+		token, tokenErr := getRegistryToken(res.Header.Get("Www-Authenticate"))
+		if tokenErr != nil {
+			return 0, tokenErr
+		}
+
+		res, err = getManifest(manifestURL, token)
+		if err != nil {
+			return 0, err
+		}
+	}
+	defer res.Body.Close()
+
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status from registry: %d", res.StatusCode)
+	}
+
+	manifest := imageManifest{}
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return 0, fmt.Errorf("unable to parse manifest: %s", err.Error())
+	}
+
+	size := manifest.Config.Size
+	for _, layer := range manifest.Layers {
+		size += layer.Size
+	}
+	return size, nil
+}
+
+func getManifest(manifestURL, token string) (*http.Response, error) {
+	req, _ := http.NewRequest(http.MethodGet, manifestURL, nil)
+	req.Header.Set("Accept", manifestMediaType)
+	if len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return registryClient.Do(req)
+}
+
+// getRegistryToken requests an anonymous token from the realm given in the
+// challenge of the registry, i.e. Bearer realm="https://auth.docker.io/token",service="registry.docker.io"
+func getRegistryToken(challenge string) (string, error) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return "", fmt.Errorf("unsupported registry authentication: %s", challenge)
+	}
+
+	params := map[string]string{}
+	for _, part := range strings.Split(strings.TrimPrefix(challenge, "Bearer "), ",") {
+		keyValue := strings.SplitN(part, "=", 2)
+		if len(keyValue) == 2 {
+			params[strings.TrimSpace(keyValue[0])] = strings.Trim(keyValue[1], `"`)
+		}
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || len(realm.Host) == 0 {
+		return "", fmt.Errorf("invalid realm in registry challenge: %s", challenge)
+	}
+
+	query := realm.Query()
+	for _, key := range []string{"service", "scope"} {
+		if val, ok := params[key]; ok {
+			query.Set(key, val)
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	res, err := registryClient.Get(realm.String())
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status from registry auth: %d", res.StatusCode)
+	}
+
+	token := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("unable to parse registry token: %s", err.Error())
+	}
+
+	if len(token.Token) > 0 {
+		return token.Token, nil
+	}
+	return token.AccessToken, nil
+}
+
+// parseImageName splits an image such as registry:5000/alexellis-fn1:latest-af6db12
+// into the registry host, repository and tag or digest
+func parseImageName(image string) (string, string, string) {
+	host := dockerHubRegistry
+	name := image
+
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		host = parts[0]
+		name = parts[1]
+	}
+
+	if host == "docker.io" || host == "index.docker.io" {
+		host = dockerHubRegistry
+	}
+
+	reference := "latest"
+	if index := strings.Index(name, "@"); index >= 0 {
+		reference = name[index+1:]
+		name = name[:index]
+	} else if index := strings.LastIndex(name, ":"); index >= 0 {
+		reference = name[index+1:]
+		name = name[:index]
+	}
+
+	if host == dockerHubRegistry && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+
+	return host, name, reference
+}
+
+// registryScheme uses plain HTTP for local registries, as Docker does, and
+// for registries addressed by a service name such as registry:5000
+func registryScheme(host string) string {
+	hostname := strings.Split(host, ":")[0]
+	if hostname == "localhost" || strings.HasPrefix(hostname, "127.") || !strings.Contains(hostname, ".") {
+		return "http"
+	}
+	return "https"
+}
@@ -0,0 +1,79 @@
+package function
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_parseImageName(t *testing.T) {
+	cases := []struct {
+		image      string
+		host       string
+		repository string
+		reference  string
+	}{
+		{image: "registry:5000/alexellis-fn1:latest-af6db12", host: "registry:5000", repository: "alexellis-fn1", reference: "latest-af6db12"},
+		{image: "docker.io/ofcommunity/alexellis-fn1:latest-af6db12", host: dockerHubRegistry, repository: "ofcommunity/alexellis-fn1", reference: "latest-af6db12"},
+		{image: "alpine", host: dockerHubRegistry, repository: "library/alpine", reference: "latest"},
+		{image: "ghcr.io/openfaas/fn1@sha256:abc", host: "ghcr.io", repository: "openfaas/fn1", reference: "sha256:abc"},
+	}
+
+	for _, c := range cases {
+		host, repository, reference := parseImageName(c.image)
+		if host != c.host || repository != c.repository || reference != c.reference {
+			t.Errorf("%s: want %s %s %s, got %s %s %s", c.image, c.host, c.repository, c.reference, host, repository, reference)
+		}
+	}
+}
+
+func Test_registryScheme(t *testing.T) {
+	cases := map[string]string{
+		"registry:5000":        "http",
+		"127.0.0.1:5000":       "http",
+		"localhost:5000":       "http",
+		"ghcr.io":              "https",
+		"registry-1.docker.io": "https",
+	}
+
+	for host, want := range cases {
+		if got := registryScheme(host); got != want {
+			t.Errorf("%s: want %s, got %s", host, want, got)
+		}
+	}
+}
+
+func Test_getImageSize_WithToken(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			if r.URL.Query().Get("scope") != "repository:alexellis-fn1:pull" {
+				t.Errorf("unexpected scope: %s", r.URL.Query().Get("scope"))
+			}
+			w.Write([]byte(`{"token": "abc"}`))
+		case "/v2/alexellis-fn1/manifests/latest-af6db12":
+			if r.Header.Get("Authorization") != "Bearer abc" {
+				w.Header().Set("Www-Authenticate", `Bearer realm="`+server.URL+`/token",service="registry",scope="repository:alexellis-fn1:pull"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"config": {"size": 1000}, "layers": [{"size": 2000}, {"size": 3000}]}`))
+		default:
+			t.Errorf("unexpected path: %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	size, err := getImageSize(host + "/alexellis-fn1:latest-af6db12")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if size != 6000 {
+		t.Errorf("want size of 6000, got %d", size)
+	}
+}
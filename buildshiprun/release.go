@@ -0,0 +1,101 @@
+package function
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	faasSDK "github.com/openfaas/faas-cli/proxy"
+	"github.com/openfaas/faas-cli/stack"
+	"github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+// releaseDeployment deploys a function held by the deployment-gate through
+// the same readiness wait, smoke test and rollback as any other deploy
+func releaseDeployment(req []byte, gatewayURL, payloadSecret string) string {
+	pending := sdk.PendingDeployment{}
+	if err := json.Unmarshal(req, &pending); err != nil {
+		log.Printf("unable to parse held deploy: %s", err.Error())
+		os.Exit(1)
+	}
+
+	event := pending.Event
+	deployedMessage := fmt.Sprintf("deployed: %s", pending.FunctionName)
+	if len(pending.ApprovedBy) > 0 {
+		event.Actor = pending.ApprovedBy
+		deployedMessage = fmt.Sprintf("deployed: %s, approved by %s", pending.FunctionName, pending.ApprovedBy)
+	}
+
+	client := faasSDK.NewClient(&FaaSAuth{}, gatewayURL, nil, &timeout)
+	ctx := context.Background()
+
+	serviceValue := sdk.FormatServiceName(event.Owner, event.Service)
+
+	var previous *types.FunctionStatus
+	if info, infoErr := client.GetFunctionInfo(ctx, serviceValue, namespace); infoErr == nil {
+		previous = &info
+	}
+
+	rollout := &Rollout{
+		Client:          client,
+		Status:          sdk.BuildStatus(&event, sdk.EmptyAuthToken),
+		Event:           &event,
+		Deploy:          buildReleaseSpec(&pending, time.Now()),
+		Previous:        previous,
+		Canary:          pending.FunctionName != serviceValue,
+		DeployedMessage: deployedMessage,
+		GatewayURL:      gatewayURL,
+		PayloadSecret:   payloadSecret,
+	}
+
+	msg, _ := rollout.Run(ctx)
+	return msg
+}
+
+// buildReleaseSpec gives the deploy spec of a held deploy from its record,
+// with the registry credentials and root filesystem of this installation
+func buildReleaseSpec(pending *sdk.PendingDeployment, now time.Time) *faasSDK.DeployFunctionSpec {
+	record := pending.Record
+
+	labels := map[string]string{}
+	for k, v := range record.Labels {
+		labels[k] = v
+	}
+	labels[sdk.FunctionLabelPrefix+"git-deploytime"] = strconv.FormatInt(now.Unix(), 10)
+
+	deploySpec := &faasSDK.DeployFunctionSpec{
+		FunctionName: pending.FunctionName,
+		Image:        record.Image,
+		Network:      "func_functions",
+		EnvVars:      record.Environment,
+		Secrets:      record.Secrets,
+		Labels:       labels,
+		Annotations:  record.Annotations,
+		FunctionResourceRequest: faasSDK.FunctionResourceRequest{
+			Limits:   &stack.FunctionResources{},
+			Requests: &stack.FunctionResources{},
+		},
+		ReadOnlyRootFilesystem: getReadOnlyRootFS(),
+	}
+
+	if registryAuth := getRegistryAuthSecret(); len(registryAuth) > 0 {
+		deploySpec.RegistryAuth = registryAuth
+	}
+
+	if record.Limits != nil {
+		deploySpec.FunctionResourceRequest.Limits.Memory = record.Limits.Memory
+		deploySpec.FunctionResourceRequest.Limits.CPU = record.Limits.CPU
+	}
+
+	if record.Requests != nil {
+		deploySpec.FunctionResourceRequest.Requests.Memory = record.Requests.Memory
+		deploySpec.FunctionResourceRequest.Requests.CPU = record.Requests.CPU
+	}
+
+	return deploySpec
+}
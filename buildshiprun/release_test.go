@@ -0,0 +1,35 @@
+package function
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+func Test_buildReleaseSpec(t *testing.T) {
+	pending := &sdk.PendingDeployment{
+		FunctionName: "alexellis-fn1",
+		Record: sdk.DeploymentRecord{
+			Image:   "alexellis/fn1:latest-bbb",
+			Secrets: []string{"alexellis-token"},
+			Labels:  map[string]string{sdk.FunctionLabelPrefix + "git-sha": "bbb"},
+			Limits:  &sdk.DeploymentResources{Memory: "128m"},
+		},
+	}
+
+	spec := buildReleaseSpec(pending, time.Unix(1586952000, 0))
+
+	if spec.FunctionName != "alexellis-fn1" {
+		t.Errorf("want name: %s, got: %s", "alexellis-fn1", spec.FunctionName)
+	}
+	if spec.Image != "alexellis/fn1:latest-bbb" {
+		t.Errorf("want image: %s, got: %s", "alexellis/fn1:latest-bbb", spec.Image)
+	}
+	if got := spec.Labels[sdk.FunctionLabelPrefix+"git-deploytime"]; got != "1586952000" {
+		t.Errorf("want git-deploytime label: %s, got: %s", "1586952000", got)
+	}
+	if spec.FunctionResourceRequest.Limits.Memory != "128m" {
+		t.Errorf("want memory limit: %s, got: %s", "128m", spec.FunctionResourceRequest.Limits.Memory)
+	}
+}
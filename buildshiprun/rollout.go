@@ -0,0 +1,167 @@
+package function
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	faasSDK "github.com/openfaas/faas-cli/proxy"
+	"github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+// Rollout is a deploy of a function which is verified once it is made, it
+// is used for each build and for deploys released by the deployment-gate
+type Rollout struct {
+	Client *faasSDK.Client
+	Status *sdk.Status
+	Event  *sdk.Event
+	Deploy *faasSDK.DeployFunctionSpec
+
+	// Previous is the function which was running before the deploy
+	Previous *types.FunctionStatus
+	Canary   bool
+
+	// DeployedMessage is reported for the function once it is verified
+	DeployedMessage string
+
+	GatewayURL    string
+	PayloadSecret string
+}
+
+// Run deploys the function, waits for it to become ready and runs its smoke
+// test, rolling back when the test fails. The deploy is recorded and its
+// result is reported for the function, false is returned when the function
+// did not become ready.
+func (r *Rollout) Run(ctx context.Context) (string, bool) {
+	event := r.Event
+	status := r.Status
+	deploy := r.Deploy
+	deployName := deploy.FunctionName
+	buildContext := sdk.BuildFunctionContext(event.Service)
+
+	auditEvent := sdk.AuditEvent{
+		Owner:  event.Owner,
+		Repo:   event.Repository,
+		Source: "buildshiprun",
+	}
+
+	deployStage := sdk.StartStage(sdk.StageDeploy)
+	deployResult, err := deployFunction(ctx, r.Client, deploy, r.GatewayURL)
+	deployStage = deployStage.Complete()
+	log.Println(deployResult)
+
+	if err != nil {
+		status.AddStageStatus(sdk.StatusFailure, deployStage.Description(sdk.StatusFailure), buildContext, deployStage)
+		status.AddStatus(sdk.StatusFailure, err.Error(), sdk.BuildFunctionContext(event.Service))
+		statusErr := reportStatus(status, event.SCM)
+		if statusErr != nil {
+			log.Printf(statusErr.Error())
+		}
+		auditEvent.Message = fmt.Sprintf("buildshiprun failure: %s", err.Error())
+		sdk.PostAudit(auditEvent)
+		log.Fatalf("buildshiprun failure: %s", err.Error())
+	}
+
+	status.AddStageStatus(sdk.StatusSuccess, deployStage.Description(sdk.StatusSuccess), buildContext, deployStage)
+	status.AddStatus(sdk.StatusPending, fmt.Sprintf("waiting for %s to become ready", deployName), sdk.BuildFunctionContext(event.Service))
+	statusErr := reportStatus(status, event.SCM)
+	if statusErr != nil {
+		log.Printf(statusErr.Error())
+	}
+
+	healthURL := sdk.BuildHealthURL(r.GatewayURL, deployName, deploy.Annotations)
+	readinessStage := sdk.StartStage(sdk.StageReadiness)
+	readyErr := sdk.WaitForReadiness(ctx, r.GatewayURL, deployName, deploy.Image, healthURL, sdk.GetReadinessConfig())
+	readinessStage = readinessStage.Complete()
+	if readyErr != nil {
+		msg := fmt.Sprintf("%s %s", deployName, readyErr.Error())
+
+		status.AddStageStatus(sdk.StatusFailure, readinessStage.Description(sdk.StatusFailure), buildContext, readinessStage)
+		status.AddStatus(sdk.StatusFailure, msg, sdk.BuildFunctionContext(event.Service))
+		statusErr := reportStatus(status, event.SCM)
+		if statusErr != nil {
+			log.Printf(statusErr.Error())
+		}
+
+		auditEvent.Message = fmt.Sprintf("buildshiprun failure: %s", msg)
+		sdk.PostAudit(auditEvent)
+
+		recordDeployment(r.GatewayURL, r.PayloadSecret, event, deploy, deploymentStatusFailure)
+
+		return auditEvent.Message, false
+	}
+
+	status.AddStageStatus(sdk.StatusSuccess, readinessStage.Description(sdk.StatusSuccess), buildContext, readinessStage)
+
+	auditEvent.Message = fmt.Sprintf("buildshiprun succeeded: deployed %s", deploy.Image)
+	sdk.PostAudit(auditEvent)
+
+	deploymentStatus := deploymentStatusSuccess
+
+	// A failed smoke test is reported on the function context in place of
+	// the deploy, along with the rollback when there was one
+	var smokeTestFailure string
+
+	smokeTestContext := sdk.BuildSmokeTestContext(event.Service)
+	smokeTest, smokeTestErr := getSmokeTest(event.Annotations)
+	if smokeTestErr != nil {
+		status.AddStatus(sdk.StatusFailure, smokeTestErr.Error(), smokeTestContext)
+	} else if smokeTest != nil {
+		status.AddStatus(sdk.StatusPending, fmt.Sprintf("running smoke test for %s", deployName), sdk.BuildFunctionContext(event.Service))
+		status.AddStatus(sdk.StatusPending, fmt.Sprintf("running smoke test: %s %s", smokeTest.Method, smokeTest.Path), smokeTestContext)
+		statusErr := reportStatus(status, event.SCM)
+		if statusErr != nil {
+			log.Printf(statusErr.Error())
+		}
+
+		if runErr := smokeTest.Run(r.GatewayURL, deployName); runErr != nil {
+			msg := fmt.Sprintf("smoke test failed: %s", runErr.Error())
+
+			if smokeTest.Rollback && r.Canary {
+				if removeErr := removeCanary(ctx, r.Client, deployName); removeErr != nil {
+					msg = fmt.Sprintf("%s, unable to remove canary: %s", msg, removeErr.Error())
+				} else {
+					msg = fmt.Sprintf("%s, removed canary: %s", msg, deployName)
+					deploymentStatus = deploymentStatusRolledBack
+				}
+			} else if smokeTest.Rollback {
+				rolledBackTo, rollbackErr := rollback(ctx, r.Client, deploy, r.Previous, r.GatewayURL)
+				if rollbackErr != nil {
+					msg = fmt.Sprintf("%s, rollback failed: %s", msg, rollbackErr.Error())
+				} else {
+					msg = fmt.Sprintf("%s, rolled back to: %s", msg, rolledBackTo)
+					deploymentStatus = deploymentStatusRolledBack
+				}
+			}
+
+			if deploymentStatus != deploymentStatusRolledBack {
+				deploymentStatus = deploymentStatusFailure
+			}
+
+			status.AddStatus(sdk.StatusFailure, msg, smokeTestContext)
+			smokeTestFailure = msg
+
+			auditEvent.Message = fmt.Sprintf("buildshiprun %s: %s", deployName, msg)
+			sdk.PostAudit(auditEvent)
+		} else {
+			status.AddStatus(sdk.StatusSuccess, fmt.Sprintf("smoke test passed: %s %s", smokeTest.Method, smokeTest.Path), smokeTestContext)
+		}
+	}
+
+	recordDeployment(r.GatewayURL, r.PayloadSecret, event, deploy, deploymentStatus)
+
+	msg := r.DeployedMessage
+	state := sdk.StatusSuccess
+	if len(smokeTestFailure) > 0 {
+		msg = smokeTestFailure
+		state = sdk.StatusFailure
+	}
+
+	status.AddStatus(state, msg, sdk.BuildFunctionContext(event.Service))
+	statusErr = reportStatus(status, event.SCM)
+	if statusErr != nil {
+		log.Printf(statusErr.Error())
+	}
+	return msg, true
+}
@@ -0,0 +1,201 @@
+package function
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	faasSDK "github.com/openfaas/faas-cli/proxy"
+	"github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+// Annotations used in stack.yml to declare a smoke test for a function
+const (
+	smokeTestPrefix    = sdk.FunctionLabelPrefix + "smoke-test."
+	smokeTestMethod    = smokeTestPrefix + "method"
+	smokeTestPath      = smokeTestPrefix + "path"
+	smokeTestBody      = smokeTestPrefix + "body"
+	smokeTestStatus    = smokeTestPrefix + "status"
+	smokeTestBodyRegex = smokeTestPrefix + "body-regex"
+	smokeTestJSONPath  = smokeTestPrefix + "json-path"
+	smokeTestJSONValue = smokeTestPrefix + "json-value"
+	smokeTestRollback  = smokeTestPrefix + "rollback"
+)
+
+const smokeTestTimeout = time.Second * 30
+
+// SmokeTest describes a request made to a function after it has been
+// deployed along with the assertions made on the response
+type SmokeTest struct {
+	Method    string
+	Path      string
+	Body      string
+	Status    int
+	BodyRegex string
+	JSONPath  string
+	JSONValue string
+	Rollback  bool
+}
+
+// getSmokeTest reads a smoke test from the annotations of a function, the
+// returned value is nil when no smoke test was declared.
+func getSmokeTest(annotations map[string]string) (*SmokeTest, error) {
+	path, ok := annotations[smokeTestPath]
+	if !ok {
+		return nil, nil
+	}
+
+	test := &SmokeTest{
+		Method:    http.MethodGet,
+		Path:      "/" + strings.TrimPrefix(path, "/"),
+		Body:      annotations[smokeTestBody],
+		Status:    http.StatusOK,
+		BodyRegex: annotations[smokeTestBodyRegex],
+		JSONPath:  annotations[smokeTestJSONPath],
+		JSONValue: annotations[smokeTestJSONValue],
+	}
+
+	if val := annotations[smokeTestMethod]; len(val) > 0 {
+		test.Method = strings.ToUpper(val)
+	}
+
+	if val := annotations[smokeTestStatus]; len(val) > 0 {
+		code, err := strconv.Atoi(val)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", smokeTestStatus, val)
+		}
+		test.Status = code
+	}
+
+	if len(test.BodyRegex) > 0 {
+		if _, err := regexp.Compile(test.BodyRegex); err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", smokeTestBodyRegex, err.Error())
+		}
+	}
+
+	if val := annotations[smokeTestRollback]; len(val) > 0 {
+		test.Rollback, _ = strconv.ParseBool(val)
+	}
+
+	return test, nil
+}
+
+// Run invokes the function through the gateway and checks the response
+// against the assertions of the smoke test
+func (s *SmokeTest) Run(gatewayURL, functionName string) error {
+	if !strings.HasSuffix(gatewayURL, "/") {
+		gatewayURL = gatewayURL + "/"
+	}
+
+	c := http.Client{Timeout: smokeTestTimeout}
+
+	invokeURL := gatewayURL + "function/" + functionName + s.Path
+	req, err := http.NewRequest(s.Method, invokeURL, strings.NewReader(s.Body))
+	if err != nil {
+		return fmt.Errorf("unable to create request: %s", err.Error())
+	}
+
+	res, err := c.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to invoke %s %s: %s", s.Method, s.Path, err.Error())
+	}
+
+	var body []byte
+	if res.Body != nil {
+		defer res.Body.Close()
+		body, _ = ioutil.ReadAll(res.Body)
+	}
+
+	return s.check(res.StatusCode, body)
+}
+
+func (s *SmokeTest) check(statusCode int, body []byte) error {
+	if statusCode != s.Status {
+		return fmt.Errorf("%s %s returned status: %d, want: %d", s.Method, s.Path, statusCode, s.Status)
+	}
+
+	if len(s.BodyRegex) > 0 {
+		if !regexp.MustCompile(s.BodyRegex).Match(body) {
+			return fmt.Errorf("%s %s body did not match: %s", s.Method, s.Path, s.BodyRegex)
+		}
+	}
+
+	if len(s.JSONPath) > 0 {
+		var doc interface{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return fmt.Errorf("%s %s body is not valid JSON: %s", s.Method, s.Path, err.Error())
+		}
+
+		value, found := lookupJSONPath(doc, s.JSONPath)
+		if !found {
+			return fmt.Errorf("%s %s body has no value at: %s", s.Method, s.Path, s.JSONPath)
+		}
+
+		if len(s.JSONValue) > 0 && fmt.Sprintf("%v", value) != s.JSONValue {
+			return fmt.Errorf("%s %s body has %v at: %s, want: %s", s.Method, s.Path, value, s.JSONPath, s.JSONValue)
+		}
+	}
+
+	return nil
+}
+
+// lookupJSONPath walks a dot-separated path such as "items.0.name" through
+// a decoded JSON document.
+func lookupJSONPath(doc interface{}, path string) (interface{}, bool) {
+	current := doc
+	for _, key := range strings.Split(strings.TrimPrefix(path, "$."), ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			val, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			current = val
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+// rollback redeploys the image which was running before the latest deployment
+// using the deployment spec of the latest deployment.
+func rollback(ctx context.Context, client *faasSDK.Client, deploySpec *faasSDK.DeployFunctionSpec, previous *types.FunctionStatus, gatewayURL string) (string, error) {
+	if previous == nil || len(previous.Image) == 0 {
+		return "", fmt.Errorf("no previous image to roll back to")
+	}
+
+	spec := *deploySpec
+	spec.Image = previous.Image
+	spec.Labels = map[string]string{}
+	for k, v := range deploySpec.Labels {
+		spec.Labels[k] = v
+	}
+
+	if previous.Labels != nil {
+		if sha, ok := (*previous.Labels)[sdk.FunctionLabelPrefix+"git-sha"]; ok {
+			spec.Labels[sdk.FunctionLabelPrefix+"git-sha"] = sha
+		}
+	}
+
+	_, err := deployFunction(ctx, client, &spec, gatewayURL)
+	if err != nil {
+		return "", err
+	}
+
+	return previous.Image, nil
+}
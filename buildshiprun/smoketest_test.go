@@ -0,0 +1,167 @@
+package function
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_getSmokeTest_NotDeclared(t *testing.T) {
+	test, err := getSmokeTest(map[string]string{"topic": "cron-function"})
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err.Error())
+	}
+	if test != nil {
+		t.Errorf("want nil smoke test, got: %v", test)
+	}
+}
+
+func Test_getSmokeTest_Defaults(t *testing.T) {
+	test, err := getSmokeTest(map[string]string{smokeTestPath: "healthz"})
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err.Error())
+	}
+
+	if test.Method != http.MethodGet {
+		t.Errorf("want method: %s, got: %s", http.MethodGet, test.Method)
+	}
+	if test.Path != "/healthz" {
+		t.Errorf("want path: %s, got: %s", "/healthz", test.Path)
+	}
+	if test.Status != http.StatusOK {
+		t.Errorf("want status: %d, got: %d", http.StatusOK, test.Status)
+	}
+	if test.Rollback {
+		t.Errorf("want rollback to be disabled by default")
+	}
+}
+
+func Test_getSmokeTest_InvalidStatus(t *testing.T) {
+	_, err := getSmokeTest(map[string]string{
+		smokeTestPath:   "/",
+		smokeTestStatus: "ok",
+	})
+	if err == nil {
+		t.Fatalf("want error for invalid status")
+	}
+}
+
+func Test_getSmokeTest_InvalidRegex(t *testing.T) {
+	_, err := getSmokeTest(map[string]string{
+		smokeTestPath:      "/",
+		smokeTestBodyRegex: "([a-z",
+	})
+	if err == nil {
+		t.Fatalf("want error for invalid regex")
+	}
+}
+
+func Test_SmokeTest_Run(t *testing.T) {
+	var gotMethod, gotPath, gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod = r.Method
+		gotPath = r.URL.Path
+		body, _ := ioutil.ReadAll(r.Body)
+		gotBody = string(body)
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"status": "ok", "items": [{"name": "first"}]}`))
+	}))
+	defer srv.Close()
+
+	test, _ := getSmokeTest(map[string]string{
+		smokeTestMethod:    "post",
+		smokeTestPath:      "/items",
+		smokeTestBody:      `{"name": "first"}`,
+		smokeTestStatus:    "201",
+		smokeTestBodyRegex: `"status":\s*"ok"`,
+		smokeTestJSONPath:  "items.0.name",
+		smokeTestJSONValue: "first",
+	})
+
+	err := test.Run(srv.URL, "alexellis-fn1")
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err.Error())
+	}
+
+	if gotMethod != http.MethodPost {
+		t.Errorf("want method: %s, got: %s", http.MethodPost, gotMethod)
+	}
+	if gotPath != "/function/alexellis-fn1/items" {
+		t.Errorf("want path: %s, got: %s", "/function/alexellis-fn1/items", gotPath)
+	}
+	if gotBody != `{"name": "first"}` {
+		t.Errorf("want body: %s, got: %s", `{"name": "first"}`, gotBody)
+	}
+}
+
+func Test_SmokeTest_check(t *testing.T) {
+	cases := []struct {
+		name      string
+		test      SmokeTest
+		status    int
+		body      string
+		wantError string
+	}{
+		{
+			name:   "status matches",
+			test:   SmokeTest{Method: http.MethodGet, Path: "/", Status: http.StatusOK},
+			status: http.StatusOK,
+		},
+		{
+			name:      "status differs",
+			test:      SmokeTest{Method: http.MethodGet, Path: "/", Status: http.StatusOK},
+			status:    http.StatusInternalServerError,
+			wantError: "returned status: 500, want: 200",
+		},
+		{
+			name:      "body regex does not match",
+			test:      SmokeTest{Method: http.MethodGet, Path: "/", Status: http.StatusOK, BodyRegex: "^pong$"},
+			status:    http.StatusOK,
+			body:      "ping",
+			wantError: "body did not match",
+		},
+		{
+			name:      "json path missing",
+			test:      SmokeTest{Method: http.MethodGet, Path: "/", Status: http.StatusOK, JSONPath: "data.id"},
+			status:    http.StatusOK,
+			body:      `{"data": {}}`,
+			wantError: "no value at: data.id",
+		},
+		{
+			name:      "json value differs",
+			test:      SmokeTest{Method: http.MethodGet, Path: "/", Status: http.StatusOK, JSONPath: "$.count", JSONValue: "2"},
+			status:    http.StatusOK,
+			body:      `{"count": 1}`,
+			wantError: "has 1 at: $.count, want: 2",
+		},
+		{
+			name:      "body is not JSON",
+			test:      SmokeTest{Method: http.MethodGet, Path: "/", Status: http.StatusOK, JSONPath: "count"},
+			status:    http.StatusOK,
+			body:      "count=1",
+			wantError: "not valid JSON",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.test.check(c.status, []byte(c.body))
+			if len(c.wantError) == 0 {
+				if err != nil {
+					t.Errorf("want no error, got: %s", err.Error())
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("want error containing %q, got nil", c.wantError)
+			}
+			if !strings.Contains(err.Error(), c.wantError) {
+				t.Errorf("want error containing %q, got: %q", c.wantError, err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package function
+
+import (
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+// addBuildStages adds a status for the build and push of the image using
+// the timings from of-builder, or the time taken by the request to
+// of-builder when it did not report any. When the build failed the
+// last stage to have started is marked as the one which failed.
+func addBuildStages(status *sdk.Status, context string, stages []sdk.StageTiming, requested sdk.StageTiming, succeeded bool) {
+	if len(stages) == 0 {
+		requested.Stage = sdk.StageBuild
+		stages = []sdk.StageTiming{requested}
+	}
+
+	for i, stage := range stages {
+		state := sdk.StatusSuccess
+		if !succeeded && i == len(stages)-1 {
+			state = sdk.StatusFailure
+		}
+
+		status.AddStageStatus(state, stage.Description(state), context, stage)
+	}
+}
@@ -0,0 +1,46 @@
+package function
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+func Test_addBuildStages_FailedPush(t *testing.T) {
+	status := sdk.BuildStatus(&sdk.Event{}, sdk.EmptyAuthToken)
+
+	started := time.Unix(1586952000, 0)
+	stages := []sdk.StageTiming{
+		{Stage: sdk.StageBuild, Started: started, Completed: started.Add(20 * time.Second)},
+		{Stage: sdk.StagePush, Started: started.Add(20 * time.Second), Completed: started.Add(25 * time.Second)},
+	}
+
+	addBuildStages(status, "fn1", stages, sdk.StageTiming{}, false)
+
+	if got := status.CommitStatuses["fn1/build"]; got.Status != sdk.StatusSuccess || got.Description != "build took 20s" {
+		t.Errorf("want build to succeed in 20s, got: %s %q", got.Status, got.Description)
+	}
+
+	if got := status.CommitStatuses["fn1/push"]; got.Status != sdk.StatusFailure || got.Description != "push failed after 5s" {
+		t.Errorf("want push to fail after 5s, got: %s %q", got.Status, got.Description)
+	}
+}
+
+func Test_addBuildStages_UsesRequestTiming(t *testing.T) {
+	status := sdk.BuildStatus(&sdk.Event{}, sdk.EmptyAuthToken)
+
+	started := time.Unix(1586952000, 0)
+	requested := sdk.StageTiming{Started: started, Completed: started.Add(time.Minute)}
+
+	addBuildStages(status, "fn1", nil, requested, true)
+
+	if len(status.CommitStatuses) != 1 {
+		t.Fatalf("want a single status, got: %v", status.CommitStatuses)
+	}
+
+	got := status.CommitStatuses["fn1/build"]
+	if got.Status != sdk.StatusSuccess || got.Stage != sdk.StageBuild || got.Description != "build took 1m0s" {
+		t.Errorf("want build to succeed in 1m0s, got: %s %s %q", got.Status, got.Stage, got.Description)
+	}
+}
@@ -0,0 +1,34 @@
+package function
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+// getSupersedingSHA returns the newer commit pushed since the function was
+// built, the function is still deployed when the of-builder can't be asked
+func getSupersedingSHA(builderURL string, event *sdk.Event) string {
+	ref := sdk.NewBuildRef(event.Owner, event.Repository, event.SHA)
+
+	newerSHA, err := sdk.GetSupersedingSHA(builderURL, ref)
+	if err != nil {
+		log.Printf("unable to get the latest build of %s/%s: %s", ref.Owner, ref.Repo, err.Error())
+		return ""
+	}
+	return newerSHA
+}
+
+// supersededMessage describes why a function was not deployed, newerSHA is
+// empty when the of-builder cancelled the build
+func supersededMessage(serviceValue, newerSHA string) string {
+	if len(newerSHA) == 0 {
+		return fmt.Sprintf("not deploying %s, superseded by a newer commit", serviceValue)
+	}
+
+	if len(newerSHA) > 7 {
+		newerSHA = newerSHA[:7]
+	}
+	return fmt.Sprintf("not deploying %s, superseded by %s", serviceValue, newerSHA)
+}
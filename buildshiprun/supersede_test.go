@@ -0,0 +1,37 @@
+package function
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+func Test_getSupersedingSHA(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"owner": "alexellis", "repo": "repo1", "sha": "4c7b2f1e9a"}`))
+	}))
+	defer s.Close()
+
+	event := &sdk.Event{Owner: "alexellis", Repository: "repo1", SHA: "4c7b2f1e9a"}
+	if got := getSupersedingSHA(s.URL+"/", event); got != "" {
+		t.Errorf("the newest commit should not be superseded, got: %s", got)
+	}
+
+	event.SHA = "d1e2f3a4b5"
+	if got := getSupersedingSHA(s.URL+"/", event); got != "4c7b2f1e9a" {
+		t.Errorf("want: %s, got: %s", "4c7b2f1e9a", got)
+	}
+
+	if got := supersededMessage("alexellis-fn1", "4c7b2f1e9a"); got != "not deploying alexellis-fn1, superseded by 4c7b2f1" {
+		t.Errorf("unexpected message: %s", got)
+	}
+}
+
+func Test_getSupersedingSHA_BuilderUnavailable(t *testing.T) {
+	event := &sdk.Event{Owner: "alexellis", Repository: "repo1", SHA: "d1e2f3a4b5"}
+	if got := getSupersedingSHA("http://127.0.0.1:1/", event); got != "" {
+		t.Errorf("the function should be deployed when the builder is unavailable, got: %s", got)
+	}
+}
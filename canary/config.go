@@ -0,0 +1,130 @@
+package function
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+const (
+	defaultCanarySteps        = "10,25,50"
+	defaultCanaryInterval     = time.Minute * 5
+	defaultCanaryMaxErrorRate = 5.0
+)
+
+// CanaryConfig is read from the annotations of a canary function
+type CanaryConfig struct {
+	// Steps are the percentages of traffic sent to the canary, each
+	// step lasts for Interval
+	Steps    []int
+	Interval time.Duration
+
+	// MaxErrorRate is the percentage of failed invocations at which
+	// the canary is rolled back
+	MaxErrorRate float64
+
+	// MaxLatency is the average duration of an invocation at which
+	// the canary is rolled back, zero disables the check
+	MaxLatency time.Duration
+}
+
+func getCanaryConfig(annotations map[string]string) CanaryConfig {
+	cfg := CanaryConfig{
+		Steps:        parseSteps(defaultCanarySteps),
+		Interval:     defaultCanaryInterval,
+		MaxErrorRate: defaultCanaryMaxErrorRate,
+	}
+
+	if val, ok := annotations[sdk.CanaryStepsAnnotation]; ok && len(val) > 0 {
+		if steps := parseSteps(val); len(steps) > 0 {
+			cfg.Steps = steps
+		} else {
+			log.Printf("invalid %s: %s, using: %s", sdk.CanaryStepsAnnotation, val, defaultCanarySteps)
+		}
+	}
+
+	if val, ok := annotations[sdk.CanaryIntervalAnnotation]; ok && len(val) > 0 {
+		if interval, err := time.ParseDuration(val); err == nil && interval > 0 {
+			cfg.Interval = interval
+		} else {
+			log.Printf("invalid %s: %s, using: %s", sdk.CanaryIntervalAnnotation, val, defaultCanaryInterval)
+		}
+	}
+
+	if val, ok := annotations[sdk.CanaryMaxErrorRateAnnotation]; ok && len(val) > 0 {
+		if rate, err := strconv.ParseFloat(strings.TrimSuffix(val, "%"), 64); err == nil && rate >= 0 {
+			cfg.MaxErrorRate = rate
+		} else {
+			log.Printf("invalid %s: %s, using: %.1f", sdk.CanaryMaxErrorRateAnnotation, val, defaultCanaryMaxErrorRate)
+		}
+	}
+
+	if val, ok := annotations[sdk.CanaryMaxLatencyAnnotation]; ok && len(val) > 0 {
+		if latency, err := time.ParseDuration(val); err == nil {
+			cfg.MaxLatency = latency
+		} else {
+			log.Printf("invalid %s: %s, latency will not be checked", sdk.CanaryMaxLatencyAnnotation, val)
+		}
+	}
+
+	return cfg
+}
+
+// parseSteps reads a list of percentages such as "10,25,50", values outside
+// of 1-99 or which do not increase are rejected
+func parseSteps(val string) []int {
+	steps := []int{}
+	last := 0
+	for _, part := range strings.Split(val, ",") {
+		step, err := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(part), "%")))
+		if err != nil || step <= last || step >= 100 {
+			return nil
+		}
+		steps = append(steps, step)
+		last = step
+	}
+	return steps
+}
+
+// Weight gives the percentage of traffic to send to a canary deployed at
+// deployTime, 100 means that the canary is due to be promoted
+func (c CanaryConfig) Weight(deployTime, now time.Time) int {
+	if len(c.Steps) == 0 {
+		return 100
+	}
+
+	step := 0
+	if elapsed := now.Sub(deployTime); elapsed > 0 {
+		step = int(elapsed / c.Interval)
+	}
+
+	if step >= len(c.Steps) {
+		return 100
+	}
+	return c.Steps[step]
+}
+
+// Check returns the reason for rolling back a canary, or an empty
+// string when its metrics are within the limits
+func (c CanaryConfig) Check(metrics *Metrics) string {
+	total := metrics.Success + metrics.Failure
+	if total > 0 {
+		errorRate := float64(metrics.Failure) * 100 / float64(total)
+		if errorRate > c.MaxErrorRate {
+			return fmt.Sprintf("error rate %.1f%% exceeds %.1f%%", errorRate, c.MaxErrorRate)
+		}
+	}
+
+	if c.MaxLatency > 0 {
+		latency := time.Duration(metrics.Latency * float64(time.Second))
+		if latency > c.MaxLatency {
+			return fmt.Sprintf("latency %s exceeds %s", latency.Round(time.Millisecond), c.MaxLatency)
+		}
+	}
+
+	return ""
+}
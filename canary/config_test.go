@@ -0,0 +1,106 @@
+package function
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+func Test_getCanaryConfig_Defaults(t *testing.T) {
+	cfg := getCanaryConfig(map[string]string{})
+
+	if len(cfg.Steps) != 3 || cfg.Steps[0] != 10 {
+		t.Errorf("want steps: %s, got: %v", defaultCanarySteps, cfg.Steps)
+	}
+	if cfg.Interval != defaultCanaryInterval {
+		t.Errorf("want interval: %s, got: %s", defaultCanaryInterval, cfg.Interval)
+	}
+	if cfg.MaxErrorRate != defaultCanaryMaxErrorRate {
+		t.Errorf("want max error rate: %f, got: %f", defaultCanaryMaxErrorRate, cfg.MaxErrorRate)
+	}
+	if cfg.MaxLatency != 0 {
+		t.Errorf("want latency check to be disabled, got: %s", cfg.MaxLatency)
+	}
+}
+
+func Test_getCanaryConfig_Overrides(t *testing.T) {
+	cfg := getCanaryConfig(map[string]string{
+		sdk.CanaryStepsAnnotation:        "5%, 50%",
+		sdk.CanaryIntervalAnnotation:     "1m",
+		sdk.CanaryMaxErrorRateAnnotation: "1%",
+		sdk.CanaryMaxLatencyAnnotation:   "500ms",
+	})
+
+	if len(cfg.Steps) != 2 || cfg.Steps[0] != 5 || cfg.Steps[1] != 50 {
+		t.Errorf("want steps: [5 50], got: %v", cfg.Steps)
+	}
+	if cfg.Interval != time.Minute {
+		t.Errorf("want interval: %s, got: %s", time.Minute, cfg.Interval)
+	}
+	if cfg.MaxErrorRate != 1 {
+		t.Errorf("want max error rate: %f, got: %f", 1.0, cfg.MaxErrorRate)
+	}
+	if cfg.MaxLatency != time.Millisecond*500 {
+		t.Errorf("want max latency: %s, got: %s", time.Millisecond*500, cfg.MaxLatency)
+	}
+}
+
+func Test_parseSteps_Invalid(t *testing.T) {
+	for _, val := range []string{"50,25", "10,100", "ten", "0,10"} {
+		if steps := parseSteps(val); steps != nil {
+			t.Errorf("want no steps for %q, got: %v", val, steps)
+		}
+	}
+}
+
+func Test_CanaryConfig_Weight(t *testing.T) {
+	cfg := CanaryConfig{Steps: []int{10, 25, 50}, Interval: time.Minute}
+	deployTime := time.Unix(1586952000, 0)
+
+	cases := []struct {
+		elapsed time.Duration
+		want    int
+	}{
+		{elapsed: 0, want: 10},
+		{elapsed: time.Second * 59, want: 10},
+		{elapsed: time.Minute, want: 25},
+		{elapsed: time.Minute * 2, want: 50},
+		{elapsed: time.Minute * 3, want: 100},
+	}
+
+	for _, c := range cases {
+		got := cfg.Weight(deployTime, deployTime.Add(c.elapsed))
+		if got != c.want {
+			t.Errorf("after %s want weight: %d, got: %d", c.elapsed, c.want, got)
+		}
+	}
+}
+
+func Test_CanaryConfig_Check(t *testing.T) {
+	cfg := CanaryConfig{MaxErrorRate: 5, MaxLatency: time.Millisecond * 500}
+
+	cases := []struct {
+		name    string
+		metrics Metrics
+		want    string
+	}{
+		{name: "no invocations", metrics: Metrics{}, want: ""},
+		{name: "within limits", metrics: Metrics{Success: 99, Failure: 1, Latency: 0.1}, want: ""},
+		{name: "error rate exceeded", metrics: Metrics{Success: 90, Failure: 10}, want: "error rate 10.0% exceeds 5.0%"},
+		{name: "latency exceeded", metrics: Metrics{Success: 10, Latency: 0.75}, want: "latency 750ms exceeds 500ms"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := cfg.Check(&c.metrics)
+			if len(c.want) == 0 && len(got) > 0 {
+				t.Errorf("want no reason, got: %s", got)
+			}
+			if !strings.Contains(got, c.want) {
+				t.Errorf("want reason: %s, got: %s", c.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,311 @@
+package function
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	faasSDK "github.com/openfaas/faas-cli/proxy"
+	"github.com/openfaas/faas-cli/stack"
+	"github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+const (
+	Source    = "canary"
+	namespace = ""
+)
+
+var timeout = 3 * time.Second
+
+//FaaSAuth Authentication type for OpenFaaS
+type FaaSAuth struct {
+}
+
+//Set add basic authentication to the request
+func (auth *FaaSAuth) Set(req *http.Request) error {
+	return sdk.AddBasicAuth(req)
+}
+
+// Metrics as returned by the metrics function
+type Metrics struct {
+	Success int     `json:"success"`
+	Failure int     `json:"failure"`
+	Latency float64 `json:"latency"`
+}
+
+// Canary is a function deployed alongside the function which it will
+// replace when promoted
+type Canary struct {
+	Name       string
+	Primary    string
+	Image      string
+	Owner      string
+	Repo       string
+	Function   string
+	DeployTime time.Time
+	Config     CanaryConfig
+}
+
+// Handle returns the percentage of traffic to send to each canary for a
+// GET, any other request progresses the canaries by promoting them or
+// rolling them back based upon their metrics. The edge-router signs those
+// requests with the payload-secret.
+func Handle(req []byte) string {
+	if os.Getenv("Http_Method") != http.MethodGet {
+		hmacErr := sdk.ValidHMAC(&req, "payload-secret", os.Getenv("Http_X_Cloud_Signature"))
+		if hmacErr != nil {
+			log.Printf("hmac error %s\n", hmacErr.Error())
+			os.Exit(1)
+		}
+	}
+
+	gatewayURL := os.Getenv("gateway_url")
+
+	client := faasSDK.NewClient(&FaaSAuth{}, gatewayURL, nil, &timeout)
+	ctx := context.Background()
+
+	canaries, err := listCanaries(ctx, client)
+	if err != nil {
+		return fmt.Sprintf("unable to list canaries: %s", err.Error())
+	}
+
+	now := time.Now()
+
+	if os.Getenv("Http_Method") == http.MethodGet {
+		weights := map[string]int{}
+		for _, canary := range canaries {
+			weights[canary.Primary] = canary.Config.Weight(canary.DeployTime, now)
+		}
+
+		bytesOut, _ := json.Marshal(weights)
+		return string(bytesOut)
+	}
+
+	results := []string{}
+	for _, canary := range canaries {
+		result, err := progress(ctx, client, gatewayURL, canary, now)
+		if err != nil {
+			log.Printf("canary %s: %s", canary.Name, err.Error())
+			result = err.Error()
+		}
+
+		results = append(results, fmt.Sprintf("%s: %s", canary.Name, result))
+	}
+
+	bytesOut, _ := json.Marshal(results)
+	return string(bytesOut)
+}
+
+func listCanaries(ctx context.Context, client *faasSDK.Client) ([]Canary, error) {
+	functions, err := client.ListFunctions(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	canaries := []Canary{}
+	for _, fn := range functions {
+		if canary, ok := toCanary(fn); ok {
+			canaries = append(canaries, canary)
+		}
+	}
+
+	return canaries, nil
+}
+
+func toCanary(fn types.FunctionStatus) (Canary, bool) {
+	if fn.Labels == nil {
+		return Canary{}, false
+	}
+
+	labels := *fn.Labels
+	primary, ok := labels[sdk.CanaryOfLabel]
+	if !ok || len(primary) == 0 {
+		return Canary{}, false
+	}
+
+	annotations := map[string]string{}
+	if fn.Annotations != nil {
+		annotations = *fn.Annotations
+	}
+
+	owner := labels[sdk.FunctionLabelPrefix+"git-owner"]
+
+	canary := Canary{
+		Name:     fn.Name,
+		Primary:  primary,
+		Image:    fn.Image,
+		Owner:    owner,
+		Repo:     labels[sdk.FunctionLabelPrefix+"git-repo"],
+		Function: strings.TrimPrefix(primary, strings.ToLower(owner)+"-"),
+		Config:   getCanaryConfig(annotations),
+	}
+
+	if deployTime, err := strconv.ParseInt(labels[sdk.FunctionLabelPrefix+"git-deploytime"], 10, 64); err == nil {
+		canary.DeployTime = time.Unix(deployTime, 0)
+	}
+
+	return canary, true
+}
+
+// progress promotes or rolls back a canary once enough time has passed
+// or as soon as its metrics exceed the limits set for it.
+func progress(ctx context.Context, client *faasSDK.Client, gatewayURL string, canary Canary, now time.Time) (string, error) {
+	metrics, err := getMetrics(gatewayURL, canary.Name, canary.Config.Interval)
+	if err != nil {
+		return "", err
+	}
+
+	if reason := canary.Config.Check(metrics); len(reason) > 0 {
+		if err := client.DeleteFunction(ctx, canary.Name, namespace); err != nil {
+			return "", fmt.Errorf("unable to roll back: %s", err.Error())
+		}
+
+		msg := fmt.Sprintf("Rolled back canary %s (%s): %s", canary.Name, canary.Image, reason)
+		postAudit(canary, msg)
+		return msg, nil
+	}
+
+	weight := canary.Config.Weight(canary.DeployTime, now)
+	if weight < 100 {
+		return fmt.Sprintf("serving %d%% of traffic", weight), nil
+	}
+
+	if err := promote(ctx, client, gatewayURL, canary); err != nil {
+		return "", fmt.Errorf("unable to promote: %s", err.Error())
+	}
+
+	msg := fmt.Sprintf("Promoted canary %s (%s) to %s", canary.Name, canary.Image, canary.Primary)
+	postAudit(canary, msg)
+	return msg, nil
+}
+
+// promote redeploys the function with the spec recorded for the canary in the
+// deployment history, then removes the canary.
+func promote(ctx context.Context, client *faasSDK.Client, gatewayURL string, canary Canary) error {
+	history, err := sdk.GetDeploymentHistory(gatewayURL, canary.Owner, canary.Function)
+	if err != nil {
+		return err
+	}
+
+	record := findRecord(history, canary.Image)
+	if record == nil {
+		return fmt.Errorf("no deployment recorded for image: %s", canary.Image)
+	}
+
+	deploySpec := buildPromoteSpec(record, canary.Primary)
+
+	resStatus := client.DeployFunction(ctx, deploySpec)
+	if resStatus < 200 || resStatus > 299 {
+		return fmt.Errorf("http status code %d", resStatus)
+	}
+
+	if payloadSecret, keyErr := sdk.ReadSecret("payload-secret"); keyErr == nil {
+		promoted := *record
+		promoted.Action = sdk.DeploymentActionPromote
+		promoted.Time = time.Now().UTC()
+		promoted.Labels = deploySpec.Labels
+
+		if recordErr := sdk.PostDeploymentRecord(gatewayURL, payloadSecret, promoted); recordErr != nil {
+			log.Printf("deployment-history: error: %s", recordErr.Error())
+		}
+	}
+
+	return client.DeleteFunction(ctx, canary.Name, namespace)
+}
+
+func findRecord(history []sdk.DeploymentRecord, image string) *sdk.DeploymentRecord {
+	for i, record := range history {
+		if record.Image == image && record.Action == sdk.DeploymentActionCanary {
+			return &history[i]
+		}
+	}
+	return nil
+}
+
+func buildPromoteSpec(record *sdk.DeploymentRecord, primary string) *faasSDK.DeployFunctionSpec {
+	labels := map[string]string{}
+	for k, v := range record.Labels {
+		labels[k] = v
+	}
+	delete(labels, sdk.CanaryOfLabel)
+	labels["faas_function"] = primary
+	labels["app"] = primary
+	labels[sdk.FunctionLabelPrefix+"git-deploytime"] = strconv.FormatInt(time.Now().Unix(), 10)
+
+	deploySpec := &faasSDK.DeployFunctionSpec{
+		FunctionName: primary,
+		Image:        record.Image,
+		Network:      "func_functions",
+		Update:       true,
+		EnvVars:      record.Environment,
+		Secrets:      record.Secrets,
+		Labels:       labels,
+		Annotations:  record.Annotations,
+		FunctionResourceRequest: faasSDK.FunctionResourceRequest{
+			Limits:   &stack.FunctionResources{},
+			Requests: &stack.FunctionResources{},
+		},
+	}
+
+	if record.Limits != nil {
+		deploySpec.FunctionResourceRequest.Limits.Memory = record.Limits.Memory
+		deploySpec.FunctionResourceRequest.Limits.CPU = record.Limits.CPU
+	}
+
+	if record.Requests != nil {
+		deploySpec.FunctionResourceRequest.Requests.Memory = record.Requests.Memory
+		deploySpec.FunctionResourceRequest.Requests.CPU = record.Requests.CPU
+	}
+
+	return deploySpec
+}
+
+// getMetrics fetches the invocation metrics of a function over the given
+// window from the metrics function
+func getMetrics(gatewayURL, functionName string, window time.Duration) (*Metrics, error) {
+	query := url.Values{}
+	query.Set("function", functionName)
+	query.Set("metrics_window", fmt.Sprintf("%ds", int(window.Seconds())))
+
+	req, _ := http.NewRequest(http.MethodGet, gatewayURL+"function/metrics?"+query.Encode(), nil)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Body != nil {
+		defer res.Body.Close()
+	}
+
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status from metrics: %d, body: %s", res.StatusCode, string(body))
+	}
+
+	metrics := Metrics{}
+	if err := json.Unmarshal(body, &metrics); err != nil {
+		return nil, fmt.Errorf("unable to parse metrics: %s", err.Error())
+	}
+
+	return &metrics, nil
+}
+
+func postAudit(canary Canary, msg string) {
+	auditEvent := sdk.AuditEvent{
+		Message: msg,
+		Owner:   canary.Owner,
+		Repo:    canary.Repo,
+		Source:  Source,
+	}
+	sdk.PostAudit(auditEvent)
+}
@@ -0,0 +1,80 @@
+package function
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openfaas/faas-provider/types"
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+func Test_toCanary(t *testing.T) {
+	labels := map[string]string{
+		sdk.CanaryOfLabel:                          "alexellis-fn1",
+		sdk.FunctionLabelPrefix + "git-owner":      "AlexEllis",
+		sdk.FunctionLabelPrefix + "git-repo":       "super-pancake",
+		sdk.FunctionLabelPrefix + "git-deploytime": "1586952000",
+	}
+
+	canary, ok := toCanary(types.FunctionStatus{
+		Name:   "alexellis-fn1-canary",
+		Image:  "alexellis/fn1:latest-bbb",
+		Labels: &labels,
+	})
+
+	if !ok {
+		t.Fatalf("want function to be a canary")
+	}
+	if canary.Function != "fn1" {
+		t.Errorf("want function: %s, got: %s", "fn1", canary.Function)
+	}
+	if !canary.DeployTime.Equal(time.Unix(1586952000, 0)) {
+		t.Errorf("want deploy time: %s, got: %s", time.Unix(1586952000, 0), canary.DeployTime)
+	}
+}
+
+func Test_toCanary_NotCanary(t *testing.T) {
+	labels := map[string]string{
+		sdk.FunctionLabelPrefix + "git-owner": "alexellis",
+	}
+
+	_, ok := toCanary(types.FunctionStatus{Name: "alexellis-fn1", Labels: &labels})
+	if ok {
+		t.Errorf("want function not to be a canary")
+	}
+}
+
+func Test_buildPromoteSpec(t *testing.T) {
+	history := []sdk.DeploymentRecord{
+		{Image: "alexellis/fn1:latest-ccc", Action: sdk.DeploymentActionDeploy},
+		{
+			Image:  "alexellis/fn1:latest-bbb",
+			Action: sdk.DeploymentActionCanary,
+			Labels: map[string]string{
+				"faas_function":   "alexellis-fn1-canary",
+				sdk.CanaryOfLabel: "alexellis-fn1",
+			},
+			Secrets: []string{"alexellis-token"},
+		},
+	}
+
+	record := findRecord(history, "alexellis/fn1:latest-bbb")
+	if record == nil {
+		t.Fatalf("want record for canary image")
+	}
+
+	spec := buildPromoteSpec(record, "alexellis-fn1")
+
+	if spec.FunctionName != "alexellis-fn1" {
+		t.Errorf("want name: %s, got: %s", "alexellis-fn1", spec.FunctionName)
+	}
+	if spec.Labels["faas_function"] != "alexellis-fn1" {
+		t.Errorf("want faas_function label: %s, got: %s", "alexellis-fn1", spec.Labels["faas_function"])
+	}
+	if _, ok := spec.Labels[sdk.CanaryOfLabel]; ok {
+		t.Errorf("want %s label to be removed", sdk.CanaryOfLabel)
+	}
+	if len(spec.Secrets) != 1 {
+		t.Errorf("want 1 secret, got: %d", len(spec.Secrets))
+	}
+}
@@ -198,6 +198,35 @@ class FunctionsApi {
             throw Error("Failed to fetch function logs - is the function scaled to 0? \nmessage:" + fail.message)
         });
   }
+
+  fetchPendingDeploys(user) {
+    const url = `${this.apiBaseUrl}/deployment-gate?user=${user}`;
+    return axios.get(url).then(res => res.data || []);
+  }
+
+  reviewDeploy(action, { owner, repo, functionName, sha }) {
+    const url = `${this.apiBaseUrl}/deployment-gate`;
+    return axios
+      .post(url, {
+        action,
+        owner,
+        repo,
+        function: functionName,
+        sha,
+      })
+      .then(res => res.data);
+  }
+
+  rollbackFunction({ owner, functionName, sha }) {
+    const url = `${this.apiBaseUrl}/rollback`;
+    return axios
+      .post(url, {
+        owner,
+        function: functionName,
+        sha,
+      })
+      .then(res => res.data);
+  }
 }
 
 export const buildPublicFunctionURL = (url, newEnding) => {
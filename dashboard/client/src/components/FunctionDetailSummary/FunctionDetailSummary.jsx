@@ -5,6 +5,7 @@ import { FontAwesomeIcon } from '@fortawesome/react-fontawesome';
 import {
   faAward,
   faCloudDownloadAlt,
+  faUndo,
   faUserSecret
 } from '@fortawesome/free-solid-svg-icons';
 
@@ -37,7 +38,9 @@ const FunctionDetailSummary = ({
   fn,
   functionInvocationData,
   handleShowBadgeModal,
-  handleShowRunOnMyOFModal
+  handleShowRunOnMyOFModal,
+  handleRollback,
+  isRollingBack
 }) => {
   const toBuildLogs = `${fn.shortName}/build-log?repoPath=${fn.gitOwner}/${
     fn.gitRepo
@@ -56,6 +59,16 @@ const FunctionDetailSummary = ({
           <div className="d-flex align-items-start">
             <div>{fn.shortName}</div>
             <div className="ml-auto">
+              <Button
+                outline
+                size="xs"
+                className="mr-2"
+                title="Roll back to the previous deploy"
+                disabled={isRollingBack}
+                onClick={handleRollback}
+              >
+                <FontAwesomeIcon icon={faUndo} spin={isRollingBack} />
+              </Button>
               <Button
                 outline
                 size="xs"
@@ -202,7 +215,9 @@ const FunctionDetailSummary = ({
 
 FunctionDetailSummary.propTypes = {
   fn: PropTypes.object.isRequired,
-  handleShowBadgeModal: PropTypes.func.isRequired
+  handleShowBadgeModal: PropTypes.func.isRequired,
+  handleRollback: PropTypes.func.isRequired,
+  isRollingBack: PropTypes.bool
 };
 
 export { FunctionDetailSummary };
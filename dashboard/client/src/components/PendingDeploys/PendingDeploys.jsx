@@ -0,0 +1,116 @@
+import React, { Component } from 'react';
+import PropTypes from 'prop-types';
+import { Alert, Button, CardBody, CardTitle, Table } from 'reactstrap';
+
+import { functionsApi } from '../../api/functionsApi';
+
+// PendingDeploys lists the deploys held by the deployment-gate for a user
+// and lets approvers approve or reject them
+class PendingDeploys extends Component {
+  constructor(props) {
+    super(props);
+
+    this.state = {
+      deploys: [],
+      message: '',
+    };
+  }
+
+  componentDidMount() {
+    this.fetchDeploys();
+  }
+
+  fetchDeploys() {
+    functionsApi
+      .fetchPendingDeploys(this.props.user)
+      .then(deploys => this.setState({ deploys }))
+      .catch(e => console.error('Error fetching held deploys', e));
+  }
+
+  review(action, deploy) {
+    const { event, functionName } = deploy;
+
+    functionsApi
+      .reviewDeploy(action, {
+        owner: event.owner,
+        repo: event.repository,
+        functionName,
+        sha: event.sha,
+      })
+      .then(message => {
+        this.setState({ message });
+        this.fetchDeploys();
+      })
+      .catch(e => this.setState({ message: `Unable to ${action} ${functionName}: ${e.message}` }));
+  }
+
+  render() {
+    const { deploys, message } = this.state;
+
+    if (deploys.length === 0) {
+      return null;
+    }
+
+    return (
+      <CardBody>
+        <CardTitle tag="h5">Held deploys</CardTitle>
+        { message && <Alert color="info">{ message }</Alert> }
+        <Table responsive hover>
+          <thead>
+            <tr>
+              <th>Function</th>
+              <th>Repository</th>
+              <th>SHA</th>
+              <th>Waiting for</th>
+              <th />
+            </tr>
+          </thead>
+          <tbody>
+            { deploys.map(deploy => (
+              <tr key={`${deploy.event.repository}/${deploy.functionName}`}>
+                <td>{ deploy.functionName }</td>
+                <td>{ deploy.event.owner }/{ deploy.event.repository }</td>
+                <td>{ deploy.event.sha.substring(0, 7) }</td>
+                <td>{ renderWaiting(deploy) }</td>
+                <td className="text-right">
+                  { deploy.requiresApproval && !deploy.approvedBy && (
+                    <span>
+                      <Button size="sm" color="success" className="mr-2" onClick={() => this.review('approve', deploy)}>
+                        Approve
+                      </Button>
+                      <Button size="sm" color="danger" onClick={() => this.review('reject', deploy)}>
+                        Reject
+                      </Button>
+                    </span>
+                  )}
+                </td>
+              </tr>
+            ))}
+          </tbody>
+        </Table>
+      </CardBody>
+    );
+  }
+}
+
+const renderWaiting = ({ requiresApproval, approvedBy, approvers, requiredChecks, checks }) => {
+  const waiting = [];
+
+  if (requiresApproval && !approvedBy) {
+    waiting.push(approvers && approvers.length > 0 ? `approval by ${approvers.join(', ')}` : 'approval');
+  }
+
+  (requiredChecks || [])
+    .filter(name => (checks || {})[name.toLowerCase()] !== 'success')
+    .forEach(name => waiting.push(`check ${name}`));
+
+  return waiting.length > 0 ? waiting.join(', ') : 'freeze window';
+};
+
+PendingDeploys.propTypes = {
+  user: PropTypes.string.isRequired,
+};
+
+export {
+  PendingDeploys,
+};
@@ -1,7 +1,7 @@
 import React, { Component } from 'react';
 import queryString from 'query-string';
 import { FontAwesomeIcon } from '@fortawesome/react-fontawesome';
-import { Card, CardHeader, CardBody } from 'reactstrap';
+import { Alert, Card, CardHeader, CardBody } from 'reactstrap';
 
 import { functionsApi } from '../api/functionsApi';
 import { FunctionDetailSummary } from '../components/FunctionDetailSummary';
@@ -20,6 +20,8 @@ export class FunctionDetailPage extends Component {
     this.handleShowRunOnMyOFModal = this.handleShowRunOnMyOFModal.bind(this);
     this.handleCloseRunOnMyOFModal = this.handleCloseRunOnMyOFModal.bind(this);
 
+    this.handleRollback = this.handleRollback.bind(this);
+
     this.state = {
       isLoading: true,
       fn: null,
@@ -28,7 +30,9 @@ export class FunctionDetailPage extends Component {
       repoPath,
       functionName,
       showBadgeModal: false,
-      showRunOnMyOFModal: false
+      showRunOnMyOFModal: false,
+      isRollingBack: false,
+      rollbackMessage: ''
     };
   }
 
@@ -58,6 +62,31 @@ export class FunctionDetailPage extends Component {
     this.changeFunctionInvocationTimePeriod('60m');
   }
 
+  handleRollback() {
+    const { user, fn } = this.state;
+
+    if (!window.confirm(`Roll back ${fn.shortName} to its previous deploy?`)) {
+      return;
+    }
+
+    this.setState({ isRollingBack: true, rollbackMessage: '' });
+
+    functionsApi
+      .rollbackFunction({ owner: user, functionName: fn.shortName })
+      .then(() =>
+        this.setState({
+          isRollingBack: false,
+          rollbackMessage: `Rolling back ${fn.shortName}, reload the page once it is ready`
+        })
+      )
+      .catch(e =>
+        this.setState({
+          isRollingBack: false,
+          rollbackMessage: `Unable to roll back ${fn.shortName}: ${e.message}`
+        })
+      );
+  }
+
   handleShowBadgeModal() {
     this.setState({ showBadgeModal: true });
   }
@@ -75,7 +104,13 @@ export class FunctionDetailPage extends Component {
   }
 
   render() {
-    const { isLoading, fn, functionInvocationData } = this.state;
+    const {
+      isLoading,
+      fn,
+      functionInvocationData,
+      isRollingBack,
+      rollbackMessage
+    } = this.state;
     let panelBody = (
       <FunctionDetailSummary
         fn={fn}
@@ -85,6 +120,8 @@ export class FunctionDetailPage extends Component {
         functionInvocationData={functionInvocationData}
         handleShowBadgeModal={this.handleShowBadgeModal}
         handleShowRunOnMyOFModal={this.handleShowRunOnMyOFModal}
+        handleRollback={this.handleRollback}
+        isRollingBack={isRollingBack}
       />
     );
 
@@ -101,7 +138,10 @@ export class FunctionDetailPage extends Component {
         <CardHeader className="bg-success color-success d-flex align-items-center justify-content-between">
           <div>Function Overview</div>
         </CardHeader>
-        <CardBody>{panelBody}</CardBody>
+        <CardBody>
+          {rollbackMessage && <Alert color="info">{rollbackMessage}</Alert>}
+          {panelBody}
+        </CardBody>
         <GetBadgeModal
           state={this.state.showBadgeModal}
           closeModal={this.handleCloseBadgeModal}
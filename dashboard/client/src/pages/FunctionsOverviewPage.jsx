@@ -1,6 +1,7 @@
 import React, { Component } from 'react';
 import { FunctionTable } from '../components/FunctionTable';
 import { FunctionEmptyState } from "../components/FunctionEmptyState";
+import { PendingDeploys } from '../components/PendingDeploys';
 import { functionsApi } from '../api/functionsApi';
 import {
   Card,
@@ -91,6 +92,7 @@ export class FunctionsOverviewPage extends Component {
         </CardHeader>
 
         { this.renderContentView() }
+        <PendingDeploys user={user} />
       </Card>
     );
   }
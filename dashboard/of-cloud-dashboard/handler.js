@@ -1,6 +1,7 @@
 'use strict';
 
 const axios = require('axios');
+const crypto = require('crypto');
 const fs = require('fs');
 const fsPromises = fs.promises
 var qs = require('qs');
@@ -8,7 +9,10 @@ var qs = require('qs');
 module.exports = async (event, context) => {
   const { method, path , query} = event;
 
-  if (method !== 'GET') {
+  const isGatePath = /^\/api\/deployment-gate\/?$/.test(path);
+  const isRollbackPath = /^\/api\/rollback\/?$/.test(path);
+
+  if (method !== 'GET' && !(method === 'POST' && (isGatePath || isRollbackPath))) {
     return context.status(405).fail('Method not allowed');
   }
 
@@ -20,6 +24,14 @@ module.exports = async (event, context) => {
   let decodedCookie = decodeCookie(cookie);
   let organizations = parseOrganizations(decodedCookie);
 
+  if (isGatePath) {
+    return handleDeploymentGate(event, context, decodedCookie, organizations);
+  }
+
+  if (isRollbackPath) {
+    return handleRollback(event, context, decodedCookie, organizations);
+  }
+
   if (/^\/api\/(list-functions|metrics|pipeline-log|function-logs).*/.test(path)) {
 
     // See if a user is trying to query functions they do not have permissions to view
@@ -227,6 +239,134 @@ function isRepoOwnedByUser(query, user, organisations) {
   return false;
 }
 
+// handleDeploymentGate lists the deploys held for an owner, or approves or
+// rejects one as the signed-in user. The request to the deployment-gate is
+// signed with the payload-secret, so the user is taken from the cookie and
+// never from the request body.
+const handleDeploymentGate = async (event, context, decodedCookie, organizations) => {
+  const { method, query } = event;
+
+  if (method === 'GET') {
+    if (!query["user"] || !isResourceInTokenClaims(event.path, query, decodedCookie, organizations)) {
+      return context.status(403).succeed('Forbidden');
+    }
+
+    return postSignedFunction(context, method, 'function/deployment-gate', {
+      action: 'list',
+      owner: query["user"],
+    });
+  }
+
+  if (!decodedCookie || !decodedCookie["sub"]) {
+    return context.status(403).succeed('Sign in to approve or reject a deploy');
+  }
+
+  let body = event.body;
+  if (typeof body === 'string' || Buffer.isBuffer(body)) {
+    try {
+      body = JSON.parse(body.toString());
+    } catch (e) {
+      return context.status(400).fail('Invalid request body');
+    }
+  }
+  body = body || {};
+
+  if (body.action !== 'approve' && body.action !== 'reject') {
+    return context.status(400).fail('Action must be approve or reject');
+  }
+
+  if (!isOwnerInTokenClaims(body.owner, decodedCookie, organizations)) {
+    console.log("The user '" + decodedCookie["sub"] + "' tried to " + body.action + " a deploy for " + body.owner)
+    return context.status(403).succeed('Forbidden');
+  }
+
+  return postSignedFunction(context, method, 'function/deployment-gate', {
+    action: body.action,
+    owner: body.owner,
+    repo: body.repo,
+    function: body.function,
+    sha: body.sha,
+    user: decodedCookie["sub"],
+  });
+}
+
+// postSignedFunction signs the request with the payload-secret, as the
+// deployment-gate and rollback functions refuse requests which are not signed
+const postSignedFunction = async (context, method, functionPath, request) => {
+  const gatewayUrl = process.env.gateway_url.replace(/\/$/, '');
+  const gateRequest = JSON.stringify(request);
+
+  let payloadSecret = "";
+  try {
+    payloadSecret = (await fsPromises.readFile('/var/openfaas/secrets/payload-secret')).toString().trim();
+  } catch (err) {
+    console.log(`unable to read payload-secret, error: ${err}`);
+    return context.status(500).fail('Unable to sign request');
+  }
+
+  const digest = crypto.createHmac('sha1', payloadSecret).update(gateRequest).digest('hex');
+  const upstreamURL = `${gatewayUrl}/${functionPath}`;
+
+  try {
+    let res = await axios.post(upstreamURL, gateRequest, {
+      headers: {
+        'Content-Type': 'application/json',
+        'X-Cloud-Signature': `sha1=${digest}`,
+      },
+    });
+    console.log(`${method} ${upstreamURL} - ${res.status}`);
+    return context.status(res.status).succeed(res.data);
+  } catch (err) {
+    console.log(`${method} ${upstreamURL} - 500, error: ${err}`);
+    return context.status(500).fail('Proxy request failed');
+  }
+}
+
+// handleRollback queues the rollback of a function to its previous deploy,
+// or to the deploy of a SHA, as the signed-in user who is recorded as the
+// actor. The rollback waits for the function to become ready so it is run
+// asynchronously and its result is sent to the audit function.
+const handleRollback = async (event, context, decodedCookie, organizations) => {
+  if (!decodedCookie || !decodedCookie["sub"]) {
+    return context.status(403).succeed('Sign in to roll back a function');
+  }
+
+  let body = event.body;
+  if (typeof body === 'string' || Buffer.isBuffer(body)) {
+    try {
+      body = JSON.parse(body.toString());
+    } catch (e) {
+      return context.status(400).fail('Invalid request body');
+    }
+  }
+  body = body || {};
+
+  if (!body.function) {
+    return context.status(400).fail('Function is required');
+  }
+
+  if (!isOwnerInTokenClaims(body.owner, decodedCookie, organizations)) {
+    console.log("The user '" + decodedCookie["sub"] + "' tried to roll back " + body.function + " for " + body.owner)
+    return context.status(403).succeed('Forbidden');
+  }
+
+  return postSignedFunction(context, event.method, 'async-function/rollback', {
+    owner: body.owner,
+    function: body.function,
+    sha: body.sha || '',
+    actor: decodedCookie["sub"],
+  });
+}
+
+var isOwnerInTokenClaims = function (owner, decodedCookie, organisations) {
+  if (!owner) {
+    return false
+  }
+
+  let claims = [decodedCookie["sub"]].concat(organisations.split(","));
+  return claims.some(claim => claim && claim.toLowerCase() === owner.toLowerCase())
+}
+
 const handleLogout = async (context) => {
   const now = new Date();
   const year = now.getFullYear();
@@ -0,0 +1,18 @@
+module github.com/openfaas/openfaas-cloud/deployment-gate
+
+go 1.13
+
+require (
+	github.com/alexellis/derek v0.0.0-20200824120721-b453a7326b67
+	github.com/alexellis/hmac v0.0.0-20180624211220-5c52ab81c0de
+	github.com/dgrijalva/jwt-go v3.2.0+incompatible
+	github.com/drone/envsubst v1.0.2
+	github.com/minio/minio-go v6.0.14+incompatible
+	github.com/mitchellh/go-homedir v1.1.0
+	github.com/openfaas/faas v0.0.0-20200305154109-326cc7a9b923
+	github.com/openfaas/faas-cli v0.0.0-20200226083118-b0a70a3f4f20
+	github.com/openfaas/faas-provider v0.0.0-20191005090653-478f741b64cb
+	github.com/openfaas/openfaas-cloud v0.0.0-20200303103051-6c3e056a6ac4
+	github.com/ryanuber/go-glob v1.0.0
+	gopkg.in/yaml.v2 v2.2.8
+)
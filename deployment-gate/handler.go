@@ -0,0 +1,578 @@
+package function
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/alexellis/hmac"
+	minio "github.com/minio/minio-go"
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+const (
+	Source = "deployment-gate"
+
+	// GitHub SCM
+	GitHub = "github"
+	// GitLab SCM
+	GitLab = "gitlab"
+)
+
+// checksRetention is how long the results of CI checks are kept for
+// commits which have no held deploy
+const checksRetention = 7 * 24 * time.Hour
+
+// Handle holds deploys until they are approved, their required checks have
+// passed and they are outside of any freeze window. A signed POST lists the
+// held deploys for an owner, queues, approves or rejects a deploy or records
+// CI checks and an empty POST from the cron-connector releases any deploys
+// which are ready. Released deploys are made by buildshiprun.
+func Handle(req []byte) string {
+	if os.Getenv("Http_Method") != http.MethodPost {
+		log.Printf("only POST is supported")
+		os.Exit(1)
+	}
+
+	if len(req) > 0 {
+		hmacErr := sdk.ValidHMAC(&req, "payload-secret", os.Getenv("Http_X_Cloud_Signature"))
+		if hmacErr != nil {
+			log.Printf("hmac error %s\n", hmacErr.Error())
+			os.Exit(1)
+		}
+	}
+
+	region := regionName()
+	bucketName := bucketName()
+
+	minioClient, connectErr := connectToMinio(region)
+	if connectErr != nil {
+		log.Printf("S3/Minio connection error %s\n", connectErr.Error())
+		os.Exit(1)
+	}
+
+	store := &gateStore{client: minioClient, bucket: bucketName}
+
+	if len(req) == 0 {
+		return processQueue(store)
+	}
+
+	gateReq := sdk.GateRequest{}
+	if err := json.Unmarshal(req, &gateReq); err != nil {
+		log.Printf("unable to parse request: %s", err.Error())
+		os.Exit(1)
+	}
+
+	switch gateReq.Action {
+	case sdk.GateActionList:
+		if len(gateReq.Owner) == 0 {
+			log.Printf("owner is required to list deploys")
+			os.Exit(1)
+		}
+
+		pending, err := store.List(getOwnerPrefix(gateReq.Owner))
+		if err != nil {
+			log.Printf("error listing deploys for: %s, error: %s", gateReq.Owner, err.Error())
+			os.Exit(1)
+		}
+
+		bytesOut, _ := json.Marshal(pending)
+		return string(bytesOut)
+
+	case sdk.GateActionQueue:
+		if gateReq.Deployment == nil {
+			log.Printf("deployment is required to queue a deploy")
+			os.Exit(1)
+		}
+
+		minioClient.MakeBucket(bucketName, region)
+
+		pending := gateReq.Deployment
+		if len(pending.RequiredChecks) > 0 {
+			// CI may have finished before the function was built
+			pending.SetChecks(store.GetChecks(pending.Event.Owner, pending.Event.Repository, pending.Event.SHA))
+		}
+
+		return release(store, pending, time.Now(), false)
+
+	case sdk.GateActionCheck:
+		if len(gateReq.SHA) == 0 || len(gateReq.Checks) == 0 {
+			return fmt.Sprintf("No checks given for %s/%s", gateReq.Owner, gateReq.Repo)
+		}
+
+		minioClient.MakeBucket(bucketName, region)
+
+		return updateChecks(store, gateReq, time.Now())
+
+	case sdk.GateActionApprove, sdk.GateActionReject:
+		path := getPath(gateReq.Owner, gateReq.Repo, gateReq.Function)
+		pending, err := store.Get(path)
+		if err != nil {
+			return fmt.Sprintf("No deploy waiting for %s/%s %s", gateReq.Owner, gateReq.Repo, gateReq.Function)
+		}
+
+		if len(gateReq.SHA) > 0 && !strings.HasPrefix(pending.Event.SHA, gateReq.SHA) {
+			return fmt.Sprintf("Deploy of %s is waiting for %s, not %s", pending.FunctionName, sdk.FormatShortSHA(pending.Event.SHA), gateReq.SHA)
+		}
+
+		canApprove, err := pending.CanApprove(gateReq.User, getMembership(pending.Event.SCM))
+		if err != nil {
+			log.Printf("unable to check whether %s can approve %s: %s", gateReq.User, pending.FunctionName, err.Error())
+		}
+
+		if !canApprove {
+			msg := fmt.Sprintf("%s is not an approver for %s", gateReq.User, pending.FunctionName)
+			postAudit(pending, msg)
+			return msg
+		}
+
+		if gateReq.Action == sdk.GateActionReject {
+			store.Remove(path)
+
+			msg := fmt.Sprintf("deploy of %s rejected by %s", pending.FunctionName, gateReq.User)
+			report(pending, sdk.StatusFailure, msg)
+			postAudit(pending, msg)
+			return msg
+		}
+
+		pending.ApprovedBy = gateReq.User
+		postAudit(pending, fmt.Sprintf("deploy of %s approved by %s", pending.FunctionName, gateReq.User))
+
+		return release(store, pending, time.Now(), true)
+	}
+
+	return fmt.Sprintf("%s, unknown action: %s", Source, gateReq.Action)
+}
+
+// getMembership resolves the members of the organization or group which
+// owns a repository, who can approve its deploys when no approvers are named
+func getMembership(scm string) sdk.MembershipChecker {
+	if scm != GitLab {
+		return sdk.NewGitHubAppMembership()
+	}
+
+	instance := os.Getenv("gitlab_instance")
+	apiToken, err := sdk.ReadSecret("gitlab-api-token")
+	if len(instance) == 0 || err != nil {
+		log.Printf("gitlab_instance and the gitlab-api-token secret are required to check group membership")
+		return nil
+	}
+	return sdk.NewGitLabMembership(strings.TrimSuffix(instance, "/")+"/api/v4", apiToken)
+}
+
+// processQueue releases each held deploy which is now ready
+func processQueue(store *gateStore) string {
+	pending, err := store.List("gates/")
+	if err != nil {
+		log.Printf("error listing deploys: %s", err.Error())
+		os.Exit(1)
+	}
+
+	results := []string{}
+	now := time.Now()
+	for i := range pending {
+		results = append(results, release(store, &pending[i], now, false))
+	}
+
+	store.RemoveChecksBefore(now.Add(-checksRetention))
+
+	bytesOut, _ := json.Marshal(results)
+	return string(bytesOut)
+}
+
+// updateChecks stores the state of the CI checks for a commit and releases
+// any deploys of the commit which were waiting for them
+func updateChecks(store *gateStore, gateReq sdk.GateRequest, now time.Time) string {
+	checks := store.GetChecks(gateReq.Owner, gateReq.Repo, gateReq.SHA)
+	for name, state := range gateReq.Checks {
+		checks[strings.ToLower(name)] = state
+	}
+
+	if err := store.PutChecks(gateReq.Owner, gateReq.Repo, gateReq.SHA, checks); err != nil {
+		log.Printf("unable to store checks for %s: %s", sdk.FormatShortSHA(gateReq.SHA), err.Error())
+	}
+
+	pending, err := store.List(getRepoPrefix(gateReq.Owner, gateReq.Repo))
+	if err != nil {
+		return fmt.Sprintf("error listing deploys for: %s/%s, error: %s", gateReq.Owner, gateReq.Repo, err.Error())
+	}
+
+	results := []string{}
+	for i := range pending {
+		if pending[i].Event.SHA != gateReq.SHA || len(pending[i].RequiredChecks) == 0 {
+			continue
+		}
+
+		pending[i].SetChecks(checks)
+		results = append(results, release(store, &pending[i], now, true))
+	}
+
+	bytesOut, _ := json.Marshal(results)
+	return string(bytesOut)
+}
+
+// release deploys a held deploy when it is ready, otherwise the deploy is
+// stored and when notify is set its reason is reported as the status. A
+// deploy with a failed required check is discarded.
+func release(store *gateStore, pending *sdk.PendingDeployment, now time.Time, notify bool) string {
+	if failed := pending.FailedCheck(); len(failed) > 0 {
+		store.Remove(getPath(pending.Event.Owner, pending.Event.Repository, pending.Event.Service))
+
+		msg := fmt.Sprintf("deploy of %s cancelled, required check failed: %s", pending.FunctionName, failed)
+		report(pending, sdk.StatusFailure, msg)
+		postAudit(pending, msg)
+		return msg
+	}
+
+	ready, reason, err := checkReady(pending, os.Getenv("freeze_windows"), now)
+	if err != nil {
+		return fmt.Sprintf("%s: %s", pending.FunctionName, err.Error())
+	}
+
+	if !ready {
+		if err := store.Put(pending); err != nil {
+			return fmt.Sprintf("%s: unable to store deploy: %s", pending.FunctionName, err.Error())
+		}
+
+		// Leave the action_required status in place until approved
+		if notify && pending.Approved() {
+			report(pending, sdk.StatusPending, reason)
+		}
+		return reason
+	}
+
+	// A newer commit may have been pushed while the deploy was held, so
+	// never release an older one over it
+	if newerSHA := getSupersedingSHA(os.Getenv("builder_url"), pending); len(newerSHA) > 0 {
+		store.Remove(getPath(pending.Event.Owner, pending.Event.Repository, pending.Event.Service))
+
+		msg := fmt.Sprintf("not deploying %s, superseded by %s", pending.FunctionName, sdk.FormatShortSHA(newerSHA))
+		report(pending, sdk.StatusSuperseded, msg)
+		postAudit(pending, msg)
+		return msg
+	}
+
+	store.Remove(getPath(pending.Event.Owner, pending.Event.Repository, pending.Event.Service))
+
+	if err := deploy(pending); err != nil {
+		msg := fmt.Sprintf("unable to deploy %s: %s", pending.FunctionName, err.Error())
+		report(pending, sdk.StatusFailure, msg)
+		recordDeployment(pending, "failure")
+		postAudit(pending, msg)
+		return msg
+	}
+
+	msg := fmt.Sprintf("deploying %s", pending.FunctionName)
+	report(pending, sdk.StatusPending, msg)
+	postAudit(pending, msg)
+
+	return msg
+}
+
+// checkReady is true when a deploy has been approved, if required, its
+// required checks have passed and no freeze window is in effect, otherwise
+// the reason is returned
+func checkReady(pending *sdk.PendingDeployment, adminWindows string, now time.Time) (bool, string, error) {
+	if !pending.Approved() {
+		return false, fmt.Sprintf("awaiting approval to deploy %s", pending.FunctionName), nil
+	}
+
+	if waiting := pending.WaitingChecks(); len(waiting) > 0 {
+		return false, fmt.Sprintf("waiting for checks to pass before deploying %s: %s", pending.FunctionName, strings.Join(waiting, ", ")), nil
+	}
+
+	window, frozen, err := sdk.ActiveFreeze(adminWindows, pending.Record.Annotations, now)
+	if err != nil {
+		return false, "", err
+	}
+
+	if frozen {
+		reason := fmt.Sprintf("deploy of %s queued during freeze: %s", pending.FunctionName, window.Description)
+		if len(pending.ApprovedBy) > 0 {
+			reason = fmt.Sprintf("deploy of %s approved by %s, queued during freeze: %s", pending.FunctionName, pending.ApprovedBy, window.Description)
+		}
+		return false, reason, nil
+	}
+
+	return true, "", nil
+}
+
+// deploy hands a released deploy to buildshiprun, which waits for the
+// function to become ready and runs its smoke test as for any other deploy
+func deploy(pending *sdk.PendingDeployment) error {
+	payloadSecret, keyErr := sdk.ReadSecret("payload-secret")
+	if keyErr != nil {
+		return fmt.Errorf("failed to load hmac key: %s", keyErr.Error())
+	}
+
+	bytesOut, _ := json.Marshal(pending)
+	req, _ := http.NewRequest(http.MethodPost, os.Getenv("gateway_url")+"async-function/buildshiprun", bytes.NewReader(bytesOut))
+
+	digest := hmac.Sign(bytesOut, []byte(payloadSecret))
+	req.Header.Add(sdk.CloudSignatureHeader, "sha1="+hex.EncodeToString(digest))
+	req.Header.Add(sdk.GateReleaseHeader, "true")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	if res.Body != nil {
+		defer res.Body.Close()
+	}
+
+	if res.StatusCode != http.StatusAccepted && res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status from buildshiprun: %d", res.StatusCode)
+	}
+	return nil
+}
+
+func recordDeployment(pending *sdk.PendingDeployment, status string) {
+	payloadSecret, keyErr := sdk.ReadSecret("payload-secret")
+	if keyErr != nil {
+		log.Printf("failed to load hmac key, error %s", keyErr.Error())
+		return
+	}
+
+	record := pending.Record
+	record.Status = status
+	record.Time = time.Now().UTC()
+	if len(pending.ApprovedBy) > 0 {
+		record.Actor = pending.ApprovedBy
+	}
+
+	if err := sdk.PostDeploymentRecord(os.Getenv("gateway_url"), payloadSecret, record); err != nil {
+		log.Printf("deployment-history: error: %s", err.Error())
+	}
+}
+
+// report sends the state of a held deploy to GitHub or GitLab along with
+// the timing of any stages which ran to reach that state
+func report(pending *sdk.PendingDeployment, state, desc string, stages ...sdk.StageTiming) {
+	if os.Getenv("report_status") != "true" {
+		return
+	}
+
+	payloadSecret, keyErr := sdk.ReadSecret("payload-secret")
+	if keyErr != nil {
+		log.Printf("failed to load hmac key for status, error: %s", keyErr)
+		return
+	}
+
+	gatewayURL := os.Getenv("gateway_url")
+
+	status := sdk.BuildStatus(&pending.Event, sdk.EmptyAuthToken)
+	status.AddStatus(state, desc, sdk.BuildFunctionContext(pending.Event.Service))
+	for _, stage := range stages {
+		status.AddStageStatus(state, stage.Description(state), sdk.BuildFunctionContext(pending.Event.Service), stage)
+	}
+
+	switch pending.Event.SCM {
+	case GitHub:
+		if _, err := status.Report(gatewayURL, payloadSecret); err != nil {
+			log.Printf("failed to report status, error: %s", err.Error())
+		}
+	case GitLab:
+		statusBytes, _ := json.Marshal(status)
+		req, _ := http.NewRequest(http.MethodPost, gatewayURL+"function/gitlab-status", bytes.NewReader(statusBytes))
+
+		digest := hmac.Sign(statusBytes, []byte(payloadSecret))
+		req.Header.Add(sdk.CloudSignatureHeader, "sha1="+hex.EncodeToString(digest))
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			log.Printf("failed to report status, error: %s", err.Error())
+			return
+		}
+		if res.Body != nil {
+			res.Body.Close()
+		}
+	default:
+		log.Printf("non-supported SCM: %s", pending.Event.SCM)
+	}
+}
+
+func postAudit(pending *sdk.PendingDeployment, msg string) {
+	auditEvent := sdk.AuditEvent{
+		Message: msg,
+		Owner:   pending.Event.Owner,
+		Repo:    pending.Event.Repository,
+		Source:  Source,
+	}
+	sdk.PostAudit(auditEvent)
+}
+
+// gateStore keeps one held deploy per function in S3, a newer deploy
+// replaces the one before it
+type gateStore struct {
+	client *minio.Client
+	bucket string
+}
+
+func (s *gateStore) Put(pending *sdk.PendingDeployment) error {
+	bytesOut, _ := json.Marshal(pending)
+	reader := bytes.NewReader(bytesOut)
+
+	_, err := s.client.PutObject(s.bucket,
+		getPath(pending.Event.Owner, pending.Event.Repository, pending.Event.Service),
+		reader,
+		int64(reader.Len()),
+		minio.PutObjectOptions{ContentType: "application/json"})
+
+	return err
+}
+
+func (s *gateStore) Get(path string) (*sdk.PendingDeployment, error) {
+	obj, err := s.client.GetObject(s.bucket, path, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+
+	bytesIn, err := ioutil.ReadAll(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := sdk.PendingDeployment{}
+	if err := json.Unmarshal(bytesIn, &pending); err != nil {
+		return nil, err
+	}
+	return &pending, nil
+}
+
+func (s *gateStore) Remove(path string) {
+	if err := s.client.RemoveObject(s.bucket, path); err != nil {
+		log.Printf("error removing: %s, error: %s", path, err.Error())
+	}
+}
+
+func (s *gateStore) List(prefix string) ([]sdk.PendingDeployment, error) {
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+
+	pending := []sdk.PendingDeployment{}
+	for obj := range s.client.ListObjectsV2(s.bucket, prefix, true, doneCh) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+
+		deployment, err := s.Get(obj.Key)
+		if err != nil {
+			log.Printf("skipping unreadable deploy: %s, error: %s", obj.Key, err.Error())
+			continue
+		}
+		pending = append(pending, *deployment)
+	}
+
+	return pending, nil
+}
+
+// GetChecks returns the state of each CI check recorded for a commit
+func (s *gateStore) GetChecks(owner, repo, sha string) map[string]string {
+	checks := map[string]string{}
+
+	obj, err := s.client.GetObject(s.bucket, getChecksPath(owner, repo, sha), minio.GetObjectOptions{})
+	if err != nil {
+		return checks
+	}
+	defer obj.Close()
+
+	if bytesIn, err := ioutil.ReadAll(obj); err == nil {
+		json.Unmarshal(bytesIn, &checks)
+	}
+	return checks
+}
+
+func (s *gateStore) PutChecks(owner, repo, sha string, checks map[string]string) error {
+	bytesOut, _ := json.Marshal(checks)
+	reader := bytes.NewReader(bytesOut)
+
+	_, err := s.client.PutObject(s.bucket,
+		getChecksPath(owner, repo, sha),
+		reader,
+		int64(reader.Len()),
+		minio.PutObjectOptions{ContentType: "application/json"})
+
+	return err
+}
+
+// RemoveChecksBefore removes the checks for commits which have not been
+// updated since the cutoff
+func (s *gateStore) RemoveChecksBefore(cutoff time.Time) {
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+
+	for obj := range s.client.ListObjectsV2(s.bucket, "checks/", true, doneCh) {
+		if obj.Err != nil {
+			log.Printf("error listing checks: %s", obj.Err.Error())
+			return
+		}
+
+		if obj.LastModified.Before(cutoff) {
+			s.Remove(obj.Key)
+		}
+	}
+}
+
+// getOwnerPrefix produces a string such as gates/alexellis/
+func getOwnerPrefix(owner string) string {
+	return fmt.Sprintf("gates/%s/", strings.ToLower(owner))
+}
+
+// getRepoPrefix produces a string such as gates/alexellis/super-pancake/
+func getRepoPrefix(owner, repo string) string {
+	return fmt.Sprintf("%s%s/", getOwnerPrefix(owner), repo)
+}
+
+// getPath produces a string such as gates/alexellis/super-pancake/slack-fn.json
+func getPath(owner, repo, function string) string {
+	return fmt.Sprintf("%s%s.json", getRepoPrefix(owner, repo), function)
+}
+
+// getChecksPath produces a string such as checks/alexellis/super-pancake/af6db1234567.json
+func getChecksPath(owner, repo, sha string) string {
+	return fmt.Sprintf("checks/%s/%s/%s.json", strings.ToLower(owner), repo, sha)
+}
+
+func connectToMinio(region string) (*minio.Client, error) {
+
+	endpoint := os.Getenv("s3_url")
+
+	tlsEnabled := tlsEnabled()
+
+	secretKey, _ := sdk.ReadSecret("s3-secret-key")
+	accessKey, _ := sdk.ReadSecret("s3-access-key")
+
+	return minio.New(endpoint, accessKey, secretKey, tlsEnabled)
+}
+
+func tlsEnabled() bool {
+	if connection := os.Getenv("s3_tls"); connection == "true" || connection == "1" {
+		return true
+	}
+	return false
+}
+
+func bucketName() string {
+	bucketName, exist := os.LookupEnv("s3_bucket")
+	if exist == false || len(bucketName) == 0 {
+		bucketName = "pipeline"
+		log.Printf("Bucket name not found, set to default: %v\n", bucketName)
+	}
+	return bucketName
+}
+
+func regionName() string {
+	regionName, exist := os.LookupEnv("s3_region")
+	if exist == false || len(regionName) == 0 {
+		regionName = "us-east-1"
+	}
+	return regionName
+}
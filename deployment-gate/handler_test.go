@@ -0,0 +1,102 @@
+package function
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+func Test_getPath(t *testing.T) {
+	want := "gates/alexellis/super-pancake/slack-fn.json"
+	got := getPath("AlexEllis", "super-pancake", "slack-fn")
+	if got != want {
+		t.Errorf("want: %s, got: %s", want, got)
+	}
+}
+
+func Test_getChecksPath(t *testing.T) {
+	want := "checks/alexellis/super-pancake/af6db1234567.json"
+	got := getChecksPath("AlexEllis", "super-pancake", "af6db1234567")
+	if got != want {
+		t.Errorf("want: %s, got: %s", want, got)
+	}
+}
+
+func Test_checkReady(t *testing.T) {
+	saturday := time.Date(2020, 4, 18, 12, 0, 0, 0, time.UTC)
+	monday := time.Date(2020, 4, 20, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name       string
+		pending    sdk.PendingDeployment
+		now        time.Time
+		wantReady  bool
+		wantReason string
+	}{
+		{
+			name:       "awaiting approval",
+			pending:    sdk.PendingDeployment{FunctionName: "alexellis-fn1", RequiresApproval: true},
+			now:        monday,
+			wantReason: "awaiting approval to deploy alexellis-fn1",
+		},
+		{
+			name:      "approved",
+			pending:   sdk.PendingDeployment{FunctionName: "alexellis-fn1", RequiresApproval: true, ApprovedBy: "rgee0"},
+			now:       monday,
+			wantReady: true,
+		},
+		{
+			name:       "approved during freeze",
+			pending:    sdk.PendingDeployment{FunctionName: "alexellis-fn1", RequiresApproval: true, ApprovedBy: "rgee0"},
+			now:        saturday,
+			wantReason: "deploy of alexellis-fn1 approved by rgee0, queued during freeze: Sat-Sun",
+		},
+		{
+			name: "waiting for checks",
+			pending: sdk.PendingDeployment{
+				FunctionName:   "alexellis-fn1",
+				RequiredChecks: []string{"test", "lint"},
+				Checks:         map[string]string{"test": sdk.StatusSuccess},
+			},
+			now:        monday,
+			wantReason: "waiting for checks to pass before deploying alexellis-fn1: lint",
+		},
+		{
+			name: "checks passed",
+			pending: sdk.PendingDeployment{
+				FunctionName:   "alexellis-fn1",
+				RequiredChecks: []string{"test"},
+				Checks:         map[string]string{"test": sdk.StatusSuccess},
+			},
+			now:       monday,
+			wantReady: true,
+		},
+		{
+			name: "owner freeze",
+			pending: sdk.PendingDeployment{
+				FunctionName: "alexellis-fn1",
+				Record: sdk.DeploymentRecord{
+					Annotations: map[string]string{sdk.DeployFreezeAnnotation: "Mon"},
+				},
+			},
+			now:        monday,
+			wantReason: "deploy of alexellis-fn1 queued during freeze: Mon",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ready, reason, err := checkReady(&c.pending, "Sat-Sun", c.now)
+			if err != nil {
+				t.Fatalf("want no error, got: %s", err.Error())
+			}
+			if ready != c.wantReady {
+				t.Errorf("want ready: %t, got: %t", c.wantReady, ready)
+			}
+			if reason != c.wantReason {
+				t.Errorf("want reason: %q, got: %q", c.wantReason, reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package function
+
+import (
+	"log"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+// getSupersedingSHA returns the newer commit pushed while the deploy was
+// held, the deploy is still released when the of-builder can't be asked
+func getSupersedingSHA(builderURL string, pending *sdk.PendingDeployment) string {
+	ref := sdk.NewBuildRef(pending.Event.Owner, pending.Event.Repository, pending.Event.SHA)
+
+	newerSHA, err := sdk.GetSupersedingSHA(builderURL, ref)
+	if err != nil {
+		log.Printf("unable to get the latest build of %s/%s: %s", ref.Owner, ref.Repo, err.Error())
+		return ""
+	}
+	return newerSHA
+}
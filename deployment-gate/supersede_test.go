@@ -0,0 +1,33 @@
+package function
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+func Test_getSupersedingSHA(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"owner": "alexellis", "repo": "repo1", "sha": "4c7b2f1e9a"}`))
+	}))
+	defer s.Close()
+
+	pending := &sdk.PendingDeployment{Event: sdk.Event{Owner: "alexellis", Repository: "repo1", SHA: "4c7b2f1e9a"}}
+	if got := getSupersedingSHA(s.URL+"/", pending); got != "" {
+		t.Errorf("the newest commit should not be superseded, got: %s", got)
+	}
+
+	pending.Event.SHA = "d1e2f3a4b5"
+	if got := getSupersedingSHA(s.URL+"/", pending); got != "4c7b2f1e9a" {
+		t.Errorf("want: %s, got: %s", "4c7b2f1e9a", got)
+	}
+}
+
+func Test_getSupersedingSHA_BuilderUnavailable(t *testing.T) {
+	pending := &sdk.PendingDeployment{Event: sdk.Event{Owner: "alexellis", Repository: "repo1", SHA: "d1e2f3a4b5"}}
+	if got := getSupersedingSHA("http://127.0.0.1:1/", pending); got != "" {
+		t.Errorf("the deploy should be released when the builder is unavailable, got: %s", got)
+	}
+}
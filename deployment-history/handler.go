@@ -0,0 +1,208 @@
+package function
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	minio "github.com/minio/minio-go"
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+const defaultHistoryLimit = 20
+
+// Handle records deployments of functions and returns the
+// deployment history of a function, most recent first
+func Handle(req []byte) string {
+	method := os.Getenv("Http_Method")
+
+	if method == http.MethodPost {
+		hmacErr := sdk.ValidHMAC(&req, "payload-secret", os.Getenv("Http_X_Cloud_Signature"))
+		if hmacErr != nil {
+			log.Printf("hmac error %s\n", hmacErr.Error())
+			os.Exit(1)
+		}
+	}
+
+	region := regionName()
+
+	bucketName := bucketName()
+
+	minioClient, connectErr := connectToMinio(region)
+	if connectErr != nil {
+		log.Printf("S3/Minio connection error %s\n", connectErr.Error())
+		os.Exit(1)
+	}
+
+	switch method {
+	case http.MethodPost:
+		record := sdk.DeploymentRecord{}
+		if err := json.Unmarshal(req, &record); err != nil {
+			log.Printf("unable to parse deployment record: %s", err.Error())
+			os.Exit(1)
+		}
+
+		if len(record.Owner) == 0 || len(record.Function) == 0 {
+			log.Printf("owner and function are required for a deployment record")
+			os.Exit(1)
+		}
+
+		if record.Time.IsZero() {
+			record.Time = time.Now().UTC()
+		}
+
+		minioClient.MakeBucket(bucketName, region)
+
+		bytesOut, _ := json.Marshal(&record)
+		reader := bytes.NewReader(bytesOut)
+		fullPath := getPath(&record)
+
+		n, err := minioClient.PutObject(bucketName,
+			fullPath,
+			reader,
+			int64(reader.Len()),
+			minio.PutObjectOptions{ContentType: "application/json"})
+
+		if err != nil {
+			log.Printf("error writing: %s, error: %s", fullPath, err.Error())
+			os.Exit(1)
+		}
+		return fmt.Sprintf("Wrote %d bytes to %s\n", n, fullPath)
+
+	case http.MethodGet:
+		query, parseErr := url.ParseQuery(os.Getenv("Http_Query"))
+		if parseErr != nil {
+			return parseErr.Error()
+		}
+
+		owner := query.Get("owner")
+		function := query.Get("function")
+		if len(owner) == 0 || len(function) == 0 {
+			log.Printf("owner and function are required in the query")
+			os.Exit(1)
+		}
+
+		limit := defaultHistoryLimit
+		if val := query.Get("limit"); len(val) > 0 {
+			if parsed, err := strconv.Atoi(val); err == nil && parsed > 0 {
+				limit = parsed
+			}
+		}
+
+		records, err := listRecords(minioClient, bucketName, owner, function, limit)
+		if err != nil {
+			log.Printf("error listing deployments for: %s/%s, error: %s", owner, function, err.Error())
+			os.Exit(1)
+		}
+
+		bytesOut, _ := json.Marshal(records)
+		return string(bytesOut)
+	}
+
+	return fmt.Sprintf("deployment-history, unknown request")
+}
+
+func listRecords(minioClient *minio.Client, bucketName, owner, function string, limit int) ([]sdk.DeploymentRecord, error) {
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+
+	keys := []string{}
+	for obj := range minioClient.ListObjectsV2(bucketName, getPrefix(owner, function), true, doneCh) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		keys = append(keys, obj.Key)
+	}
+
+	keys = sortKeys(keys, limit)
+
+	records := []sdk.DeploymentRecord{}
+	for _, key := range keys {
+		obj, err := minioClient.GetObject(bucketName, key, minio.GetObjectOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		recordBytes, readErr := ioutil.ReadAll(obj)
+		obj.Close()
+		if readErr != nil {
+			return nil, readErr
+		}
+
+		record := sdk.DeploymentRecord{}
+		if err := json.Unmarshal(recordBytes, &record); err != nil {
+			log.Printf("skipping unreadable record: %s, error: %s", key, err.Error())
+			continue
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// sortKeys orders keys with the most recent deployment first and
+// returns at most limit keys
+func sortKeys(keys []string, limit int) []string {
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+	return keys
+}
+
+// getPrefix produces a string such as deployments/alexellis/slack-fn/
+func getPrefix(owner, function string) string {
+	return fmt.Sprintf("deployments/%s/%s/", strings.ToLower(owner), function)
+}
+
+// getPath produces a string such as deployments/alexellis/slack-fn/01586952000000000000-af6db.json
+// where the timestamp is padded so that keys sort in the order of deployment
+func getPath(record *sdk.DeploymentRecord) string {
+	return fmt.Sprintf("%s%020d-%s.json", getPrefix(record.Owner, record.Function), record.Time.UnixNano(), sdk.FormatShortSHA(record.SHA))
+}
+
+func connectToMinio(region string) (*minio.Client, error) {
+
+	endpoint := os.Getenv("s3_url")
+
+	tlsEnabled := tlsEnabled()
+
+	secretKey, _ := sdk.ReadSecret("s3-secret-key")
+	accessKey, _ := sdk.ReadSecret("s3-access-key")
+
+	return minio.New(endpoint, accessKey, secretKey, tlsEnabled)
+}
+
+func tlsEnabled() bool {
+	if connection := os.Getenv("s3_tls"); connection == "true" || connection == "1" {
+		return true
+	}
+	return false
+}
+
+func bucketName() string {
+	bucketName, exist := os.LookupEnv("s3_bucket")
+	if exist == false || len(bucketName) == 0 {
+		bucketName = "pipeline"
+		log.Printf("Bucket name not found, set to default: %v\n", bucketName)
+	}
+	return bucketName
+}
+
+func regionName() string {
+	regionName, exist := os.LookupEnv("s3_region")
+	if exist == false || len(regionName) == 0 {
+		regionName = "us-east-1"
+	}
+	return regionName
+}
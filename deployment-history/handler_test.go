@@ -0,0 +1,46 @@
+package function
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+func Test_getPath(t *testing.T) {
+	got := getPath(&sdk.DeploymentRecord{
+		Owner:    "AlexEllis",
+		Function: "slack-fn",
+		SHA:      "af6db1234567",
+		Time:     time.Unix(1586952000, 0),
+	})
+	want := "deployments/alexellis/slack-fn/01586952000000000000-af6db12.json"
+	if got != want {
+		t.Errorf("got: %s, but want: %s", got, want)
+	}
+}
+
+func Test_sortKeys_MostRecentFirst(t *testing.T) {
+	keys := []string{
+		getPath(&sdk.DeploymentRecord{Owner: "alexellis", Function: "fn1", SHA: "aaa", Time: time.Unix(1000, 0)}),
+		getPath(&sdk.DeploymentRecord{Owner: "alexellis", Function: "fn1", SHA: "ccc", Time: time.Unix(3000, 0)}),
+		getPath(&sdk.DeploymentRecord{Owner: "alexellis", Function: "fn1", SHA: "bbb", Time: time.Unix(2000, 0)}),
+	}
+
+	got := sortKeys(keys, 2)
+	if len(got) != 2 {
+		t.Fatalf("want 2 keys, got %d", len(got))
+	}
+
+	want := getPath(&sdk.DeploymentRecord{Owner: "alexellis", Function: "fn1", SHA: "ccc", Time: time.Unix(3000, 0)})
+	if got[0] != want {
+		t.Errorf("want first key: %s, got: %s", want, got[0])
+	}
+}
+
+func Test_bucketName_Default(t *testing.T) {
+	want := "pipeline"
+	if got := bucketName(); got != want {
+		t.Errorf("want: %s, got: %s", want, got)
+	}
+}
@@ -30,7 +30,7 @@ type ProviderAccessToken struct {
 }
 
 func buildGitHubURL(config *Config, resource string, scope string) *url.URL {
-	authURL := "https://github.com/login/oauth/authorize"
+	authURL := config.GitHubURLs.URL + "/login/oauth/authorize"
 	u, _ := url.Parse(authURL)
 	q := u.Query()
 
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"net/url"
 	"testing"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
 )
 
 func TestCombineURL_BuildsValidURL(t *testing.T) {
@@ -66,6 +68,28 @@ func Test_buildGitLabURL(t *testing.T) {
 	}
 }
 
+func Test_buildGitHubURL_EnterpriseServer(t *testing.T) {
+	c := &Config{
+		GitHubURLs:             sdk.MakeGitHubURLs("https://github.example.com", ""),
+		ClientID:               "baz",
+		ExternalRedirectDomain: "http://bazfoz.com",
+	}
+
+	gotURL := buildGitHubURL(c, "", "read:org")
+
+	if gotURL.Host != "github.example.com" {
+		t.Errorf("Expected host: \"%s\". Got: \"%s\"", "github.example.com", gotURL.Host)
+	}
+
+	if gotURL.Path != "/login/oauth/authorize" {
+		t.Errorf("Expected path: \"%s\". Got: \"%s\"", "/login/oauth/authorize", gotURL.Path)
+	}
+
+	if gotURL.Query().Get("client_id") != c.ClientID {
+		t.Errorf("Expected query.client_id: \"%s\". Got: \"%s\"", c.ClientID, gotURL.Query().Get("client_id"))
+	}
+}
+
 func Test_GetOrganizations(t *testing.T) {
 	tests := []struct {
 		Title                 string
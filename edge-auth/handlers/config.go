@@ -2,11 +2,14 @@ package handlers
 
 import (
 	"time"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
 )
 
 type Config struct {
 	OAuthProvider          string
 	OAuthProviderBaseURL   string
+	GitHubURLs             sdk.GitHubURLs
 	ClientID               string
 	OAuthClientSecretPath  string
 	ExternalRedirectDomain string
@@ -17,4 +20,10 @@ type Config struct {
 	PublicKeyPath          string
 	PrivateKeyPath         string
 	Debug                  bool // Debug enables verbose logging of claims / cookies
+
+	// SetupEnabled serves /setup/ to create the GitHub App from a manifest
+	SetupEnabled       bool
+	WebhookURL         string
+	Namespace          string
+	FunctionsNamespace string
 }
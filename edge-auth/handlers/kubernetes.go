@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const serviceAccountPath = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// SecretWriter saves secrets so that they can be mounted into the functions
+// and into edge-auth
+type SecretWriter interface {
+	// Apply creates the secret, or replaces its data when it exists
+	Apply(namespace, name string, data map[string]string) error
+}
+
+// KubernetesSecrets writes secrets through the API of the cluster with the
+// token of the service account of edge-auth
+type KubernetesSecrets struct {
+	APIURL string
+	Token  string
+	Client *http.Client
+}
+
+type kubernetesSecret struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   map[string]string `json:"metadata"`
+	Type       string            `json:"type"`
+	StringData map[string]string `json:"stringData"`
+}
+
+// NewInClusterSecrets uses the service account mounted into the pod
+func NewInClusterSecrets() (*KubernetesSecrets, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if len(host) == 0 || len(port) == 0 {
+		return nil, fmt.Errorf("not running in a Kubernetes cluster")
+	}
+
+	token, err := ioutil.ReadFile(filepath.Join(serviceAccountPath, "token"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read service account token: %s", err.Error())
+	}
+
+	caData, err := ioutil.ReadFile(filepath.Join(serviceAccountPath, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read service account CA: %s", err.Error())
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caData) {
+		return nil, fmt.Errorf("unable to parse service account CA")
+	}
+
+	return &KubernetesSecrets{
+		APIURL: "https://" + net.JoinHostPort(host, port),
+		Token:  strings.TrimSpace(string(token)),
+		Client: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: pool},
+			},
+		},
+	}, nil
+}
+
+// Apply creates the secret, or replaces it when it already exists
+func (k *KubernetesSecrets) Apply(namespace, name string, data map[string]string) error {
+	secret := kubernetesSecret{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata: map[string]string{
+			"name":      name,
+			"namespace": namespace,
+		},
+		Type:       "Opaque",
+		StringData: data,
+	}
+	body, _ := json.Marshal(secret)
+
+	secretsURL := fmt.Sprintf("%s/api/v1/namespaces/%s/secrets", k.APIURL, namespace)
+	status, err := k.do(http.MethodPost, secretsURL, body)
+	if err != nil {
+		return err
+	}
+
+	if status == http.StatusConflict {
+		status, err = k.do(http.MethodPut, secretsURL+"/"+name, body)
+		if err != nil {
+			return err
+		}
+	}
+
+	if status != http.StatusOK && status != http.StatusCreated {
+		return fmt.Errorf("unable to write secret %s/%s, status: %d", namespace, name, status)
+	}
+	return nil
+}
+
+func (k *KubernetesSecrets) do(method, url string, body []byte) (int, error) {
+	req, _ := http.NewRequest(method, url, bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+k.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := k.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	ioutil.ReadAll(res.Body)
+	return res.StatusCode, nil
+}
@@ -79,8 +79,8 @@ func MakeOAuth2Handler(config *Config) func(http.ResponseWriter, *http.Request)
 
 		switch config.OAuthProvider {
 		case githubName:
-			tokenURL = "https://github.com/login/oauth/access_token"
-			oauthProvider = provider.NewGitHub(c)
+			tokenURL = config.GitHubURLs.URL + "/login/oauth/access_token"
+			oauthProvider = provider.NewGitHub(c, config.GitHubURLs.APIURL)
 
 			break
 		case gitlabName:
@@ -193,7 +193,7 @@ func createSession(token ProviderAccessToken, privateKey crypto.PrivateKey, conf
 	organizationList := ""
 
 	if providerName == "github" {
-		github := provider.NewGitHub(http.DefaultClient)
+		github := provider.NewGitHub(http.DefaultClient, config.GitHubURLs.APIURL)
 
 		organizations, organizationsErr := github.GetUserOrganizations(token.AccessToken)
 		if organizationsErr != nil {
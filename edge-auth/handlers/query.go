@@ -49,7 +49,7 @@ func MakeQueryHandler(config *Config, protected []string, restrictedPrefix []str
 			status = http.StatusUnauthorized
 		} else if isProtected(resource, protected) {
 			started := time.Now()
-			cookieStatus := validCookie(r, cookieName, publicKey, customers, config.Debug)
+			cookieStatus := validCookie(r, cookieName, publicKey, customers, config)
 
 			log.Printf("Cookie verified: %fs [%d]", time.Since(started).Seconds(), cookieStatus)
 
@@ -101,7 +101,8 @@ func isProtected(resource string, protected []string) bool {
 	return false
 }
 
-func validCookie(r *http.Request, cookieName string, publicKey crypto.PublicKey, customers *sdk.Customers, debug bool) int {
+func validCookie(r *http.Request, cookieName string, publicKey crypto.PublicKey, customers *sdk.Customers, config *Config) int {
+	debug := config.Debug
 
 	cookie, err := r.Cookie(cookieName)
 	if err != nil {
@@ -128,7 +129,12 @@ func validCookie(r *http.Request, cookieName string, publicKey crypto.PublicKey,
 				log.Println("Claims", claims)
 				log.Printf("Validated JWT for (%s) %s", claims.Subject, claims.Name)
 			}
-			if found, _ := customers.Get(claims.Subject); found == false {
+			found, err := customers.GetWithMembership(claims.Subject, userMembership(config, &claims))
+			if err != nil {
+				log.Printf("unable to resolve membership of [%s]: %s", claims.Subject, err.Error())
+			}
+
+			if found == false {
 				log.Printf("user [%s] was not a valid customer", claims.Subject)
 				return http.StatusUnauthorized
 			}
@@ -144,3 +150,21 @@ func validCookie(r *http.Request, cookieName string, publicKey crypto.PublicKey,
 
 	return http.StatusUnauthorized
 }
+
+// userMembership resolves the organization, team and group rules of the
+// customers list with the token the user logged in with
+func userMembership(config *Config, claims *OpenFaaSCloudClaims) sdk.MembershipChecker {
+	if len(claims.AccessToken) == 0 {
+		return nil
+	}
+
+	switch config.OAuthProvider {
+	case githubName:
+		return sdk.NewGitHubUserMembership(config.GitHubURLs.APIURL, claims.AccessToken)
+	case gitlabName:
+		membership := sdk.NewGitLabMembership(config.OAuthProviderBaseURL+"/api/v4", claims.AccessToken)
+		membership.OAuth = true
+		return membership
+	}
+	return nil
+}
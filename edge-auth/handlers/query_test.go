@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+func Test_userMembership(t *testing.T) {
+	claims := &OpenFaaSCloudClaims{AccessToken: "token"}
+
+	github := &Config{OAuthProvider: githubName, GitHubURLs: sdk.MakeGitHubURLs("", "")}
+	if membership, ok := userMembership(github, claims).(*sdk.GitHubMembership); !ok || membership.APIURL != "https://api.github.com" {
+		t.Errorf("want GitHub membership through api.github.com, got: %v", userMembership(github, claims))
+	}
+
+	gitlab := &Config{OAuthProvider: gitlabName, OAuthProviderBaseURL: "https://gitlab.example.com"}
+	membership, ok := userMembership(gitlab, claims).(*sdk.GitLabMembership)
+	if !ok || membership.APIURL != "https://gitlab.example.com/api/v4" || !membership.OAuth {
+		t.Errorf("want GitLab membership with the OAuth token, got: %v", membership)
+	}
+
+	if membership := userMembership(github, &OpenFaaSCloudClaims{}); membership != nil {
+		t.Errorf("want no membership without an access token, got: %v", membership)
+	}
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	setupStateCookieName = "openfaas_cloud_setup_state"
+	setupStateExpiry     = 10 * time.Minute
+	defaultAppName       = "OpenFaaS Cloud"
+)
+
+// appManifest describes the GitHub App which is created by the setup, see
+// https://developer.github.com/apps/building-github-apps/creating-github-apps-from-a-manifest/
+type appManifest struct {
+	Name               string            `json:"name"`
+	URL                string            `json:"url"`
+	HookAttributes     map[string]string `json:"hook_attributes"`
+	RedirectURL        string            `json:"redirect_url"`
+	CallbackURLs       []string          `json:"callback_urls"`
+	Public             bool              `json:"public"`
+	DefaultPermissions map[string]string `json:"default_permissions"`
+	DefaultEvents      []string          `json:"default_events"`
+}
+
+// appConversion is the GitHub App returned for the code of the manifest
+type appConversion struct {
+	ID            int    `json:"id"`
+	Slug          string `json:"slug"`
+	Name          string `json:"name"`
+	HTMLURL       string `json:"html_url"`
+	ClientID      string `json:"client_id"`
+	ClientSecret  string `json:"client_secret"`
+	WebhookSecret string `json:"webhook_secret"`
+	PEM           string `json:"pem"`
+}
+
+type setupPage struct {
+	Action   string
+	Manifest string
+	App      *appConversion
+	Error    string
+}
+
+// MakeSetupHandler creates a GitHub App from a manifest and saves its ID,
+// private key, webhook secret and OAuth credentials as secrets. It is only
+// served when setup is enabled, as anyone who can reach it can replace the
+// app of the installation.
+func MakeSetupHandler(config *Config, secrets SecretWriter) func(http.ResponseWriter, *http.Request) {
+	c := &http.Client{
+		Timeout: profileFetchTimeout,
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Println("Setup path", r.URL.Path)
+
+		if !config.SetupEnabled {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/setup/", "/setup":
+			state, err := newSetupState()
+			if err != nil {
+				writeSetupPage(w, http.StatusInternalServerError, setupPage{Error: err.Error()})
+				return
+			}
+
+			http.SetCookie(w, &http.Cookie{
+				Name:     setupStateCookieName,
+				Value:    state,
+				Path:     "/setup/",
+				Expires:  time.Now().Add(setupStateExpiry),
+				HttpOnly: true,
+				Secure:   config.SecureCookie,
+			})
+
+			manifest, _ := json.Marshal(buildAppManifest(config, r.URL.Query().Get("name")))
+			writeSetupPage(w, http.StatusOK, setupPage{
+				Action:   buildAppManifestURL(config, r.URL.Query().Get("org"), state),
+				Manifest: string(manifest),
+			})
+
+		case "/setup/callback":
+			query := r.URL.Query()
+			cookie, err := r.Cookie(setupStateCookieName)
+			if err != nil || len(query.Get("state")) == 0 || cookie.Value != query.Get("state") {
+				writeSetupPage(w, http.StatusUnauthorized, setupPage{Error: "the setup state is invalid or has expired, start the setup again"})
+				return
+			}
+
+			app, err := exchangeManifestCode(c, config.GitHubURLs.APIURL, query.Get("code"))
+			if err != nil {
+				log.Printf("setup: %s", err.Error())
+				writeSetupPage(w, http.StatusBadGateway, setupPage{Error: err.Error()})
+				return
+			}
+
+			if err := saveApp(secrets, config, app); err != nil {
+				log.Printf("setup: %s", err.Error())
+				writeSetupPage(w, http.StatusInternalServerError, setupPage{App: app, Error: err.Error()})
+				return
+			}
+
+			log.Printf("setup: created GitHub App %s with ID: %d", app.Slug, app.ID)
+			writeSetupPage(w, http.StatusOK, setupPage{App: app})
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+}
+
+// buildAppManifest asks for the permissions and events which the functions
+// need, webhooks are sent to github-event and users log in through edge-auth
+func buildAppManifest(config *Config, name string) appManifest {
+	if len(name) == 0 {
+		name = defaultAppName
+	}
+
+	return appManifest{
+		Name:           name,
+		URL:            "https://github.com/openfaas/openfaas-cloud",
+		HookAttributes: map[string]string{"url": config.WebhookURL},
+		RedirectURL:    combineURL(config.ExternalRedirectDomain, "/setup/callback"),
+		CallbackURLs:   []string{combineURL(config.ExternalRedirectDomain, "/oauth2/authorized")},
+		Public:         true,
+		DefaultPermissions: map[string]string{
+			"checks":        "write",
+			"contents":      "read",
+			"deployments":   "write",
+			"metadata":      "read",
+			"members":       "read",
+			"pull_requests": "write",
+			"statuses":      "write",
+		},
+		DefaultEvents: []string{
+			"check_run",
+			"check_suite",
+			"push",
+			"repository",
+			"workflow_run",
+		},
+	}
+}
+
+// buildAppManifestURL is where the manifest is posted to create the app for
+// the user, or for the organization when one is given
+func buildAppManifestURL(config *Config, org string, state string) string {
+	manifestURL := config.GitHubURLs.URL + "/settings/apps/new"
+	if len(org) > 0 {
+		manifestURL = fmt.Sprintf("%s/organizations/%s/settings/apps/new", config.GitHubURLs.URL, url.PathEscape(org))
+	}
+
+	return manifestURL + "?state=" + url.QueryEscape(state)
+}
+
+// exchangeManifestCode gets the app which GitHub created from the manifest,
+// the code is valid for an hour and can only be used once
+func exchangeManifestCode(c *http.Client, apiURL string, code string) (*appConversion, error) {
+	if len(code) == 0 {
+		return nil, fmt.Errorf("no code was given by GitHub")
+	}
+
+	conversionURL := fmt.Sprintf("%s/app-manifests/%s/conversions", apiURL, url.PathEscape(code))
+	req, _ := http.NewRequest(http.MethodPost, conversionURL, nil)
+	req.Header.Set("Accept", "application/vnd.github.fury-preview+json")
+
+	res, err := c.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error while exchanging the manifest code: %s", err.Error())
+	}
+	defer res.Body.Close()
+
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("bad status code from GitHub for the manifest code: %d", res.StatusCode)
+	}
+
+	app := appConversion{}
+	if err := json.Unmarshal(body, &app); err != nil {
+		return nil, fmt.Errorf("error while un-marshaling the GitHub App: %s", err.Error())
+	}
+	return &app, nil
+}
+
+// saveApp writes the secrets of the functions to the functions namespace and
+// the OAuth credentials of edge-auth to its own namespace
+func saveApp(secrets SecretWriter, config *Config, app *appConversion) error {
+	functionSecrets := map[string]string{
+		"github-app-id":         strconv.Itoa(app.ID),
+		"private-key":           app.PEM,
+		"github-webhook-secret": app.WebhookSecret,
+	}
+
+	for name, value := range functionSecrets {
+		if err := secrets.Apply(config.FunctionsNamespace, name, map[string]string{name: value}); err != nil {
+			return err
+		}
+	}
+
+	authSecrets := map[string]string{
+		"of-client-id":     app.ClientID,
+		"of-client-secret": app.ClientSecret,
+	}
+
+	for name, value := range authSecrets {
+		if err := secrets.Apply(config.Namespace, name, map[string]string{name: value}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newSetupState() (string, error) {
+	state := make([]byte, 16)
+	if _, err := rand.Read(state); err != nil {
+		return "", fmt.Errorf("unable to create setup state: %s", err.Error())
+	}
+	return hex.EncodeToString(state), nil
+}
+
+func writeSetupPage(w http.ResponseWriter, statusCode int, page setupPage) {
+	tmpl, err := template.ParseFiles("./template/setup.html")
+	if err != nil {
+		log.Printf("unable to parse setup template: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var tpl bytes.Buffer
+	if err := tmpl.Execute(&tpl, page); err != nil {
+		log.Printf("unable to execute setup template: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(statusCode)
+	w.Write(tpl.Bytes())
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/openfaas/openfaas-cloud/edge-auth/handlers"
 	"github.com/openfaas/openfaas-cloud/edge-auth/provider"
+	"github.com/openfaas/openfaas-cloud/sdk"
 )
 
 const cookieExpiry = time.Hour * 48
@@ -27,6 +29,12 @@ func main() {
 	var privateKeyPath string
 
 	var oauthClientSecretPath string
+	var oauthClientIDPath string
+
+	var setupEnabled bool
+	var setupWebhookURL string
+	namespace := "openfaas"
+	functionsNamespace := "openfaas-fn"
 
 	var writeDebug bool
 	secureCookie := false
@@ -47,6 +55,13 @@ func main() {
 		oauthProviderBaseURL = val
 	}
 
+	// A GitHub Enterprise Server can be given by oauth_provider_base_url
+	// like GitLab, or by github_url like for the functions
+	githubURLs := sdk.GetGitHubURLs()
+	if strings.EqualFold(oauthProvider, "github") && len(oauthProviderBaseURL) > 0 {
+		githubURLs = sdk.MakeGitHubURLs(oauthProviderBaseURL, os.Getenv("github_api_url"))
+	}
+
 	if val, exists := os.LookupEnv("client_id"); exists {
 		clientID = val
 	}
@@ -78,13 +93,48 @@ func main() {
 		oauthClientSecretPath = val
 	}
 
+	if val, exists := os.LookupEnv("oauth_client_id_path"); exists {
+		oauthClientIDPath = val
+	}
+
+	// The client ID is read from the secret written by the setup when it
+	// isn't given
+	if len(clientID) == 0 && len(oauthClientIDPath) > 0 {
+		if val, err := ioutil.ReadFile(oauthClientIDPath); err == nil {
+			clientID = strings.TrimSpace(string(val))
+		}
+	}
+
 	if val, exists := os.LookupEnv("write_debug"); exists && (val == "true" || val == "1") {
 		writeDebug = true
 	}
 
+	if val, exists := os.LookupEnv("enable_setup"); exists && (val == "true" || val == "1") {
+		setupEnabled = true
+	}
+
+	if val, exists := os.LookupEnv("setup_webhook_url"); exists {
+		setupWebhookURL = val
+	} else if len(cookieRootDomain) > 0 {
+		scheme := "http"
+		if secureCookie {
+			scheme = "https"
+		}
+		setupWebhookURL = fmt.Sprintf("%s://system%s/github-event", scheme, cookieRootDomain)
+	}
+
+	if val, exists := os.LookupEnv("namespace"); exists && len(val) > 0 {
+		namespace = val
+	}
+
+	if val, exists := os.LookupEnv("functions_namespace"); exists && len(val) > 0 {
+		functionsNamespace = val
+	}
+
 	config := &handlers.Config{
 		OAuthProvider:          strings.ToLower(oauthProvider),
 		OAuthProviderBaseURL:   oauthProviderBaseURL,
+		GitHubURLs:             githubURLs,
 		ClientID:               clientID,
 		CookieExpiresIn:        cookieExpiry,
 		CookieRootDomain:       cookieRootDomain,
@@ -95,6 +145,20 @@ func main() {
 		PrivateKeyPath:         privateKeyPath,
 		OAuthClientSecretPath:  oauthClientSecretPath,
 		Debug:                  writeDebug,
+		SetupEnabled:           setupEnabled,
+		WebhookURL:             setupWebhookURL,
+		Namespace:              namespace,
+		FunctionsNamespace:     functionsNamespace,
+	}
+
+	var secrets handlers.SecretWriter
+	if setupEnabled {
+		inCluster, err := handlers.NewInClusterSecrets()
+		if err != nil {
+			log.Fatalf("setup is enabled but secrets can't be written: %s", err.Error())
+		}
+		secrets = inCluster
+		log.Printf("Setup is enabled at /setup/, webhooks will be sent to: %s", setupWebhookURL)
 	}
 
 	protected := []string{
@@ -112,6 +176,10 @@ func main() {
 		"/function/github-status",
 		"/function/import-secrets",
 		"/function/pipeline-log",
+		"/function/deployment-history",
+		"/function/rollback",
+		"/function/canary",
+		"/function/deployment-gate",
 		"/function/list-functions",
 		"/function/audit-event",
 		"/function/echo",
@@ -136,6 +204,7 @@ func main() {
 	router.HandleFunc("/q/", handlers.MakeQueryHandler(config, protected, restrictedPrefix))
 	router.HandleFunc("/login/", handlers.MakeLoginHandler(config))
 	router.HandleFunc("/oauth2/", handlers.MakeOAuth2Handler(config))
+	router.HandleFunc("/setup/", handlers.MakeSetupHandler(config, secrets))
 	router.HandleFunc("/healthz/", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK."))
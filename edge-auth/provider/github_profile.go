@@ -12,12 +12,17 @@ import (
 // GitHub provider
 type GitHub struct {
 	Client *http.Client
+
+	// APIURL is the base URL of the API of GitHub or of a GitHub Enterprise
+	// Server, without a trailing slash
+	APIURL string
 }
 
 // NewGitHub create a new GitHub API provider
-func NewGitHub(c *http.Client) *GitHub {
+func NewGitHub(c *http.Client, apiURL string) *GitHub {
 	return &GitHub{
 		Client: c,
+		APIURL: apiURL,
 	}
 }
 
@@ -27,7 +32,7 @@ func (gh *GitHub) GetProfile(accessToken string) (*Profile, error) {
 	var githubProfile GitHubProfile
 	profile := &Profile{}
 
-	req, reqErr := http.NewRequest(http.MethodGet, "https://api.github.com/user", nil)
+	req, reqErr := http.NewRequest(http.MethodGet, gh.APIURL+"/user", nil)
 	req.Header.Add("Authorization", "token "+accessToken)
 	if reqErr != nil {
 		return profile, reqErr
@@ -77,7 +82,7 @@ type GitHubProfile struct {
 // GetUserOrganizations using the API "List your organizations"
 // https://developer.github.com/v3/orgs/#list-your-organizations
 func (gh *GitHub) GetUserOrganizations(accessToken string) (string, error) {
-	apiURL := fmt.Sprintf("https://api.github.com/user/orgs")
+	apiURL := gh.APIURL + "/user/orgs"
 
 	req, reqErr := http.NewRequest(http.MethodGet, apiURL, nil)
 	if reqErr != nil {
@@ -0,0 +1,47 @@
+package provider
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_GitHub_EnterpriseServer(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token access-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/api/v3/user":
+			w.Write([]byte(`{"id": 1, "login": "alexellis", "name": "Alex Ellis"}`))
+		case "/api/v3/user/orgs":
+			w.Write([]byte(`[{"login": "openfaas", "id": 2}, {"login": "inlets", "id": 3}]`))
+		default:
+			t.Errorf("unexpected path: %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	github := NewGitHub(s.Client(), s.URL+"/api/v3")
+
+	profile, err := github.GetProfile("access-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if profile.Login != "alexellis" {
+		t.Errorf("want login: %s, got: %s", "alexellis", profile.Login)
+	}
+
+	organizations, err := github.GetUserOrganizations("access-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if organizations != "openfaas,inlets" {
+		t.Errorf("want organizations: %s, got: %s", "openfaas,inlets", organizations)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const canarySuffix = "-canary"
+
+// canaryRouter sends a percentage of the traffic for a function to its
+// canary, the weights are fetched from the canary function and cached.
+type canaryRouter struct {
+	URL     string
+	Client  *http.Client
+	Refresh time.Duration
+
+	lock     sync.Mutex
+	weights  map[string]int
+	fetched  time.Time
+	fetching bool
+}
+
+// Route returns the name of the function which should serve a request,
+// which is either the function or its canary.
+func (c *canaryRouter) Route(functionName string) string {
+	weight := c.Weight(functionName)
+	if weight > 0 && rand.Intn(100) < weight {
+		return functionName + canarySuffix
+	}
+	return functionName
+}
+
+// Weight returns the percentage of traffic to send to the canary of
+// a function, or 0 when it has no canary. Stale weights are refreshed in
+// the background and the cached weights are served meanwhile, so that
+// requests never wait on the canary function.
+func (c *canaryRouter) Weight(functionName string) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.fetching && time.Since(c.fetched) > c.Refresh {
+		c.fetching = true
+		go c.refresh()
+	}
+
+	return c.weights[functionName]
+}
+
+// refresh fetches the weights without holding the lock, the previous
+// weights are kept until the next refresh on error
+func (c *canaryRouter) refresh() {
+	weights, err := c.fetch()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err != nil {
+		log.Printf("Unable to fetch canary weights: %s\n", err)
+	} else {
+		c.weights = weights
+	}
+
+	c.fetched = time.Now()
+	c.fetching = false
+}
+
+func (c *canaryRouter) fetch() (map[string]int, error) {
+	req, _ := http.NewRequest(http.MethodGet, c.URL, nil)
+
+	res, err := c.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Body != nil {
+		defer res.Body.Close()
+	}
+
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %d", res.StatusCode)
+	}
+
+	weights := map[string]int{}
+	if err := json.Unmarshal(body, &weights); err != nil {
+		return nil, err
+	}
+
+	return weights, nil
+}
+
+// progress asks the canary function to promote or roll back its canaries
+// at each interval. The request is signed with the payload-secret, as the
+// canary function refuses to change canaries for a request which is not.
+func (c *canaryRouter) progress(payloadSecret string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := c.postProgress(payloadSecret); err != nil {
+			log.Printf("Unable to progress canaries: %s\n", err)
+		}
+	}
+}
+
+func (c *canaryRouter) postProgress(payloadSecret string) error {
+	body := []byte{}
+
+	mac := hmac.New(sha1.New, []byte(payloadSecret))
+	mac.Write(body)
+
+	req, _ := http.NewRequest(http.MethodPost, c.URL, bytes.NewReader(body))
+	req.Header.Set("X-Cloud-Signature", "sha1="+hex.EncodeToString(mac.Sum(nil)))
+
+	res, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	if res.Body != nil {
+		defer res.Body.Close()
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status: %d", res.StatusCode)
+	}
+	return nil
+}
+
+// splitFunctionPath splits a request URI such as "kubecon-tester/path?q=1"
+// into the function name and the remainder of the URI.
+func splitFunctionPath(requestURI string) (string, string) {
+	if index := strings.IndexAny(requestURI, "/?"); index > -1 {
+		return requestURI[:index], requestURI[index:]
+	}
+	return requestURI, ""
+}
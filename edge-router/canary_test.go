@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_splitFunctionPath(t *testing.T) {
+	tests := []struct {
+		RequestURI string
+		Function   string
+		Rest       string
+	}{
+		{RequestURI: "dashboard", Function: "dashboard", Rest: ""},
+		{RequestURI: "dashboard/static/app.js", Function: "dashboard", Rest: "/static/app.js"},
+		{RequestURI: "dashboard?user=alexellis", Function: "dashboard", Rest: "?user=alexellis"},
+	}
+
+	for _, test := range tests {
+		function, rest := splitFunctionPath(test.RequestURI)
+		if function != test.Function || rest != test.Rest {
+			t.Errorf("want %s and %s, got %s and %s", test.Function, test.Rest, function, rest)
+		}
+	}
+}
+
+func Test_makeHandler_RoutesToCanary(t *testing.T) {
+	gatewayHandler := &gateway{}
+	gateway := httptest.NewServer(gatewayHandler)
+	defer gateway.Close()
+
+	weights := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"alexellis-always": 100, "alexellis-never": 0}`))
+	}))
+	defer weights.Close()
+
+	canary := &canaryRouter{
+		URL:     weights.URL,
+		Client:  http.DefaultClient,
+		Refresh: time.Minute,
+	}
+	canary.refresh()
+
+	router := httptest.NewServer(passHandler{
+		Next: makeHandler(http.DefaultClient, time.Second*10, gateway.URL, nil, canary),
+	})
+	defer router.Close()
+
+	tests := []struct {
+		Path        string
+		UpstreamURL string
+	}{
+		{Path: "/always/items", UpstreamURL: "/function/alexellis-always-canary/items"},
+		{Path: "/never/items", UpstreamURL: "/function/alexellis-never/items"},
+		{Path: "/other", UpstreamURL: "/function/alexellis-other"},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest(http.MethodGet, router.URL+test.Path, nil)
+		req.Host = "alexellis.example.xyz"
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+
+		if gatewayHandler.RequestURI != test.UpstreamURL {
+			t.Errorf("RequestURI want: %s, got: %s", test.UpstreamURL, gatewayHandler.RequestURI)
+		}
+	}
+}
+
+func Test_canaryRouter_WeightDoesNotWaitForFetch(t *testing.T) {
+	release := make(chan struct{})
+	weights := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte(`{"alexellis-fn1": 20}`))
+	}))
+	defer weights.Close()
+
+	canary := &canaryRouter{
+		URL:     weights.URL,
+		Client:  http.DefaultClient,
+		Refresh: time.Minute,
+	}
+
+	start := time.Now()
+	if weight := canary.Weight("alexellis-fn1"); weight != 0 {
+		t.Errorf("want no weight before the first fetch, got: %d", weight)
+	}
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("want Weight to return without waiting for the fetch, waited: %s", waited)
+	}
+
+	close(release)
+	for i := 0; i < 100 && canary.Weight("alexellis-fn1") == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if weight := canary.Weight("alexellis-fn1"); weight != 20 {
+		t.Errorf("want weight: 20 once fetched, got: %d", weight)
+	}
+}
+
+func Test_canaryRouter_postProgressIsSigned(t *testing.T) {
+	var method, signature string
+	canaryFunction := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		signature = r.Header.Get("X-Cloud-Signature")
+	}))
+	defer canaryFunction.Close()
+
+	canary := &canaryRouter{
+		URL:    canaryFunction.URL,
+		Client: http.DefaultClient,
+	}
+
+	if err := canary.postProgress("secret"); err != nil {
+		t.Fatalf("want no error, got: %s", err.Error())
+	}
+
+	mac := hmac.New(sha1.New, []byte("secret"))
+	want := "sha1=" + hex.EncodeToString(mac.Sum(nil))
+
+	if method != http.MethodPost {
+		t.Errorf("want method: %s, got: %s", http.MethodPost, method)
+	}
+	if signature != want {
+		t.Errorf("want signature: %s, got: %s", want, signature)
+	}
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"io/ioutil"
 	"os"
+	"path"
 	"strconv"
 	"strings"
 	"time"
@@ -13,6 +15,16 @@ type RouterConfig struct {
 	UpstreamURL string
 	AuthURL     string
 	Timeout     time.Duration
+
+	// CanaryURL returns the weights for canaries, routing to canaries
+	// is disabled when empty
+	CanaryURL     string
+	CanaryRefresh time.Duration
+
+	// CanaryProgress is how often canaries are promoted or rolled back,
+	// which needs the payload-secret to sign the request
+	CanaryProgress time.Duration
+	PayloadSecret  string
 }
 
 // NewRouterConfig create a new RouterConfig by loading
@@ -44,6 +56,22 @@ func NewRouterConfig() RouterConfig {
 
 	cfg.Timeout = parseIntOrDurationValue(os.Getenv("timeout"), time.Second*60)
 
+	if val, exists := os.LookupEnv("canary_url"); exists && len(val) > 0 {
+		cfg.CanaryURL = val
+	}
+
+	cfg.CanaryRefresh = parseIntOrDurationValue(os.Getenv("canary_refresh"), time.Second*10)
+	cfg.CanaryProgress = parseIntOrDurationValue(os.Getenv("canary_progress"), time.Minute)
+
+	secretMountPath := "/var/openfaas/secrets/"
+	if val, exists := os.LookupEnv("secret_mount_path"); exists && len(val) > 0 {
+		secretMountPath = val
+	}
+
+	if secret, err := ioutil.ReadFile(path.Join(secretMountPath, "payload-secret")); err == nil {
+		cfg.PayloadSecret = strings.TrimSpace(string(secret))
+	}
+
 	return cfg
 }
 
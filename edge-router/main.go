@@ -38,8 +38,25 @@ func main() {
 		Client: proxyClient,
 	}
 
+	var canary *canaryRouter
+	if len(cfg.CanaryURL) > 0 {
+		log.Printf("Canary URL: %s\n", cfg.CanaryURL)
+
+		canary = &canaryRouter{
+			URL:     cfg.CanaryURL,
+			Client:  proxyClient,
+			Refresh: cfg.CanaryRefresh,
+		}
+
+		if len(cfg.PayloadSecret) > 0 {
+			go canary.progress(cfg.PayloadSecret, cfg.CanaryProgress)
+		} else {
+			log.Printf("No payload-secret found, canaries will not be promoted or rolled back\n")
+		}
+	}
+
 	router := http.NewServeMux()
-	router.HandleFunc("/", makeHandler(proxyClient, cfg.Timeout, cfg.UpstreamURL, &authProxy1))
+	router.HandleFunc("/", makeHandler(proxyClient, cfg.Timeout, cfg.UpstreamURL, &authProxy1, canary))
 	router.HandleFunc("/healthz", makeHealthzHandler())
 
 	log.Printf("Using port %s\n", cfg.Port)
@@ -60,7 +77,8 @@ func main() {
 // i.e. system.o6s.io/dashboard
 //      becomes: gateway:8080/function/system-dashboard, where gateway:8080
 //      is specified in upstreamURL
-func makeHandler(c *http.Client, timeout time.Duration, upstreamURL string, auth *authProxy) func(w http.ResponseWriter, r *http.Request) {
+// When canary is set, a share of the requests for a function is sent to its canary.
+func makeHandler(c *http.Client, timeout time.Duration, upstreamURL string, auth *authProxy, canary *canaryRouter) func(w http.ResponseWriter, r *http.Request) {
 
 	if strings.HasSuffix(upstreamURL, "/") == false {
 		upstreamURL = upstreamURL + "/"
@@ -177,6 +195,15 @@ func makeHandler(c *http.Client, timeout time.Duration, upstreamURL string, auth
 			}
 		}
 
+		if canary != nil && !isAuthHost {
+			function, rest := splitFunctionPath(requestURI)
+			functionName := fmt.Sprintf("%s-%s", host, function)
+
+			if routed := canary.Route(functionName); routed != functionName {
+				upstreamFullURL, _ = url.Parse(fmt.Sprintf("%sfunction/%s%s", upstreamURL, routed, rest))
+			}
+		}
+
 		req, _ := http.NewRequest(r.Method, upstreamFullURL.String(), r.Body)
 
 		timeoutContext, cancel := context.WithTimeout(context.Background(), timeout)
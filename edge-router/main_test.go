@@ -67,7 +67,7 @@ func Test_makeHandler(t *testing.T) {
 	}
 
 	router := httptest.NewServer(passHandler{
-		Next: makeHandler(http.DefaultClient, time.Second*10, gateway.URL, nil),
+		Next: makeHandler(http.DefaultClient, time.Second*10, gateway.URL, nil, nil),
 	})
 
 	defer router.Close()
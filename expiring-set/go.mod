@@ -0,0 +1,11 @@
+module github.com/openfaas/openfaas-cloud/expiring-set
+
+go 1.13
+
+require (
+	github.com/alexellis/derek v0.0.0-20200824120721-b453a7326b67
+	github.com/alexellis/hmac v0.0.0-20180624211220-5c52ab81c0de
+	github.com/dgrijalva/jwt-go v3.2.0+incompatible
+	github.com/minio/minio-go v6.0.14+incompatible
+	github.com/openfaas/openfaas-cloud/sdk v0.0.0-20201205095205-64e6798b27b1
+)
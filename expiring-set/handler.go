@@ -0,0 +1,254 @@
+package function
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"time"
+
+	minio "github.com/minio/minio-go"
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+var validSetName = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// setRecord is stored for each key, Nonce tells apart two requests which
+// add the same key at the same time
+type setRecord struct {
+	Expires time.Time `json:"expires"`
+	Nonce   string    `json:"nonce"`
+}
+
+// Handle adds, looks up or removes a key of a set which is kept in the S3
+// bucket for a TTL, so that every replica of a function sees the same keys.
+// Requests must be signed with the payload-secret.
+func Handle(req []byte) string {
+	if os.Getenv("Http_Method") != http.MethodPost {
+		log.Printf("only POST is supported")
+		os.Exit(1)
+	}
+
+	hmacErr := sdk.ValidHMAC(&req, "payload-secret", os.Getenv("Http_X_Cloud_Signature"))
+	if hmacErr != nil {
+		log.Printf("hmac error %s\n", hmacErr.Error())
+		os.Exit(1)
+	}
+
+	setReq := sdk.SetRequest{}
+	if err := json.Unmarshal(req, &setReq); err != nil {
+		log.Printf("unable to parse request: %s", err.Error())
+		os.Exit(1)
+	}
+
+	ttl, err := validateRequest(&setReq)
+	if err != nil {
+		log.Printf("invalid request: %s", err.Error())
+		os.Exit(1)
+	}
+
+	region := regionName()
+	bucketName := bucketName()
+
+	minioClient, connectErr := connectToMinio(region)
+	if connectErr != nil {
+		log.Printf("S3/Minio connection error %s\n", connectErr.Error())
+		os.Exit(1)
+	}
+
+	store := &setStore{client: minioClient, bucket: bucketName}
+	path := getPath(setReq.Set, setReq.Key)
+	now := time.Now()
+
+	var member bool
+	switch setReq.Action {
+	case sdk.SetActionContains:
+		member = store.contains(path, now)
+
+	case sdk.SetActionAdd:
+		minioClient.MakeBucket(bucketName, region)
+
+		member, err = store.add(path, now, ttl)
+		if err != nil {
+			log.Printf("unable to add key to %s: %s", setReq.Set, err.Error())
+			os.Exit(1)
+		}
+		store.prune(getPrefix(setReq.Set), now, ttl)
+
+	case sdk.SetActionRemove:
+		member = store.contains(path, now)
+		if err := minioClient.RemoveObject(bucketName, path); err != nil {
+			log.Printf("unable to remove key from %s: %s", setReq.Set, err.Error())
+			os.Exit(1)
+		}
+
+	default:
+		log.Printf("unknown action: %s", setReq.Action)
+		os.Exit(1)
+	}
+
+	bytesOut, _ := json.Marshal(sdk.SetResponse{Member: member})
+	return string(bytesOut)
+}
+
+// validateRequest checks the name of the set and the key and returns the
+// TTL of the set
+func validateRequest(setReq *sdk.SetRequest) (time.Duration, error) {
+	if !validSetName.MatchString(setReq.Set) {
+		return 0, fmt.Errorf("set name must only use a-z, 0-9 and -, got: %q", setReq.Set)
+	}
+
+	if len(setReq.Key) == 0 {
+		return 0, fmt.Errorf("key is required")
+	}
+
+	ttl, err := time.ParseDuration(setReq.TTL)
+	if err != nil || ttl <= 0 {
+		return 0, fmt.Errorf("a positive ttl is required, got: %q", setReq.TTL)
+	}
+	return ttl, nil
+}
+
+type setStore struct {
+	client *minio.Client
+	bucket string
+}
+
+// contains is true when the key was added and has not expired
+func (s *setStore) contains(path string, now time.Time) bool {
+	record, err := s.get(path)
+	return err == nil && !expired(record, now)
+}
+
+// add stores the key unless it is already in the set. S3 has no atomic
+// create, so the record is read back and a request which finds the nonce
+// of another request which wrote the key at the same time reports it as
+// a member.
+func (s *setStore) add(path string, now time.Time, ttl time.Duration) (bool, error) {
+	if s.contains(path, now) {
+		return true, nil
+	}
+
+	record := setRecord{Expires: now.Add(ttl), Nonce: newNonce()}
+	bytesOut, _ := json.Marshal(&record)
+	reader := bytes.NewReader(bytesOut)
+
+	_, err := s.client.PutObject(s.bucket,
+		path,
+		reader,
+		int64(reader.Len()),
+		minio.PutObjectOptions{ContentType: "application/json"})
+	if err != nil {
+		return false, err
+	}
+
+	stored, err := s.get(path)
+	if err != nil {
+		return false, err
+	}
+	return stored.Nonce != record.Nonce, nil
+}
+
+func (s *setStore) get(path string) (*setRecord, error) {
+	obj, err := s.client.GetObject(s.bucket, path, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+
+	data, err := ioutil.ReadAll(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	record := setRecord{}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// prune removes keys of the set which were written more than ttl ago, so
+// that the bucket doesn't keep growing
+func (s *setStore) prune(prefix string, now time.Time, ttl time.Duration) {
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+
+	for obj := range s.client.ListObjectsV2(s.bucket, prefix, true, doneCh) {
+		if obj.Err != nil {
+			log.Printf("unable to list %s: %s", prefix, obj.Err.Error())
+			return
+		}
+
+		if obj.LastModified.Add(ttl).Before(now) {
+			s.client.RemoveObject(s.bucket, obj.Key)
+		}
+	}
+}
+
+// expired is true once the record is past its expiry, a record without one
+// counts as expired
+func expired(record *setRecord, now time.Time) bool {
+	return !now.Before(record.Expires)
+}
+
+// getPrefix produces a string such as sets/deliveries/
+func getPrefix(set string) string {
+	return fmt.Sprintf("sets/%s/", set)
+}
+
+// getPath hashes the key so that any key can be used in the path of the
+// object, such as sets/deliveries/<sha256 of key>
+func getPath(set, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return getPrefix(set) + hex.EncodeToString(sum[:])
+}
+
+func newNonce() string {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	return hex.EncodeToString(nonce)
+}
+
+func connectToMinio(region string) (*minio.Client, error) {
+
+	endpoint := os.Getenv("s3_url")
+
+	tlsEnabled := tlsEnabled()
+
+	secretKey, _ := sdk.ReadSecret("s3-secret-key")
+	accessKey, _ := sdk.ReadSecret("s3-access-key")
+
+	return minio.New(endpoint, accessKey, secretKey, tlsEnabled)
+}
+
+func tlsEnabled() bool {
+	if connection := os.Getenv("s3_tls"); connection == "true" || connection == "1" {
+		return true
+	}
+	return false
+}
+
+func bucketName() string {
+	bucketName, exist := os.LookupEnv("s3_bucket")
+	if exist == false || len(bucketName) == 0 {
+		bucketName = "pipeline"
+		log.Printf("Bucket name not found, set to default: %v\n", bucketName)
+	}
+	return bucketName
+}
+
+func regionName() string {
+	regionName, exist := os.LookupEnv("s3_region")
+	if exist == false || len(regionName) == 0 {
+		regionName = "us-east-1"
+	}
+	return regionName
+}
@@ -0,0 +1,56 @@
+package function
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+func Test_getPath(t *testing.T) {
+	got := getPath("deliveries", "72d3162e-cc78-11e3-81ab-4c9367dc0958")
+	if !strings.HasPrefix(got, "sets/deliveries/") || len(got) != len("sets/deliveries/")+64 {
+		t.Errorf("want a hashed key under sets/deliveries/, got: %s", got)
+	}
+
+	if getPath("deliveries", "a") == getPath("builds-done", "a") {
+		t.Errorf("want the same key in different sets to be stored apart")
+	}
+}
+
+func Test_validateRequest(t *testing.T) {
+	ttl, err := validateRequest(&sdk.SetRequest{Action: sdk.SetActionAdd, Set: "builds-in-progress", Key: "alexellis/fn@af6db:", TTL: "15m0s"})
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err.Error())
+	}
+	if ttl != 15*time.Minute {
+		t.Errorf("want ttl: 15m, got: %s", ttl)
+	}
+
+	invalid := []sdk.SetRequest{
+		{Set: "../gates", Key: "a", TTL: "1h"},
+		{Set: "deliveries", TTL: "1h"},
+		{Set: "deliveries", Key: "a"},
+		{Set: "deliveries", Key: "a", TTL: "-1h"},
+	}
+	for _, setReq := range invalid {
+		if _, err := validateRequest(&setReq); err == nil {
+			t.Errorf("want error for %v", setReq)
+		}
+	}
+}
+
+func Test_expired(t *testing.T) {
+	now := time.Now()
+
+	if expired(&setRecord{Expires: now.Add(time.Minute)}, now) {
+		t.Errorf("want record before expiry to be kept")
+	}
+	if !expired(&setRecord{Expires: now}, now) {
+		t.Errorf("want record at expiry to have expired")
+	}
+	if !expired(&setRecord{}, now) {
+		t.Errorf("want record without expiry to have expired")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -70,8 +71,7 @@ func Handle(req []byte) string {
 	client := faasSDK.NewClient(&FaaSAuth{}, gatewayURL, nil, &timeout)
 	deleted := 0
 	for _, fn := range deployedFunctions {
-		if garbageReq.Repo == "*" ||
-			(fn.GetRepo() == garbageReq.Repo && !included(&fn, owner, garbageReq.Functions)) {
+		if shouldDelete(&fn, &garbageReq) {
 			log.Printf("Delete: %s\n", fn.Name)
 			err = client.DeleteFunction(context.Background(), fn.Name, namespace)
 			if err != nil {
@@ -113,14 +113,46 @@ func validateRequestSigning(req []byte) (err error) {
 	return nil
 }
 
+// shouldDelete is true when the function was deployed from the repo in the
+// request and is no longer in its stack. The IDs in the request, when
+// given, must also match so that functions of another account or repo
+// which have since taken the name are kept.
+func shouldDelete(fn *openFaaSFunction, garbageReq *GarbageRequest) bool {
+	if !labelMatches(fn.Labels[sdk.FunctionLabelPrefix+"git-owner-id"], garbageReq.OwnerID) {
+		return false
+	}
+
+	if garbageReq.Repo == "*" {
+		return true
+	}
+
+	return fn.GetRepo() == garbageReq.Repo &&
+		labelMatches(fn.Labels[sdk.FunctionLabelPrefix+"git-repo-id"], garbageReq.RepoID) &&
+		!included(fn, garbageReq.Owner, garbageReq.Functions)
+}
+
+// labelMatches is true when the label holds the ID, or when either is not
+// known such as for functions deployed before IDs were recorded
+func labelMatches(label string, id int64) bool {
+	if id == 0 || len(label) == 0 || label == "0" {
+		return true
+	}
+	return label == strconv.FormatInt(id, 10)
+}
+
 func formatCloudName(name, owner string) string {
 	return owner + "-" + name
 }
 
 func included(fn *openFaaSFunction, owner string, functionStack []string) bool {
+	// A canary is kept for as long as the function it will replace
+	fnName := fn.Name
+	if canaryOf, ok := fn.Labels[sdk.CanaryOfLabel]; ok && len(canaryOf) > 0 {
+		fnName = canaryOf
+	}
 
 	for _, name := range functionStack {
-		if strings.EqualFold(formatCloudName(name, owner), fn.Name) {
+		if strings.EqualFold(formatCloudName(name, owner), fnName) {
 			return true
 		}
 	}
@@ -162,6 +194,8 @@ type GarbageRequest struct {
 	Functions []string `json:"functions"`
 	Repo      string   `json:"repo"`
 	Owner     string   `json:"owner"`
+	RepoID    int64    `json:"repo_id,omitempty"`
+	OwnerID   int64    `json:"owner_id,omitempty"`
 }
 
 type openFaaSFunction struct {
@@ -0,0 +1,79 @@
+package function
+
+import (
+	"testing"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+func Test_shouldDelete(t *testing.T) {
+	function := func(repo, repoID, ownerID string) *openFaaSFunction {
+		return &openFaaSFunction{
+			Name: "alexellis-fn1",
+			Labels: map[string]string{
+				sdk.FunctionLabelPrefix + "git-owner":    "alexellis",
+				sdk.FunctionLabelPrefix + "git-repo":     repo,
+				sdk.FunctionLabelPrefix + "git-repo-id":  repoID,
+				sdk.FunctionLabelPrefix + "git-owner-id": ownerID,
+			},
+		}
+	}
+
+	tests := []struct {
+		title string
+		fn    *openFaaSFunction
+		req   GarbageRequest
+		want  bool
+	}{
+		{
+			title: "function removed from stack",
+			fn:    function("alexa-skill", "", ""),
+			req:   GarbageRequest{Owner: "alexellis", Repo: "alexa-skill", Functions: []string{"fn2"}},
+			want:  true,
+		},
+		{
+			title: "function still in stack",
+			fn:    function("alexa-skill", "", ""),
+			req:   GarbageRequest{Owner: "alexellis", Repo: "alexa-skill", Functions: []string{"fn1"}},
+			want:  false,
+		},
+		{
+			title: "function of another repo",
+			fn:    function("other", "", ""),
+			req:   GarbageRequest{Owner: "alexellis", Repo: "alexa-skill"},
+			want:  false,
+		},
+		{
+			title: "renamed repo with matching ID",
+			fn:    function("alexa-skill", "7", "3"),
+			req:   GarbageRequest{Owner: "alexellis", Repo: "alexa-skill", RepoID: 7, OwnerID: 3},
+			want:  true,
+		},
+		{
+			title: "another repo which took the name",
+			fn:    function("alexa-skill", "8", "3"),
+			req:   GarbageRequest{Owner: "alexellis", Repo: "alexa-skill", RepoID: 7, OwnerID: 3},
+			want:  false,
+		},
+		{
+			title: "another account which took the name",
+			fn:    function("alexa-skill", "7", "4"),
+			req:   GarbageRequest{Owner: "alexellis", Repo: "*", OwnerID: 3},
+			want:  false,
+		},
+		{
+			title: "function deployed before IDs were recorded",
+			fn:    function("alexa-skill", "", "0"),
+			req:   GarbageRequest{Owner: "alexellis", Repo: "alexa-skill", RepoID: 7, OwnerID: 3},
+			want:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			if got := shouldDelete(test.fn, &test.req); got != test.want {
+				t.Errorf("want %t, got %t", test.want, got)
+			}
+		})
+	}
+}
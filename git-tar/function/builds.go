@@ -0,0 +1,191 @@
+package function
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+const (
+	// defaultBuildTimeout is how long a build is taken to be in progress,
+	// after which the same commit can be built again
+	defaultBuildTimeout = 15 * time.Minute
+
+	// defaultBuildRecordTTL is how long a completed build is remembered
+	defaultBuildRecordTTL = 24 * time.Hour
+)
+
+// buildRecords remembers which commits are being built or were built, so
+// that a push which is delivered more than once is only built once by any
+// replica of git-tar
+type buildRecords struct {
+	inProgress sdk.KeySet
+	done       sdk.KeySet
+	gatewayURL string
+}
+
+func newBuildRecords(gatewayURL string) *buildRecords {
+	return &buildRecords{
+		gatewayURL: gatewayURL,
+		inProgress: sdk.NewSharedSet(gatewayURL, "builds-in-progress", getDuration("build_timeout", defaultBuildTimeout)),
+		done:       sdk.NewSharedSet(gatewayURL, "builds-done", getDuration("build_record_ttl", defaultBuildRecordTTL)),
+	}
+}
+
+// start records that the commit is being built. When the commit is
+// already being built or was built, unless a rebuild was asked for, it
+// returns false with the state to report for the stack and why.
+func (b *buildRecords) start(pushEvent sdk.PushEvent) (bool, string, string) {
+	key := getBuildKey(pushEvent)
+
+	if pushEvent.Rebuild {
+		b.inProgress.Remove(key)
+		b.done.Remove(key)
+	}
+
+	if b.done.Contains(key) {
+		if b.isLatestDeploy(pushEvent) {
+			return false, sdk.StatusSuccess, fmt.Sprintf("Already built: %s", key)
+		}
+
+		// A newer commit was deployed since, such as before a force-push
+		// back to this one
+		b.done.Remove(key)
+	}
+
+	started, err := b.inProgress.Add(key)
+	if err != nil {
+		log.Printf("unable to record build of %s: %s", key, err.Error())
+		return true, "", ""
+	}
+
+	if !started {
+		// The build in progress reports the result
+		return false, sdk.StatusPending, fmt.Sprintf("Already being built: %s", key)
+	}
+	return true, "", ""
+}
+
+// complete records that the commit was built
+func (b *buildRecords) complete(pushEvent sdk.PushEvent) {
+	key := getBuildKey(pushEvent)
+
+	if _, err := b.done.Add(key); err != nil {
+		log.Printf("unable to record build of %s: %s", key, err.Error())
+	}
+	b.inProgress.Remove(key)
+}
+
+// abort forgets a build which failed so that the commit can be built again
+// without waiting for build_timeout
+func (b *buildRecords) abort(pushEvent sdk.PushEvent) {
+	key := getBuildKey(pushEvent)
+
+	if err := b.inProgress.Remove(key); err != nil {
+		log.Printf("unable to forget build of %s: %s", key, err.Error())
+	}
+}
+
+// isLatestDeploy is true when the functions of the repo which were deployed
+// last were built from the commit, it is false when they can't be listed
+func (b *buildRecords) isLatestDeploy(pushEvent sdk.PushEvent) bool {
+	owner := pushEvent.Repository.Owner.Login
+	res, err := http.Get(b.gatewayURL + "function/list-functions?user=" + url.QueryEscape(owner))
+	if err != nil {
+		log.Printf("unable to list functions for %s: %s", owner, err.Error())
+		return false
+	}
+	defer res.Body.Close()
+
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		log.Printf("unable to list functions for %s: %d, %s", owner, res.StatusCode, string(body))
+		return false
+	}
+
+	functions := []struct {
+		Labels map[string]string `json:"labels"`
+	}{}
+	if err := json.Unmarshal(body, &functions); err != nil {
+		log.Printf("unable to parse functions for %s: %s", owner, err.Error())
+		return false
+	}
+
+	latestSHA := ""
+	latestTime := int64(-1)
+	for _, function := range functions {
+		if !strings.EqualFold(function.Labels[sdk.FunctionLabelPrefix+"git-repo"], pushEvent.Repository.Name) {
+			continue
+		}
+
+		deployTime, _ := strconv.ParseInt(function.Labels[sdk.FunctionLabelPrefix+"git-deploytime"], 10, 64)
+		if deployTime > latestTime {
+			latestTime = deployTime
+			latestSHA = function.Labels[sdk.FunctionLabelPrefix+"git-sha"]
+		}
+	}
+
+	return len(latestSHA) > 0 && latestSHA == pushEvent.AfterCommitID
+}
+
+// getBuildKey identifies the build of a commit to an environment
+func getBuildKey(pushEvent sdk.PushEvent) string {
+	ref := sdk.NewBuildRef(pushEvent.Repository.Owner.Login, pushEvent.Repository.Name, pushEvent.AfterCommitID)
+	return fmt.Sprintf("%s/%s@%s:%s", ref.Owner, ref.Repo, ref.SHA, ref.Environment)
+}
+
+func getDuration(key string, fallback time.Duration) time.Duration {
+	if val, err := time.ParseDuration(os.Getenv(key)); err == nil && val > 0 {
+		return val
+	}
+	return fallback
+}
+
+// errSuperseded is returned when a newer commit of the repo was pushed while
+// the commit was being built
+var errSuperseded = fmt.Errorf("superseded by a newer commit")
+
+// getSupersedingSHA asks the of-builder for a newer commit of the repo, the
+// commit is built when the of-builder can't be reached
+func getSupersedingSHA(pushEvent sdk.PushEvent) string {
+	ref := sdk.NewBuildRef(pushEvent.Repository.Owner.Login, pushEvent.Repository.Name, pushEvent.AfterCommitID)
+
+	newerSHA, err := sdk.GetSupersedingSHA(os.Getenv("builder_url"), ref)
+	if err != nil {
+		log.Printf("unable to find the newest commit of %s/%s: %s", ref.Owner, ref.Repo, err.Error())
+		return ""
+	}
+	return newerSHA
+}
+
+// reportSuperseded reports that the stack of the commit won't be deployed
+// as a newer commit was pushed
+func reportSuperseded(status *sdk.Status, pushEvent sdk.PushEvent, newerSHA string) string {
+	msg := "superseded by a newer commit"
+	if len(newerSHA) > 0 {
+		msg = fmt.Sprintf("superseded by %s", sdk.FormatShortSHA(newerSHA))
+	}
+	log.Println(msg)
+
+	status.AddStatus(sdk.StatusSuperseded, msg, sdk.StackContext)
+	if statusErr := reportStatus(status, pushEvent.SCM); statusErr != nil {
+		log.Printf(statusErr.Error())
+	}
+
+	sdk.PostAudit(sdk.AuditEvent{
+		Message: fmt.Sprintf("%s@%s %s", pushEvent.Repository.FullName, sdk.FormatShortSHA(pushEvent.AfterCommitID), msg),
+		Owner:   pushEvent.Repository.Owner.Login,
+		Repo:    pushEvent.Repository.Name,
+		Source:  Source,
+	})
+	return msg
+}
@@ -56,7 +56,33 @@ func Handle(req []byte) []byte {
 	}
 
 	statusEvent := sdk.BuildEventFromPushEvent(pushEvent)
-	status := sdk.BuildStatus(statusEvent, sdk.EmptyAuthToken)
+	status := sdk.BuildStatus(statusEvent, pushEvent.AuthToken)
+
+	// Pushes can be delivered more than once, a commit is only built again
+	// when a rebuild is asked for. github-push and gitlab-push have already
+	// reported the stack as pending, so the skip is reported too.
+	builds := newBuildRecords(os.Getenv("gateway_url"))
+	if started, state, msg := builds.start(pushEvent); !started {
+		log.Println(msg)
+
+		status.AddStatus(state, msg, sdk.StackContext)
+		if statusErr := reportStatus(status, pushEvent.SCM); statusErr != nil {
+			log.Printf(statusErr.Error())
+		}
+		return []byte(msg + "\n")
+	}
+
+	// A failed build is forgotten so that the commit can be built again
+	exit := func(code int) {
+		builds.abort(pushEvent)
+		os.Exit(code)
+	}
+
+	// A commit which was queued behind a newer commit of the repo isn't built
+	if newerSHA := getSupersedingSHA(pushEvent); len(newerSHA) > 0 {
+		builds.abort(pushEvent)
+		return []byte(reportSuperseded(status, pushEvent, newerSHA) + "\n")
+	}
 
 	hasStackFile, getStackFileErr := findStackFile(&pushEvent)
 
@@ -70,7 +96,7 @@ func Handle(req []byte) []byte {
 			log.Printf(statusErr.Error())
 		}
 
-		os.Exit(-1)
+		exit(-1)
 	}
 
 	if !hasStackFile {
@@ -91,23 +117,29 @@ func Handle(req []byte) []byte {
 		}
 		sdk.PostAudit(auditEvent)
 
-		os.Exit(-1)
+		exit(-1)
 	}
 
 	fetcher := GitRepoFetcher{}
+	cloneStage := sdk.StartStage(sdk.StageClone)
 	clonePath, err := clone(fetcher, pushEvent)
+	cloneStage = cloneStage.Complete()
 	if err != nil {
 		msg := fmt.Sprintf("error cloning repo: %s ", err.Error())
 		log.Println(msg)
+		status.AddStageStatus(sdk.StatusFailure, cloneStage.Description(sdk.StatusFailure), sdk.StackContext, cloneStage)
 		status.AddStatus(sdk.StatusFailure, msg, sdk.StackContext)
 
 		statusErr := reportStatus(status, pushEvent.SCM)
 		if statusErr != nil {
 			log.Printf(statusErr.Error())
 		}
-		os.Exit(-1)
+		exit(-1)
 	}
 
+	// The stage statuses are sent with the next status to be reported
+	status.AddStageStatus(sdk.StatusSuccess, cloneStage.Description(sdk.StatusSuccess), sdk.StackContext, cloneStage)
+
 	if _, err := os.Stat(path.Join(clonePath, "template")); err == nil {
 		msg := `unsupported custom "templates" folder`
 		log.Println(msg)
@@ -116,7 +148,7 @@ func Handle(req []byte) []byte {
 		if statusErr != nil {
 			log.Printf(statusErr.Error())
 		}
-		os.Exit(-1)
+		exit(-1)
 	}
 
 	stack, err := parseYAML(clonePath)
@@ -127,7 +159,30 @@ func Handle(req []byte) []byte {
 		if statusErr != nil {
 			log.Printf(statusErr.Error())
 		}
-		os.Exit(-1)
+		exit(-1)
+	}
+
+	if err := applySmokeTests(clonePath, stack); err != nil {
+		log.Println(err.Error())
+		status.AddStatus(sdk.StatusFailure, err.Error(), sdk.StackContext)
+		statusErr := reportStatus(status, pushEvent.SCM)
+		if statusErr != nil {
+			log.Printf(statusErr.Error())
+		}
+		exit(-1)
+	}
+
+	// Only the functions which were asked for are built, the whole stack is
+	// still used for garbage collection so that other functions are kept
+	built, err := selectFunctions(stack, pushEvent.Functions)
+	if err != nil {
+		log.Println(err.Error())
+		status.AddStatus(sdk.StatusFailure, err.Error(), sdk.StackContext)
+		statusErr := reportStatus(status, pushEvent.SCM)
+		if statusErr != nil {
+			log.Printf(statusErr.Error())
+		}
+		exit(-1)
 	}
 
 	if hasDockerfileFunction(stack.Functions) && !isDockerfileEnabled() {
@@ -136,20 +191,23 @@ func Handle(req []byte) []byte {
 		if statusErr != nil {
 			log.Printf(statusErr.Error())
 		}
-		os.Exit(1)
+		exit(1)
 	}
 
+	templatesStage := sdk.StartStage(sdk.StageTemplates)
 	err = fetchTemplates(clonePath)
+	templatesStage = templatesStage.Complete()
 	if err != nil {
 		msg := fmt.Sprintf("error fetching templates: %s", err.Error())
 		log.Println(msg)
 
+		status.AddStageStatus(sdk.StatusFailure, templatesStage.Description(sdk.StatusFailure), sdk.StackContext, templatesStage)
 		status.AddStatus(sdk.StatusFailure, msg, sdk.StackContext)
 		statusErr := reportStatus(status, pushEvent.SCM)
 		if statusErr != nil {
 			log.Printf(statusErr.Error())
 		}
-		os.Exit(-1)
+		exit(-1)
 	}
 
 	err = checkCompatibleTemplates(stack, clonePath)
@@ -157,42 +215,52 @@ func Handle(req []byte) []byte {
 		msg := fmt.Sprintf("missing language template: %s", err.Error())
 		log.Println(msg)
 
+		status.AddStageStatus(sdk.StatusFailure, templatesStage.Description(sdk.StatusFailure), sdk.StackContext, templatesStage)
 		status.AddStatus(sdk.StatusFailure, msg, sdk.StackContext)
 		statusErr := reportStatus(status, pushEvent.SCM)
 		if statusErr != nil {
 			log.Printf(statusErr.Error())
 		}
-		os.Exit(-1)
+		exit(-1)
 	}
 
+	status.AddStageStatus(sdk.StatusSuccess, templatesStage.Description(sdk.StatusSuccess), sdk.StackContext, templatesStage)
+
+	shrinkwrapStage := sdk.StartStage(sdk.StageShrinkwrap)
 	var shrinkWrapPath string
 	shrinkWrapPath, err = shrinkwrap(clonePath)
 	if err != nil {
 		msg := fmt.Sprintf("cannot shrinkwrap: %s", err.Error())
 		log.Println(msg)
 
+		shrinkwrapStage = shrinkwrapStage.Complete()
+		status.AddStageStatus(sdk.StatusFailure, shrinkwrapStage.Description(sdk.StatusFailure), sdk.StackContext, shrinkwrapStage)
 		status.AddStatus(sdk.StatusFailure, msg, sdk.StackContext)
 		statusErr := reportStatus(status, pushEvent.SCM)
 		if statusErr != nil {
 			log.Printf(statusErr.Error())
 		}
-		os.Exit(-1)
+		exit(-1)
 	}
 
 	var tars []tarEntry
-	tars, err = makeTar(pushEvent, shrinkWrapPath, stack)
+	tars, err = makeTar(pushEvent, shrinkWrapPath, built)
+	shrinkwrapStage = shrinkwrapStage.Complete()
 	if err != nil {
 		msg := fmt.Sprintf("cannot create tar(s): %s", err.Error())
 		log.Println(msg)
 
+		status.AddStageStatus(sdk.StatusFailure, shrinkwrapStage.Description(sdk.StatusFailure), sdk.StackContext, shrinkwrapStage)
 		status.AddStatus(sdk.StatusFailure, msg, sdk.StackContext)
 		statusErr := reportStatus(status, pushEvent.SCM)
 		if statusErr != nil {
 			log.Printf(statusErr.Error())
 		}
-		os.Exit(-1)
+		exit(-1)
 	}
 
+	status.AddStageStatus(sdk.StatusSuccess, shrinkwrapStage.Description(sdk.StatusSuccess), sdk.StackContext, shrinkwrapStage)
+
 	err = importSecrets(pushEvent, stack, clonePath)
 	if err != nil {
 		msg := fmt.Sprintf("cannot parse secrets: %s", err.Error())
@@ -203,10 +271,15 @@ func Handle(req []byte) []byte {
 		if statusErr != nil {
 			log.Printf(statusErr.Error())
 		}
-		os.Exit(-1)
+		exit(-1)
+	}
+
+	err = deploy(tars, pushEvent, built, status, payloadSecret)
+	if err == errSuperseded {
+		builds.abort(pushEvent)
+		return []byte(reportSuperseded(status, pushEvent, getSupersedingSHA(pushEvent)) + "\n")
 	}
 
-	err = deploy(tars, pushEvent, stack, status, payloadSecret)
 	if err != nil {
 		msg := fmt.Sprintf("deploy failed: %s", err.Error())
 		log.Println(msg)
@@ -218,7 +291,7 @@ func Handle(req []byte) []byte {
 			log.Printf(statusErr.Error())
 		}
 
-		os.Exit(-1)
+		exit(-1)
 	}
 
 	status.AddStatus(sdk.StatusSuccess, "stack is successfully deployed", sdk.StackContext)
@@ -227,9 +300,17 @@ func Handle(req []byte) []byte {
 		log.Printf(statusErr.Error())
 	}
 
-	err = garbageCollect(pushEvent, stack)
-	if err != nil {
-		log.Printf("garbage-collect error: %s", err)
+	builds.complete(pushEvent)
+
+	// The newer commit removes the functions it no longer has once it is
+	// deployed, functions it added must not be removed for this commit
+	if newerSHA := getSupersedingSHA(pushEvent); len(newerSHA) > 0 {
+		log.Printf("skipping garbage-collect, superseded by %s", newerSHA)
+	} else {
+		err = garbageCollect(pushEvent, stack)
+		if err != nil {
+			log.Printf("garbage-collect error: %s", err)
+		}
 	}
 
 	completed := time.Since(start)
@@ -253,19 +334,54 @@ func Handle(req []byte) []byte {
 }
 
 func garbageCollect(pushEvent sdk.PushEvent, stack *stack.Services) error {
-	var err error
-
-	gatewayURL := os.Getenv("gateway_url")
-
-	garbageReq := GarbageRequest{
-		Owner: pushEvent.Repository.Owner.Login,
-		Repo:  pushEvent.Repository.Name,
+	for _, garbageReq := range getGarbageRequests(pushEvent, stack) {
+		if err := postGarbageRequest(garbageReq); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
+// getGarbageRequests removes functions which are no longer in the stack
+// and, after a rename or transfer, the functions deployed from where the
+// repo used to be
+func getGarbageRequests(pushEvent sdk.PushEvent, stack *stack.Services) []GarbageRequest {
+	functions := []string{}
 	for k := range stack.Functions {
-		garbageReq.Functions = append(garbageReq.Functions, k)
+		functions = append(functions, k)
+	}
+
+	garbageReqs := []GarbageRequest{{
+		Owner:     pushEvent.Repository.Owner.Login,
+		Repo:      pushEvent.Repository.Name,
+		Functions: functions,
+		RepoID:    pushEvent.Repository.ID,
+		OwnerID:   pushEvent.Repository.Owner.ID,
+	}}
+
+	if moved := pushEvent.MovedFrom; moved != nil {
+		movedReq := GarbageRequest{
+			Owner:   moved.Owner,
+			Repo:    moved.Repo,
+			RepoID:  pushEvent.Repository.ID,
+			OwnerID: moved.OwnerID,
+		}
+
+		// Names of functions only change with the owner
+		if strings.EqualFold(moved.Owner, pushEvent.Repository.Owner.Login) {
+			movedReq.Functions = functions
+		}
+		garbageReqs = append(garbageReqs, movedReq)
 	}
 
+	return garbageReqs
+}
+
+func postGarbageRequest(garbageReq GarbageRequest) error {
+	var err error
+
+	gatewayURL := os.Getenv("gateway_url")
+
 	bytesReq, _ := json.Marshal(garbageReq)
 	bufferReader := bytes.NewBuffer(bytesReq)
 
@@ -301,6 +417,8 @@ type GarbageRequest struct {
 	Functions []string `json:"functions"`
 	Repo      string   `json:"repo"`
 	Owner     string   `json:"owner"`
+	RepoID    int64    `json:"repo_id,omitempty"`
+	OwnerID   int64    `json:"owner_id,omitempty"`
 }
 
 func enableStatusReporting() bool {
@@ -363,7 +481,7 @@ func getRawURL(scm string, repositoryURL string, repositoryOwnerLogin string, re
 	rawURL := ""
 	switch scm {
 	case GitHub:
-		rawURL = fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s/stack.yml", repositoryOwnerLogin, repositoryName, buildBranch())
+		rawURL = sdk.GetGitHubURLs().RawFileURL(repositoryOwnerLogin, repositoryName, buildBranch(), "stack.yml")
 	case GitLab:
 		rawURL = fmt.Sprintf("%s/raw/%s/stack.yml", repositoryURL, buildBranch())
 	}
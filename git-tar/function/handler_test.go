@@ -1,9 +1,16 @@
 package function
 
 import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/openfaas/faas-cli/stack"
+	"github.com/openfaas/openfaas-cloud/sdk"
 )
 
 func Test_getRawURL(t *testing.T) {
@@ -54,6 +61,17 @@ func Test_getRawURL(t *testing.T) {
 
 }
 
+func Test_getRawURL_EnterpriseServer(t *testing.T) {
+	os.Setenv("github_url", "https://github.example.com")
+	defer os.Unsetenv("github_url")
+
+	want := "https://github.example.com/raw/myuser/myrepo/master/stack.yml"
+	addr, _ := getRawURL("github", "https://github.example.com/myuser/myrepo", "myuser", "myrepo")
+	if addr != want {
+		t.Errorf("Want \"%s\", got \"%s\"", want, addr)
+	}
+}
+
 func Test_hasDockerfileFunction(t *testing.T) {
 	var cases = []struct {
 		title    string
@@ -89,3 +107,155 @@ func Test_hasDockerfileFunction(t *testing.T) {
 		})
 	}
 }
+
+func Test_getGarbageRequests(t *testing.T) {
+	services := &stack.Services{Functions: map[string]stack.Function{"fn1": {}}}
+
+	pushEvent := sdk.PushEvent{
+		Repository: sdk.PushEventRepository{
+			Name:  "alexa-skills",
+			ID:    7,
+			Owner: sdk.Owner{Login: "alexellis", ID: 3},
+		},
+	}
+
+	got := getGarbageRequests(pushEvent, services)
+	if len(got) != 1 {
+		t.Fatalf("want 1 garbage request, got %d", len(got))
+	}
+	if got[0].Repo != "alexa-skills" || got[0].RepoID != 7 || got[0].OwnerID != 3 || len(got[0].Functions) != 1 {
+		t.Errorf("unexpected garbage request: %v", got[0])
+	}
+
+	pushEvent.MovedFrom = &sdk.RepositoryMove{Owner: "alexellis", OwnerID: 3, Repo: "alexa-skill"}
+	got = getGarbageRequests(pushEvent, services)
+	if len(got) != 2 {
+		t.Fatalf("want 2 garbage requests after a rename, got %d", len(got))
+	}
+	if got[1].Owner != "alexellis" || got[1].Repo != "alexa-skill" || got[1].RepoID != 7 || len(got[1].Functions) != 1 {
+		t.Errorf("want functions of the old repo name to be removed unless still in the stack, got: %v", got[1])
+	}
+
+	pushEvent.MovedFrom = &sdk.RepositoryMove{Owner: "openfaas", OwnerID: 9, Repo: "alexa-skills"}
+	got = getGarbageRequests(pushEvent, services)
+	if got[1].Owner != "openfaas" || got[1].OwnerID != 9 || len(got[1].Functions) != 0 {
+		t.Errorf("want all functions of the old owner to be removed after a transfer, got: %v", got[1])
+	}
+}
+
+func Test_buildRecords(t *testing.T) {
+	dir, err := ioutil.TempDir("", "builds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	deployedSHA := "af6db1234567"
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[
+	{"name": "alexellis-fn1", "labels": {"com.openfaas.cloud.git-repo": "alexa-skills", "com.openfaas.cloud.git-sha": "` + deployedSHA + `", "com.openfaas.cloud.git-deploytime": "1586952000"}},
+	{"name": "alexellis-fn2", "labels": {"com.openfaas.cloud.git-repo": "other-repo", "com.openfaas.cloud.git-sha": "cf6db1234567", "com.openfaas.cloud.git-deploytime": "1586953000"}}
+]`))
+	}))
+	defer s.Close()
+
+	// Each replica sees the same records
+	newBuildRecords := func() *buildRecords {
+		return &buildRecords{
+			inProgress: sdk.NewExpiringSet(filepath.Join(dir, "in-progress"), time.Hour),
+			done:       sdk.NewExpiringSet(filepath.Join(dir, "done"), time.Hour),
+			gatewayURL: s.URL + "/",
+		}
+	}
+
+	pushEvent := sdk.PushEvent{
+		Repository: sdk.PushEventRepository{
+			Name:  "alexa-skills",
+			Owner: sdk.Owner{Login: "alexellis"},
+		},
+		AfterCommitID: "af6db1234567",
+	}
+
+	builds := newBuildRecords()
+	if started, _, msg := builds.start(pushEvent); !started {
+		t.Fatalf("want first build to start, got: %s", msg)
+	}
+	if started, state, _ := newBuildRecords().start(pushEvent); started || state != sdk.StatusPending {
+		t.Errorf("want a build already in progress to be skipped as pending, got: %s", state)
+	}
+
+	builds.complete(pushEvent)
+	if started, state, _ := newBuildRecords().start(pushEvent); started || state != sdk.StatusSuccess {
+		t.Errorf("want a completed build to be skipped as success, got: %s", state)
+	}
+
+	pushEvent.Rebuild = true
+	if started, _, msg := newBuildRecords().start(pushEvent); !started {
+		t.Errorf("want a rebuild to start, got: %s", msg)
+	}
+
+	pushEvent.Rebuild = false
+	pushEvent.AfterCommitID = "bf6db1234567"
+	if started, _, msg := newBuildRecords().start(pushEvent); !started {
+		t.Errorf("want another commit to be built, got: %s", msg)
+	}
+}
+
+func Test_buildRecords_NewerCommitDeployed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "builds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"name": "alexellis-fn1", "labels": {"com.openfaas.cloud.git-repo": "alexa-skills", "com.openfaas.cloud.git-sha": "bf6db1234567", "com.openfaas.cloud.git-deploytime": "1586952000"}}]`))
+	}))
+	defer s.Close()
+
+	builds := &buildRecords{
+		inProgress: sdk.NewExpiringSet(filepath.Join(dir, "in-progress"), time.Hour),
+		done:       sdk.NewExpiringSet(filepath.Join(dir, "done"), time.Hour),
+		gatewayURL: s.URL + "/",
+	}
+
+	pushEvent := sdk.PushEvent{
+		Repository: sdk.PushEventRepository{
+			Name:  "alexa-skills",
+			Owner: sdk.Owner{Login: "alexellis"},
+		},
+		AfterCommitID: "af6db1234567",
+	}
+
+	builds.start(pushEvent)
+	builds.complete(pushEvent)
+
+	// bf6db was deployed after af6db, then the branch was force-pushed back
+	if started, _, msg := builds.start(pushEvent); !started {
+		t.Errorf("want a commit to be built again once a newer commit was deployed, got: %s", msg)
+	}
+}
+
+func Test_buildRecords_Abort(t *testing.T) {
+	dir, err := ioutil.TempDir("", "builds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	builds := &buildRecords{
+		inProgress: sdk.NewExpiringSet(filepath.Join(dir, "in-progress"), time.Hour),
+		done:       sdk.NewExpiringSet(filepath.Join(dir, "done"), time.Hour),
+	}
+
+	pushEvent := sdk.PushEvent{
+		Repository:    sdk.PushEventRepository{Name: "alexa-skills", Owner: sdk.Owner{Login: "alexellis"}},
+		AfterCommitID: "af6db1234567",
+	}
+
+	builds.start(pushEvent)
+	builds.abort(pushEvent)
+	if started, _, msg := builds.start(pushEvent); !started {
+		t.Errorf("want a failed build to be retried, got: %s", msg)
+	}
+}
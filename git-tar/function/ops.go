@@ -21,7 +21,6 @@ import (
 	"code.cloudfoundry.org/bytefmt"
 	"github.com/openfaas/faas-cli/schema"
 
-	"github.com/alexellis/derek/auth"
 	"github.com/alexellis/hmac"
 	"github.com/openfaas/faas-cli/stack"
 	"github.com/openfaas/openfaas-cloud/sdk"
@@ -42,6 +41,32 @@ func parseYAML(filePath string) (*stack.Services, error) {
 	return parsed, err
 }
 
+// selectFunctions returns the stack with only the named functions, or the
+// whole stack when no functions are named
+func selectFunctions(services *stack.Services, names []string) (*stack.Services, error) {
+	if len(names) == 0 {
+		return services, nil
+	}
+
+	selected := *services
+	selected.Functions = map[string]stack.Function{}
+
+	missing := []string{}
+	for _, name := range names {
+		function, ok := services.Functions[name]
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		selected.Functions[name] = function
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("functions not found in stack.yml: %s", strings.Join(missing, ", "))
+	}
+	return &selected, nil
+}
+
 func fetchTemplates(filePath string) error {
 	templateRepos, errors := formatTemplateRepos()
 
@@ -121,10 +146,18 @@ func makeTar(pushEvent sdk.PushEvent, filePath string, services *stack.Services)
 		allowedBuildArgs := []string{"GO111MODULE"}
 		buildArgs := makeBuildArgs(v.BuildArgs, allowedBuildArgs)
 
-		// Write a config file for the Docker build
+		// Write a config file for the Docker build, the commit lets the
+		// of-builder cancel the build when a newer commit is pushed
+		buildRef := sdk.NewBuildRef(pushEvent.Repository.Owner.Login, pushEvent.Repository.Name, pushEvent.AfterCommitID)
 		config := buildConfig{
 			Ref:       imageName,
 			BuildArgs: buildArgs,
+			Build:     &buildRef,
+			NoCache:   pushEvent.NoCache,
+		}
+
+		if v.Annotations != nil {
+			config.Test = sdk.GetTestConfig(*v.Annotations)
 		}
 
 		configBytes, _ := json.Marshal(config)
@@ -211,31 +244,18 @@ func formatImageShaTag(registry string, function *stack.Function, sha string, ow
 }
 
 type githubAuthToken struct {
-	appID          string
 	installationID int
-	privateKeyPath string
-	token          string
+	tokens         *sdk.InstallationTokenCache
+
+	// token was passed on with the push, it is used in place of the cache
+	token string
 }
 
 func (t *githubAuthToken) getToken() (string, error) {
-	if t.token != "" {
+	if len(t.token) > 0 && sdk.ValidToken(t.token) {
 		return t.token, nil
 	}
-
-	privateKey, ioErr := ioutil.ReadFile(t.privateKeyPath)
-	if ioErr != nil {
-		return "", ioErr
-	}
-
-	token, err := auth.MakeAccessTokenForInstallation(t.appID, t.installationID, string(privateKey))
-
-	if err != nil {
-		return "", err
-	}
-
-	t.token = token
-
-	return token, nil
+	return t.tokens.GetToken(t.installationID)
 }
 
 func (t *githubAuthToken) getInstallationID() int {
@@ -332,7 +352,14 @@ func deploy(tars []tarEntry, pushEvent sdk.PushEvent, stack *stack.Services, sta
 	failedFunctions := []string{}
 	owner := pushEvent.Repository.Owner.Login
 
-	for _, tarEntry := range tars {
+	for i, tarEntry := range tars {
+		if newerSHA := getSupersedingSHA(pushEvent); len(newerSHA) > 0 {
+			for _, superseded := range tars[i:] {
+				status.AddStatus(sdk.StatusSuperseded, fmt.Sprintf("superseded by %s", sdk.FormatShortSHA(newerSHA)),
+					sdk.BuildFunctionContext(superseded.functionName))
+			}
+			return errSuperseded
+		}
 
 		if isAWSECR(tarEntry.imageName) {
 			log.Printf("Registering image for %s: ", tarEntry.imageName)
@@ -434,7 +461,14 @@ func deployFunction(tarEntry tarEntry, pushEvent sdk.PushEvent, stack *stack.Ser
 	httpReq.Header.Add("Scm", sourceManagement)
 	httpReq.Header.Add("Private", strconv.FormatBool(privateRepo))
 	httpReq.Header.Add("Repo-URL", repositoryURL)
-	httpReq.Header.Add("Owner-ID", fmt.Sprintf("%d,", ownerID))
+	httpReq.Header.Add("Owner-ID", fmt.Sprintf("%d", ownerID))
+	httpReq.Header.Add("Repo-ID", fmt.Sprintf("%d", pushEvent.Repository.ID))
+	httpReq.Header.Add("Actor", pushEvent.Sender.Login)
+	httpReq.Header.Add("Handler", stack.Functions[tarEntry.functionName].Handler)
+
+	if len(status.AuthToken) > 0 {
+		httpReq.Header.Add("Auth-Token", status.AuthToken)
+	}
 
 	envJSON, marshalErr := json.Marshal(stack.Functions[tarEntry.functionName].Environment)
 	if marshalErr != nil {
@@ -650,23 +684,35 @@ func reportGitHubStatus(status *sdk.Status) {
 	}
 }
 
-func formatGitLabCloneURL(pushEvent sdk.PushEvent, tokenAPI string) (string, error) {
+func formatGitLabCloneURL(pushEvent sdk.PushEvent, credential *sdk.GitLabCredential) (string, error) {
 	url, urlErr := url.Parse(pushEvent.Repository.CloneURL)
 	if urlErr != nil {
 		return "", fmt.Errorf("error while parsing URL: %s", urlErr.Error())
 	}
-	return fmt.Sprintf("https://%s:%s@%s%s", pushEvent.Repository.Owner.Login, tokenAPI, url.Host, url.Path), nil
+
+	// Deploy tokens are only accepted with their own username
+	username := credential.Username
+	if len(username) == 0 {
+		username = pushEvent.Repository.Owner.Login
+	}
+	return fmt.Sprintf("https://%s:%s@%s%s", username, credential.Token, url.Host, url.Path), nil
 }
 
 // GitLabCloneURL builds a URL from an sdk.PushEvent on how to clone a
-// GitLab repository
+// GitLab repository, with the token sealed for the project by its owners
+// or with the gitlab-api-token secret
 func GitLabCloneURL(pushEvent sdk.PushEvent) (string, error) {
-	tokenAPI, tokenErr := sdk.ReadSecret("gitlab-api-token")
-	if tokenErr != nil {
-		return "", fmt.Errorf("cannot read api token from GitLab in secret `gitlab-api-token`: %s", tokenErr.Error())
+	payloadSecret, err := getPayloadSecret()
+	if err != nil {
+		return "", err
+	}
+
+	credential, err := sdk.GetGitLabCredential(os.Getenv("gateway_url"), payloadSecret, pushEvent.Repository.FullName, false)
+	if err != nil {
+		return "", err
 	}
 
-	cloneURL, formatErr := formatGitLabCloneURL(pushEvent, tokenAPI)
+	cloneURL, formatErr := formatGitLabCloneURL(pushEvent, credential)
 	if formatErr != nil {
 		return "", fmt.Errorf("error while formatting clone URL for GitLab: %s", formatErr.Error())
 	}
@@ -678,9 +724,9 @@ func GitLabCloneURL(pushEvent sdk.PushEvent) (string, error) {
 // GitHub repository
 func GitHubCloneURL(pushEvent sdk.PushEvent) (string, error) {
 	at := &githubAuthToken{
-		appID:          os.Getenv("github_app_id"),
 		installationID: pushEvent.Installation.ID,
-		privateKeyPath: sdk.GetPrivateKeyPath(),
+		tokens:         sdk.NewGitHubAppTokenCache(),
+		token:          pushEvent.AuthToken,
 	}
 
 	cloneURL, err := getRepositoryURL(pushEvent, at)
@@ -0,0 +1,110 @@
+package function
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/openfaas/faas-cli/stack"
+	"github.com/openfaas/openfaas-cloud/sdk"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// SmokeTestsFileName is the file in the root of the repo which can declare
+// smoke tests for the functions of stack.yml
+const SmokeTestsFileName = "smoke-tests.yml"
+
+// smokeTestPrefix is the prefix of the annotations buildshiprun reads the
+// smoke test of a function from
+const smokeTestPrefix = sdk.FunctionLabelPrefix + "smoke-test."
+
+// smokeTestsFile is the format of smoke-tests.yml, the tests are keyed by
+// the name of the function in stack.yml
+type smokeTestsFile struct {
+	Functions map[string]smokeTestConfig `yaml:"functions"`
+}
+
+type smokeTestConfig struct {
+	Method    string `yaml:"method"`
+	Path      string `yaml:"path"`
+	Body      string `yaml:"body"`
+	Status    int    `yaml:"status"`
+	BodyRegex string `yaml:"body-regex"`
+	JSONPath  string `yaml:"json-path"`
+	JSONValue string `yaml:"json-value"`
+	Rollback  bool   `yaml:"rollback"`
+}
+
+// annotations gives the smoke test as the annotations which declare it in
+// stack.yml
+func (c smokeTestConfig) annotations() map[string]string {
+	values := map[string]string{
+		"method":     c.Method,
+		"path":       c.Path,
+		"body":       c.Body,
+		"body-regex": c.BodyRegex,
+		"json-path":  c.JSONPath,
+		"json-value": c.JSONValue,
+	}
+	if c.Status > 0 {
+		values["status"] = strconv.Itoa(c.Status)
+	}
+	if c.Rollback {
+		values["rollback"] = "true"
+	}
+
+	annotations := map[string]string{}
+	for k, v := range values {
+		if len(v) > 0 {
+			annotations[smokeTestPrefix+k] = v
+		}
+	}
+	return annotations
+}
+
+// applySmokeTests reads the smoke tests of smoke-tests.yml into the
+// annotations of the functions, a smoke test declared with annotations in
+// stack.yml is kept as it is. The file is optional.
+func applySmokeTests(filePath string, services *stack.Services) error {
+	data, err := ioutil.ReadFile(path.Join(filePath, SmokeTestsFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	tests := smokeTestsFile{}
+	if err := yaml.UnmarshalStrict(data, &tests); err != nil {
+		return fmt.Errorf("unable to parse %s: %s", SmokeTestsFileName, err.Error())
+	}
+
+	for name, test := range tests.Functions {
+		function, ok := services.Functions[name]
+		if !ok {
+			return fmt.Errorf("%s has a smoke test for %s which is not in stack.yml", SmokeTestsFileName, name)
+		}
+		if len(test.Path) == 0 {
+			return fmt.Errorf("%s has no path for the smoke test of %s", SmokeTestsFileName, name)
+		}
+
+		annotations := map[string]string{}
+		if function.Annotations != nil {
+			for k, v := range *function.Annotations {
+				annotations[k] = v
+			}
+		}
+
+		if _, declared := annotations[smokeTestPrefix+"path"]; !declared {
+			for k, v := range test.annotations() {
+				annotations[k] = v
+			}
+		}
+
+		function.Annotations = &annotations
+		services.Functions[name] = function
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package function
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/openfaas/faas-cli/stack"
+)
+
+func Test_applySmokeTests(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smoke-tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := `functions:
+  fn1:
+    path: /items
+    method: POST
+    status: 201
+    json-path: items.0.name
+    rollback: true
+  fn2:
+    path: /from-file
+`
+	if err := ioutil.WriteFile(path.Join(dir, SmokeTestsFileName), []byte(file), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	declared := map[string]string{smokeTestPrefix + "path": "/from-stack"}
+	services := &stack.Services{
+		Functions: map[string]stack.Function{
+			"fn1": {},
+			"fn2": {Annotations: &declared},
+		},
+	}
+
+	if err := applySmokeTests(dir, services); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		smokeTestPrefix + "path":      "/items",
+		smokeTestPrefix + "method":    "POST",
+		smokeTestPrefix + "status":    "201",
+		smokeTestPrefix + "json-path": "items.0.name",
+		smokeTestPrefix + "rollback":  "true",
+	}
+	got := *services.Functions["fn1"].Annotations
+	if len(got) != len(want) {
+		t.Errorf("want %d annotations, got %d: %v", len(want), len(got), got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("annotation %s, want: %s, got: %s", k, v, got[k])
+		}
+	}
+
+	if path := (*services.Functions["fn2"].Annotations)[smokeTestPrefix+"path"]; path != "/from-stack" {
+		t.Errorf("want the smoke test from stack.yml to be kept, got path: %s", path)
+	}
+}
+
+func Test_applySmokeTests_NoFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smoke-tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	services := &stack.Services{Functions: map[string]stack.Function{"fn1": {}}}
+	if err := applySmokeTests(dir, services); err != nil {
+		t.Fatal(err)
+	}
+
+	if services.Functions["fn1"].Annotations != nil {
+		t.Errorf("want no annotations, got: %v", *services.Functions["fn1"].Annotations)
+	}
+}
+
+func Test_applySmokeTests_UnknownFunction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smoke-tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := "functions:\n  missing:\n    path: /\n"
+	if err := ioutil.WriteFile(path.Join(dir, SmokeTestsFileName), []byte(file), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	services := &stack.Services{Functions: map[string]stack.Function{"fn1": {}}}
+	if err := applySmokeTests(dir, services); err == nil {
+		t.Errorf("want an error for a function which is not in stack.yml")
+	}
+}
@@ -1,7 +1,12 @@
 package function
 
+import "github.com/openfaas/openfaas-cloud/sdk"
+
 type buildConfig struct {
 	Ref       string            `json:"ref"`
 	Frontend  string            `json:"frontend,omitempty"`
 	BuildArgs map[string]string `json:"buildArgs,omitempty"`
+	Test      *sdk.TestConfig   `json:"test,omitempty"`
+	Build     *sdk.BuildRef     `json:"build,omitempty"`
+	NoCache   bool              `json:"noCache,omitempty"`
 }
@@ -0,0 +1,167 @@
+package function
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+// App is the GitHub App which created a check run or check suite
+type App struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// CheckRunEvent is sent when a check run changes or when a button on one
+// of our check runs is clicked
+type CheckRunEvent struct {
+	Action          string `json:"action"`
+	RequestedAction struct {
+		Identifier string `json:"identifier"`
+	} `json:"requested_action"`
+	CheckRun struct {
+		Name       string `json:"name"`
+		HeadSHA    string `json:"head_sha"`
+		Status     string `json:"status"`
+		Conclusion string `json:"conclusion"`
+		App        App    `json:"app"`
+		CheckSuite struct {
+			HeadBranch string `json:"head_branch"`
+		} `json:"check_suite"`
+	} `json:"check_run"`
+	Repository   sdk.PushEventRepository   `json:"repository"`
+	Installation sdk.PushEventInstallation `json:"installation"`
+	Sender       sdk.Sender                `json:"sender"`
+}
+
+// CheckSuiteEvent is sent when all the check runs of an app have completed
+// for a commit
+type CheckSuiteEvent struct {
+	Action     string `json:"action"`
+	CheckSuite struct {
+		HeadSHA    string `json:"head_sha"`
+		HeadBranch string `json:"head_branch"`
+		Status     string `json:"status"`
+		Conclusion string `json:"conclusion"`
+		App        App    `json:"app"`
+	} `json:"check_suite"`
+	Repository   sdk.PushEventRepository   `json:"repository"`
+	Installation sdk.PushEventInstallation `json:"installation"`
+	Sender       sdk.Sender                `json:"sender"`
+}
+
+// WorkflowRunEvent is sent when a GitHub Actions workflow is requested or
+// completes
+type WorkflowRunEvent struct {
+	Action      string `json:"action"`
+	WorkflowRun struct {
+		Name       string `json:"name"`
+		HeadSHA    string `json:"head_sha"`
+		Status     string `json:"status"`
+		Conclusion string `json:"conclusion"`
+	} `json:"workflow_run"`
+	Repository sdk.PushEventRepository `json:"repository"`
+}
+
+// parseGateRequest turns a check_run, check_suite or workflow_run event into
+// a request for the deployment-gate function. Checks created by our own app
+// are ignored.
+func parseGateRequest(eventHeader string, req []byte, appID string) (sdk.GateRequest, bool, error) {
+	switch eventHeader {
+	case "check_run":
+		event := CheckRunEvent{}
+		if err := json.Unmarshal(req, &event); err != nil {
+			return sdk.GateRequest{}, false, err
+		}
+
+		if event.Action == "requested_action" {
+			gateReq, ok := getGateRequest(&event)
+			return gateReq, ok, nil
+		}
+
+		if isOwnApp(event.CheckRun.App, appID) {
+			return sdk.GateRequest{}, false, nil
+		}
+
+		gateReq := getCheckGateRequest(event.Repository, event.CheckRun.HeadSHA, event.CheckRun.Name,
+			getCheckState(event.CheckRun.Status, event.CheckRun.Conclusion))
+		return gateReq, true, nil
+
+	case "check_suite":
+		event := CheckSuiteEvent{}
+		if err := json.Unmarshal(req, &event); err != nil {
+			return sdk.GateRequest{}, false, err
+		}
+
+		if isOwnApp(event.CheckSuite.App, appID) {
+			return sdk.GateRequest{}, false, nil
+		}
+
+		gateReq := getCheckGateRequest(event.Repository, event.CheckSuite.HeadSHA, event.CheckSuite.App.Name,
+			getCheckState(event.CheckSuite.Status, event.CheckSuite.Conclusion))
+		return gateReq, true, nil
+
+	case "workflow_run":
+		event := WorkflowRunEvent{}
+		if err := json.Unmarshal(req, &event); err != nil {
+			return sdk.GateRequest{}, false, err
+		}
+
+		gateReq := getCheckGateRequest(event.Repository, event.WorkflowRun.HeadSHA, event.WorkflowRun.Name,
+			getCheckState(event.WorkflowRun.Status, event.WorkflowRun.Conclusion))
+		return gateReq, true, nil
+	}
+
+	return sdk.GateRequest{}, false, nil
+}
+
+// getGateRequest turns the Approve or Reject button on a check run into a
+// request for the deployment-gate function
+func getGateRequest(event *CheckRunEvent) (sdk.GateRequest, bool) {
+	if event.Action != "requested_action" {
+		return sdk.GateRequest{}, false
+	}
+
+	action := event.RequestedAction.Identifier
+	if action != sdk.GateActionApprove && action != sdk.GateActionReject {
+		return sdk.GateRequest{}, false
+	}
+
+	return sdk.GateRequest{
+		Action:   action,
+		Owner:    event.Repository.Owner.Login,
+		Repo:     event.Repository.Name,
+		Function: event.CheckRun.Name,
+		SHA:      event.CheckRun.HeadSHA,
+		User:     event.Sender.Login,
+	}, true
+}
+
+func getCheckGateRequest(repository sdk.PushEventRepository, sha, name, state string) sdk.GateRequest {
+	return sdk.GateRequest{
+		Action: sdk.GateActionCheck,
+		Owner:  repository.Owner.Login,
+		Repo:   repository.Name,
+		SHA:    sha,
+		Checks: map[string]string{name: state},
+	}
+}
+
+// getCheckState maps the status and conclusion of a check or workflow to
+// the state of a commit status
+func getCheckState(status, conclusion string) string {
+	if status != "completed" {
+		return sdk.StatusPending
+	}
+
+	switch conclusion {
+	case "success", "neutral", "skipped":
+		return sdk.StatusSuccess
+	}
+	return sdk.StatusFailure
+}
+
+func isOwnApp(app App, appID string) bool {
+	return len(appID) > 0 && strconv.FormatInt(app.ID, 10) == appID
+}
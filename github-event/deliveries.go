@@ -0,0 +1,39 @@
+package function
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+// checkDelivery records the X-GitHub-Delivery ID of an event, GitHub
+// delivers an event again when it doesn't get a response in time so a
+// delivery which was already recorded is a duplicate. Deliveries are
+// handled when they can't be recorded.
+func checkDelivery(deliveries sdk.KeySet, deliveryID string) (bool, string) {
+	if len(deliveryID) == 0 {
+		return false, ""
+	}
+
+	added, err := deliveries.Add(deliveryID)
+	if err != nil {
+		log.Printf("unable to record delivery %s: %s", deliveryID, err.Error())
+		return false, ""
+	}
+
+	if !added {
+		return true, fmt.Sprintf("Ignoring duplicate delivery: %s", deliveryID)
+	}
+	return false, ""
+}
+
+func forgetDelivery(deliveries sdk.KeySet, deliveryID string) {
+	if len(deliveryID) == 0 {
+		return
+	}
+
+	if err := deliveries.Remove(deliveryID); err != nil {
+		log.Printf("unable to forget delivery %s: %s", deliveryID, err.Error())
+	}
+}
@@ -24,32 +24,38 @@ type GarbageRequest struct {
 	Functions []string `json:"functions"`
 	Repo      string   `json:"repo"`
 	Owner     string   `json:"owner"`
+	RepoID    int64    `json:"repo_id,omitempty"`
+	OwnerID   int64    `json:"owner_id,omitempty"`
 }
 
 type InstallationRepositoriesEvent struct {
 	Action       string `json:"action"`
 	Installation struct {
+		ID      int `json:"id"`
 		Account struct {
 			Login string
 		}
 	} `json:"installation"`
+	Sender              sdk.Sender     `json:"sender"`
 	RepositoriesRemoved []Installation `json:"repositories_removed"`
 	RepositoriesAdded   []Installation `json:"repositories_added"`
 	Repositories        []Installation `json:"repositories"`
 }
 
 type Installation struct {
+	ID       int64  `json:"id"`
 	Name     string `json:"name"`
 	FullName string `json:"full_name"`
 }
 
 // Handle receives events from the GitHub app and checks the origin via
-// HMAC. Valid events are push or installation events.
+// HMAC. Valid events are push, installation, check and repository events.
 func Handle(req []byte) string {
 	customersPath := os.Getenv("customers_path")
 	customersURL := os.Getenv("customers_url")
 
 	customers := sdk.NewCustomers(customersPath, customersURL)
+	customers.Membership = sdk.NewGitHubAppMembership()
 	customers.Fetch()
 
 	queryVal := os.Getenv("Http_Query")
@@ -66,8 +72,24 @@ func Handle(req []byte) string {
 
 	eventHeader := os.Getenv("Http_X_Github_Event")
 	xHubSignature := os.Getenv("Http_X_Hub_Signature")
+	deliveryID := os.Getenv("Http_X_Github_Delivery")
+	deliveries := sdk.NewDeliverySet(os.Getenv("gateway_url"))
+
+	if eventHeader == initialBuildEvent {
+		hmacErr := sdk.ValidHMAC(&req, "payload-secret", os.Getenv("Http_X_Cloud_Signature"))
+		if hmacErr != nil {
+			log.Printf("hmac error %s\n", hmacErr.Error())
+			os.Exit(1)
+		}
+		return handleInitialBuild(req)
+	}
 
 	if eventHeader != "push" &&
+		eventHeader != "check_run" &&
+		eventHeader != "check_suite" &&
+		eventHeader != "workflow_run" &&
+		eventHeader != "repository" &&
+		eventHeader != "installation_target" &&
 		eventHeader != "installation_repositories" &&
 		eventHeader != "integration_installation" &&
 		eventHeader != "installation" {
@@ -110,6 +132,10 @@ func Handle(req []byte) string {
 			}
 		}
 
+		if duplicate, msg := checkDelivery(deliveries, deliveryID); duplicate {
+			return msg
+		}
+
 		headers := map[string]string{
 			"X-Hub-Signature": xHubSignature,
 			"X-GitHub-Event":  eventHeader,
@@ -119,6 +145,11 @@ func Handle(req []byte) string {
 		forwardTo := "github-push"
 		body, statusCode, err := forward(req, forwardTo, headers)
 
+		// GitHub can deliver the push again when it wasn't forwarded
+		if err != nil {
+			forgetDelivery(deliveries, deliveryID)
+		}
+
 		if statusCode == http.StatusOK {
 			return fmt.Sprintf("[%s]: %d, %s", forwardTo, statusCode, body)
 		}
@@ -130,6 +161,90 @@ func Handle(req []byte) string {
 		return body
 	}
 
+	if eventHeader == "check_run" ||
+		eventHeader == "check_suite" ||
+		eventHeader == "workflow_run" {
+		if sdk.HmacEnabled() {
+			webhookSecretKey, secretErr := sdk.ReadSecret("github-webhook-secret")
+			if secretErr != nil {
+				return secretErr.Error()
+			}
+
+			validateErr := hmac.Validate(req, xHubSignature, webhookSecretKey)
+			if validateErr != nil {
+				log.Fatal(validateErr)
+			}
+		}
+
+		if duplicate, msg := checkDelivery(deliveries, deliveryID); duplicate {
+			return msg
+		}
+
+		pushEvent, rebuildRequested, err := parseRebuildRequest(eventHeader, req, sdk.GetGitHubAppID())
+		if err != nil {
+			return err.Error()
+		}
+
+		if rebuildRequested {
+			if sdk.ValidateCustomers() {
+				if err := validateCustomers(&pushEvent, customers); err != nil {
+					return err.Error()
+				}
+			}
+
+			if err := checkSuperseded(pushEvent); err != nil {
+				return err.Error()
+			}
+
+			res, err := sendPushEvent(pushEvent)
+			if err != nil {
+				return err.Error()
+			}
+			return res
+		}
+
+		gateReq, ok, err := parseGateRequest(eventHeader, req, sdk.GetGitHubAppID())
+		if err != nil {
+			return err.Error()
+		}
+
+		if !ok {
+			return fmt.Sprintf("Ignoring %s event", eventHeader)
+		}
+
+		payloadSecret, err := sdk.ReadSecret("payload-secret")
+		if err != nil {
+			return err.Error()
+		}
+
+		res, err := sdk.PostGateRequest(os.Getenv("gateway_url"), payloadSecret, gateReq)
+		if err != nil {
+			return err.Error()
+		}
+		return res
+	}
+
+	if eventHeader == "repository" ||
+		eventHeader == "installation_target" {
+		if sdk.HmacEnabled() {
+			webhookSecretKey, secretErr := sdk.ReadSecret("github-webhook-secret")
+			if secretErr != nil {
+				return secretErr.Error()
+			}
+
+			validateErr := hmac.Validate(req, xHubSignature, webhookSecretKey)
+			if validateErr != nil {
+				log.Fatal(validateErr)
+			}
+		}
+
+		if duplicate, msg := checkDelivery(deliveries, deliveryID); duplicate {
+			return msg
+		}
+
+		return handleMove(eventHeader, req, customers)
+	}
+
 	if eventHeader == "installation" ||
 		eventHeader == "installation_repositories" ||
 		eventHeader == "integration_installation" {
@@ -167,6 +282,10 @@ func Handle(req []byte) string {
 			}
 		}
 
+		if duplicate, msg := checkDelivery(deliveries, deliveryID); duplicate {
+			return msg
+		}
+
 		fmt.Printf("event.Action: %s\n", event.Action)
 
 		switch event.Action {
@@ -191,6 +310,11 @@ func Handle(req []byte) string {
 
 			sdk.PostAudit(auditEvent)
 
+			if initialBuildEnabled() {
+				added := append(event.RepositoriesAdded, event.Repositories...)
+				buildAddedRepositories(&event, added)
+			}
+
 		case "removed":
 			garbageRequests := []GarbageRequest{}
 			for _, repo := range event.RepositoriesRemoved {
@@ -2,12 +2,16 @@ package function
 
 import (
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path"
+	"reflect"
 	"testing"
+	"time"
 
+	"github.com/alexellis/hmac"
 	"github.com/openfaas/openfaas-cloud/sdk"
 )
 
@@ -195,3 +199,441 @@ func Test_RedirectSetupAction(t *testing.T) {
 	}
 
 }
+
+func Test_getGateRequest_Approve(t *testing.T) {
+	event := CheckRunEvent{Action: "requested_action"}
+	event.RequestedAction.Identifier = "approve"
+	event.CheckRun.Name = "slack-fn"
+	event.CheckRun.HeadSHA = "af6db1234567"
+	event.Repository.Name = "super-pancake"
+	event.Repository.Owner.Login = "alexellis"
+	event.Sender.Login = "rgee0"
+
+	got, ok := getGateRequest(&event)
+	if !ok {
+		t.Fatalf("want a gate request for an approve action")
+	}
+
+	want := sdk.GateRequest{
+		Action:   sdk.GateActionApprove,
+		Owner:    "alexellis",
+		Repo:     "super-pancake",
+		Function: "slack-fn",
+		SHA:      "af6db1234567",
+		User:     "rgee0",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func Test_getGateRequest_IgnoresOtherActions(t *testing.T) {
+	event := CheckRunEvent{Action: "rerequested"}
+	if _, ok := getGateRequest(&event); ok {
+		t.Errorf("want no gate request for action: %s", event.Action)
+	}
+
+	event = CheckRunEvent{Action: "requested_action"}
+	event.RequestedAction.Identifier = "fix-it"
+	if _, ok := getGateRequest(&event); ok {
+		t.Errorf("want no gate request for identifier: %s", event.RequestedAction.Identifier)
+	}
+}
+
+func Test_parseGateRequest_WorkflowRun(t *testing.T) {
+	req := []byte(`{"action": "completed",
+	"workflow_run": {"name": "CI", "head_sha": "af6db1234567", "status": "completed", "conclusion": "failure"},
+	"repository": {"name": "super-pancake", "owner": {"login": "alexellis"}}}`)
+
+	got, ok, err := parseGateRequest("workflow_run", req, "12345")
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err.Error())
+	}
+	if !ok {
+		t.Fatalf("want a gate request for a workflow_run")
+	}
+
+	if got.Action != sdk.GateActionCheck || got.Owner != "alexellis" || got.Repo != "super-pancake" || got.SHA != "af6db1234567" {
+		t.Errorf("unexpected gate request: %v", got)
+	}
+	if got.Checks["CI"] != sdk.StatusFailure {
+		t.Errorf("want CI check to be %s, got: %v", sdk.StatusFailure, got.Checks)
+	}
+}
+
+func Test_parseGateRequest_IgnoresOwnCheckSuite(t *testing.T) {
+	req := []byte(`{"action": "completed",
+	"check_suite": {"head_sha": "af6db1234567", "status": "completed", "conclusion": "success", "app": {"id": 12345, "name": "OpenFaaS Cloud"}},
+	"repository": {"name": "super-pancake", "owner": {"login": "alexellis"}}}`)
+
+	_, ok, err := parseGateRequest("check_suite", req, "12345")
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err.Error())
+	}
+	if ok {
+		t.Errorf("want check suites from our own app to be ignored")
+	}
+}
+
+func Test_getCheckState(t *testing.T) {
+	cases := []struct {
+		status     string
+		conclusion string
+		want       string
+	}{
+		{status: "in_progress", want: sdk.StatusPending},
+		{status: "completed", conclusion: "success", want: sdk.StatusSuccess},
+		{status: "completed", conclusion: "skipped", want: sdk.StatusSuccess},
+		{status: "completed", conclusion: "timed_out", want: sdk.StatusFailure},
+	}
+
+	for _, c := range cases {
+		if got := getCheckState(c.status, c.conclusion); got != c.want {
+			t.Errorf("%s/%s: want %s, got %s", c.status, c.conclusion, c.want, got)
+		}
+	}
+}
+
+func Test_parseRebuildRequest_CheckRunOfFunction(t *testing.T) {
+	req := []byte(`{"action": "rerequested",
+	"check_run": {"name": "fn1/build", "head_sha": "af6db1234567", "app": {"id": 12345}, "check_suite": {"head_branch": "master"}},
+	"repository": {"name": "super-pancake", "clone_url": "https://github.com/alexellis/super-pancake.git", "owner": {"login": "alexellis"}},
+	"installation": {"id": 42},
+	"sender": {"login": "rgee0"}}`)
+
+	got, ok, err := parseRebuildRequest("check_run", req, "12345")
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err.Error())
+	}
+	if !ok {
+		t.Fatalf("want a rebuild for a rerequested check run")
+	}
+
+	if got.Ref != "refs/heads/master" || got.AfterCommitID != "af6db1234567" || got.Installation.ID != 42 {
+		t.Errorf("unexpected push event: %v", got)
+	}
+	if got.Repository.Owner.Login != "alexellis" || got.Repository.CloneURL != "https://github.com/alexellis/super-pancake.git" {
+		t.Errorf("unexpected repository: %v", got.Repository)
+	}
+	if got.Sender.Login != "rgee0" {
+		t.Errorf("want sender rgee0, got: %s", got.Sender.Login)
+	}
+	if len(got.Functions) != 1 || got.Functions[0] != "fn1" {
+		t.Errorf("want only fn1 to be built, got: %v", got.Functions)
+	}
+	if !got.Rebuild {
+		t.Errorf("want the commit to be built again")
+	}
+}
+
+func Test_parseRebuildRequest_CheckSuiteBuildsStack(t *testing.T) {
+	req := []byte(`{"action": "rerequested",
+	"check_suite": {"head_sha": "af6db1234567", "head_branch": "master", "app": {"id": 12345}},
+	"repository": {"name": "super-pancake", "owner": {"login": "alexellis"}},
+	"installation": {"id": 42}}`)
+
+	got, ok, err := parseRebuildRequest("check_suite", req, "12345")
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err.Error())
+	}
+	if !ok {
+		t.Fatalf("want a rebuild for a rerequested check suite")
+	}
+	if got.AfterCommitID != "af6db1234567" || len(got.Functions) != 0 {
+		t.Errorf("want the whole stack to be built, got: %v", got)
+	}
+}
+
+func Test_parseRebuildRequest_IgnoresOtherChecks(t *testing.T) {
+	cases := []struct {
+		title string
+		req   string
+	}{
+		{
+			title: "check run of another app",
+			req:   `{"action": "rerequested", "check_run": {"name": "CI", "app": {"id": 99}}}`,
+		},
+		{
+			title: "completed check run of our app",
+			req:   `{"action": "completed", "check_run": {"name": "fn1", "app": {"id": 12345}}}`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.title, func(t *testing.T) {
+			_, ok, err := parseRebuildRequest("check_run", []byte(c.req), "12345")
+			if err != nil {
+				t.Fatalf("want no error, got: %s", err.Error())
+			}
+			if ok {
+				t.Errorf("want no rebuild")
+			}
+		})
+	}
+}
+
+func Test_getRebuildFunctions(t *testing.T) {
+	cases := []struct {
+		checkName string
+		want      []string
+	}{
+		{checkName: "fn1", want: []string{"fn1"}},
+		{checkName: "fn1/smoke-test", want: []string{"fn1"}},
+		{checkName: "stack-deploy", want: nil},
+		{checkName: "stack-deploy/clone", want: nil},
+	}
+
+	for _, c := range cases {
+		got := getRebuildFunctions(c.checkName)
+		if len(got) != len(c.want) || (len(got) > 0 && got[0] != c.want[0]) {
+			t.Errorf("%s: want %v, got %v", c.checkName, c.want, got)
+		}
+	}
+}
+
+func Test_checkSuperseded(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/latest" || r.URL.Query().Get("repo") != "repo1" {
+			t.Errorf("unexpected request: %s", r.URL.String())
+		}
+		w.Write([]byte(`{"owner": "alexellis", "repo": "repo1", "sha": "newer"}`))
+	}))
+	defer s.Close()
+
+	os.Setenv("builder_url", s.URL+"/")
+	defer os.Unsetenv("builder_url")
+
+	pushEvent := sdk.PushEvent{
+		Repository: sdk.PushEventRepository{
+			Name:  "Repo1",
+			Owner: sdk.Owner{Login: "alexellis"},
+		},
+	}
+
+	pushEvent.AfterCommitID = "newer"
+	if err := checkSuperseded(pushEvent); err != nil {
+		t.Errorf("the newest commit should be built again, got: %s", err.Error())
+	}
+
+	pushEvent.AfterCommitID = "older"
+	if err := checkSuperseded(pushEvent); err == nil {
+		t.Errorf("an older commit should not be built again")
+	}
+}
+
+func Test_getInitialBuildEvent(t *testing.T) {
+	var requested []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		if r.Header.Get("Authorization") != "token installation-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/repos/alexellis/super-pancake":
+			w.Write([]byte(`{"name": "super-pancake", "full_name": "alexellis/super-pancake",
+			"clone_url": "https://github.com/alexellis/super-pancake.git", "private": true, "id": 7,
+			"default_branch": "main", "owner": {"login": "alexellis", "id": 3}}`))
+		case "/repos/alexellis/super-pancake/commits/main", "/repos/alexellis/super-pancake/commits/master":
+			if r.Header.Get("Accept") != "application/vnd.github.v3.sha" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte("af6db1234567"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	cases := []struct {
+		title   string
+		branch  string
+		wantRef string
+	}{
+		{title: "default branch", branch: "", wantRef: "refs/heads/main"},
+		{title: "build branch", branch: "master", wantRef: "refs/heads/master"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.title, func(t *testing.T) {
+			got, err := getInitialBuildEvent(s.URL, "installation-token", "alexellis/super-pancake", c.branch)
+			if err != nil {
+				t.Fatalf("want no error, got: %s", err.Error())
+			}
+
+			if got.Ref != c.wantRef || got.AfterCommitID != "af6db1234567" {
+				t.Errorf("want %s at af6db1234567, got: %s at %s", c.wantRef, got.Ref, got.AfterCommitID)
+			}
+			if got.Repository.Owner.Login != "alexellis" || !got.Repository.Private || got.Repository.CloneURL != "https://github.com/alexellis/super-pancake.git" {
+				t.Errorf("unexpected repository: %v", got.Repository)
+			}
+		})
+	}
+
+	if _, err := getInitialBuildEvent(s.URL, "installation-token", "alexellis/missing", ""); err == nil {
+		t.Errorf("want an error for a missing repository, requested: %v", requested)
+	}
+}
+
+func Test_getRepositoryMove(t *testing.T) {
+	cases := []struct {
+		title  string
+		body   string
+		want   *sdk.RepositoryMove
+		wantOK bool
+	}{
+		{
+			title: "renamed",
+			body: `{"action": "renamed", "repository": {"id": 7, "name": "super-pancake", "full_name": "alexellis/super-pancake", "owner": {"login": "alexellis", "id": 3}},
+				"changes": {"repository": {"name": {"from": "pancake"}}}}`,
+			want:   &sdk.RepositoryMove{Owner: "alexellis", OwnerID: 3, Repo: "pancake"},
+			wantOK: true,
+		},
+		{
+			title: "transferred from a user",
+			body: `{"action": "transferred", "repository": {"id": 7, "name": "super-pancake", "full_name": "openfaas/super-pancake", "owner": {"login": "openfaas", "id": 5}},
+				"changes": {"owner": {"from": {"user": {"login": "alexellis", "id": 3}}}}}`,
+			want:   &sdk.RepositoryMove{Owner: "alexellis", OwnerID: 3, Repo: "super-pancake"},
+			wantOK: true,
+		},
+		{
+			title: "transferred from an organization",
+			body: `{"action": "transferred", "repository": {"id": 7, "name": "super-pancake", "full_name": "alexellis/super-pancake", "owner": {"login": "alexellis", "id": 3}},
+				"changes": {"owner": {"from": {"organization": {"login": "openfaas", "id": 5}}}}}`,
+			want:   &sdk.RepositoryMove{Owner: "openfaas", OwnerID: 5, Repo: "super-pancake"},
+			wantOK: true,
+		},
+		{
+			title: "renamed to the same name",
+			body:  `{"action": "renamed", "repository": {"name": "super-pancake"}, "changes": {"repository": {"name": {"from": "super-pancake"}}}}`,
+		},
+		{
+			title: "archived",
+			body:  `{"action": "archived", "repository": {"name": "super-pancake"}}`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.title, func(t *testing.T) {
+			event := RepositoryEvent{}
+			if err := json.Unmarshal([]byte(c.body), &event); err != nil {
+				t.Fatal(err)
+			}
+
+			got, ok := getRepositoryMove(&event)
+			if ok != c.wantOK {
+				t.Fatalf("want ok %t, got %t", c.wantOK, ok)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("want %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func Test_getOwnerMoveBuilds(t *testing.T) {
+	functions := []openFaaSFunction{
+		{Name: "alexellis-fn1", Labels: map[string]string{"com.openfaas.cloud.git-repo": "super-pancake", "com.openfaas.cloud.git-repo-id": "7"}},
+		{Name: "alexellis-fn2", Labels: map[string]string{"com.openfaas.cloud.git-repo": "super-pancake", "com.openfaas.cloud.git-repo-id": "7"}},
+		{Name: "alexellis-fn3", Labels: map[string]string{"com.openfaas.cloud.git-repo": "bread"}},
+		{Name: "alexellis-system", Labels: map[string]string{}},
+	}
+
+	got := getOwnerMoveBuilds(functions, "ellis", &sdk.RepositoryMove{Owner: "alexellis", OwnerID: 3})
+
+	want := []repositoryBuild{
+		{Name: "bread", FullName: "ellis/bread", MovedFrom: &sdk.RepositoryMove{Owner: "alexellis", OwnerID: 3, Repo: "bread"}},
+		{ID: 7, Name: "super-pancake", FullName: "ellis/super-pancake", MovedFrom: &sdk.RepositoryMove{Owner: "alexellis", OwnerID: 3, Repo: "super-pancake"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func Test_checkDelivery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deliveries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	deliveries := sdk.NewExpiringSet(dir, time.Hour)
+
+	if duplicate, _ := checkDelivery(deliveries, "72d3162e-cc78-11e3-81ab-4c9367dc0958"); duplicate {
+		t.Errorf("want first delivery to be handled")
+	}
+	if duplicate, msg := checkDelivery(deliveries, "72d3162e-cc78-11e3-81ab-4c9367dc0958"); !duplicate {
+		t.Errorf("want redelivery to be a duplicate, got: %s", msg)
+	}
+
+	forgetDelivery(deliveries, "72d3162e-cc78-11e3-81ab-4c9367dc0958")
+	if duplicate, _ := checkDelivery(deliveries, "72d3162e-cc78-11e3-81ab-4c9367dc0958"); duplicate {
+		t.Errorf("want forgotten delivery to be handled again")
+	}
+
+	if duplicate, _ := checkDelivery(deliveries, ""); duplicate {
+		t.Errorf("want events without a delivery ID to be handled")
+	}
+}
+
+func Test_handleInitialBuild_QueuesRemainingBuilds(t *testing.T) {
+	dir, err := ioutil.TempDir("", "initial-build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(path.Join(dir, "payload-secret"), []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	queued := []initialBuildRequest{}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.URL.Path != "/async-function/github-event" || r.Header.Get("X-GitHub-Event") != initialBuildEvent {
+			t.Errorf("unexpected request: %s, event: %s", r.URL.Path, r.Header.Get("X-GitHub-Event"))
+		}
+		if err := hmac.Validate(body, r.Header.Get(sdk.CloudSignatureHeader), "secret"); err != nil {
+			t.Errorf("want a signed request, got: %s", err.Error())
+		}
+
+		buildReq := initialBuildRequest{}
+		json.Unmarshal(body, &buildReq)
+		queued = append(queued, buildReq)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer s.Close()
+
+	for k, v := range map[string]string{
+		"secret_mount_path":      dir,
+		"token_cache_path":       path.Join(dir, "tokens"),
+		"gateway_url":            s.URL + "/",
+		"initial_build_interval": "0s",
+	} {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	buildReq := initialBuildRequest{
+		InstallationID: 1,
+		Owner:          "alexellis",
+		Builds: []repositoryBuild{
+			{ID: 1, Name: "one", FullName: "alexellis/one"},
+			{ID: 2, Name: "two", FullName: "alexellis/two"},
+			{ID: 3, Name: "three", FullName: "alexellis/three"},
+		},
+	}
+	req, _ := json.Marshal(buildReq)
+
+	// The first build fails without a private key, the rest are still queued
+	handleInitialBuild(req)
+
+	if len(queued) != 1 || len(queued[0].Builds) != 2 || queued[0].Builds[0].FullName != "alexellis/two" {
+		t.Fatalf("want the remaining 2 builds to be queued, got: %v", queued)
+	}
+	if queued[0].InstallationID != 1 || queued[0].Owner != "alexellis" {
+		t.Errorf("want the installation to be queued with the builds, got: %v", queued[0])
+	}
+}
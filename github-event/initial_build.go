@@ -0,0 +1,280 @@
+package function
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/alexellis/hmac"
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+const (
+	// defaultInitialBuildInterval is the pause between each build so that
+	// the builder isn't sent every repository at once
+	defaultInitialBuildInterval = 500 * time.Millisecond
+
+	// initialBuildEvent is sent by github-event to itself, through the
+	// asynchronous queue, to build the next of the repositories added to
+	// the app. GitHub never sends an event of this name.
+	initialBuildEvent = "ofc_initial_build"
+)
+
+// githubRepository is the part of a repository from the GitHub API needed
+// to build it
+type githubRepository struct {
+	Name          string    `json:"name"`
+	FullName      string    `json:"full_name"`
+	CloneURL      string    `json:"clone_url"`
+	Private       bool      `json:"private"`
+	ID            int64     `json:"id"`
+	URL           string    `json:"url"`
+	DefaultBranch string    `json:"default_branch"`
+	Owner         sdk.Owner `json:"owner"`
+}
+
+func initialBuildEnabled() bool {
+	return os.Getenv("initial_build") != "false"
+}
+
+func getInitialBuildInterval() time.Duration {
+	if val, err := time.ParseDuration(os.Getenv("initial_build_interval")); err == nil && val >= 0 {
+		return val
+	}
+	return defaultInitialBuildInterval
+}
+
+// repositoryBuild is a repository to build without a push
+type repositoryBuild struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	FullName string `json:"full_name"`
+
+	// MovedFrom is set when the repository or its owner was renamed
+	MovedFrom *sdk.RepositoryMove `json:"moved_from,omitempty"`
+}
+
+// initialBuildRequest holds the repositories which are still to be built
+// for an installation, the first is built by the next request
+type initialBuildRequest struct {
+	InstallationID int               `json:"installation_id"`
+	Owner          string            `json:"owner"`
+	Sender         sdk.Sender        `json:"sender"`
+	Builds         []repositoryBuild `json:"builds"`
+}
+
+// buildAddedRepositories builds the HEAD of the build branch of repositories
+// which were added to the app
+func buildAddedRepositories(event *InstallationRepositoriesEvent, repositories []Installation) {
+	builds := []repositoryBuild{}
+	for _, repository := range repositories {
+		builds = append(builds, repositoryBuild{ID: repository.ID, Name: repository.Name, FullName: repository.FullName})
+	}
+
+	buildRepositories(event.Installation.ID, event.Installation.Account.Login, event.Sender, builds)
+}
+
+// buildRepositories queues the builds of the HEAD of the build branch of
+// each repository, so that the webhook returns straight away. Functions
+// from where a repository was before it moved are removed when it can't be
+// queued, the repository is then built on its next push.
+func buildRepositories(installationID int, owner string, sender sdk.Sender, builds []repositoryBuild) {
+	if len(builds) == 0 {
+		return
+	}
+
+	buildReq := initialBuildRequest{
+		InstallationID: installationID,
+		Owner:          owner,
+		Sender:         sender,
+		Builds:         builds,
+	}
+
+	if err := queueInitialBuilds(buildReq); err != nil {
+		names := []string{}
+		for _, build := range builds {
+			names = append(names, build.FullName)
+			if build.MovedFrom != nil {
+				removeMovedFunctions(build.MovedFrom, build.ID)
+			}
+		}
+
+		sdk.PostAudit(sdk.AuditEvent{
+			Message: fmt.Sprintf("unable to queue builds, %d repositories will be built on their next push: %s, error: %s", len(names), strings.Join(names, ", "), err.Error()),
+			Owner:   owner,
+			Source:  Source,
+		})
+	}
+}
+
+// handleInitialBuild builds the first repository of a queued request, then
+// waits for initial_build_interval and queues the rest, so that the builder
+// is sent one repository at a time however many were added
+func handleInitialBuild(req []byte) string {
+	buildReq := initialBuildRequest{}
+	if err := json.Unmarshal(req, &buildReq); err != nil {
+		return fmt.Sprintf("unable to parse initial build: %s", err.Error())
+	}
+
+	if len(buildReq.Builds) == 0 {
+		return "no repositories to build"
+	}
+
+	build := buildReq.Builds[0]
+	msg := buildQueuedRepository(buildReq, build)
+
+	if rest := buildReq.Builds[1:]; len(rest) > 0 {
+		time.Sleep(getInitialBuildInterval())
+
+		buildReq.Builds = rest
+		if err := queueInitialBuilds(buildReq); err != nil {
+			log.Printf("unable to queue %d remaining builds, they will be built on their next push: %s", len(rest), err.Error())
+
+			for _, remaining := range rest {
+				if remaining.MovedFrom != nil {
+					removeMovedFunctions(remaining.MovedFrom, remaining.ID)
+				}
+			}
+		}
+	}
+
+	return msg
+}
+
+func buildQueuedRepository(buildReq initialBuildRequest, build repositoryBuild) string {
+	token, err := sdk.NewGitHubAppTokenCache().GetToken(buildReq.InstallationID)
+	if err == nil {
+		var res string
+		var pushEvent sdk.PushEvent
+		res, pushEvent, err = buildRepository(token, buildReq.InstallationID, buildReq.Sender, build)
+		if err == nil {
+			msg := fmt.Sprintf("build of %s@%s without a push: %s", build.FullName, pushEvent.AfterCommitID, res)
+			sdk.PostAudit(sdk.AuditEvent{
+				Message: msg,
+				Owner:   buildReq.Owner,
+				Repo:    build.Name,
+				Source:  Source,
+			})
+			return msg
+		}
+	}
+
+	log.Printf("unable to build %s: %s", build.FullName, err.Error())
+	if build.MovedFrom != nil {
+		removeMovedFunctions(build.MovedFrom, build.ID)
+	}
+	return fmt.Sprintf("unable to build %s: %s", build.FullName, err.Error())
+}
+
+// queueInitialBuilds sends the builds to this function through the
+// asynchronous queue, signed with the payload-secret
+func queueInitialBuilds(buildReq initialBuildRequest) error {
+	payloadSecret, err := sdk.ReadSecret("payload-secret")
+	if err != nil {
+		return err
+	}
+
+	body, _ := json.Marshal(buildReq)
+	req, _ := http.NewRequest(http.MethodPost, os.Getenv("gateway_url")+"async-function/"+Source, bytes.NewReader(body))
+
+	digest := hmac.Sign(body, []byte(payloadSecret))
+	req.Header.Add(sdk.CloudSignatureHeader, "sha1="+hex.EncodeToString(digest))
+	req.Header.Add("X-GitHub-Event", initialBuildEvent)
+	req.Header.Add("Content-Type", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	if res.Body != nil {
+		defer res.Body.Close()
+	}
+
+	if res.StatusCode != http.StatusAccepted {
+		resBody, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("unexpected status: %d, body: %s", res.StatusCode, string(resBody))
+	}
+	return nil
+}
+
+func buildRepository(token string, installationID int, sender sdk.Sender, build repositoryBuild) (string, sdk.PushEvent, error) {
+	pushEvent, err := getInitialBuildEvent(sdk.GetGitHubURLs().APIURL, token, build.FullName, os.Getenv("build_branch"))
+	if err != nil {
+		return "", pushEvent, err
+	}
+
+	pushEvent.Installation.ID = installationID
+	pushEvent.Sender = sender
+	pushEvent.MovedFrom = build.MovedFrom
+
+	// The functions may have been removed since the commit was last built
+	pushEvent.Rebuild = true
+
+	res, err := sendPushEvent(pushEvent)
+	return res, pushEvent, err
+}
+
+// getInitialBuildEvent creates the push event for the HEAD of the build
+// branch of a repository, or of its default branch when no build branch
+// is set
+func getInitialBuildEvent(apiURL, token, fullName, branch string) (sdk.PushEvent, error) {
+	repository := githubRepository{}
+	if err := getGitHubAPI(apiURL+"/repos/"+fullName, token, "application/vnd.github.v3+json", &repository); err != nil {
+		return sdk.PushEvent{}, err
+	}
+
+	if len(branch) == 0 {
+		branch = repository.DefaultBranch
+	}
+
+	sha := ""
+	if err := getGitHubAPI(fmt.Sprintf("%s/repos/%s/commits/%s", apiURL, fullName, branch), token, "application/vnd.github.v3.sha", &sha); err != nil {
+		return sdk.PushEvent{}, fmt.Errorf("unable to find HEAD of %s: %s", branch, err.Error())
+	}
+
+	return sdk.PushEvent{
+		Ref: "refs/heads/" + branch,
+		Repository: sdk.PushEventRepository{
+			Name:          repository.Name,
+			FullName:      repository.FullName,
+			CloneURL:      repository.CloneURL,
+			Private:       repository.Private,
+			ID:            repository.ID,
+			RepositoryURL: repository.URL,
+			Owner:         repository.Owner,
+		},
+		AfterCommitID: sha,
+	}, nil
+}
+
+// getGitHubAPI reads from the GitHub API, the body is decoded as JSON
+// unless out is a string
+func getGitHubAPI(URL, token, accept string, out interface{}) error {
+	req, _ := http.NewRequest(http.MethodGet, URL, nil)
+	req.Header.Set("Authorization", "token "+token)
+	req.Header.Set("Accept", accept)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status from GitHub API: %d, body: %s", res.StatusCode, string(body))
+	}
+
+	if text, ok := out.(*string); ok {
+		*text = strings.TrimSpace(string(body))
+		return nil
+	}
+	return json.Unmarshal(body, out)
+}
@@ -0,0 +1,287 @@
+package function
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/alexellis/hmac"
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+// RepositoryEvent is sent when a repository is renamed or transferred to
+// another account
+type RepositoryEvent struct {
+	Action     string                  `json:"action"`
+	Repository sdk.PushEventRepository `json:"repository"`
+	Changes    struct {
+		Repository struct {
+			Name struct {
+				From string `json:"from"`
+			} `json:"name"`
+		} `json:"repository"`
+		Owner struct {
+			From struct {
+				User         *sdk.Owner `json:"user"`
+				Organization *sdk.Owner `json:"organization"`
+			} `json:"from"`
+		} `json:"owner"`
+	} `json:"changes"`
+	Installation sdk.PushEventInstallation `json:"installation"`
+	Sender       sdk.Sender                `json:"sender"`
+}
+
+// InstallationTargetEvent is sent when the account the app is installed on
+// is renamed
+type InstallationTargetEvent struct {
+	Action  string    `json:"action"`
+	Account sdk.Owner `json:"account"`
+	Changes struct {
+		Login struct {
+			From string `json:"from"`
+		} `json:"login"`
+	} `json:"changes"`
+	Installation sdk.PushEventInstallation `json:"installation"`
+	Sender       sdk.Sender                `json:"sender"`
+}
+
+// SecretsMigration asks import-secrets to copy the secrets of an owner to
+// its new name
+type SecretsMigration struct {
+	Owner         string `json:"owner"`
+	PreviousOwner string `json:"previous_owner"`
+}
+
+// getRepositoryMove returns where a repository was before it was renamed
+// or transferred
+func getRepositoryMove(event *RepositoryEvent) (*sdk.RepositoryMove, bool) {
+	switch event.Action {
+	case "renamed":
+		from := event.Changes.Repository.Name.From
+		if len(from) == 0 || from == event.Repository.Name {
+			return nil, false
+		}
+
+		return &sdk.RepositoryMove{
+			Owner:   event.Repository.Owner.Login,
+			OwnerID: event.Repository.Owner.ID,
+			Repo:    from,
+		}, true
+
+	case "transferred":
+		from := event.Changes.Owner.From.User
+		if from == nil {
+			from = event.Changes.Owner.From.Organization
+		}
+		if from == nil || len(from.Login) == 0 {
+			return nil, false
+		}
+
+		return &sdk.RepositoryMove{
+			Owner:   from.Login,
+			OwnerID: from.ID,
+			Repo:    event.Repository.Name,
+		}, true
+	}
+
+	return nil, false
+}
+
+// moveRepository builds a renamed or transferred repository in its new
+// place, the functions deployed from where it was are removed afterwards
+func moveRepository(event *RepositoryEvent, move *sdk.RepositoryMove) {
+	build := repositoryBuild{
+		ID:        event.Repository.ID,
+		Name:      event.Repository.Name,
+		FullName:  event.Repository.FullName,
+		MovedFrom: move,
+	}
+
+	buildRepositories(event.Installation.ID, event.Repository.Owner.Login, event.Sender, []repositoryBuild{build})
+}
+
+// moveOwner builds every repository with functions deployed under the old
+// name of the account with its new name, after copying its secrets
+func moveOwner(event *InstallationTargetEvent) error {
+	previousOwner := event.Changes.Login.From
+	owner := event.Account.Login
+	if len(previousOwner) == 0 || previousOwner == owner {
+		return nil
+	}
+
+	functions, err := listFunctions(previousOwner)
+	if err != nil {
+		return err
+	}
+
+	if err := migrateSecrets(previousOwner, owner); err != nil {
+		return fmt.Errorf("unable to migrate secrets from %s to %s: %s", previousOwner, owner, err.Error())
+	}
+
+	builds := getOwnerMoveBuilds(functions, owner, &sdk.RepositoryMove{Owner: previousOwner, OwnerID: event.Account.ID})
+	buildRepositories(event.Installation.ID, owner, event.Sender, builds)
+	return nil
+}
+
+// getOwnerMoveBuilds returns a build for each repository which functions
+// were deployed from, once for each repository
+func getOwnerMoveBuilds(functions []openFaaSFunction, owner string, move *sdk.RepositoryMove) []repositoryBuild {
+	repos := map[string]repositoryBuild{}
+	for _, fn := range functions {
+		repo := fn.Labels[sdk.FunctionLabelPrefix+"git-repo"]
+		if len(repo) == 0 {
+			continue
+		}
+		if _, ok := repos[repo]; ok {
+			continue
+		}
+
+		repoID, _ := strconv.ParseInt(fn.Labels[sdk.FunctionLabelPrefix+"git-repo-id"], 10, 64)
+		repoMove := *move
+		repoMove.Repo = repo
+
+		repos[repo] = repositoryBuild{
+			ID:        repoID,
+			Name:      repo,
+			FullName:  owner + "/" + repo,
+			MovedFrom: &repoMove,
+		}
+	}
+
+	builds := []repositoryBuild{}
+	for _, build := range repos {
+		builds = append(builds, build)
+	}
+	sort.Slice(builds, func(i, j int) bool {
+		return builds[i].Name < builds[j].Name
+	})
+	return builds
+}
+
+func removeMovedFunctions(move *sdk.RepositoryMove, repoID int64) {
+	err := garbageCollect([]GarbageRequest{{
+		Owner:     move.Owner,
+		Repo:      move.Repo,
+		Functions: []string{},
+		RepoID:    repoID,
+		OwnerID:   move.OwnerID,
+	}})
+
+	if err != nil {
+		log.Printf("unable to remove functions of %s/%s: %s", move.Owner, move.Repo, err.Error())
+	}
+}
+
+type openFaaSFunction struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels"`
+}
+
+func listFunctions(owner string) ([]openFaaSFunction, error) {
+	res, err := http.Get(os.Getenv("gateway_url") + "function/list-functions?user=" + owner)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list functions for %s: %s", owner, err.Error())
+	}
+	defer res.Body.Close()
+
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to list functions for %s: %d, %s", owner, res.StatusCode, string(body))
+	}
+
+	functions := []openFaaSFunction{}
+	if err := json.Unmarshal(body, &functions); err != nil {
+		return nil, fmt.Errorf("unable to parse functions for %s: %s", owner, err.Error())
+	}
+	return functions, nil
+}
+
+// migrateSecrets copies the secrets of the old owner name to the new one
+// so that functions can use them once they are deployed again
+func migrateSecrets(previousOwner, owner string) error {
+	payloadSecret, err := sdk.ReadSecret("payload-secret")
+	if err != nil {
+		return err
+	}
+
+	body, _ := json.Marshal(SecretsMigration{Owner: owner, PreviousOwner: previousOwner})
+	req, _ := http.NewRequest(http.MethodPost, os.Getenv("gateway_url")+"function/import-secrets", bytes.NewReader(body))
+
+	digest := hmac.Sign(body, []byte(payloadSecret))
+	req.Header.Add(sdk.CloudSignatureHeader, "sha1="+hex.EncodeToString(digest))
+	req.Header.Add("Owner", owner)
+	req.Header.Add("X-Secrets-Migration", "true")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	resBody, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("unexpected status from import-secrets: %d, %s", res.StatusCode, string(resBody))
+	}
+
+	log.Printf("import-secrets: %s", string(resBody))
+	return nil
+}
+
+// handleMove follows a repository or account which was renamed, or a
+// repository which was transferred
+func handleMove(eventHeader string, req []byte, customers *sdk.Customers) string {
+	switch eventHeader {
+	case "repository":
+		event := RepositoryEvent{}
+		if err := json.Unmarshal(req, &event); err != nil {
+			return err.Error()
+		}
+
+		move, ok := getRepositoryMove(&event)
+		if !ok {
+			return fmt.Sprintf("Ignoring repository event: %s", event.Action)
+		}
+
+		if sdk.ValidateCustomers() {
+			if err := validateCustomers(&sdk.PushEvent{Repository: event.Repository}, customers); err != nil {
+				removeMovedFunctions(move, event.Repository.ID)
+				return err.Error()
+			}
+		}
+
+		moveRepository(&event, move)
+		return fmt.Sprintf("Moved %s/%s to %s", move.Owner, move.Repo, event.Repository.FullName)
+
+	case "installation_target":
+		event := InstallationTargetEvent{}
+		if err := json.Unmarshal(req, &event); err != nil {
+			return err.Error()
+		}
+
+		if event.Action != "renamed" {
+			return fmt.Sprintf("Ignoring installation_target event: %s", event.Action)
+		}
+
+		// The functions are left as they are until the new name is a customer
+		if sdk.ValidateCustomers() {
+			customer := sdk.PushEvent{Repository: sdk.PushEventRepository{Owner: event.Account}}
+			if err := validateCustomers(&customer, customers); err != nil {
+				return err.Error()
+			}
+		}
+
+		if err := moveOwner(&event); err != nil {
+			return err.Error()
+		}
+		return fmt.Sprintf("Moved %s to %s", event.Changes.Login.From, event.Account.Login)
+	}
+
+	return fmt.Sprintf("%s cannot handle event: %s", Source, eventHeader)
+}
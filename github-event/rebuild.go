@@ -0,0 +1,125 @@
+package function
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/alexellis/hmac"
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+const checkRerequested = "rerequested"
+
+// parseRebuildRequest turns the Re-run button on one of our check runs or
+// check suites into the push event which built the commit. Re-running the
+// check run of a function builds only that function, anything else builds
+// the whole stack again.
+func parseRebuildRequest(eventHeader string, req []byte, appID string) (sdk.PushEvent, bool, error) {
+	switch eventHeader {
+	case "check_run":
+		event := CheckRunEvent{}
+		if err := json.Unmarshal(req, &event); err != nil {
+			return sdk.PushEvent{}, false, err
+		}
+
+		if event.Action != checkRerequested || !isOwnApp(event.CheckRun.App, appID) {
+			return sdk.PushEvent{}, false, nil
+		}
+
+		pushEvent := getRebuildEvent(event.Repository, event.Installation, event.Sender,
+			event.CheckRun.CheckSuite.HeadBranch, event.CheckRun.HeadSHA)
+		pushEvent.Functions = getRebuildFunctions(event.CheckRun.Name)
+		return pushEvent, true, nil
+
+	case "check_suite":
+		event := CheckSuiteEvent{}
+		if err := json.Unmarshal(req, &event); err != nil {
+			return sdk.PushEvent{}, false, err
+		}
+
+		if event.Action != checkRerequested || !isOwnApp(event.CheckSuite.App, appID) {
+			return sdk.PushEvent{}, false, nil
+		}
+
+		pushEvent := getRebuildEvent(event.Repository, event.Installation, event.Sender,
+			event.CheckSuite.HeadBranch, event.CheckSuite.HeadSHA)
+		return pushEvent, true, nil
+	}
+
+	return sdk.PushEvent{}, false, nil
+}
+
+func getRebuildEvent(repository sdk.PushEventRepository, installation sdk.PushEventInstallation, sender sdk.Sender, branch, sha string) sdk.PushEvent {
+	ref := ""
+	if len(branch) > 0 {
+		ref = "refs/heads/" + branch
+	}
+
+	return sdk.PushEvent{
+		Ref:           ref,
+		Repository:    repository,
+		AfterCommitID: sha,
+		Installation:  installation,
+		Sender:        sender,
+		Rebuild:       true,
+	}
+}
+
+// getRebuildFunctions returns the function a check run was reported for,
+// nil is returned for the checks of the stack so that all of it is built
+func getRebuildFunctions(checkName string) []string {
+	name := strings.SplitN(checkName, "/", 2)[0]
+	if len(name) == 0 || name == sdk.StackContext {
+		return nil
+	}
+	return []string{name}
+}
+
+// checkSuperseded refuses to build a commit again once a newer commit was
+// pushed, so that a Re-run of an old check can't replace the newer functions
+func checkSuperseded(pushEvent sdk.PushEvent) error {
+	ref := sdk.NewBuildRef(pushEvent.Repository.Owner.Login, pushEvent.Repository.Name, pushEvent.AfterCommitID)
+
+	newerSHA, err := sdk.GetSupersedingSHA(os.Getenv("builder_url"), ref)
+	if err != nil {
+		log.Printf("unable to get the latest build of %s/%s: %s", ref.Owner, ref.Repo, err.Error())
+		return nil
+	}
+
+	if len(newerSHA) > 0 {
+		return fmt.Errorf("%s can't be built again, it was superseded by %s", ref.SHA, newerSHA)
+	}
+	return nil
+}
+
+// sendPushEvent sends the push event to github-push as if it had come from
+// GitHub, so that it goes through the same checks as a push
+func sendPushEvent(pushEvent sdk.PushEvent) (string, error) {
+	body, _ := json.Marshal(pushEvent)
+
+	headers := map[string]string{
+		"X-GitHub-Event": "push",
+		"Content-Type":   "application/json",
+	}
+
+	if sdk.HmacEnabled() {
+		webhookSecretKey, secretErr := sdk.ReadSecret("github-webhook-secret")
+		if secretErr != nil {
+			return "", secretErr
+		}
+
+		digest := hmac.Sign(body, []byte(webhookSecretKey))
+		headers["X-Hub-Signature"] = "sha1=" + hex.EncodeToString(digest)
+	}
+
+	forwardTo := "github-push"
+	res, statusCode, err := forward(body, forwardTo, headers)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("[%s]: %d, %s", forwardTo, statusCode, res), nil
+}
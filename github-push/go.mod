@@ -3,7 +3,9 @@ module github.com/openfaas/openfaas-cloud/github-push
 go 1.13
 
 require (
+	github.com/alexellis/derek v0.0.0-20200824120721-b453a7326b67
 	github.com/alexellis/hmac v0.0.0-20180624211220-5c52ab81c0de
+	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/openfaas/faas-provider v0.0.0-20181216160432-220324e98f5d
 	github.com/openfaas/openfaas-cloud v0.0.0-20200303103051-6c3e056a6ac4
 )
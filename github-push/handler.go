@@ -8,6 +8,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/alexellis/hmac"
 	"github.com/openfaas/openfaas-cloud/sdk"
@@ -83,11 +84,49 @@ func Handle(req []byte) string {
 		return msg
 	}
 
+	// a commit which skips ci still supersedes the builds of older commits,
+	// a rebuild was checked against the newest commit by github-event and
+	// must not cancel the builds of newer commits
+	if !pushEvent.Rebuild {
+		supersedeBuilds(pushEvent)
+	}
+
+	directives := sdk.ParseCommitDirectives(pushEvent.HeadCommit)
+	if directives.Skip {
+		msg := fmt.Sprintf("skipping build for: %s, the commit message asks to skip ci", pushEvent.AfterCommitID)
+		auditEvent := sdk.AuditEvent{
+			Message: msg,
+			Owner:   pushEvent.Repository.Owner.Login,
+			Repo:    pushEvent.Repository.Name,
+			Source:  Source,
+		}
+
+		audit.Post(auditEvent)
+
+		status.AddStatus(sdk.StatusSkipped, msg, sdk.StackContext)
+		reportGitHubStatus(status)
+		return msg
+	}
+
 	serviceValue := sdk.FormatServiceName(pushEvent.Repository.Owner.Login, pushEvent.Repository.Name)
 
 	status.AddStatus(sdk.StatusPending, fmt.Sprintf("%s stack deploy is in progress", serviceValue), sdk.StackContext)
 	reportGitHubStatus(status)
 
+	if msg := directives.Describe(); len(msg) > 0 {
+		directives.Apply(&pushEvent)
+
+		audit.Post(sdk.AuditEvent{
+			Message: fmt.Sprintf("%s: %s, as asked by the commit message", pushEvent.AfterCommitID, msg),
+			Owner:   pushEvent.Repository.Owner.Login,
+			Repo:    pushEvent.Repository.Name,
+			Source:  Source,
+		})
+	}
+
+	// the token minted for the status is reused for the rest of the build
+	pushEvent.AuthToken = status.AuthToken
+
 	statusCode, postErr := postEvent(pushEvent)
 	if postErr != nil {
 		status.AddStatus(sdk.StatusFailure, postErr.Error(), sdk.StackContext)
@@ -111,6 +150,34 @@ func formatPushEvent(pushEvent sdk.PushEvent) string {
 	return pushEvent.Repository.Owner.Login + "/" + pushEvent.Repository.Name + "@" + pushEvent.Ref + "#" + pushEvent.Ref + " [" + pushEvent.Repository.CloneURL + "]"
 }
 
+// supersedeBuilds records the pushed commit as the newest commit of the repo
+// so that builds of older commits are cancelled, a failure is only logged
+// as the commit can still be built
+func supersedeBuilds(pushEvent sdk.PushEvent) {
+	payloadSecret, err := sdk.ReadSecret("payload-secret")
+	if err != nil {
+		log.Printf("unable to supersede builds: %s", err.Error())
+		return
+	}
+
+	ref := sdk.NewBuildRef(pushEvent.Repository.Owner.Login, pushEvent.Repository.Name, pushEvent.AfterCommitID)
+	ref.Before = pushEvent.BeforeCommitID
+	result, err := sdk.SupersedeBuilds(os.Getenv("builder_url"), payloadSecret, ref)
+	if err != nil {
+		log.Printf("unable to supersede builds of %s/%s: %s", ref.Owner, ref.Repo, err.Error())
+		return
+	}
+
+	if len(result.Cancelled) > 0 {
+		sdk.PostAudit(sdk.AuditEvent{
+			Message: fmt.Sprintf("%s superseded builds of: %s", ref.SHA, strings.Join(result.Cancelled, ", ")),
+			Owner:   pushEvent.Repository.Owner.Login,
+			Repo:    pushEvent.Repository.Name,
+			Source:  Source,
+		})
+	}
+}
+
 func postEvent(pushEvent sdk.PushEvent) (int, error) {
 	gatewayURL := os.Getenv("gateway_url")
 
@@ -34,6 +34,22 @@ func Test_Handle_Push_InvalidBranch(t *testing.T) {
 	}
 }
 
+func Test_Handle_Push_SkipCI(t *testing.T) {
+	audit = sdk.NilLogger{}
+	os.Setenv("Http_X_Github_Event", "push")
+	os.Setenv("validate_hmac", "false")
+	os.Setenv("validate_customers", "false")
+
+	res := Handle([]byte(
+		`{"ref":"refs/heads/master","after":"4c7b2f1e9a","head_commit":{"id":"4c7b2f1e9a","message":"Update README [skip ci]"}}`,
+	))
+
+	want := "skipping build for: 4c7b2f1e9a, the commit message asks to skip ci"
+	if res != want {
+		t.Errorf("want error: \"%s\", got: \"%s\"", want, res)
+	}
+}
+
 func Test_Handle_EmptyEvent(t *testing.T) {
 	audit = sdk.NilLogger{}
 	os.Setenv("Http_X_Github_Event", "")
@@ -0,0 +1,69 @@
+package function
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/github"
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+// Identifiers of the check run actions sent back to github-event in a
+// check_run "requested_action" event
+const (
+	approveActionIdentifier = "approve"
+	rejectActionIdentifier  = "reject"
+)
+
+const mediaTypeCheckRunsPreview = "application/vnd.github.antiope-preview+json"
+
+// checkRunAction is a button shown on a check run, the vendored go-github
+// client does not support actions so these are set with a separate request
+type checkRunAction struct {
+	Label       string `json:"label"`
+	Description string `json:"description"`
+	Identifier  string `json:"identifier"`
+}
+
+type checkRunActions struct {
+	Actions []checkRunAction `json:"actions"`
+}
+
+// getCheckRunActions returns the buttons to show for a status
+func getCheckRunActions(status string) []checkRunAction {
+	if status != sdk.StatusActionRequired {
+		return nil
+	}
+
+	return []checkRunAction{
+		{
+			Label:       "Approve",
+			Description: "Approve the deploy",
+			Identifier:  approveActionIdentifier,
+		},
+		{
+			Label:       "Reject",
+			Description: "Reject the deploy",
+			Identifier:  rejectActionIdentifier,
+		},
+	}
+}
+
+func setCheckRunActions(ctx context.Context, client *github.Client, owner, repo string, checkRunID int64, actions []checkRunAction) error {
+	return patchCheckRun(ctx, client, owner, repo, checkRunID, &checkRunActions{Actions: actions})
+}
+
+// patchCheckRun updates a check run with fields which the vendored
+// go-github client does not support
+func patchCheckRun(ctx context.Context, client *github.Client, owner, repo string, checkRunID int64, body interface{}) error {
+	u := fmt.Sprintf("repos/%v/%v/check-runs/%v", owner, repo, checkRunID)
+	req, err := client.NewRequest("PATCH", u, body)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", mediaTypeCheckRunsPreview)
+
+	_, err = client.Do(ctx, req, nil)
+	return err
+}
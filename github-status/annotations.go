@@ -0,0 +1,242 @@
+package function
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// maxAnnotations is the most annotations GitHub accepts in one request
+const maxAnnotations = 50
+
+// maxNpmErrors is how many "npm ERR!" lines are kept in an annotation
+const maxNpmErrors = 10
+
+const (
+	annotationFailure = "failure"
+	annotationWarning = "warning"
+)
+
+var (
+	// l: 2019-04-08T09:42:37Z from the of-builder log
+	logPrefix = regexp.MustCompile(`^[vsl]: \S+ `)
+
+	// function/handler.go:12:5: undefined: x
+	goError = regexp.MustCompile(`^\s*(\S+\.go):(\d+)(?::(\d+))?: (.+)$`)
+
+	// handler.ts(12,5): error TS2322: Type 'string' is not assignable
+	tscError = regexp.MustCompile(`^(\S+\.(?:ts|tsx|js|jsx))\((\d+),(\d+)\): (error|warning) (.+)$`)
+
+	// handler.ts:12:5 - error TS2322: Type 'string' is not assignable
+	tscPrettyError = regexp.MustCompile(`^(\S+\.(?:ts|tsx|js|jsx)):(\d+):(\d+) - (error|warning) (.+)$`)
+
+	// /home/app/function/handler.js followed by lines of problems
+	eslintFile = regexp.MustCompile(`^(\S+\.(?:ts|tsx|js|jsx|mjs|cjs))$`)
+
+	//   12:5  error  'x' is defined but never used  no-unused-vars
+	eslintProblem = regexp.MustCompile(`^\s+(\d+):(\d+)\s+(error|warning)\s+(.+?)(?:\s{2,}(\S+))?\s*$`)
+
+	//   File "/home/app/function/handler.py", line 12, in handle
+	pythonFrame = regexp.MustCompile(`^\s*File "([^"]+)", line (\d+)`)
+
+	// npm ERR! code ETARGET
+	npmError = regexp.MustCompile(`^npm ERR! (.*)$`)
+)
+
+// checkRunAnnotation marks a line of a file in the GitHub Checks UI
+type checkRunAnnotation struct {
+	Path            string `json:"path"`
+	StartLine       int    `json:"start_line"`
+	EndLine         int    `json:"end_line"`
+	StartColumn     int    `json:"start_column,omitempty"`
+	EndColumn       int    `json:"end_column,omitempty"`
+	AnnotationLevel string `json:"annotation_level"`
+	Title           string `json:"title,omitempty"`
+	Message         string `json:"message"`
+}
+
+type checkRunAnnotationOutput struct {
+	Title       string               `json:"title"`
+	Summary     string               `json:"summary"`
+	Text        string               `json:"text,omitempty"`
+	Annotations []checkRunAnnotation `json:"annotations"`
+}
+
+type checkRunAnnotations struct {
+	Output checkRunAnnotationOutput `json:"output"`
+}
+
+func setCheckRunAnnotations(ctx context.Context, client *github.Client, owner, repo string, checkRunID int64, output *github.CheckRunOutput, annotations []checkRunAnnotation) error {
+	body := checkRunAnnotations{
+		Output: checkRunAnnotationOutput{
+			Title:       output.GetTitle(),
+			Summary:     output.GetSummary(),
+			Text:        output.GetText(),
+			Annotations: annotations,
+		},
+	}
+
+	return patchCheckRun(ctx, client, owner, repo, checkRunID, &body)
+}
+
+// parseAnnotations finds Go, TypeScript, ESLint, Python and npm errors in a
+// build log. Paths in the build context such as build/fn1/function/handler.go
+// or /home/app/function/handler.go are mapped back to the handler folder of
+// the function in the repo.
+func parseAnnotations(logs string, handler string) []checkRunAnnotation {
+	annotations := []checkRunAnnotation{}
+	seen := map[string]bool{}
+
+	add := func(annotation checkRunAnnotation) {
+		key := fmt.Sprintf("%s:%d:%s", annotation.Path, annotation.StartLine, annotation.Message)
+		if seen[key] || len(annotations) >= maxAnnotations {
+			return
+		}
+		seen[key] = true
+		annotations = append(annotations, annotation)
+	}
+
+	var eslintPath string
+	var pythonPath string
+	var pythonLine int
+	npmErrors := []string{}
+
+	for _, line := range strings.Split(logs, "\n") {
+		line = strings.TrimRight(logPrefix.ReplaceAllString(line, ""), "\r")
+
+		if match := tscError.FindStringSubmatch(line); match != nil {
+			if repoPath, ok := mapBuildPath(match[1], handler); ok {
+				add(newAnnotation(repoPath, match[2], match[3], match[4], "tsc", match[5]))
+			}
+			continue
+		}
+
+		if match := tscPrettyError.FindStringSubmatch(line); match != nil {
+			if repoPath, ok := mapBuildPath(match[1], handler); ok {
+				add(newAnnotation(repoPath, match[2], match[3], match[4], "tsc", match[5]))
+			}
+			continue
+		}
+
+		if match := goError.FindStringSubmatch(line); match != nil {
+			if repoPath, ok := mapBuildPath(match[1], handler); ok {
+				add(newAnnotation(repoPath, match[2], match[3], annotationFailure, "go", match[4]))
+			}
+			continue
+		}
+
+		if match := eslintFile.FindStringSubmatch(line); match != nil {
+			eslintPath, _ = mapBuildPath(match[1], handler)
+			continue
+		}
+
+		if match := eslintProblem.FindStringSubmatch(line); match != nil && len(eslintPath) > 0 {
+			title := "eslint"
+			if len(match[5]) > 0 {
+				title = fmt.Sprintf("eslint (%s)", match[5])
+			}
+			add(newAnnotation(eslintPath, match[1], match[2], match[3], title, match[4]))
+			continue
+		}
+
+		if match := pythonFrame.FindStringSubmatch(line); match != nil {
+			// Keep the last frame in the function's own code
+			if repoPath, ok := mapBuildPath(match[1], handler); ok {
+				pythonPath = repoPath
+				pythonLine, _ = strconv.Atoi(match[2])
+			}
+			continue
+		}
+
+		if len(pythonPath) > 0 && len(line) > 0 && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "Traceback") {
+			add(checkRunAnnotation{
+				Path:            pythonPath,
+				StartLine:       pythonLine,
+				EndLine:         pythonLine,
+				AnnotationLevel: annotationFailure,
+				Title:           "python",
+				Message:         line,
+			})
+			pythonPath = ""
+			continue
+		}
+
+		if match := npmError.FindStringSubmatch(line); match != nil {
+			msg := strings.TrimSpace(match[1])
+			if len(msg) > 0 && len(npmErrors) < maxNpmErrors && !strings.HasPrefix(msg, "A complete log of this run") {
+				npmErrors = append(npmErrors, msg)
+			}
+			continue
+		}
+
+		eslintPath = ""
+	}
+
+	if len(npmErrors) > 0 {
+		if repoPath, ok := mapBuildPath("function/package.json", handler); ok {
+			add(checkRunAnnotation{
+				Path:            repoPath,
+				StartLine:       1,
+				EndLine:         1,
+				AnnotationLevel: annotationFailure,
+				Title:           "npm",
+				Message:         strings.Join(npmErrors, "\n"),
+			})
+		}
+	}
+
+	return annotations
+}
+
+func newAnnotation(repoPath, line, column, level, title, message string) checkRunAnnotation {
+	lineNumber, _ := strconv.Atoi(line)
+	columnNumber, _ := strconv.Atoi(column)
+
+	annotationLevel := annotationFailure
+	if level == "warning" {
+		annotationLevel = annotationWarning
+	}
+
+	return checkRunAnnotation{
+		Path:            repoPath,
+		StartLine:       lineNumber,
+		EndLine:         lineNumber,
+		StartColumn:     columnNumber,
+		EndColumn:       columnNumber,
+		AnnotationLevel: annotationLevel,
+		Title:           title,
+		Message:         message,
+	}
+}
+
+// mapBuildPath maps a path in the shrinkwrapped build context to a path
+// in the repo, i.e. build/fn1/function/handler.go to fn1/handler.go when
+// the handler of fn1 is ./fn1
+func mapBuildPath(buildPath string, handler string) (string, bool) {
+	if len(handler) == 0 {
+		return "", false
+	}
+
+	relative := !strings.HasPrefix(buildPath, "/")
+	buildPath = "/" + strings.TrimPrefix(buildPath, "./")
+
+	var rel string
+	if index := strings.Index(buildPath, "/function/"); index >= 0 {
+		rel = buildPath[index+len("/function/"):]
+	} else if relative {
+		rel = buildPath[1:]
+	} else {
+		return "", false
+	}
+
+	repoPath := path.Join(handler, rel)
+	if strings.HasPrefix(repoPath, "../") || repoPath == ".." {
+		return "", false
+	}
+	return repoPath, true
+}
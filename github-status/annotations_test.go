@@ -0,0 +1,61 @@
+package function
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_mapBuildPath(t *testing.T) {
+	cases := []struct {
+		buildPath string
+		handler   string
+		want      string
+		wantOK    bool
+	}{
+		{buildPath: "build/fn1/function/handler.go", handler: "./fn1", want: "fn1/handler.go", wantOK: true},
+		{buildPath: "/home/app/function/lib/index.js", handler: "./fns/fn1", want: "fns/fn1/lib/index.js", wantOK: true},
+		{buildPath: "./function/handler.go", handler: "fn1", want: "fn1/handler.go", wantOK: true},
+		{buildPath: "handler_test.go", handler: "./fn1", want: "fn1/handler_test.go", wantOK: true},
+		{buildPath: "/usr/local/go/src/fmt/print.go", handler: "./fn1", wantOK: false},
+		{buildPath: "function/handler.go", handler: "", wantOK: false},
+	}
+
+	for _, c := range cases {
+		got, ok := mapBuildPath(c.buildPath, c.handler)
+		if ok != c.wantOK || got != c.want {
+			t.Errorf("%s: want %q (%t), got %q (%t)", c.buildPath, c.want, c.wantOK, got, ok)
+		}
+	}
+}
+
+func Test_parseAnnotations(t *testing.T) {
+	logs := `v: 2019-04-08T09:42:37Z [build 5/8] RUN go build
+l: 2019-04-08T09:42:38Z # handler/function
+l: 2019-04-08T09:42:38Z function/handler.go:12:5: undefined: x
+l: 2019-04-08T09:42:38Z src/handler.ts(3,7): error TS2322: Type 'string' is not assignable to type 'number'.
+/home/app/function/index.js
+  4:7  warning  'unused' is assigned a value but never used  no-unused-vars
+
+Traceback (most recent call last):
+  File "/home/app/index.py", line 20, in <module>
+  File "/home/app/function/handler.py", line 8, in handle
+    return 1 / 0
+ZeroDivisionError: division by zero
+npm ERR! code ETARGET
+npm ERR! notarget No matching version found for left-pad@^9.0.0.
+npm ERR! A complete log of this run can be found in:`
+
+	got := parseAnnotations(logs, "./fn1")
+
+	want := []checkRunAnnotation{
+		{Path: "fn1/handler.go", StartLine: 12, EndLine: 12, StartColumn: 5, EndColumn: 5, AnnotationLevel: "failure", Title: "go", Message: "undefined: x"},
+		{Path: "fn1/src/handler.ts", StartLine: 3, EndLine: 3, StartColumn: 7, EndColumn: 7, AnnotationLevel: "failure", Title: "tsc", Message: "TS2322: Type 'string' is not assignable to type 'number'."},
+		{Path: "fn1/index.js", StartLine: 4, EndLine: 4, StartColumn: 7, EndColumn: 7, AnnotationLevel: "warning", Title: "eslint (no-unused-vars)", Message: "'unused' is assigned a value but never used"},
+		{Path: "fn1/handler.py", StartLine: 8, EndLine: 8, AnnotationLevel: "failure", Title: "python", Message: "ZeroDivisionError: division by zero"},
+		{Path: "fn1/package.json", StartLine: 1, EndLine: 1, AnnotationLevel: "failure", Title: "npm", Message: "code ETARGET\nnotarget No matching version found for left-pad@^9.0.0."},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want:\n%+v\ngot:\n%+v", want, got)
+	}
+}
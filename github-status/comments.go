@@ -0,0 +1,110 @@
+package function
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/google/go-github/github"
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+// Listing the pull requests for a commit is only available as a preview
+// in the vendored go-github client
+const mediaTypeListPullsForCommitPreview = "application/vnd.github.groot-preview+json"
+
+type commitPullRequest struct {
+	Number int    `json:"number"`
+	State  string `json:"state"`
+}
+
+// pullRequestComments are the comments on a pull request
+type pullRequestComments struct {
+	ctx    context.Context
+	client *github.Client
+	owner  string
+	repo   string
+	number int
+}
+
+func (p *pullRequestComments) List() ([]sdk.SummaryComment, error) {
+	comments := []sdk.SummaryComment{}
+	opts := &github.IssueListCommentsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, res, err := p.client.Issues.ListComments(p.ctx, p.owner, p.repo, p.number, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, comment := range page {
+			comments = append(comments, sdk.SummaryComment{ID: comment.GetID(), Body: comment.GetBody()})
+		}
+
+		if res.NextPage == 0 {
+			return comments, nil
+		}
+		opts.Page = res.NextPage
+	}
+}
+
+func (p *pullRequestComments) Create(body string) error {
+	_, _, err := p.client.Issues.CreateComment(p.ctx, p.owner, p.repo, p.number, &github.IssueComment{Body: &body})
+	return err
+}
+
+func (p *pullRequestComments) Update(id int64, body string) error {
+	_, _, err := p.client.Issues.EditComment(p.ctx, p.owner, p.repo, id, &github.IssueComment{Body: &body})
+	return err
+}
+
+func (p *pullRequestComments) Delete(id int64) error {
+	_, err := p.client.Issues.DeleteComment(p.ctx, p.owner, p.repo, id)
+	return err
+}
+
+func pullRequestCommentsEnabled() bool {
+	return os.Getenv("use_pr_comments") != "false"
+}
+
+// reportPullRequests keeps a summary of the build in a comment on each
+// open pull request which contains the commit
+func reportPullRequests(ctx context.Context, client *github.Client, status *sdk.Status) error {
+	summary, ok := sdk.GetFunctionSummary(status, os.Getenv("gateway_public_url"))
+	if !ok {
+		return nil
+	}
+
+	event := &status.EventInfo
+	pulls, err := listPullRequestsForCommit(ctx, client, event.Owner, event.Repository, event.SHA)
+	if err != nil {
+		return fmt.Errorf("unable to list pull requests for %s: %s", event.SHA, err.Error())
+	}
+
+	for _, pull := range pulls {
+		if pull.State != "open" {
+			continue
+		}
+
+		comments := &pullRequestComments{ctx: ctx, client: client, owner: event.Owner, repo: event.Repository, number: pull.Number}
+		if err := sdk.UpdateSummaryComment(comments, event.SHA, summary); err != nil {
+			return fmt.Errorf("pull request #%d: %s", pull.Number, err.Error())
+		}
+	}
+	return nil
+}
+
+func listPullRequestsForCommit(ctx context.Context, client *github.Client, owner, repo, sha string) ([]commitPullRequest, error) {
+	u := fmt.Sprintf("repos/%v/%v/commits/%v/pulls", owner, repo, sha)
+	req, err := client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", mediaTypeListPullsForCommitPreview)
+
+	pulls := []commitPullRequest{}
+	if _, err := client.Do(ctx, req, &pulls); err != nil {
+		return nil, err
+	}
+	return pulls, nil
+}
@@ -0,0 +1,36 @@
+package function
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func Test_listPullRequestsForCommit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/alexellis/fns/commits/af6db12/pulls" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Accept"); got != mediaTypeListPullsForCommitPreview {
+			t.Errorf("want preview media type, got: %s", got)
+		}
+		w.Write([]byte(`[{"number": 7, "state": "open"}, {"number": 3, "state": "closed"}]`))
+	}))
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	pulls, err := listPullRequestsForCommit(context.Background(), client, "alexellis", "fns", "af6db12")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if len(pulls) != 2 || pulls[0].Number != 7 || pulls[0].State != "open" {
+		t.Errorf("want pull requests 7 and 3, got %+v", pulls)
+	}
+}
@@ -0,0 +1,171 @@
+package function
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/google/go-github/github"
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+// States of a GitHub deployment status
+const (
+	githubDeploymentInProgress = "in_progress"
+	githubDeploymentSuccess    = "success"
+	githubDeploymentFailure    = "failure"
+)
+
+const defaultDeploymentEnvironment = "production"
+
+// The in_progress state and environment URLs are only accepted with
+// these previews
+const mediaTypeDeploymentsPreview = "application/vnd.github.ant-man-preview+json, application/vnd.github.flash-preview+json"
+
+// deploymentStatusRequest is sent with a separate request as the vendored
+// go-github client does not set the preview needed for in_progress
+type deploymentStatusRequest struct {
+	State          string `json:"state"`
+	Description    string `json:"description,omitempty"`
+	EnvironmentURL string `json:"environment_url,omitempty"`
+	AutoInactive   bool   `json:"auto_inactive"`
+}
+
+// deploymentUpdate is the state of the deployment of a function derived
+// from the statuses which were reported together
+type deploymentUpdate struct {
+	State       string
+	Description string
+
+	// Create is true when the function was deployed, otherwise an
+	// existing deployment is updated
+	Create bool
+}
+
+func deploymentsEnabled() bool {
+	return os.Getenv("use_deployments") != "false"
+}
+
+// getDeploymentEnvironment returns the GitHub environment for a function,
+// each function has its own environment so that the deploy of one function
+// does not mark the deployment of another as inactive
+func getDeploymentEnvironment(service string) string {
+	environment := os.Getenv("deployment_environment")
+	if len(environment) == 0 {
+		environment = defaultDeploymentEnvironment
+	}
+	return fmt.Sprintf("%s/%s", environment, service)
+}
+
+// getDeploymentUpdate works out the state of the deployment of a function
+// from the deploy stage, the function and its smoke test, nil is returned
+// when the statuses are not about a deployment
+func getDeploymentUpdate(commitStatuses map[string]sdk.CommitStatus, service string) *deploymentUpdate {
+	deployStatus, deployed := commitStatuses[sdk.BuildStageContext(service, sdk.StageDeploy)]
+	functionStatus, hasFunction := commitStatuses[sdk.BuildFunctionContext(service)]
+	smokeTestStatus, hasSmokeTest := commitStatuses[sdk.BuildSmokeTestContext(service)]
+
+	var update *deploymentUpdate
+	switch {
+	case deployed && deployStatus.Status == sdk.StatusFailure:
+		return &deploymentUpdate{State: githubDeploymentFailure, Description: deployStatus.Description, Create: true}
+	case hasFunction && functionStatus.Status == sdk.StatusFailure:
+		update = &deploymentUpdate{State: githubDeploymentFailure, Description: functionStatus.Description}
+	case hasFunction && functionStatus.Status == sdk.StatusSuccess:
+		update = &deploymentUpdate{State: githubDeploymentSuccess, Description: functionStatus.Description}
+	case deployed:
+		update = &deploymentUpdate{State: githubDeploymentInProgress, Description: deployStatus.Description}
+	default:
+		return nil
+	}
+
+	if hasSmokeTest && smokeTestStatus.Status == sdk.StatusFailure {
+		update.State = githubDeploymentFailure
+		update.Description = smokeTestStatus.Description
+	}
+
+	update.Create = deployed
+	return update
+}
+
+// reportDeployment creates a GitHub deployment for a function when it is
+// deployed and then keeps its status up to date as it becomes ready
+func reportDeployment(ctx context.Context, client *github.Client, status *sdk.Status) error {
+	event := &status.EventInfo
+	if len(event.Service) == 0 {
+		return nil
+	}
+
+	update := getDeploymentUpdate(status.CommitStatuses, event.Service)
+	if update == nil {
+		return nil
+	}
+
+	environment := getDeploymentEnvironment(event.Service)
+
+	var deploymentID int64
+	if update.Create {
+		requiredContexts := []string{}
+		autoMerge := false
+		task := "deploy"
+		description := fmt.Sprintf("Deploy %s", sdk.FormatServiceName(event.Owner, event.Service))
+
+		deployment, _, err := client.Repositories.CreateDeployment(ctx, event.Owner, event.Repository, &github.DeploymentRequest{
+			Ref:              &event.SHA,
+			Task:             &task,
+			AutoMerge:        &autoMerge,
+			RequiredContexts: &requiredContexts,
+			Environment:      &environment,
+			Description:      &description,
+		})
+		if err != nil {
+			return fmt.Errorf("unable to create deployment to %s: %s", environment, err.Error())
+		}
+		deploymentID = deployment.GetID()
+	} else {
+		deployments, _, err := client.Repositories.ListDeployments(ctx, event.Owner, event.Repository, &github.DeploymentsListOptions{
+			SHA:         event.SHA,
+			Environment: environment,
+		})
+		if err != nil {
+			return fmt.Errorf("unable to list deployments to %s: %s", environment, err.Error())
+		}
+
+		// Nothing was deployed, i.e. the build failed
+		if len(deployments) == 0 {
+			return nil
+		}
+		deploymentID = deployments[0].GetID()
+
+		statuses, _, err := client.Repositories.ListDeploymentStatuses(ctx, event.Owner, event.Repository, deploymentID, &github.ListOptions{PerPage: 1})
+		if err == nil && len(statuses) > 0 && statuses[0].GetState() == update.State {
+			return nil
+		}
+	}
+
+	request := deploymentStatusRequest{
+		State:       update.State,
+		Description: truncate(140, update.Description),
+	}
+
+	if update.State == githubDeploymentSuccess {
+		// Earlier deployments to the environment are marked as inactive
+		request.AutoInactive = true
+		request.EnvironmentURL = buildPublicStatusURL(sdk.StatusSuccess, sdk.BuildFunctionContext(event.Service), event)
+	}
+
+	return createDeploymentStatus(ctx, client, event.Owner, event.Repository, deploymentID, &request)
+}
+
+func createDeploymentStatus(ctx context.Context, client *github.Client, owner, repo string, deploymentID int64, request *deploymentStatusRequest) error {
+	u := fmt.Sprintf("repos/%v/%v/deployments/%v/statuses", owner, repo, deploymentID)
+	req, err := client.NewRequest("POST", u, request)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", mediaTypeDeploymentsPreview)
+
+	_, err = client.Do(ctx, req, nil)
+	return err
+}
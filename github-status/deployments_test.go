@@ -0,0 +1,96 @@
+package function
+
+import (
+	"os"
+	"testing"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+func Test_getDeploymentUpdate(t *testing.T) {
+	deployContext := sdk.BuildStageContext("fn1", sdk.StageDeploy)
+	functionContext := sdk.BuildFunctionContext("fn1")
+	smokeTestContext := sdk.BuildSmokeTestContext("fn1")
+
+	cases := []struct {
+		name     string
+		statuses map[string]sdk.CommitStatus
+		want     *deploymentUpdate
+	}{
+		{
+			name: "build started",
+			statuses: map[string]sdk.CommitStatus{
+				functionContext: {Status: sdk.StatusPending, Description: "fn1 function build started"},
+			},
+		},
+		{
+			name: "deployed and waiting for readiness",
+			statuses: map[string]sdk.CommitStatus{
+				deployContext:   {Status: sdk.StatusSuccess, Description: "deploy took 1.2s"},
+				functionContext: {Status: sdk.StatusPending, Description: "waiting for alexellis-fn1 to become ready"},
+			},
+			want: &deploymentUpdate{State: githubDeploymentInProgress, Description: "deploy took 1.2s", Create: true},
+		},
+		{
+			name: "deployed by the deployment gate",
+			statuses: map[string]sdk.CommitStatus{
+				deployContext:   {Status: sdk.StatusSuccess, Description: "deploy took 1.2s"},
+				functionContext: {Status: sdk.StatusSuccess, Description: "deployed: alexellis-fn1"},
+			},
+			want: &deploymentUpdate{State: githubDeploymentSuccess, Description: "deployed: alexellis-fn1", Create: true},
+		},
+		{
+			name: "deploy failed",
+			statuses: map[string]sdk.CommitStatus{
+				deployContext:   {Status: sdk.StatusFailure, Description: "deploy failed after 1.2s"},
+				functionContext: {Status: sdk.StatusFailure, Description: "http status code 500"},
+			},
+			want: &deploymentUpdate{State: githubDeploymentFailure, Description: "deploy failed after 1.2s", Create: true},
+		},
+		{
+			name: "ready",
+			statuses: map[string]sdk.CommitStatus{
+				functionContext: {Status: sdk.StatusSuccess, Description: "deployed: alexellis-fn1"},
+			},
+			want: &deploymentUpdate{State: githubDeploymentSuccess, Description: "deployed: alexellis-fn1"},
+		},
+		{
+			name: "smoke test failed",
+			statuses: map[string]sdk.CommitStatus{
+				functionContext:  {Status: sdk.StatusSuccess, Description: "deployed: alexellis-fn1"},
+				smokeTestContext: {Status: sdk.StatusFailure, Description: "smoke test failed: unexpected status 500"},
+			},
+			want: &deploymentUpdate{State: githubDeploymentFailure, Description: "smoke test failed: unexpected status 500"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := getDeploymentUpdate(c.statuses, "fn1")
+			if c.want == nil {
+				if got != nil {
+					t.Fatalf("want no update, got %+v", got)
+				}
+				return
+			}
+
+			if got == nil || *got != *c.want {
+				t.Fatalf("want %+v, got %+v", c.want, got)
+			}
+		})
+	}
+}
+
+func Test_getDeploymentEnvironment(t *testing.T) {
+	os.Setenv("deployment_environment", "")
+	if got := getDeploymentEnvironment("fn1"); got != "production/fn1" {
+		t.Errorf("want production/fn1, got %s", got)
+	}
+
+	os.Setenv("deployment_environment", "staging")
+	defer os.Unsetenv("deployment_environment")
+
+	if got := getDeploymentEnvironment("fn1"); got != "staging/fn1" {
+		t.Errorf("want staging/fn1, got %s", got)
+	}
+}
@@ -7,10 +7,11 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"time"
 
-	"github.com/alexellis/derek/auth"
 	"github.com/alexellis/derek/factory"
 	"github.com/alexellis/hmac"
 	"github.com/google/go-github/github"
@@ -26,6 +27,11 @@ const (
 	githubConclusionFailure  = "failure"
 	githubConclusionSuccess  = "success"
 	githubConclusionNeutral  = "neutral"
+
+	githubConclusionActionRequired = "action_required"
+	githubConclusionCancelled      = "cancelled"
+	githubConclusionSkipped        = "skipped"
+	githubStateError               = "error"
 )
 
 var (
@@ -64,27 +70,18 @@ func Handle(req []byte) string {
 		log.Fatal("failed commit statuses are empty: ", status.CommitStatuses)
 	}
 
-	// use auth token if provided
+	// use auth token if provided, otherwise tokens are shared with earlier
+	// requests for the installation until they expire, rather than being
+	// minted for each status
 	if status.AuthToken != sdk.EmptyAuthToken && sdk.ValidToken(status.AuthToken) {
 		token = status.AuthToken
 		log.Printf("reusing provided auth token")
 	} else {
 		var tokenErr error
-		privateKey, err := sdk.ReadSecret(defaultPrivateKeyName)
-		if err != nil {
-			fmt.Sprintf("Error reading privateKey: %v", err)
-			return "Error reading github private Key"
-		}
-		token, tokenErr = auth.MakeAccessTokenForInstallation(os.Getenv("github_app_id"), status.EventInfo.InstallationID, privateKey)
+		token, tokenErr = sdk.NewGitHubAppTokenCache().GetToken(status.EventInfo.InstallationID)
 		if tokenErr != nil {
 			log.Fatalf("failed to report status %v, error: %s\n", status, tokenErr.Error())
 		}
-
-		if token == "" {
-			log.Fatalf("failed to report status %v, error: authentication failed Invalid token\n", status)
-		}
-
-		log.Printf("auth token is created")
 	}
 
 	for _, commitStatus := range status.CommitStatuses {
@@ -94,6 +91,28 @@ func Handle(req []byte) string {
 		}
 	}
 
+	if deploymentsEnabled() || pullRequestCommentsEnabled() {
+		cfg, err := getConfig()
+		if err != nil {
+			log.Printf("failed to create GitHub client, error: %s", err.Error())
+		} else {
+			ctx := context.Background()
+			client := makeClient(ctx, token, cfg)
+
+			if deploymentsEnabled() {
+				if err := reportDeployment(ctx, client, status); err != nil {
+					log.Printf("failed to report deployment, error: %s", err.Error())
+				}
+			}
+
+			if pullRequestCommentsEnabled() {
+				if err := reportPullRequests(ctx, client, status); err != nil {
+					log.Printf("failed to comment on pull request, error: %s", err.Error())
+				}
+			}
+		}
+	}
+
 	// marshal token
 	token = sdk.MarshalToken(token)
 
@@ -125,42 +144,81 @@ func getLogs(status *sdk.CommitStatus, event *sdk.Event) (string, error) {
 }
 
 func reportToGithub(commitStatus *sdk.CommitStatus, event *sdk.Event) error {
+	cfg, err := getConfig()
+	if err != nil {
+		return err
+	}
+
+	appID := sdk.GetGitHubAppID()
+	if os.Getenv("use_checks") == "false" {
+		return reportStatus(commitStatus.Status, commitStatus.Description, appID, event, cfg)
+	}
+	return reportCheck(commitStatus, event, cfg)
+}
+
+// getConfig reads the secrets used to create a GitHub client
+func getConfig() (config.Config, error) {
 	secretKey, err := sdk.ReadSecret(defaultPayloadSecretName)
 	if err != nil {
 		log.Printf("reusing provided auth token")
 		log.Printf("Error reading secretKey: %v", err)
-		return err
+		return config.Config{}, err
 	}
 	privateKey, err := sdk.ReadSecret(defaultPrivateKeyName)
 	if err != nil {
 		log.Printf("Error reading privateKey: %v", err)
-		return err
+		return config.Config{}, err
 	}
 
-	appID := os.Getenv("github_app_id")
-	cfg := config.Config{
+	return config.Config{
 		SecretKey:     secretKey,
 		PrivateKey:    privateKey,
-		ApplicationID: appID,
-	}
-	if os.Getenv("use_checks") == "false" {
-		return reportStatus(commitStatus.Status, commitStatus.Description, appID, event, cfg)
+		ApplicationID: sdk.GetGitHubAppID(),
+	}, nil
+}
+
+// makeClient creates a client for the API of GitHub, or of the GitHub
+// Enterprise Server given by github_url
+func makeClient(ctx context.Context, accessToken string, cfg config.Config) *github.Client {
+	client := factory.MakeClient(ctx, accessToken, cfg)
+
+	apiURL, err := url.Parse(sdk.GetGitHubURLs().APIURL + "/")
+	if err != nil {
+		log.Printf("invalid GitHub API URL, error: %s", err.Error())
+		return client
 	}
-	return reportCheck(commitStatus, event, cfg)
+
+	client.BaseURL = apiURL
+	return client
 }
 
 func reportStatus(status string, desc string, statusContext string, event *sdk.Event, cfg config.Config) error {
-	appID := os.Getenv("github_app_id")
+	appID := sdk.GetGitHubAppID()
 
 	ctx := context.Background()
 
 	url := buildPublicStatusURL(status, statusContext, event)
 
+	// The Status API has no state for a deploy waiting on approval
+	if status == sdk.StatusActionRequired {
+		status = sdk.StatusPending
+	}
+
+	// or for a build which was superseded by a newer commit
+	if status == sdk.StatusSuperseded {
+		status = githubStateError
+	}
+
+	// or for a commit which asked not to be built
+	if status == sdk.StatusSkipped {
+		status = sdk.StatusSuccess
+	}
+
 	repoStatus := buildStatus(status, desc, statusContext, url)
 
 	log.Printf("Status: %s, Context: %s, GitHub AppID: %s, Repo: %s, Owner: %s", status, statusContext, appID, event.Repository, event.Owner)
 
-	client := factory.MakeClient(ctx, token, cfg)
+	client := makeClient(ctx, token, cfg)
 
 	_, _, apiErr := client.Repositories.CreateStatus(ctx, event.Owner, event.Repository, event.SHA, repoStatus)
 	if apiErr != nil {
@@ -172,15 +230,16 @@ func reportStatus(status string, desc string, statusContext string, event *sdk.E
 
 func reportCheck(commitStatus *sdk.CommitStatus, event *sdk.Event, cfg config.Config) error {
 	ctx := context.Background()
-	appID := os.Getenv("github_app_id")
+	appID := sdk.GetGitHubAppID()
 	status := commitStatus.Status
 	url := buildPublicStatusURL(commitStatus.Status, commitStatus.Context, event)
 
 	log.Printf("Check: %s, Context: %s, GitHub AppID: %s, Repo: %s, Owner: %s", status, commitStatus.Context, appID, event.Repository, event.Owner)
 
-	client := factory.MakeClient(ctx, token, cfg)
+	client := makeClient(ctx, token, cfg)
 
 	now := github.Timestamp{time.Now()}
+	startedAt, completedAt := getCheckRunTimes(commitStatus, now)
 
 	logs, err := getLogs(commitStatus, event)
 	if err != nil {
@@ -201,13 +260,15 @@ func reportCheck(commitStatus *sdk.CommitStatus, event *sdk.Event, cfg config.Co
 	summary := getCheckRunDescription(commitStatus, &url)
 	log.Printf("Check run status: %s", checkRunStatus)
 
+	var checkRunID int64
 	var apiErr error
 	if *checks.Total == 0 {
 		check := github.CreateCheckRunOptions{
-			StartedAt: &now,
-			Name:      commitStatus.Context,
-			HeadSHA:   event.SHA,
-			Status:    &checkRunStatus,
+			StartedAt:  &startedAt,
+			Name:       commitStatus.Context,
+			HeadSHA:    event.SHA,
+			DetailsURL: &url,
+			Status:     &checkRunStatus,
 			Output: &github.CheckRunOutput{
 				Text:    &logValue,
 				Title:   getCheckRunTitle(commitStatus),
@@ -217,10 +278,14 @@ func reportCheck(commitStatus *sdk.CommitStatus, event *sdk.Event, cfg config.Co
 
 		if checkRunStatus == githubCheckCompleted {
 			check.Conclusion = &conclusion
-			check.CompletedAt = &now
+			check.CompletedAt = &completedAt
 		}
 		log.Printf("Creating check run %s", check.Name)
-		_, _, apiErr = client.Checks.CreateCheckRun(ctx, event.Owner, event.Repository, check)
+		var checkRun *github.CheckRun
+		checkRun, _, apiErr = client.Checks.CreateCheckRun(ctx, event.Owner, event.Repository, check)
+		if checkRun != nil && checkRun.ID != nil {
+			checkRunID = *checkRun.ID
+		}
 	} else {
 		check := github.UpdateCheckRunOptions{
 			Name:       *checks.CheckRuns[0].Name,
@@ -233,14 +298,36 @@ func reportCheck(commitStatus *sdk.CommitStatus, event *sdk.Event, cfg config.Co
 		}
 		if checkRunStatus == "completed" {
 			check.Conclusion = &conclusion
-			check.CompletedAt = &now
+			check.CompletedAt = &completedAt
 		}
 		_, _, apiErr = client.Checks.UpdateCheckRun(ctx, event.Owner, event.Repository, *checks.CheckRuns[0].ID, check)
+		checkRunID = *checks.CheckRuns[0].ID
 		log.Printf("Creating check run %s", check.Name)
 	}
 	if apiErr != nil {
 		return fmt.Errorf("failed to report status %s, error: %s", status, apiErr.Error())
 	}
+
+	if actions := getCheckRunActions(status); len(actions) > 0 && checkRunID > 0 {
+		if err := setCheckRunActions(ctx, client, event.Owner, event.Repository, checkRunID, actions); err != nil {
+			return fmt.Errorf("failed to set actions for check run %d, error: %s", checkRunID, err.Error())
+		}
+	}
+
+	// Errors from the build log are shown against the file and line in the repo
+	if commitStatus.Context == sdk.BuildFunctionContext(event.Service) && checkRunStatus == githubCheckCompleted && checkRunID > 0 {
+		if annotations := parseAnnotations(logs, event.Handler); len(annotations) > 0 {
+			output := &github.CheckRunOutput{
+				Text:    &logValue,
+				Title:   getCheckRunTitle(commitStatus),
+				Summary: summary,
+			}
+
+			if err := setCheckRunAnnotations(ctx, client, event.Owner, event.Repository, checkRunID, output, annotations); err != nil {
+				log.Printf("failed to set %d annotations for check run %d, error: %s", len(annotations), checkRunID, err.Error())
+			}
+		}
+	}
 	return nil
 }
 
@@ -251,6 +338,12 @@ func getCheckRunStatus(status *string) string {
 		return githubCheckCompleted
 	case sdk.StatusSuccess:
 		return githubCheckCompleted
+	case sdk.StatusActionRequired:
+		return githubCheckCompleted
+	case sdk.StatusSuperseded:
+		return githubCheckCompleted
+	case sdk.StatusSkipped:
+		return githubCheckCompleted
 	}
 	return githubCheckQueued
 }
@@ -262,16 +355,41 @@ func getCheckRunConclusion(status *string) string {
 		return githubConclusionFailure
 	case sdk.StatusSuccess:
 		return githubConclusionSuccess
+	case sdk.StatusActionRequired:
+		return githubConclusionActionRequired
+	case sdk.StatusSuperseded:
+		return githubConclusionCancelled
+	case sdk.StatusSkipped:
+		return githubConclusionSkipped
 	}
 	return githubConclusionNeutral
 }
 
+// getCheckRunTimes returns the start and end time of a check run, which
+// are those of the stage when the status is for a stage of the pipeline
+func getCheckRunTimes(status *sdk.CommitStatus, now github.Timestamp) (github.Timestamp, github.Timestamp) {
+	startedAt, completedAt := now, now
+	if status.Started != nil {
+		startedAt = github.Timestamp{Time: *status.Started}
+	}
+	if status.Completed != nil {
+		completedAt = github.Timestamp{Time: *status.Completed}
+	}
+	return startedAt, completedAt
+}
+
 // getCheckRunTitle returns a title for the given status to be displayed in Github Checks UI
 func getCheckRunTitle(status *sdk.CommitStatus) *string {
 	title := status.Description
-	switch status.Context {
-	case sdk.StackContext:
+	switch {
+	case len(status.Stage) > 0:
+		title = fmt.Sprintf("%s: %s", strings.TrimSuffix(status.Context, "/"+status.Stage), status.Description)
+	case status.Context == sdk.StackContext:
 		title = "Deploy to OpenFaaS"
+	case strings.HasSuffix(status.Context, "/smoke-test"):
+		title = fmt.Sprintf("Smoke test %s", strings.TrimSuffix(status.Context, "/smoke-test"))
+	case strings.HasSuffix(status.Context, "/test"):
+		title = fmt.Sprintf("Test %s", strings.TrimSuffix(status.Context, "/test"))
 	default: // Assuming status is either a function name (building) or stack deploy
 		title = fmt.Sprintf("Build %s", status.Context)
 	}
@@ -280,12 +398,16 @@ func getCheckRunTitle(status *sdk.CommitStatus) *string {
 
 // getCheckRunDescription returns a formatted summary for the Check Run page
 func getCheckRunDescription(status *sdk.CommitStatus, url *string) *string {
+	s := status.Description
 	if status.Status == sdk.StatusSuccess || status.Status == sdk.StatusFailure {
-		s := fmt.Sprintf("[%s](%s)", status.Description, *url)
-		return &s
+		s = fmt.Sprintf("[%s](%s)", status.Description, *url)
+	}
+
+	if len(status.Summary) > 0 {
+		s = fmt.Sprintf("%s\n\n%s", s, status.Summary)
 	}
 
-	return &status.Description
+	return &s
 }
 
 func buildStatus(status string, desc string, context string, url string) *github.RepoStatus {
@@ -1,10 +1,16 @@
 package function
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/alexellis/derek/config"
+	"github.com/google/go-github/github"
 	"github.com/openfaas/openfaas-cloud/sdk"
 )
 
@@ -302,6 +308,64 @@ func TestGetCheckRunTitle(t *testing.T) {
 	if *title != "Build hello-go" {
 		t.Fatalf("Expected %s but got %s", "Build hello-go", *title)
 	}
+
+	status.Context = sdk.BuildSmokeTestContext("hello-go")
+	title = getCheckRunTitle(status)
+	if *title != "Smoke test hello-go" {
+		t.Fatalf("Expected %s but got %s", "Smoke test hello-go", *title)
+	}
+
+	status.Context = sdk.BuildTestContext("hello-go")
+	title = getCheckRunTitle(status)
+	if *title != "Test hello-go" {
+		t.Fatalf("Expected %s but got %s", "Test hello-go", *title)
+	}
+
+	status.Context = sdk.BuildStageContext("hello-go", sdk.StagePush)
+	status.Stage = sdk.StagePush
+	status.Description = "push took 4.2s"
+	title = getCheckRunTitle(status)
+	if *title != "hello-go: push took 4.2s" {
+		t.Fatalf("Expected %s but got %s", "hello-go: push took 4.2s", *title)
+	}
+}
+
+func TestGetCheckRunTimes_Stage(t *testing.T) {
+	now := github.Timestamp{Time: time.Unix(1586952100, 0)}
+	started := time.Unix(1586952000, 0)
+	completed := time.Unix(1586952030, 0)
+
+	status := &sdk.CommitStatus{
+		Context:   sdk.BuildStageContext("hello-go", sdk.StageBuild),
+		Stage:     sdk.StageBuild,
+		Started:   &started,
+		Completed: &completed,
+	}
+
+	startedAt, completedAt := getCheckRunTimes(status, now)
+	if !startedAt.Time.Equal(started) || !completedAt.Time.Equal(completed) {
+		t.Fatalf("Expected %s to %s, got %s to %s", started, completed, startedAt, completedAt)
+	}
+
+	startedAt, completedAt = getCheckRunTimes(&sdk.CommitStatus{Context: "hello-go"}, now)
+	if !startedAt.Time.Equal(now.Time) || !completedAt.Time.Equal(now.Time) {
+		t.Fatalf("Expected %s, got %s to %s", now, startedAt, completedAt)
+	}
+}
+
+func TestGetCheckRunDescription_Summary(t *testing.T) {
+	url := "https://system.o6s.io/dashboard/alexellis/hello-go"
+	status := &sdk.CommitStatus{
+		Context:     sdk.BuildTestContext("hello-go"),
+		Description: "1 passed, 1 failed, 0 skipped: TestHandle",
+		Status:      sdk.StatusFailure,
+		Summary:     "* `TestHandle`",
+	}
+
+	want := "[1 passed, 1 failed, 0 skipped: TestHandle](https://system.o6s.io/dashboard/alexellis/hello-go)\n\n* `TestHandle`"
+	if got := getCheckRunDescription(status, &url); *got != want {
+		t.Fatalf("Expected %s, got %s", want, *got)
+	}
 }
 
 func TestGetCheckRunStatus(t *testing.T) {
@@ -322,6 +386,56 @@ func TestGetCheckRunStatus(t *testing.T) {
 	if checkStatus != "queued" {
 		t.Fatalf("Expected %s, got %s", "queued", checkStatus)
 	}
+
+	status = sdk.StatusActionRequired
+	checkStatus = getCheckRunStatus(&status)
+	if checkStatus != "completed" {
+		t.Fatalf("Expected %s, got %s", "completed", checkStatus)
+	}
+
+	status = sdk.StatusSuperseded
+	checkStatus = getCheckRunStatus(&status)
+	if checkStatus != "completed" {
+		t.Fatalf("Expected %s, got %s", "completed", checkStatus)
+	}
+}
+
+func TestGetCheckRunConclusion_Superseded(t *testing.T) {
+	status := sdk.StatusSuperseded
+	conclusion := getCheckRunConclusion(&status)
+	if conclusion != "cancelled" {
+		t.Fatalf("Expected %s, got %s", "cancelled", conclusion)
+	}
+}
+
+func TestGetCheckRunConclusion_Skipped(t *testing.T) {
+	status := sdk.StatusSkipped
+	if checkStatus := getCheckRunStatus(&status); checkStatus != "completed" {
+		t.Fatalf("Expected %s, got %s", "completed", checkStatus)
+	}
+
+	conclusion := getCheckRunConclusion(&status)
+	if conclusion != "skipped" {
+		t.Fatalf("Expected %s, got %s", "skipped", conclusion)
+	}
+}
+
+func TestGetCheckRunConclusion_ActionRequired(t *testing.T) {
+	status := sdk.StatusActionRequired
+	conclusion := getCheckRunConclusion(&status)
+	if conclusion != "action_required" {
+		t.Fatalf("Expected %s, got %s", "action_required", conclusion)
+	}
+
+	actions := getCheckRunActions(status)
+	if len(actions) != 2 || actions[0].Identifier != approveActionIdentifier {
+		t.Fatalf("Expected approve and reject actions, got %v", actions)
+	}
+
+	status = sdk.StatusPending
+	if actions := getCheckRunActions(status); len(actions) != 0 {
+		t.Fatalf("Expected no actions for %s, got %v", status, actions)
+	}
 }
 
 // Test_formatLog tests formatting for the GitHub Checks API
@@ -391,3 +505,25 @@ func Test_truncate(t *testing.T) {
 		})
 	}
 }
+
+func Test_makeClient_EnterpriseServer(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/alexellis/repo1/statuses/4c7b2f1e9a" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"state": "success"}`))
+	}))
+	defer s.Close()
+
+	os.Setenv("github_url", s.URL)
+	defer os.Unsetenv("github_url")
+
+	ctx := context.Background()
+	client := makeClient(ctx, "installation-token", config.Config{})
+
+	state := sdk.StatusSuccess
+	if _, _, err := client.Repositories.CreateStatus(ctx, "alexellis", "repo1", "4c7b2f1e9a", &github.RepoStatus{State: &state}); err != nil {
+		t.Fatal(err)
+	}
+}
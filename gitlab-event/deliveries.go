@@ -0,0 +1,45 @@
+package function
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+// getDeliveryID returns the X-Gitlab-Event-UUID of an event, older GitLab
+// versions don't send it so the event itself identifies the delivery
+func getDeliveryID(req []byte) string {
+	if uuid := os.Getenv("Http_X_Gitlab_Event_Uuid"); len(uuid) > 0 {
+		return uuid
+	}
+
+	sum := sha256.Sum256(req)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// checkDelivery records the ID of an event, GitLab delivers an event again
+// when it doesn't get a response in time so a delivery which was already
+// recorded is a duplicate. Deliveries are handled when they can't be
+// recorded.
+func checkDelivery(deliveries sdk.KeySet, deliveryID string) (bool, string) {
+	added, err := deliveries.Add(deliveryID)
+	if err != nil {
+		log.Printf("unable to record delivery %s: %s", deliveryID, err.Error())
+		return false, ""
+	}
+
+	if !added {
+		return true, fmt.Sprintf("Ignoring duplicate delivery: %s", deliveryID)
+	}
+	return false, ""
+}
+
+func forgetDelivery(deliveries sdk.KeySet, deliveryID string) {
+	if err := deliveries.Remove(deliveryID); err != nil {
+		log.Printf("unable to forget delivery %s: %s", deliveryID, err.Error())
+	}
+}
@@ -18,32 +18,49 @@ import (
 
 // Source name for this function when auditing
 const (
-	Source              = "gitlab-event"
-	EventSource         = "System Hook"
-	PushEvent           = "push"
-	ProjectUpdateEvent  = "project_update"
-	ProjectDestroyEvent = "project_destroy"
+	Source               = "gitlab-event"
+	EventSource          = "System Hook"
+	PushHookSource       = "Push Hook"
+	TagPushHookSource    = "Tag Push Hook"
+	PushEvent            = "push"
+	TagPushEvent         = "tag_push"
+	ProjectUpdateEvent   = "project_update"
+	ProjectDestroyEvent  = "project_destroy"
+	ProjectRenameEvent   = "project_rename"
+	ProjectTransferEvent = "project_transfer"
 )
 
 var (
-	supportedEvents = [...]string{PushEvent, ProjectUpdateEvent, ProjectDestroyEvent}
+	supportedEvents = [...]string{PushEvent, TagPushEvent, ProjectUpdateEvent, ProjectDestroyEvent, ProjectRenameEvent, ProjectTransferEvent}
+
+	// hookSources are the X-Gitlab-Event headers of the system hook and of
+	// project and group webhooks, which name the header after the event
+	hookSources = [...]string{EventSource, PushHookSource, TagPushHookSource}
 )
 
 // Handle is the function which accepts events from
 // GitLab and filters them also checks if the repository
-// is installed on the cloud
+// is installed on the cloud. Events come from the system
+// hook, or as pushes from project and group webhooks.
+// Pipeline events from project webhooks are passed on to
+// the deployment-gate.
 func Handle(req []byte) string {
 	eventHeader := os.Getenv("Http_X_Gitlab_Event")
 	xGitlabToken := os.Getenv("Http_X_Gitlab_Token")
 
-	if eventHeader != EventSource {
+	if eventHeader == PipelineEventSource {
+		return handlePipeline(req, xGitlabToken)
+	}
+
+	if !checkHookSource(eventHeader) {
+		required := strings.Join(hookSources[:], ", ")
 		auditEvent := sdk.AuditEvent{
-			Message: "required : " + EventSource,
+			Message: "required : " + required,
 			Source:  Source,
 		}
 		sdk.PostAudit(auditEvent)
 
-		return fmt.Sprintf("%s: %s required cannot handle: %s", Source, EventSource, eventHeader)
+		return fmt.Sprintf("%s: one of %s required cannot handle: %s", Source, required, eventHeader)
 	}
 
 	eventName := PureEvent{}
@@ -52,14 +69,15 @@ func Handle(req []byte) string {
 		return fmt.Sprintf("error while un-marshaling event: %s", unmarshalErr.Error())
 	}
 
-	if !checkSupportedEvents(eventName.Event) {
+	event := eventName.Name()
+	if !checkSupportedEvents(event) || !checkEventSource(eventHeader, event) {
 		auditEvent := sdk.AuditEvent{
-			Message: "bad event: " + eventName.Event,
+			Message: "bad event: " + event,
 			Source:  Source,
 		}
 		sdk.PostAudit(auditEvent)
 
-		return fmt.Sprintf("%s cannot handle event: %s", Source, eventName.Event)
+		return fmt.Sprintf("%s cannot handle event: %s", Source, event)
 	}
 
 	if readBool("validate_token") {
@@ -72,6 +90,12 @@ func Handle(req []byte) string {
 		}
 	}
 
+	deliveryID := getDeliveryID(req)
+	deliveries := sdk.NewDeliverySet(sdk.CreateServiceURL(os.Getenv("gateway_url"), os.Getenv("dns_suffix")))
+	if duplicate, msg := checkDelivery(deliveries, deliveryID); duplicate {
+		return msg
+	}
+
 	var instance string
 	if instanceURL, exist := os.LookupEnv("gitlab_instance"); exist {
 		instance = instanceURL
@@ -93,26 +117,27 @@ func Handle(req []byte) string {
 	customersURL := os.Getenv("customers_url")
 
 	customers := sdk.NewCustomers(customersPath, customersURL)
+	customers.Membership = sdk.NewGitLabMembership(strings.TrimSuffix(instance, "/")+"/api/v4", apiToken)
 	customers.Fetch()
 
-	switch eventName.Event {
-	case PushEvent:
+	switch event {
+	case PushEvent, TagPushEvent:
 		eventInfo := sdk.GitLabPushEvent{}
 		unmarshalErr := json.Unmarshal(req, &eventInfo)
 		if unmarshalErr != nil {
 			return fmt.Sprintf("unable to unmarshal request into eventInfo struct: %s", unmarshalErr.Error())
 		}
 
-		username, usernameErr := getUser(eventInfo.GitLabProject.PathWithNamespace)
-		if usernameErr != nil {
-			return fmt.Sprintf("error while formatting username: %s", usernameErr.Error())
+		namespace, namespaceErr := getNamespace(eventInfo.GitLabProject.PathWithNamespace)
+		if namespaceErr != nil {
+			return fmt.Sprintf("error while formatting namespace: %s", namespaceErr.Error())
 		}
 
 		if readBool("validate_customers") {
 
-			if valid, err := customers.Get(username); valid == false || err != nil {
+			if valid, err := isCustomer(customers, namespace); valid == false || err != nil {
 				if err != nil {
-					log.Printf("error getting customer: %q, %s", username, err.Error())
+					log.Printf("error getting customer: %q, %s", namespace, err.Error())
 				}
 
 				auditEvent := sdk.AuditEvent{
@@ -125,7 +150,12 @@ func Handle(req []byte) string {
 			}
 		}
 
-		installed, err := appInstalled(eventInfo.GitLabProject.ID, instance, apiToken, installationTag)
+		projectToken, err := getProjectToken(eventInfo.GitLabProject.PathWithNamespace)
+		if err != nil {
+			return err.Error()
+		}
+
+		installed, err := appInstalled(eventInfo.GetProjectID(), instance, projectToken, installationTag)
 		if err != nil {
 			return fmt.Sprintf("error while trying to connect to GitLab API: %s", err.Error())
 		}
@@ -138,6 +168,8 @@ func Handle(req []byte) string {
 
 			body, statusCode, err := forward(req, "gitlab-push", headers)
 			if err != nil {
+				// GitLab can deliver the push again when it wasn't forwarded
+				forgetDelivery(deliveries, deliveryID)
 				return fmt.Sprintf("error while forwarding to gitlab-push: %s", err.Error())
 			}
 			if statusCode == http.StatusOK {
@@ -155,30 +187,35 @@ func Handle(req []byte) string {
 			return fmt.Sprintf("error while un-marshaling eventInfo: %s", unmarshalErr.Error())
 		}
 
-		username, usernameErr := getUser(eventInfo.PathWithNamespace)
-		if usernameErr != nil {
-			return fmt.Sprintf("error while formatting username: %s", usernameErr.Error())
+		namespace, namespaceErr := getNamespace(eventInfo.PathWithNamespace)
+		if namespaceErr != nil {
+			return fmt.Sprintf("error while formatting namespace: %s", namespaceErr.Error())
 		}
 
 		if readBool("validate_customers") {
 
-			if valid, err := customers.Get(username); valid == false || err != nil {
+			if valid, err := isCustomer(customers, namespace); valid == false || err != nil {
 				if err != nil {
-					log.Printf("error getting customer: %q, %s", username, err.Error())
+					log.Printf("error getting customer: %q, %s", namespace, err.Error())
 				}
 
 				auditEvent := sdk.AuditEvent{
 					Message: "Customer not found",
-					Owner:   username,
+					Owner:   namespace,
 					Source:  Source,
 				}
 				sdk.PostAudit(auditEvent)
 
-				return fmt.Sprintf("Customer: %s not found in CUSTOMERS file via %s", username, customersURL)
+				return fmt.Sprintf("Customer: %s not found in CUSTOMERS file via %s", namespace, customersURL)
 			}
 		}
 
-		installed, err := appInstalled(eventInfo.ProjectID, instance, apiToken, installationTag)
+		projectToken, err := getProjectToken(eventInfo.PathWithNamespace)
+		if err != nil {
+			return err.Error()
+		}
+
+		installed, err := appInstalled(eventInfo.ProjectID, instance, projectToken, installationTag)
 		if err != nil {
 			return fmt.Sprintf("error while trying to connect to GitLab API: %s", err.Error())
 		}
@@ -186,7 +223,7 @@ func Handle(req []byte) string {
 			garbageRequest := []GarbageRequest{}
 			garbageRequest = append(garbageRequest,
 				GarbageRequest{
-					Owner:     username,
+					Owner:     sdk.FormatGitLabOwner(namespace),
 					Repo:      eventInfo.Name,
 					Functions: []string{},
 				})
@@ -197,6 +234,41 @@ func Handle(req []byte) string {
 
 			return fmt.Sprintf("Function: `%s` deleted", eventInfo.Name)
 		}
+
+	case ProjectRenameEvent, ProjectTransferEvent:
+		eventInfo := GitLabProjectMoveEvent{}
+		unmarshalErr := json.Unmarshal(req, &eventInfo)
+		if unmarshalErr != nil {
+			return fmt.Sprintf("error while un-marshaling eventInfo: %s", unmarshalErr.Error())
+		}
+
+		namespace, namespaceErr := getNamespace(eventInfo.PathWithNamespace)
+		if namespaceErr != nil {
+			return fmt.Sprintf("error while formatting namespace: %s", namespaceErr.Error())
+		}
+
+		projectToken, err := getProjectToken(eventInfo.PathWithNamespace)
+		if err != nil {
+			return err.Error()
+		}
+
+		installed, err := appInstalled(eventInfo.ProjectID, instance, projectToken, installationTag)
+		if err != nil {
+			return fmt.Sprintf("error while trying to connect to GitLab API: %s", err.Error())
+		}
+
+		// Functions where the project was are removed when it moves to a
+		// namespace which isn't a customer
+		if readBool("validate_customers") {
+			if valid, err := isCustomer(customers, namespace); valid == false || err != nil {
+				if err != nil {
+					log.Printf("error getting customer: %q, %s", namespace, err.Error())
+				}
+				installed = false
+			}
+		}
+
+		return moveProject(&eventInfo, installed, instance, projectToken, xGitlabToken)
 	}
 	return fmt.Sprintf("Message received with event: %s", eventName.Event)
 }
@@ -255,6 +327,8 @@ type GarbageRequest struct {
 	Functions []string `json:"functions"`
 	Repo      string   `json:"repo"`
 	Owner     string   `json:"owner"`
+	RepoID    int64    `json:"repo_id,omitempty"`
+	OwnerID   int64    `json:"owner_id,omitempty"`
 }
 
 func forward(req []byte, function string, headers map[string]string) (string, int, error) {
@@ -310,6 +384,21 @@ func forward(req []byte, function string, headers map[string]string) (string, in
 	return string(body), res.StatusCode, err
 }
 
+// getProjectToken returns the token the owners of the project sealed for
+// it, or the gitlab-api-token secret
+func getProjectToken(pathWithNamespace string) (string, error) {
+	payloadSecret, err := sdk.ReadSecret("payload-secret")
+	if err != nil {
+		return "", fmt.Errorf("unable to read payload-secret: %s", err.Error())
+	}
+
+	credential, err := sdk.GetGitLabCredential(os.Getenv("gateway_url"), payloadSecret, pathWithNamespace, true)
+	if err != nil {
+		return "", err
+	}
+	return credential.Token, nil
+}
+
 func appInstalled(id int, instance, apiToken, installationTag string) (bool, error) {
 	wholeURL := instance + "/api/v4/projects/" + strconv.Itoa(id)
 
@@ -382,11 +471,30 @@ func getCustomers(customerURL string) ([]string, error) {
 	return customers, nil
 }
 
-func getUser(pathWithNamespace string) (string, error) {
-	if !strings.Contains(pathWithNamespace, "/") {
+// getNamespace returns the full path of the user, group or subgroup which
+// a project belongs to
+func getNamespace(pathWithNamespace string) (string, error) {
+	namespace, err := sdk.GetGitLabNamespace(pathWithNamespace)
+	if err != nil {
 		return "", fmt.Errorf("un-proper format of the variable possible out of range error")
 	}
-	return pathWithNamespace[:strings.Index(pathWithNamespace, "/")], nil
+	return namespace, nil
+}
+
+// isCustomer checks the namespace of a project and each of its parent
+// groups, so that listing a group also lets in the projects of its subgroups
+func isCustomer(customers *sdk.Customers, namespace string) (bool, error) {
+	var lastErr error
+	for _, path := range sdk.GitLabNamespacePaths(namespace) {
+		found, err := customers.Get(path)
+		if found {
+			return true, nil
+		}
+		if err != nil {
+			lastErr = err
+		}
+	}
+	return false, lastErr
 }
 
 type GitLabProjectEvent struct {
@@ -399,8 +507,42 @@ type GitLabProjectTags struct {
 	TagList []string `json:"tag_list"`
 }
 
+// PureEvent names the event, system hooks give it as event_name and
+// webhooks of older GitLab versions only give it as object_kind
 type PureEvent struct {
-	Event string `json:"event_name"`
+	Event      string `json:"event_name"`
+	ObjectKind string `json:"object_kind"`
+}
+
+// Name of the event
+func (e PureEvent) Name() string {
+	if len(e.Event) > 0 {
+		return e.Event
+	}
+	return e.ObjectKind
+}
+
+func checkHookSource(eventHeader string) bool {
+	for _, source := range hookSources {
+		if source == eventHeader {
+			return true
+		}
+	}
+	return false
+}
+
+// checkEventSource is true when the hook which sent the event can send it,
+// project and group webhooks only send pushes and tag pushes
+func checkEventSource(eventHeader, event string) bool {
+	switch eventHeader {
+	case EventSource:
+		return true
+	case PushHookSource:
+		return event == PushEvent
+	case TagPushHookSource:
+		return event == TagPushEvent
+	}
+	return false
 }
 
 func checkSupportedEvents(event string) bool {
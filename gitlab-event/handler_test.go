@@ -1,8 +1,18 @@
 package function
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
 )
 
 func Test_checkSupportedEvents(t *testing.T) {
@@ -16,6 +26,11 @@ func Test_checkSupportedEvents(t *testing.T) {
 			event:        "push",
 			expectedBool: true,
 		},
+		{
+			title:        "Supported `tag_push` event",
+			event:        "tag_push",
+			expectedBool: true,
+		},
 		{
 			title:        "Supported `project_update` event",
 			event:        "project_update",
@@ -26,6 +41,16 @@ func Test_checkSupportedEvents(t *testing.T) {
 			event:        "project_destroy",
 			expectedBool: true,
 		},
+		{
+			title:        "Supported `project_rename` event",
+			event:        "project_rename",
+			expectedBool: true,
+		},
+		{
+			title:        "Supported `project_transfer` event",
+			event:        "project_transfer",
+			expectedBool: true,
+		},
 		{
 			title:        "Non-supported `repository_update` event",
 			event:        "repository_update",
@@ -47,7 +72,52 @@ func Test_checkSupportedEvents(t *testing.T) {
 	}
 }
 
-func Test_getUser(t *testing.T) {
+func Test_checkEventSource(t *testing.T) {
+	tests := []struct {
+		header string
+		event  string
+		want   bool
+	}{
+		{header: EventSource, event: PushEvent, want: true},
+		{header: EventSource, event: ProjectRenameEvent, want: true},
+		{header: PushHookSource, event: PushEvent, want: true},
+		{header: TagPushHookSource, event: TagPushEvent, want: true},
+		{header: PushHookSource, event: ProjectDestroyEvent, want: false},
+		{header: TagPushHookSource, event: PushEvent, want: false},
+		{header: "Merge Request Hook", event: PushEvent, want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.header+"/"+test.event, func(t *testing.T) {
+			if got := checkHookSource(test.header) && checkEventSource(test.header, test.event); got != test.want {
+				t.Errorf("want: %t, got: %t", test.want, got)
+			}
+		})
+	}
+}
+
+func Test_PureEvent_Name(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{body: `{"event_name": "push", "object_kind": "push"}`, want: PushEvent},
+		{body: `{"event_name": "project_rename"}`, want: ProjectRenameEvent},
+		{body: `{"object_kind": "tag_push"}`, want: TagPushEvent},
+	}
+
+	for _, test := range tests {
+		event := PureEvent{}
+		if err := json.Unmarshal([]byte(test.body), &event); err != nil {
+			t.Fatal(err)
+		}
+		if event.Name() != test.want {
+			t.Errorf("want: %s, got: %s", test.want, event.Name())
+		}
+	}
+}
+
+func Test_getNamespace(t *testing.T) {
 	tests := []struct {
 		title             string
 		pathWithNamespace string
@@ -60,6 +130,12 @@ func Test_getUser(t *testing.T) {
 			expectedName:      "exampleusername",
 			expectedErr:       nil,
 		},
+		{
+			title:             "The whole path of a subgroup is the namespace",
+			pathWithNamespace: "acme/platform/payments/api",
+			expectedName:      "acme/platform/payments",
+			expectedErr:       nil,
+		},
 		{
 			title:             "Error is not nil since the string is not formatted as expected",
 			pathWithNamespace: "exampleusername:exampleproject",
@@ -75,7 +151,7 @@ func Test_getUser(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.title, func(t *testing.T) {
-			username, userErr := getUser(test.pathWithNamespace)
+			username, userErr := getNamespace(test.pathWithNamespace)
 			if username != test.expectedName {
 				t.Errorf("expected name: `%s` got: `%s`", test.expectedName, username)
 			}
@@ -91,3 +167,186 @@ func Test_getUser(t *testing.T) {
 		})
 	}
 }
+
+func Test_isCustomer(t *testing.T) {
+	customersPath := filepath.Join(t.TempDir(), "customers")
+	ioutil.WriteFile(customersPath, []byte("alexellis\nacme/platform\ngroup:initech\n"), 0600)
+
+	customers := sdk.NewCustomers(customersPath, "")
+	customers.Fetch()
+
+	for namespace, want := range map[string]bool{
+		"alexellis":                true,
+		"acme/platform/payments":   true,
+		"acme/platform":            true,
+		"acme":                     false,
+		"acme/other":               false,
+		"initech/tps/reports":      true,
+		"alexellis-impersonator/x": false,
+	} {
+		if got, _ := isCustomer(customers, namespace); got != want {
+			t.Errorf("%s want: %t, got: %t", namespace, want, got)
+		}
+	}
+}
+
+func Test_getPipelineGateRequest(t *testing.T) {
+	event := PipelineEvent{}
+	event.ObjectAttributes.SHA = "af6db1234567"
+	event.ObjectAttributes.Status = "running"
+	event.Project.Namespace = "alexellis"
+	event.Project.Name = "super-pancake"
+	event.Project.PathWithNamespace = "alexellis/super-pancake"
+	event.Builds = append(event.Builds, struct {
+		Name   string `json:"name"`
+		Status string `json:"status"`
+	}{Name: "test", Status: "failed"})
+
+	got, err := getPipelineGateRequest(&event)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if got.Owner != "alexellis" || got.Repo != "super-pancake" || got.SHA != "af6db1234567" {
+		t.Errorf("unexpected gate request: %v", got)
+	}
+	if got.Checks["pipeline"] != "pending" {
+		t.Errorf("want pipeline check: pending, got: %s", got.Checks["pipeline"])
+	}
+	if got.Checks["test"] != "failure" {
+		t.Errorf("want test check: failure, got: %s", got.Checks["test"])
+	}
+}
+
+func Test_getPipelineGateRequest_Subgroup(t *testing.T) {
+	event := PipelineEvent{}
+	event.ObjectAttributes.SHA = "af6db1234567"
+	event.ObjectAttributes.Status = "success"
+	event.Project.Namespace = "sub"
+	event.Project.Name = "super-pancake"
+	event.Project.PathWithNamespace = "group/sub/super-pancake"
+
+	got, err := getPipelineGateRequest(&event)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	// The owner is the whole path of the subgroup, as for a push
+	want := sdk.FormatGitLabOwner("group/sub")
+	if got.Owner != want || got.Owner == "sub" {
+		t.Errorf("want owner: %s, got: %s", want, got.Owner)
+	}
+	if got.Repo != "super-pancake" {
+		t.Errorf("want repo: super-pancake, got: %s", got.Repo)
+	}
+}
+
+func Test_handlePipeline_IgnoresStatusPipeline(t *testing.T) {
+	req := []byte(`{"object_attributes": {"sha": "af6db1234567", "status": "running", "source": "external"}}`)
+
+	got := handlePipeline(req, "")
+	if got != "Ignored pipeline of commit statuses for: af6db1234567" {
+		t.Errorf("want the external pipeline to be ignored, got: %s", got)
+	}
+}
+
+func Test_getProjectMove(t *testing.T) {
+	event := GitLabProjectMoveEvent{
+		PathWithNamespace:    "openfaas/super-pancake",
+		OldPathWithNamespace: "alexellis/pancake",
+		ProjectID:            74,
+	}
+
+	got, err := getProjectMove(&event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &sdk.RepositoryMove{Owner: "alexellis", Repo: "pancake"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	event.OldPathWithNamespace = "pancake"
+	if _, err := getProjectMove(&event); err == nil {
+		t.Errorf("want an error for a path without a namespace")
+	}
+}
+
+func Test_getMovedPushEvent(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "api-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.EscapedPath() {
+		case "/api/v4/projects/74":
+			w.Write([]byte(`{"id": 74, "name": "super-pancake", "path_with_namespace": "openfaas/super-pancake",
+				"http_url_to_repo": "https://gitlab.example.com/openfaas/super-pancake.git", "web_url": "https://gitlab.example.com/openfaas/super-pancake",
+				"visibility": "internal", "namespace": {"path": "openfaas", "full_path": "openfaas"}}`))
+		case "/api/v4/projects/74/repository/branches/master":
+			w.Write([]byte(`{"name": "master", "commit": {"id": "af6db1234567"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	move := &sdk.RepositoryMove{Owner: "alexellis", Repo: "pancake"}
+	got, err := getMovedPushEvent(s.URL+"/", "api-token", 74, "master", move)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Ref != "refs/heads/master" || got.AfterCommitID != "af6db1234567" {
+		t.Errorf("want refs/heads/master at af6db1234567, got: %s at %s", got.Ref, got.AfterCommitID)
+	}
+	if got.GitLabProject.Namespace != "openfaas" || got.GitLabProject.VisibilityLevel != 10 || got.GitLabRepository.CloneURL != "https://gitlab.example.com/openfaas/super-pancake.git" {
+		t.Errorf("unexpected project: %v", got.GitLabProject)
+	}
+	if got.MovedFrom != move {
+		t.Errorf("want moved from %v, got %v", move, got.MovedFrom)
+	}
+
+	if _, err := getMovedPushEvent(s.URL, "api-token", 74, "develop", move); err == nil {
+		t.Errorf("want an error for a missing branch")
+	}
+}
+
+func Test_checkDelivery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deliveries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	deliveries := sdk.NewExpiringSet(dir, time.Hour)
+
+	if duplicate, _ := checkDelivery(deliveries, "uuid-1"); duplicate {
+		t.Errorf("want first delivery to be handled")
+	}
+	if duplicate, msg := checkDelivery(deliveries, "uuid-1"); !duplicate {
+		t.Errorf("want redelivery to be a duplicate, got: %s", msg)
+	}
+
+	forgetDelivery(deliveries, "uuid-1")
+	if duplicate, _ := checkDelivery(deliveries, "uuid-1"); duplicate {
+		t.Errorf("want forgotten delivery to be handled again")
+	}
+}
+
+func Test_getDeliveryID(t *testing.T) {
+	defer os.Unsetenv("Http_X_Gitlab_Event_Uuid")
+
+	first := getDeliveryID([]byte(`{"event_name": "push", "after": "af6db1234567"}`))
+	second := getDeliveryID([]byte(`{"event_name": "push", "after": "bf6db1234567"}`))
+	if first == second {
+		t.Errorf("want a different ID for each event without a UUID, got: %s", first)
+	}
+
+	os.Setenv("Http_X_Gitlab_Event_Uuid", "b4a5a5f2-a1c9-4ff5-8a8d-3b2c9d8b3f6e")
+	if got := getDeliveryID([]byte(`{}`)); got != "b4a5a5f2-a1c9-4ff5-8a8d-3b2c9d8b3f6e" {
+		t.Errorf("want the event UUID, got: %s", got)
+	}
+}
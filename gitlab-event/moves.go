@@ -0,0 +1,164 @@
+package function
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+// GitLabProjectMoveEvent is sent by the system hook when a project is
+// renamed or transferred to another namespace
+type GitLabProjectMoveEvent struct {
+	Name                 string `json:"name"`
+	PathWithNamespace    string `json:"path_with_namespace"`
+	OldPathWithNamespace string `json:"old_path_with_namespace"`
+	ProjectID            int    `json:"project_id"`
+}
+
+// gitlabAPIProject is the part of a project from the GitLab API needed to
+// build it
+type gitlabAPIProject struct {
+	ID                int    `json:"id"`
+	Name              string `json:"name"`
+	Path              string `json:"path"`
+	PathWithNamespace string `json:"path_with_namespace"`
+	HTTPURLToRepo     string `json:"http_url_to_repo"`
+	WebURL            string `json:"web_url"`
+	Visibility        string `json:"visibility"`
+	Namespace         struct {
+		Path     string `json:"path"`
+		FullPath string `json:"full_path"`
+	} `json:"namespace"`
+}
+
+var visibilityLevels = map[string]int{
+	"private":  0,
+	"internal": 10,
+	"public":   20,
+}
+
+// getProjectMove returns where a project was before it was renamed or
+// transferred
+func getProjectMove(event *GitLabProjectMoveEvent) (*sdk.RepositoryMove, error) {
+	namespace, err := getNamespace(event.OldPathWithNamespace)
+	if err != nil {
+		return nil, err
+	}
+
+	repo := event.OldPathWithNamespace[strings.LastIndex(event.OldPathWithNamespace, "/")+1:]
+	return &sdk.RepositoryMove{Owner: sdk.FormatGitLabOwner(namespace), Repo: repo}, nil
+}
+
+// getMovedPushEvent creates a push event for the HEAD of the build branch
+// of a project which moved, as if it had been pushed to
+func getMovedPushEvent(instance, apiToken string, projectID int, branch string, move *sdk.RepositoryMove) (sdk.GitLabPushEvent, error) {
+	projectURL := strings.TrimSuffix(instance, "/") + "/api/v4/projects/" + strconv.Itoa(projectID)
+
+	project := gitlabAPIProject{}
+	if err := getGitLabAPI(projectURL, apiToken, &project); err != nil {
+		return sdk.GitLabPushEvent{}, err
+	}
+
+	branchInfo := struct {
+		Commit struct {
+			ID string `json:"id"`
+		} `json:"commit"`
+	}{}
+	if err := getGitLabAPI(projectURL+"/repository/branches/"+url.PathEscape(branch), apiToken, &branchInfo); err != nil {
+		return sdk.GitLabPushEvent{}, fmt.Errorf("unable to find HEAD of %s: %s", branch, err.Error())
+	}
+
+	return sdk.GitLabPushEvent{
+		Ref: "refs/heads/" + branch,
+		GitLabProject: sdk.GitLabProject{
+			ID:                project.ID,
+			Namespace:         project.Namespace.FullPath,
+			Name:              project.Name,
+			PathWithNamespace: project.PathWithNamespace,
+			WebURL:            project.WebURL,
+			VisibilityLevel:   visibilityLevels[project.Visibility],
+		},
+		GitLabRepository: sdk.GitLabRepository{
+			CloneURL: project.HTTPURLToRepo,
+		},
+		AfterCommitID: branchInfo.Commit.ID,
+		MovedFrom:     move,
+	}, nil
+}
+
+func getGitLabAPI(URL, apiToken string, out interface{}) error {
+	req, _ := http.NewRequest(http.MethodGet, URL, nil)
+	req.Header.Add("PRIVATE-TOKEN", apiToken)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error while getting response from GitLab: %s", err.Error())
+	}
+	defer res.Body.Close()
+
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status from GitLab API: %d, body: %s", res.StatusCode, string(body))
+	}
+	return json.Unmarshal(body, out)
+}
+
+// moveProject builds a renamed or transferred project in its new place,
+// functions deployed from where it was are removed once it is built. When
+// the project isn't installed the functions are removed straight away.
+func moveProject(event *GitLabProjectMoveEvent, installed bool, instance, apiToken, xGitlabToken string) string {
+	move, err := getProjectMove(event)
+	if err != nil {
+		return fmt.Sprintf("error while formatting username: %s", err.Error())
+	}
+
+	removeMoved := func() string {
+		err := garbageCollect([]GarbageRequest{{
+			Owner:     move.Owner,
+			Repo:      move.Repo,
+			Functions: []string{},
+			RepoID:    int64(event.ProjectID),
+		}})
+		if err != nil {
+			return fmt.Sprintf("unexpected error in garbage collect: `%s`\n", err.Error())
+		}
+		return fmt.Sprintf("Functions of %s/%s deleted", move.Owner, move.Repo)
+	}
+
+	if !installed {
+		return removeMoved()
+	}
+
+	pushEvent, err := getMovedPushEvent(instance, apiToken, event.ProjectID, getBuildBranch(), move)
+	if err != nil {
+		res := removeMoved()
+		return fmt.Sprintf("unable to build %s: %s, %s", event.PathWithNamespace, err.Error(), res)
+	}
+
+	body, _ := json.Marshal(pushEvent)
+	headers := map[string]string{
+		"X-Gitlab-Token": xGitlabToken,
+		"X-Gitlab-Event": EventSource,
+		"Content-Type":   "application/json",
+	}
+
+	res, statusCode, err := forward(body, "gitlab-push", headers)
+	if err != nil {
+		return fmt.Sprintf("error while forwarding to gitlab-push: %s", err.Error())
+	}
+	return fmt.Sprintf("Moved %s to %s, gitlab-push status: %d response: %s", event.OldPathWithNamespace, event.PathWithNamespace, statusCode, res)
+}
+
+func getBuildBranch() string {
+	if branch := strings.TrimSpace(os.Getenv("build_branch")); len(branch) > 0 {
+		return branch
+	}
+	return "master"
+}
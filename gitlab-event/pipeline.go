@@ -0,0 +1,119 @@
+package function
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+// PipelineEventSource is the X-Gitlab-Event header of a project pipeline
+// webhook
+const PipelineEventSource = "Pipeline Hook"
+
+// defaultPipelineCheck is the name of the check for the whole pipeline
+// when it has not been given a name
+const defaultPipelineCheck = "pipeline"
+
+// externalPipelineSource is the source of the pipeline which holds the
+// commit statuses of gitlab-status, it is not a CI pipeline
+const externalPipelineSource = "external"
+
+// PipelineEvent is sent by a project webhook when a pipeline or one of its
+// jobs changes state
+type PipelineEvent struct {
+	ObjectAttributes struct {
+		Name   string `json:"name"`
+		SHA    string `json:"sha"`
+		Status string `json:"status"`
+		Source string `json:"source"`
+	} `json:"object_attributes"`
+	Project sdk.GitLabProject `json:"project"`
+	Builds  []struct {
+		Name   string `json:"name"`
+		Status string `json:"status"`
+	} `json:"builds"`
+}
+
+// handlePipeline sends the state of a pipeline and its jobs to the
+// deployment-gate function so that deploys waiting for them can proceed
+func handlePipeline(req []byte, xGitlabToken string) string {
+	if readBool("validate_token") {
+		tokenSecretKey, secretErr := sdk.ReadSecret("gitlab-webhook-secret")
+		if secretErr != nil {
+			return fmt.Sprintf("unable to load gitlab-webhook-secret: %s", secretErr.Error())
+		}
+		if xGitlabToken != tokenSecretKey {
+			return fmt.Sprintf("value in X-Gitlab-Token does not match gitlab-webhook-secret")
+		}
+	}
+
+	event := PipelineEvent{}
+	if err := json.Unmarshal(req, &event); err != nil {
+		return fmt.Sprintf("error while un-marshaling pipeline event: %s", err.Error())
+	}
+
+	if event.ObjectAttributes.Source == externalPipelineSource {
+		return fmt.Sprintf("Ignored pipeline of commit statuses for: %s", event.ObjectAttributes.SHA)
+	}
+
+	payloadSecret, err := sdk.ReadSecret("payload-secret")
+	if err != nil {
+		return fmt.Sprintf("error while reading payload-secret: %s", err.Error())
+	}
+
+	gatewayURL := sdk.CreateServiceURL(os.Getenv("gateway_url"), os.Getenv("dns_suffix"))
+
+	gateReq, err := getPipelineGateRequest(&event)
+	if err != nil {
+		return fmt.Sprintf("error while reading namespace of pipeline: %s", err.Error())
+	}
+
+	res, err := sdk.PostGateRequest(gatewayURL, payloadSecret, gateReq)
+	if err != nil {
+		return fmt.Sprintf("error while sending pipeline to deployment-gate: %s", err.Error())
+	}
+	return res
+}
+
+// getPipelineGateRequest names the owner as the push to the project does,
+// so that the checks are stored with the deploys they gate
+func getPipelineGateRequest(event *PipelineEvent) (sdk.GateRequest, error) {
+	namespace, err := getNamespace(event.Project.PathWithNamespace)
+	if err != nil {
+		return sdk.GateRequest{}, err
+	}
+
+	name := event.ObjectAttributes.Name
+	if len(name) == 0 {
+		name = defaultPipelineCheck
+	}
+
+	checks := map[string]string{
+		name: getPipelineState(event.ObjectAttributes.Status),
+	}
+	for _, build := range event.Builds {
+		checks[build.Name] = getPipelineState(build.Status)
+	}
+
+	return sdk.GateRequest{
+		Action: sdk.GateActionCheck,
+		Owner:  sdk.FormatGitLabOwner(namespace),
+		Repo:   event.Project.Name,
+		SHA:    event.ObjectAttributes.SHA,
+		Checks: checks,
+	}, nil
+}
+
+// getPipelineState maps the status of a GitLab pipeline or job to the state
+// of a commit status
+func getPipelineState(status string) string {
+	switch status {
+	case "success", "skipped":
+		return sdk.StatusSuccess
+	case "failed", "canceled":
+		return sdk.StatusFailure
+	}
+	return sdk.StatusPending
+}
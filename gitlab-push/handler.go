@@ -21,8 +21,14 @@ const (
 	PublicRepo   = 20
 	Source       = "gitlab-push"
 	SCM          = "gitlab"
+
+	tagRefPrefix = "refs/tags/"
 )
 
+// hookSources are the X-Gitlab-Event headers of the system hook and of
+// project and group webhooks, which gitlab-event forwards unchanged
+var hookSources = [...]string{"System Hook", "Push Hook", "Tag Push Hook"}
+
 var audit sdk.Audit
 
 // Handle accepts push event from gitlab-event
@@ -40,7 +46,7 @@ func Handle(req []byte) string {
 
 	event := os.Getenv("Http_X_Gitlab_Event")
 
-	if event != "System Hook" {
+	if !checkHookSource(event) {
 		auditEvent := sdk.AuditEvent{
 			Message: "bad event: " + event,
 			Source:  Source,
@@ -56,7 +62,23 @@ func Handle(req []byte) string {
 		return fmt.Sprintf("error while unmarshaling gitlabPushEvent struct: %s", err.Error())
 	}
 
+	owner := getOwner(gitlabPushEvent.GitLabProject)
+
+	if strings.HasPrefix(gitlabPushEvent.Ref, tagRefPrefix) {
+		msg := fmt.Sprintf("skipping build for tag: %s, only pushes to the build branch are built",
+			strings.TrimPrefix(gitlabPushEvent.Ref, tagRefPrefix))
+
+		audit.Post(sdk.AuditEvent{
+			Message: msg,
+			Owner:   owner,
+			Repo:    gitlabPushEvent.GitLabProject.Name,
+			Source:  Source,
+		})
+		return msg
+	}
+
 	privateRepo := checkPublicRepo(gitlabPushEvent.GitLabProject.VisibilityLevel)
+	projectID := gitlabPushEvent.GetProjectID()
 
 	pushEvent := sdk.PushEvent{
 		SCM: SCM,
@@ -66,16 +88,23 @@ func Handle(req []byte) string {
 			FullName: gitlabPushEvent.GitLabProject.PathWithNamespace,
 			CloneURL: gitlabPushEvent.GitLabRepository.CloneURL,
 			Private:  privateRepo,
+			ID:       int64(projectID),
 			Owner: sdk.Owner{
-				Login: gitlabPushEvent.GitLabProject.Namespace,
+				Login: owner,
 				Email: gitlabPushEvent.UserEmail,
 			},
 			RepositoryURL: gitlabPushEvent.GitLabProject.WebURL,
 		},
-		AfterCommitID: gitlabPushEvent.AfterCommitID,
+		AfterCommitID:  gitlabPushEvent.AfterCommitID,
+		BeforeCommitID: gitlabPushEvent.BeforeCommitID,
+		HeadCommit:     getHeadCommit(gitlabPushEvent),
+		Sender: sdk.Sender{
+			Login: gitlabPushEvent.UserUsername,
+		},
 		Installation: sdk.PushEventInstallation{
-			ID: gitlabPushEvent.GitLabProject.ID,
+			ID: projectID,
 		},
+		MovedFrom: gitlabPushEvent.MovedFrom,
 	}
 
 	eventInfo := sdk.BuildEventFromPushEvent(pushEvent)
@@ -98,10 +127,41 @@ func Handle(req []byte) string {
 		return branchErrorMessage
 	}
 
+	// a commit which skips ci still supersedes the builds of older commits
+	supersedeBuilds(pushEvent)
+
+	directives := sdk.ParseCommitDirectives(pushEvent.HeadCommit)
+	if directives.Skip {
+		msg := fmt.Sprintf("skipping build for: %s, the commit message asks to skip ci", pushEvent.AfterCommitID)
+		auditEvent := sdk.AuditEvent{
+			Message: msg,
+			Owner:   pushEvent.Repository.Owner.Login,
+			Repo:    pushEvent.Repository.Name,
+			Source:  Source,
+		}
+
+		audit.Post(auditEvent)
+
+		status.AddStatus(sdk.StatusSkipped, msg, sdk.StackContext)
+		reportGitLabStatus(status)
+		return msg
+	}
+
 	serviceValue := fmt.Sprintf("%s-%s", pushEvent.Repository.Owner.Login, pushEvent.Repository.Name)
 	status.AddStatus(sdk.StatusPending, fmt.Sprintf("%s stack deploy is in progress", serviceValue), sdk.StackContext)
 	reportGitLabStatus(status)
 
+	if msg := directives.Describe(); len(msg) > 0 {
+		directives.Apply(&pushEvent)
+
+		audit.Post(sdk.AuditEvent{
+			Message: fmt.Sprintf("%s: %s, as asked by the commit message", pushEvent.AfterCommitID, msg),
+			Owner:   pushEvent.Repository.Owner.Login,
+			Repo:    pushEvent.Repository.Name,
+			Source:  Source,
+		})
+	}
+
 	statusCode, postErr := postEvent(pushEvent)
 	if postErr != nil {
 		status.AddStatus(sdk.StatusFailure, postErr.Error(), sdk.StackContext)
@@ -121,6 +181,34 @@ func Handle(req []byte) string {
 	return fmt.Sprintf("Push - %v, git-tar status: %d", pushEvent, statusCode)
 }
 
+// supersedeBuilds records the pushed commit as the newest commit of the
+// project so that builds of older commits are cancelled, a failure is only
+// logged as the commit can still be built
+func supersedeBuilds(pushEvent sdk.PushEvent) {
+	payloadSecret, err := sdk.ReadSecret("payload-secret")
+	if err != nil {
+		log.Printf("unable to supersede builds: %s", err.Error())
+		return
+	}
+
+	ref := sdk.NewBuildRef(pushEvent.Repository.Owner.Login, pushEvent.Repository.Name, pushEvent.AfterCommitID)
+	ref.Before = pushEvent.BeforeCommitID
+	result, err := sdk.SupersedeBuilds(os.Getenv("builder_url"), payloadSecret, ref)
+	if err != nil {
+		log.Printf("unable to supersede builds of %s/%s: %s", ref.Owner, ref.Repo, err.Error())
+		return
+	}
+
+	if len(result.Cancelled) > 0 {
+		sdk.PostAudit(sdk.AuditEvent{
+			Message: fmt.Sprintf("%s superseded builds of: %s", ref.SHA, strings.Join(result.Cancelled, ", ")),
+			Owner:   pushEvent.Repository.Owner.Login,
+			Repo:    pushEvent.Repository.Name,
+			Source:  Source,
+		})
+	}
+}
+
 func postEvent(pushEvent sdk.PushEvent) (int, error) {
 	suffix := os.Getenv("dns_suffix")
 	gatewayURL := os.Getenv("gateway_url")
@@ -202,6 +290,36 @@ func reportGitLabStatus(status *sdk.Status) {
 	}
 }
 
+// getHeadCommit finds the commit the push moved the branch to, nil is
+// returned when GitLab left it out of the commits it sent
+func getHeadCommit(gitlabPushEvent sdk.GitLabPushEvent) *sdk.HeadCommit {
+	for _, commit := range gitlabPushEvent.Commits {
+		if commit.ID == gitlabPushEvent.AfterCommitID {
+			return &sdk.HeadCommit{ID: commit.ID, Message: commit.Message}
+		}
+	}
+	return nil
+}
+
+// getOwner encodes the full path of the namespace of the project, the
+// namespace field of the payload is only the name of the last group
+func getOwner(project sdk.GitLabProject) string {
+	namespace, err := sdk.GetGitLabNamespace(project.PathWithNamespace)
+	if err != nil {
+		return strings.ToLower(project.Namespace)
+	}
+	return sdk.FormatGitLabOwner(namespace)
+}
+
+func checkHookSource(event string) bool {
+	for _, source := range hookSources {
+		if source == event {
+			return true
+		}
+	}
+	return false
+}
+
 func checkPublicRepo(visibilityLevel int) bool {
 	return visibilityLevel != PublicRepo
 }
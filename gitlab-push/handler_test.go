@@ -4,6 +4,8 @@ import (
 	"errors"
 	"os"
 	"testing"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
 )
 
 func Test_checkPublicRepo(t *testing.T) {
@@ -38,6 +40,33 @@ func Test_checkPublicRepo(t *testing.T) {
 	}
 
 }
+
+func Test_checkHookSource(t *testing.T) {
+	for event, want := range map[string]bool{
+		"System Hook":        true,
+		"Push Hook":          true,
+		"Tag Push Hook":      true,
+		"Merge Request Hook": false,
+		"":                   false,
+	} {
+		if got := checkHookSource(event); got != want {
+			t.Errorf("%q want: %t, got: %t", event, want, got)
+		}
+	}
+}
+
+func Test_getOwner(t *testing.T) {
+	project := sdk.GitLabProject{Namespace: "Alex Ellis", PathWithNamespace: "alexellis/pancake"}
+	if got := getOwner(project); got != "alexellis" {
+		t.Errorf("want the path of the user, got: %s", got)
+	}
+
+	project = sdk.GitLabProject{Namespace: "Payments", PathWithNamespace: "acme/platform/payments/api"}
+	if got := getOwner(project); got != sdk.FormatGitLabOwner("acme/platform/payments") {
+		t.Errorf("want the whole path of the subgroup, got: %s", got)
+	}
+}
+
 func Test_filterBranchRef(t *testing.T) {
 	tests := []struct {
 		title          string
@@ -154,3 +183,23 @@ func Test_checkBranch_unsetBranchInEnv(t *testing.T) {
 		}
 	})
 }
+
+func Test_getHeadCommit(t *testing.T) {
+	gitlabPushEvent := sdk.GitLabPushEvent{
+		AfterCommitID: "4c7b2f1e9a",
+		Commits: []sdk.GitLabCommit{
+			{ID: "d1e2f3a4b5", Message: "Add fn2"},
+			{ID: "4c7b2f1e9a", Message: "Update README [skip ci]"},
+		},
+	}
+
+	headCommit := getHeadCommit(gitlabPushEvent)
+	if headCommit == nil || headCommit.Message != "Update README [skip ci]" {
+		t.Errorf("want the commit the branch was moved to, got: %v", headCommit)
+	}
+
+	gitlabPushEvent.AfterCommitID = "0a1b2c3d4e"
+	if headCommit := getHeadCommit(gitlabPushEvent); headCommit != nil {
+		t.Errorf("want no head commit when it wasn't sent, got: %v", headCommit)
+	}
+}
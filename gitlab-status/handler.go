@@ -6,11 +6,25 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
 
 	"github.com/alexellis/hmac"
 	"github.com/openfaas/openfaas-cloud/sdk"
 )
 
+// States of a GitLab commit status
+const (
+	gitlabStatePending  = "pending"
+	gitlabStateRunning  = "running"
+	gitlabStateSuccess  = "success"
+	gitlabStateFailed   = "failed"
+	gitlabStateCanceled = "canceled"
+)
+
+// maxDescriptionLength is the longest description GitLab keeps for a
+// commit status
+const maxDescriptionLength = 255
+
 // Handle reports the building process of the
 // function and the function stack to GitLab by
 // sending commit statuses on pending, success, failure
@@ -24,26 +38,72 @@ func Handle(req []byte) string {
 		return fmt.Sprintf("error while un-marshaling status from request: %s", statusErr.Error())
 	}
 
-	token, tokenErr := sdk.ReadSecret("gitlab-api-token")
+	token, tokenErr := getProjectToken(status.EventInfo.URL)
 	if tokenErr != nil {
-		return fmt.Sprintf("error while reading gitlab-api-token: %s", tokenErr.Error())
+		return fmt.Sprintf("error while reading GitLab token: %s", tokenErr.Error())
 	}
 
-	url, urlErr := gitLabURLBuilder(status.EventInfo.URL, status.EventInfo.SHA, status.EventInfo.InstallationID)
+	event := &status.EventInfo
+	statusURL, urlErr := gitLabURLBuilder(event.URL, event.SHA, event.InstallationID)
 	if urlErr != nil {
-		log.Printf("error while building base URL to the API: %s", urlErr.Error())
+		log.Fatalf("error while building base URL to the API: %s", urlErr.Error())
+	}
+
+	projectURL, _ := gitLabProjectURL(event.URL, event.InstallationID)
+	pipelineID, pipelineErr := getStatusPipelineID(projectURL, token, event.SHA)
+	if pipelineErr != nil {
+		log.Printf("unable to find the pipeline of %s, error: %s", event.SHA, pipelineErr.Error())
 	}
 
 	for _, commitStatus := range status.CommitStatuses {
-		reportErr := sendReport(url, token, commitStatus.Status, commitStatus.Description, commitStatus.Context)
+		statusRequest := buildStatusRequest(&commitStatus, event)
+		statusRequest.PipelineID = pipelineID
+
+		created, reportErr := sendReport(statusURL, token, statusRequest)
 		if reportErr != nil {
-			log.Fatalf("failed to report status %v, error: %s", status, reportErr.Error())
+			log.Fatalf("failed to report status %s for %s, error: %s", commitStatus.Status, commitStatus.Context, reportErr.Error())
+		}
+
+		// the first status creates the pipeline when there was none
+		if pipelineID == 0 {
+			pipelineID = created.PipelineID
+		}
+	}
+
+	if mergeRequestNotesEnabled() {
+		if err := reportMergeRequests(status, token); err != nil {
+			log.Printf("failed to add note to merge request, error: %s", err.Error())
 		}
 	}
 
 	return ""
 }
 
+// getProjectToken returns the token the owners of the project sealed for
+// it, or the gitlab-api-token secret
+func getProjectToken(cloneURL string) (string, error) {
+	payloadSecret, err := sdk.ReadSecret("payload-secret")
+	if err != nil {
+		return "", fmt.Errorf("unable to read payload-secret: %s", err.Error())
+	}
+
+	credential, err := sdk.GetGitLabCredential(os.Getenv("gateway_url"), payloadSecret, getProjectPath(cloneURL), true)
+	if err != nil {
+		return "", err
+	}
+	return credential.Token, nil
+}
+
+// getProjectPath gives the path with namespace of a project from its clone
+// URL, such as acme/platform/api for https://gitlab.com/acme/platform/api.git
+func getProjectPath(cloneURL string) string {
+	parsedURL, err := url.Parse(cloneURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(strings.Trim(parsedURL.Path, "/"), ".git")
+}
+
 func gitLabURLBuilder(eventURL, SHA string, id int) (string, error) {
 	if eventURL == "" || SHA == "" {
 		return "", fmt.Errorf("eventURL or SHA are empty")
@@ -55,49 +115,94 @@ func gitLabURLBuilder(eventURL, SHA string, id int) (string, error) {
 	return fmt.Sprintf("%s://%s/api/v4/projects/%d/statuses/%s", parsedURL.Scheme, parsedURL.Host, id, SHA), nil
 }
 
-func appendParameters(URL string, state string, desc string, context string) (string, error) {
-	var theURL *url.URL
-
-	theURL, urlErr := url.Parse(URL)
-	if urlErr != nil {
-		return "", fmt.Errorf("error while appending parameters to url: %s", urlErr.Error())
-	}
+// commitStatusRequest is a commit status for the GitLab API, the status
+// is added to the pipeline given by PipelineID or to a new pipeline
+type commitStatusRequest struct {
+	State       string `json:"state"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	TargetURL   string `json:"target_url,omitempty"`
+	PipelineID  int    `json:"pipeline_id,omitempty"`
+}
 
-	if state == "failure" {
-		state = "failed"
-	}
+type commitStatusResponse struct {
+	ID         int `json:"id"`
+	PipelineID int `json:"pipeline_id"`
+}
 
-	parameters := url.Values{}
-	parameters.Add("state", state)
-	parameters.Add("description", desc)
-	parameters.Add("context", context)
-	theURL.RawQuery = parameters.Encode()
+type statusPipeline struct {
+	ID int `json:"id"`
+}
 
-	return theURL.String(), nil
+func buildStatusRequest(commitStatus *sdk.CommitStatus, event *sdk.Event) *commitStatusRequest {
+	return &commitStatusRequest{
+		State:       getGitLabState(commitStatus),
+		Name:        commitStatus.Context,
+		Description: truncate(maxDescriptionLength, commitStatus.Description),
+		TargetURL:   buildPublicStatusURL(commitStatus.Status, commitStatus.Context, event),
+	}
+}
 
+// getGitLabState maps the status to one of the states of a GitLab commit
+// status: pending, running, success, failed, canceled or skipped
+func getGitLabState(commitStatus *sdk.CommitStatus) string {
+	switch commitStatus.Status {
+	case sdk.StatusPending:
+		// a stage which started and hasn't completed is running
+		if commitStatus.Started != nil && commitStatus.Completed == nil {
+			return gitlabStateRunning
+		}
+		return gitlabStatePending
+	case sdk.StatusFailure:
+		return gitlabStateFailed
+	case sdk.StatusActionRequired:
+		// GitLab has no state for a deploy waiting on approval
+		return gitlabStatePending
+	case sdk.StatusSuperseded:
+		// a build superseded by a newer commit was canceled
+		return gitlabStateCanceled
+	case sdk.StatusSkipped:
+		// not every GitLab version accepts skipped, a commit which asked
+		// not to be built is reported as a success like a push to another
+		// branch
+		return gitlabStateSuccess
+	}
+	return commitStatus.Status
 }
 
-func sendReport(URL string, token string, state string, desc string, context string) error {
-	fullURL, fullURLErr := appendParameters(URL, state, desc, context)
-	if fullURLErr != nil {
-		return fmt.Errorf("error while appending parameters to URL: %s", fullURLErr)
+// getStatusPipelineID finds the external pipeline which holds the statuses
+// of the commit, so that they are not added to a CI pipeline of the same
+// commit. It is 0 until the first status creates the pipeline.
+func getStatusPipelineID(projectURL, token, SHA string) (int, error) {
+	if len(projectURL) == 0 {
+		return 0, fmt.Errorf("no project URL")
 	}
 
-	req, reqErr := http.NewRequest(http.MethodPost, fullURL, nil)
-	if reqErr != nil {
-		return fmt.Errorf("error while creating request to GitLab API: %s", reqErr.Error())
+	pipelines := []statusPipeline{}
+	pipelinesURL := fmt.Sprintf("%s/pipelines?sha=%s&source=external&order_by=id&sort=asc&per_page=1", projectURL, url.QueryEscape(SHA))
+	if _, err := gitLabRequest(http.MethodGet, pipelinesURL, token, nil, &pipelines); err != nil {
+		return 0, err
 	}
-	req.Header.Set("PRIVATE-TOKEN", token)
 
-	resp, clientErr := http.DefaultClient.Do(req)
-	if clientErr != nil {
-		return fmt.Errorf("error while sending request to GitLab API: %s", clientErr.Error())
+	if len(pipelines) == 0 {
+		return 0, nil
 	}
-	if resp.Body != nil {
-		defer resp.Body.Close()
+	return pipelines[0].ID, nil
+}
+
+func sendReport(URL string, token string, statusRequest *commitStatusRequest) (*commitStatusResponse, error) {
+	created := &commitStatusResponse{}
+	if _, err := gitLabRequest(http.MethodPost, URL, token, statusRequest, created); err != nil {
+		return nil, err
 	}
+	return created, nil
+}
 
-	return nil
+func truncate(maxLength int, message string) string {
+	if len(message) > maxLength {
+		message = message[:maxLength]
+	}
+	return message
 }
 
 func validateRequest(req []byte) (err error) {
@@ -1,8 +1,17 @@
 package function
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
 )
 
 func Test_gitLabURLBuilder(t *testing.T) {
@@ -58,62 +67,128 @@ func Test_gitLabURLBuilder(t *testing.T) {
 	}
 }
 
-func Test_appendParameters(t *testing.T) {
+func Test_getProjectPath(t *testing.T) {
+	tests := map[string]string{
+		"https://gitlab.example.io/martindekov/gitlab-playground.git": "martindekov/gitlab-playground",
+		"https://gitlab.example.io/acme/platform/api.git":             "acme/platform/api",
+		"https://gitlab.example.io/acme/api":                          "acme/api",
+		"":                                                            "",
+	}
+
+	for cloneURL, want := range tests {
+		if got := getProjectPath(cloneURL); got != want {
+			t.Errorf("%q want: %q, got: %q", cloneURL, want, got)
+		}
+	}
+}
+
+func Test_getGitLabState(t *testing.T) {
+	started := time.Now()
+
 	tests := []struct {
-		title       string
-		url         string
-		state       string
-		desc        string
-		context     string
-		expectedURL string
+		title  string
+		status sdk.CommitStatus
+		want   string
 	}{
-		{
-			title:       "Everything is set right",
-			url:         "https://some.random.url/api/v4/projects/3/statuses/99a7c6009",
-			state:       "pending",
-			desc:        "some description",
-			context:     "stack-deploy",
-			expectedURL: "https://some.random.url/api/v4/projects/3/statuses/99a7c6009?context=stack-deploy&description=some+description&state=pending",
-		},
-		{
-			title:       "Everything is set right and state is failure",
-			url:         "https://some.random.url/api/v4/projects/3/statuses/99a7c6009",
-			state:       "failure",
-			desc:        "some description",
-			context:     "stack-deploy",
-			expectedURL: "https://some.random.url/api/v4/projects/3/statuses/99a7c6009?context=stack-deploy&description=some+description&state=failed",
-		},
-		{
-			title:       "Showing that already existing parameters are overwritten",
-			url:         "https://some.random.url/api/v4/projects/3/statuses/99a7c6009?value=somevalue",
-			state:       "failure",
-			desc:        "some description",
-			context:     "context",
-			expectedURL: "https://some.random.url/api/v4/projects/3/statuses/99a7c6009?context=context&description=some+description&state=failed",
-		},
-		{
-			title:       "When state is empty",
-			url:         "https://some.random.url/api/v4/projects/3/statuses/99a7c6009?value=somevalue",
-			state:       "",
-			desc:        "some description",
-			context:     "stack-deploy",
-			expectedURL: "https://some.random.url/api/v4/projects/3/statuses/99a7c6009?context=stack-deploy&description=some+description&state=",
-		},
-		{
-			title:       "When everything is empty",
-			url:         "https://some.random.url/api/v4/projects/3/statuses/99a7c6009?value=somevalue",
-			state:       "",
-			desc:        "",
-			context:     "",
-			expectedURL: "https://some.random.url/api/v4/projects/3/statuses/99a7c6009?context=&description=&state=",
-		},
+		{title: "pending", status: sdk.CommitStatus{Status: sdk.StatusPending}, want: "pending"},
+		{title: "pending stage which started", status: sdk.CommitStatus{Status: sdk.StatusPending, Started: &started}, want: "running"},
+		{title: "pending stage which completed", status: sdk.CommitStatus{Status: sdk.StatusPending, Started: &started, Completed: &started}, want: "pending"},
+		{title: "success", status: sdk.CommitStatus{Status: sdk.StatusSuccess}, want: "success"},
+		{title: "failure", status: sdk.CommitStatus{Status: sdk.StatusFailure}, want: "failed"},
+		{title: "action_required", status: sdk.CommitStatus{Status: sdk.StatusActionRequired}, want: "pending"},
+		{title: "superseded", status: sdk.CommitStatus{Status: sdk.StatusSuperseded}, want: "canceled"},
+		{title: "skipped", status: sdk.CommitStatus{Status: sdk.StatusSkipped}, want: "success"},
+		{title: "empty", status: sdk.CommitStatus{}, want: ""},
 	}
+
 	for _, test := range tests {
 		t.Run(test.title, func(t *testing.T) {
-			wholeUrl, _ := appendParameters(test.url, test.state, test.desc, test.context)
-			if wholeUrl != test.expectedURL {
-				t.Errorf("wanted: %s got: %s", test.expectedURL, wholeUrl)
+			if got := getGitLabState(&test.status); got != test.want {
+				t.Errorf("want: %s, got: %s", test.want, got)
 			}
 		})
 	}
 }
+
+func Test_buildStatusRequest(t *testing.T) {
+	os.Setenv("gateway_public_url", "https://cloud.o6s.io/")
+	os.Setenv("gateway_pretty_url", "")
+	defer os.Unsetenv("gateway_public_url")
+
+	event := &sdk.Event{
+		Owner:   "alexellis",
+		Service: "tester",
+		URL:     "https://gitlab.o6s.io/alexellis/fns.git",
+	}
+
+	got := buildStatusRequest(&sdk.CommitStatus{Status: sdk.StatusSuccess, Description: "deployed", Context: "tester"}, event)
+	want := &commitStatusRequest{
+		State:       "success",
+		Name:        "tester",
+		Description: "deployed",
+		TargetURL:   "https://cloud.o6s.io/function/alexellis-tester",
+	}
+	if *got != *want {
+		t.Errorf("want: %+v, got: %+v", want, got)
+	}
+
+	got = buildStatusRequest(&sdk.CommitStatus{Status: sdk.StatusPending, Description: strings.Repeat("a", 300), Context: sdk.StackContext}, event)
+	if got.TargetURL != event.URL || len(got.Description) != maxDescriptionLength {
+		t.Errorf("want the project URL and a truncated description, got: %+v", got)
+	}
+}
+
+func Test_getStatusPipelineID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if r.URL.Path != "/api/v4/projects/3/pipelines" || query.Get("source") != "external" {
+			t.Errorf("unexpected request: %s", r.URL.String())
+		}
+
+		if query.Get("sha") == "abc" {
+			w.Write([]byte(`[{"id": 42}]`))
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	projectURL := server.URL + "/api/v4/projects/3"
+	if got, err := getStatusPipelineID(projectURL, "token", "abc"); err != nil || got != 42 {
+		t.Errorf("want pipeline 42, got: %d, %v", got, err)
+	}
+
+	if got, err := getStatusPipelineID(projectURL, "token", "def"); err != nil || got != 0 {
+		t.Errorf("want no pipeline, got: %d, %v", got, err)
+	}
+}
+
+func Test_sendReport(t *testing.T) {
+	var body commitStatusRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message": "401 Unauthorized"}`))
+			return
+		}
+
+		bodyBytes, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(bodyBytes, &body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": 1, "pipeline_id": 42}`))
+	}))
+	defer server.Close()
+
+	statusRequest := &commitStatusRequest{State: "running", Name: "tester", PipelineID: 42}
+	created, err := sendReport(server.URL, "token", statusRequest)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if created.PipelineID != 42 || body != *statusRequest {
+		t.Errorf("want the status to be sent to pipeline 42, got: %+v, %+v", body, created)
+	}
+
+	if _, err := sendReport(server.URL, "wrong", statusRequest); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("want the API error to be returned, got: %v", err)
+	}
+}
@@ -0,0 +1,278 @@
+package function
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+const mergeRequestOpened = "opened"
+
+// maxNoteLogLength keeps the tail of the logs in a failure note
+const maxNoteLogLength = 50000
+
+type commitMergeRequest struct {
+	IID   int    `json:"iid"`
+	State string `json:"state"`
+}
+
+type mergeRequestNote struct {
+	ID   int64  `json:"id,omitempty"`
+	Body string `json:"body"`
+}
+
+// mergeRequestNotes are the notes on a merge request
+type mergeRequestNotes struct {
+	projectURL string
+	token      string
+	iid        int
+}
+
+func (m *mergeRequestNotes) notesURL() string {
+	return fmt.Sprintf("%s/merge_requests/%d/notes", m.projectURL, m.iid)
+}
+
+func (m *mergeRequestNotes) List() ([]sdk.SummaryComment, error) {
+	comments := []sdk.SummaryComment{}
+	page := "1"
+	for len(page) > 0 {
+		notes := []mergeRequestNote{}
+		res, err := gitLabRequest(http.MethodGet, m.notesURL()+"?per_page=100&page="+page, m.token, nil, &notes)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, note := range notes {
+			comments = append(comments, sdk.SummaryComment{ID: note.ID, Body: note.Body})
+		}
+		page = res.Header.Get("X-Next-Page")
+	}
+	return comments, nil
+}
+
+func (m *mergeRequestNotes) Create(body string) error {
+	_, err := gitLabRequest(http.MethodPost, m.notesURL(), m.token, &mergeRequestNote{Body: body}, nil)
+	return err
+}
+
+func (m *mergeRequestNotes) Update(id int64, body string) error {
+	_, err := gitLabRequest(http.MethodPut, m.notesURL()+"/"+strconv.FormatInt(id, 10), m.token, &mergeRequestNote{Body: body}, nil)
+	return err
+}
+
+func (m *mergeRequestNotes) Delete(id int64) error {
+	_, err := gitLabRequest(http.MethodDelete, m.notesURL()+"/"+strconv.FormatInt(id, 10), m.token, nil, nil)
+	return err
+}
+
+func mergeRequestNotesEnabled() bool {
+	return os.Getenv("use_mr_notes") != "false"
+}
+
+// reportMergeRequests keeps a summary of the build in a note on each
+// open merge request which contains the commit, and adds a note with the
+// logs of a function when it fails
+func reportMergeRequests(status *sdk.Status, token string) error {
+	summary, hasSummary := sdk.GetFunctionSummary(status, os.Getenv("gateway_public_url"))
+	failures := getFailedStatuses(status)
+	if !hasSummary && len(failures) == 0 {
+		return nil
+	}
+
+	event := &status.EventInfo
+	projectURL, err := gitLabProjectURL(event.URL, event.InstallationID)
+	if err != nil {
+		return err
+	}
+
+	mergeRequests := []commitMergeRequest{}
+	commitURL := fmt.Sprintf("%s/repository/commits/%s/merge_requests", projectURL, event.SHA)
+	if _, err := gitLabRequest(http.MethodGet, commitURL, token, nil, &mergeRequests); err != nil {
+		return fmt.Errorf("unable to list merge requests for %s: %s", event.SHA, err.Error())
+	}
+
+	var failureNote string
+	for _, mergeRequest := range mergeRequests {
+		if mergeRequest.State != mergeRequestOpened {
+			continue
+		}
+
+		notes := &mergeRequestNotes{projectURL: projectURL, token: token, iid: mergeRequest.IID}
+		if hasSummary {
+			if err := sdk.UpdateSummaryComment(notes, event.SHA, summary); err != nil {
+				return fmt.Errorf("merge request !%d: %s", mergeRequest.IID, err.Error())
+			}
+		}
+
+		if len(failures) == 0 {
+			continue
+		}
+
+		// logs are only fetched once there is a merge request to add them to
+		if len(failureNote) == 0 {
+			logs, err := getLogs(event)
+			if err != nil {
+				log.Printf("unable to get logs of %s, error: %s", event.Service, err.Error())
+			}
+			failureNote = buildFailureNote(failures, event, logs)
+		}
+
+		if err := addFailureNote(notes, event, failureNote); err != nil {
+			return fmt.Errorf("merge request !%d: %s", mergeRequest.IID, err.Error())
+		}
+	}
+	return nil
+}
+
+// getFailedStatuses returns the failed statuses, sorted by context
+func getFailedStatuses(status *sdk.Status) []sdk.CommitStatus {
+	failures := []sdk.CommitStatus{}
+	for _, commitStatus := range status.CommitStatuses {
+		if commitStatus.Status == sdk.StatusFailure {
+			failures = append(failures, commitStatus)
+		}
+	}
+
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].Context < failures[j].Context
+	})
+	return failures
+}
+
+// failureMarker identifies the failure note of a function for a commit, so
+// that the note is only added once
+func failureMarker(event *sdk.Event) string {
+	return fmt.Sprintf("<!-- openfaas-cloud:failure %s %s -->", event.SHA, event.Service)
+}
+
+func buildFailureNote(failures []sdk.CommitStatus, event *sdk.Event, logs string) string {
+	sb := strings.Builder{}
+	sb.WriteString(failureMarker(event) + "\n")
+	sb.WriteString(fmt.Sprintf("### :x: `%s` failed for %s\n\n", event.Service, shortSHA(event.SHA)))
+
+	for _, failure := range failures {
+		sb.WriteString(fmt.Sprintf("* `%s`: %s\n", failure.Context, failure.Description))
+	}
+
+	logsURL := buildPublicStatusURL(sdk.StatusFailure, sdk.BuildFunctionContext(event.Service), event)
+	if len(logsURL) > 0 {
+		sb.WriteString(fmt.Sprintf("\n[View the build log](%s)\n", logsURL))
+	}
+
+	if logs = strings.TrimSpace(logs); len(logs) > 0 {
+		if len(logs) > maxNoteLogLength {
+			sb.WriteString(fmt.Sprintf("\nThe log was truncated to its last %d bytes.\n", maxNoteLogLength))
+			logs = logs[len(logs)-maxNoteLogLength:]
+		}
+		sb.WriteString("\n<details><summary>Logs</summary>\n\n```shell\n" + logs + "\n```\n\n</details>\n")
+	}
+	return sb.String()
+}
+
+// addFailureNote adds the note unless the merge request already has the
+// failure note of the function for the commit
+func addFailureNote(notes *mergeRequestNotes, event *sdk.Event, body string) error {
+	existing, err := notes.List()
+	if err != nil {
+		return err
+	}
+
+	marker := failureMarker(event)
+	for _, note := range existing {
+		if strings.HasPrefix(note.Body, marker) {
+			return nil
+		}
+	}
+	return notes.Create(body)
+}
+
+// getLogs reads the logs of the function for the commit from pipeline-log
+func getLogs(event *sdk.Event) (string, error) {
+	logsURL := fmt.Sprintf("%sfunction/pipeline-log?repoPath=%s&commitSHA=%s&function=%s",
+		os.Getenv("gateway_url"),
+		url.QueryEscape(event.Owner+"/"+event.Repository),
+		url.QueryEscape(event.SHA),
+		url.QueryEscape(event.Service))
+
+	c := http.Client{Timeout: 10 * time.Second}
+	res, err := c.Get(logsURL)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status from pipeline-log: %d", res.StatusCode)
+	}
+	return string(body), nil
+}
+
+func shortSHA(SHA string) string {
+	if len(SHA) > 7 {
+		return SHA[:7]
+	}
+	return SHA
+}
+
+// gitLabProjectURL builds the API URL of a project, i.e.
+// https://gitlab.com/api/v4/projects/3
+func gitLabProjectURL(eventURL string, id int) (string, error) {
+	parsedURL, parseErr := url.Parse(eventURL)
+	if parseErr != nil || len(parsedURL.Host) == 0 {
+		return "", fmt.Errorf("error while parsing eventURL: %s", eventURL)
+	}
+	return fmt.Sprintf("%s://%s/api/v4/projects/%d", parsedURL.Scheme, parsedURL.Host, id), nil
+}
+
+// gitLabRequest sends a request to the GitLab API, encoding the body and
+// decoding the response as JSON when given
+func gitLabRequest(method, URL, token string, body interface{}, out interface{}) (*http.Response, error) {
+	var reader *bytes.Reader
+	if body != nil {
+		bodyBytes, _ := json.Marshal(body)
+		reader = bytes.NewReader(bodyBytes)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, reqErr := http.NewRequest(method, URL, reader)
+	if reqErr != nil {
+		return nil, fmt.Errorf("error while creating request to GitLab API: %s", reqErr.Error())
+	}
+	req.Header.Set("PRIVATE-TOKEN", token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, clientErr := http.DefaultClient.Do(req)
+	if clientErr != nil {
+		return nil, fmt.Errorf("error while sending request to GitLab API: %s", clientErr.Error())
+	}
+	if res.Body != nil {
+		defer res.Body.Close()
+	}
+
+	resBytes, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode < http.StatusOK || res.StatusCode > 299 {
+		return res, fmt.Errorf("unexpected status from GitLab API: %d, body: %s", res.StatusCode, string(resBytes))
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(resBytes, out); err != nil {
+			return res, fmt.Errorf("unable to parse response from GitLab API: %s", err.Error())
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,139 @@
+package function
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+func Test_gitLabProjectURL(t *testing.T) {
+	got, err := gitLabProjectURL("https://gitlab.o6s.io/alexellis/fns", 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	want := "https://gitlab.o6s.io/api/v4/projects/3"
+	if got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+
+	if _, err := gitLabProjectURL("", 3); err == nil {
+		t.Errorf("want an error for an empty URL")
+	}
+}
+
+func Test_mergeRequestNotes_ListFollowsPages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "token" {
+			t.Errorf("want the API token to be sent")
+		}
+
+		if r.URL.Query().Get("page") == "1" {
+			w.Header().Set("X-Next-Page", "2")
+			w.Write([]byte(`[{"id": 1, "body": "LGTM"}]`))
+			return
+		}
+		w.Write([]byte(`[{"id": 2, "body": "<!-- openfaas-cloud:summary -->"}]`))
+	}))
+	defer server.Close()
+
+	notes := &mergeRequestNotes{projectURL: server.URL + "/api/v4/projects/3", token: "token", iid: 7}
+
+	comments, err := notes.List()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if len(comments) != 2 || !sdk.IsBuildSummary(comments[1].Body) {
+		t.Errorf("want notes from both pages, got %+v", comments)
+	}
+}
+
+func Test_mergeRequestNotes_Update(t *testing.T) {
+	var method, path, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		bodyBytes, _ := ioutil.ReadAll(r.Body)
+		body = string(bodyBytes)
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	notes := &mergeRequestNotes{projectURL: server.URL + "/api/v4/projects/3", token: "token", iid: 7}
+	if err := notes.Update(42, "summary"); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if method != http.MethodPut || path != "/api/v4/projects/3/merge_requests/7/notes/42" || body != `{"body":"summary"}` {
+		t.Errorf("unexpected request: %s %s %s", method, path, body)
+	}
+}
+
+func Test_reportMergeRequests_AddsFailureNoteOnce(t *testing.T) {
+	created := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/function/pipeline-log":
+			if r.URL.Query().Get("function") != "tester" || r.URL.Query().Get("repoPath") != "alexellis/fns" {
+				t.Errorf("unexpected logs request: %s", r.URL.String())
+			}
+			w.Write([]byte("Step 3/9 : RUN go build\nundefined: Handle\n"))
+		case r.URL.Path == "/api/v4/projects/3/repository/commits/abc1234567/merge_requests":
+			w.Write([]byte(`[{"iid": 7, "state": "opened"}, {"iid": 8, "state": "merged"}]`))
+		case r.URL.Path == "/api/v4/projects/3/merge_requests/7/notes" && r.Method == http.MethodGet:
+			notes := []mergeRequestNote{}
+			for i, body := range created {
+				notes = append(notes, mergeRequestNote{ID: int64(i + 1), Body: body})
+			}
+			json.NewEncoder(w).Encode(notes)
+		case r.URL.Path == "/api/v4/projects/3/merge_requests/7/notes" && r.Method == http.MethodPost:
+			note := mergeRequestNote{}
+			json.NewDecoder(r.Body).Decode(&note)
+			created = append(created, note.Body)
+			w.Write([]byte(`{}`))
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.String())
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	os.Setenv("gateway_url", server.URL+"/")
+	defer os.Unsetenv("gateway_url")
+
+	status := &sdk.Status{
+		EventInfo: sdk.Event{
+			Owner:          "alexellis",
+			Repository:     "fns",
+			Service:        "tester",
+			SHA:            "abc1234567",
+			URL:            server.URL + "/alexellis/fns.git",
+			InstallationID: 3,
+		},
+		CommitStatuses: map[string]sdk.CommitStatus{
+			"tester/build": {Status: sdk.StatusFailure, Description: "build failed", Context: "tester/build"},
+		},
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := reportMergeRequests(status, "token"); err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+	}
+
+	if len(created) != 1 {
+		t.Fatalf("want one failure note, got: %v", created)
+	}
+
+	for _, want := range []string{"<!-- openfaas-cloud:failure abc1234567 tester -->", "`tester/build`: build failed", "undefined: Handle"} {
+		if !strings.Contains(created[0], want) {
+			t.Errorf("want %q in note: %s", want, created[0])
+		}
+	}
+}
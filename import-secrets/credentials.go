@@ -0,0 +1,63 @@
+package function
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
+	corev1 "k8s.io/api/core/v1"
+	errors2 "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/rest"
+)
+
+// lookupGitLabCredential returns the first of the requested secrets which
+// holds a GitLab token, so that functions calling GitLab can use the token
+// an owner sealed for their project. Only secrets named as GitLab tokens
+// can be read this way.
+func lookupGitLabCredential(config *rest.Config, req []byte) (string, error) {
+	request := sdk.GitLabCredentialsRequest{}
+	if err := json.Unmarshal(req, &request); err != nil {
+		return "", fmt.Errorf("couldn't unmarshal credentials request: %s", err)
+	}
+
+	namespace := os.Getenv("secrets_namespace")
+	if len(namespace) == 0 {
+		namespace = defaultSecretsNamespace
+	}
+
+	client, err := newCoreClient(config)
+	if err != nil {
+		return "", err
+	}
+
+	for _, name := range request.Secrets {
+		if !sdk.IsGitLabTokenSecret(name) {
+			return "", fmt.Errorf("not a GitLab token secret: %s", name)
+		}
+
+		secret := corev1.Secret{}
+		getErr := client.Get().Namespace(namespace).Resource("secrets").Name(name).Do().Into(&secret)
+		if errors2.IsNotFound(getErr) {
+			continue
+		}
+		if getErr != nil {
+			return "", fmt.Errorf("couldn't get Secret (%s) - error: %s", name, getErr)
+		}
+
+		credential := sdk.GitLabCredential{
+			Secret:   name,
+			Username: strings.TrimSpace(string(secret.Data["username"])),
+			Token:    strings.TrimSpace(string(secret.Data["token"])),
+		}
+		if len(credential.Token) == 0 || (request.API && !credential.CanCallAPI()) {
+			continue
+		}
+
+		res, _ := json.Marshal(credential)
+		return string(res), nil
+	}
+
+	return "{}", nil
+}
@@ -52,6 +52,24 @@ func Handle(req []byte) string {
 		os.Exit(-1)
 	}
 
+	if os.Getenv("Http_X_Secrets_Migration") == "true" {
+		res, migrateErr := migrateSecrets(config, req)
+		if migrateErr != nil {
+			fmt.Println(migrateErr)
+			os.Exit(-1)
+		}
+		return res
+	}
+
+	if os.Getenv("Http_X_Gitlab_Credentials") == "true" {
+		res, lookupErr := lookupGitLabCredential(config, req)
+		if lookupErr != nil {
+			fmt.Println(lookupErr)
+			os.Exit(-1)
+		}
+		return res
+	}
+
 	ssc := ssv1alpha1clientset.NewForConfigOrDie(config)
 
 	var userSecret SealedSecret
@@ -70,7 +88,9 @@ func Handle(req []byte) string {
 	name := strings.ToLower(userSecret.Metadata.Name)
 	ownerNormalized := strings.ToLower(event.owner)
 
-	if !strings.HasPrefix(name, ownerNormalized) {
+	// An owner must not bind secrets of an owner whose name it prefixes,
+	// such as the subgroups of a GitLab group
+	if !sdk.HasOwnerPrefix(name, ownerNormalized) {
 		return fmt.Errorf("unable to bind a secret which does not start with owner name: %s", ownerNormalized).Error()
 	}
 
@@ -0,0 +1,138 @@
+package function
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	ssv1alpha1clientset "github.com/bitnami-labs/sealed-secrets/pkg/client/clientset/versioned/typed/sealed-secrets/v1alpha1"
+	"github.com/openfaas/openfaas-cloud/sdk"
+	corev1 "k8s.io/api/core/v1"
+	errors2 "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	defaultSecretsNamespace = "openfaas-fn"
+
+	// managedAnnotation lets the SealedSecrets controller take over a copied
+	// secret when the user seals the secret again under the new name
+	managedAnnotation = "sealedsecrets.bitnami.com/managed"
+)
+
+// SecretsMigration is sent by github-event when an owner renames their
+// account
+type SecretsMigration struct {
+	Owner         string `json:"owner"`
+	PreviousOwner string `json:"previous_owner"`
+}
+
+// migrateSecrets copies each secret of the previous owner to the new owner
+// name, SealedSecrets can only be decrypted under the name they were sealed
+// with so the unsealed secrets are copied. The SealedSecrets of the previous
+// owner are then removed, so that the old name can't be used to read them.
+func migrateSecrets(config *rest.Config, req []byte) (string, error) {
+	migration := SecretsMigration{}
+	if err := json.Unmarshal(req, &migration); err != nil {
+		return "", fmt.Errorf("couldn't unmarshal migration: %s", err)
+	}
+
+	owner := strings.ToLower(migration.Owner)
+	previousOwner := strings.ToLower(migration.PreviousOwner)
+	if len(owner) == 0 || len(previousOwner) == 0 || owner == previousOwner {
+		return "", fmt.Errorf("invalid migration from %s to %s", migration.PreviousOwner, migration.Owner)
+	}
+
+	if owner != strings.ToLower(getEventFromHeader().owner) {
+		return "", fmt.Errorf("owner header does not match migration: %s", owner)
+	}
+
+	namespace := os.Getenv("secrets_namespace")
+	if len(namespace) == 0 {
+		namespace = defaultSecretsNamespace
+	}
+
+	client, err := newCoreClient(config)
+	if err != nil {
+		return "", err
+	}
+
+	secrets := corev1.SecretList{}
+	if err := client.Get().Namespace(namespace).Resource("secrets").Do().Into(&secrets); err != nil {
+		return "", fmt.Errorf("couldn't list secrets - error: %s", err)
+	}
+
+	copied := 0
+	for _, secret := range getMigratedSecrets(secrets.Items, previousOwner, owner) {
+		createErr := client.Post().Namespace(namespace).Resource("secrets").Body(&secret).Do().Error()
+		if createErr != nil && !errors2.IsAlreadyExists(createErr) {
+			return "", fmt.Errorf("couldn't create Secret (%s) - error: %s", secret.Name, createErr)
+		}
+		if createErr == nil {
+			copied++
+		}
+	}
+
+	ssc := ssv1alpha1clientset.NewForConfigOrDie(config)
+	sealedSecrets, err := ssc.SealedSecrets(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return "", fmt.Errorf("couldn't list SealedSecrets - error: %s", err)
+	}
+
+	for _, sealedSecret := range sealedSecrets.Items {
+		if !sdk.HasOwnerPrefix(sealedSecret.Name, previousOwner) {
+			continue
+		}
+
+		deleteErr := ssc.SealedSecrets(namespace).Delete(sealedSecret.Name, &metav1.DeleteOptions{})
+		if deleteErr != nil && !errors2.IsNotFound(deleteErr) {
+			return "", fmt.Errorf("couldn't delete SealedSecret (%s) - error: %s", sealedSecret.Name, deleteErr)
+		}
+	}
+
+	return fmt.Sprintf("Migrated %d secrets from %s to %s", copied, previousOwner, owner), nil
+}
+
+// getMigratedSecrets returns a copy of each secret of the previous owner
+// named for the new owner
+func getMigratedSecrets(secrets []corev1.Secret, previousOwner, owner string) []corev1.Secret {
+	migrated := []corev1.Secret{}
+	for _, secret := range secrets {
+		if !sdk.HasOwnerPrefix(secret.Name, previousOwner) {
+			continue
+		}
+
+		migratedSecret := corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        owner + "-" + strings.TrimPrefix(secret.Name, previousOwner+"-"),
+				Namespace:   secret.Namespace,
+				Labels:      secret.Labels,
+				Annotations: map[string]string{managedAnnotation: "true"},
+			},
+			Type: secret.Type,
+			Data: secret.Data,
+		}
+		migrated = append(migrated, migratedSecret)
+	}
+	return migrated
+}
+
+func newCoreClient(config *rest.Config) (*rest.RESTClient, error) {
+	coreConfig := *config
+	coreConfig.GroupVersion = &corev1.SchemeGroupVersion
+	coreConfig.APIPath = "/api"
+	coreConfig.NegotiatedSerializer = scheme.Codecs.WithoutConversion()
+
+	if len(coreConfig.UserAgent) == 0 {
+		coreConfig.UserAgent = rest.DefaultKubernetesUserAgent()
+	}
+
+	client, err := rest.RESTClientFor(&coreConfig)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create client for secrets - error: %s", err)
+	}
+	return client, nil
+}
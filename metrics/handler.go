@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"net/url"
 	"os"
@@ -16,6 +17,8 @@ import (
 type Metrics struct {
 	Success int `json:"success"`
 	Failure int `json:"failure"`
+	// Latency is the average duration of an invocation in seconds
+	Latency float64 `json:"latency"`
 }
 
 // Handle exposes the OpenFaaS instance metrics
@@ -46,6 +49,12 @@ func Handle(req []byte) string {
 		log.Fatalf("Couldn't get metrics from Prometheus for function %s, %t", key, err)
 	}
 
+	latency, err := getLatency(key, metricsQuery, metricsWindow)
+	if err != nil {
+		log.Printf("Couldn't get latency from Prometheus for function %s, %s", key, err)
+	}
+	fnMetrics.Latency = latency
+
 	res, err := json.Marshal(fnMetrics)
 	if err != nil {
 		log.Fatalf("Couldn't marshal json %t", err)
@@ -145,6 +154,41 @@ func getMetrics(fnName string, metricsQuery metrics.PrometheusQueryFetcher, metr
 	return result, nil
 }
 
+func getLatency(fnName string, metricsQuery metrics.PrometheusQueryFetcher, metricsWindow string) (float64, error) {
+	queryValue := fmt.Sprintf(
+		`sum(increase(gateway_functions_seconds_sum{function_name="%s"}[%s])) / sum(increase(gateway_functions_seconds_count{function_name="%s"}[%s]))`,
+		fnName,
+		metricsWindow,
+		fnName,
+		metricsWindow,
+	)
+
+	expr := url.QueryEscape(queryValue)
+
+	response, err := metricsQuery.Fetch(expr)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get latency for function %s, error: %s", fnName, err)
+	}
+
+	for _, v := range response.Data.Result {
+		latencyResIndex := len(v.Value) - 1
+		if s, ok := v.Value[latencyResIndex].(string); ok {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return 0, fmt.Errorf("Couldn't parse latency value to float. Value: %s, Error: %s", s, err)
+			}
+
+			// No invocations within the window gives NaN
+			if math.IsNaN(f) {
+				return 0, nil
+			}
+			return f, nil
+		}
+	}
+
+	return 0, nil
+}
+
 func isSuccess(code string) bool {
 	statusCode, err := strconv.Atoi(code)
 
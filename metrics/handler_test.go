@@ -121,6 +121,53 @@ func Test_getMetrics(t *testing.T) {
 	}
 }
 
+type FakeLatencyQueryFetcher struct {
+	Value string
+}
+
+func (q FakeLatencyQueryFetcher) Fetch(query string) (*metrics.VectorQueryResponse, error) {
+	val := []byte(fmt.Sprintf(`
+{
+  "Data": {
+    "Result": [
+      {
+        "Metric": {},
+        "value": [
+          1536944521.415,
+          "%s"
+        ]
+      }
+    ]
+  }
+}
+`, q.Value))
+	queryRes := metrics.VectorQueryResponse{}
+	err := json.Unmarshal(val, &queryRes)
+	return &queryRes, err
+}
+
+func Test_getLatency(t *testing.T) {
+	got, err := getLatency("testFunc", FakeLatencyQueryFetcher{Value: "0.25"}, "60m")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != 0.25 {
+		t.Errorf("Expected: %f, got: %f", 0.25, got)
+	}
+}
+
+func Test_getLatency_NoInvocations(t *testing.T) {
+	got, err := getLatency("testFunc", FakeLatencyQueryFetcher{Value: "NaN"}, "60m")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != 0 {
+		t.Errorf("Expected: %f, got: %f", 0.0, got)
+	}
+}
+
 func Test_parseMetricsWindow_whenMetricsWindowIsNotSetInQuery(t *testing.T) {
 	expected := "60m"
 
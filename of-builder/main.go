@@ -43,6 +43,14 @@ type buildConfig struct {
 	Ref       string            `json:"ref"`
 	Frontend  string            `json:"frontend,omitempty"`
 	BuildArgs map[string]string `json:"buildArgs,omitempty"`
+	Test      *sdk.TestConfig   `json:"test,omitempty"`
+
+	// Build identifies the commit being built so that the build can be
+	// cancelled when a newer commit is pushed
+	Build *buildRef `json:"build,omitempty"`
+
+	// NoCache builds the image without the build cache
+	NoCache bool `json:"noCache,omitempty"`
 }
 
 func main() {
@@ -72,9 +80,19 @@ func main() {
 		buildArgs["build-arg:no_proxy"] = val
 	}
 
+	if val, ok := os.LookupEnv("build_state_path"); ok && len(val) > 0 {
+		registry, err := loadBuildRegistry(val)
+		if err != nil {
+			log.Printf("unable to load build registry: %s", err.Error())
+		}
+		builds = registry
+	}
+
 	router := mux.NewRouter().StrictSlash(true)
 	router.HandleFunc("/build", buildHandler)
 	router.HandleFunc("/healthz", healthzHandler)
+	router.HandleFunc("/supersede", supersedeHandler)
+	router.HandleFunc("/latest", latestHandler)
 
 	addr := "0.0.0.0:8080"
 	log.Printf("of-builder serving traffic on: %s\n", addr)
@@ -104,6 +122,12 @@ func buildHandler(w http.ResponseWriter, r *http.Request) {
 
 	dt, err := build(w, r, buildArgs)
 
+	if err == errSuperseded {
+		w.WriteHeader(http.StatusConflict)
+		w.Write(dt)
+		return
+	}
+
 	if err != nil {
 		w.WriteHeader(500)
 
@@ -199,6 +223,10 @@ func build(w http.ResponseWriter, r *http.Request, buildArgs map[string]string)
 		frontendAttrs[fmt.Sprintf("build-arg:%s", k)] = v
 	}
 
+	if cfg.NoCache {
+		frontendAttrs["no-cache"] = ""
+	}
+
 	contextDir := filepath.Join(tmpdir, "context")
 	solveOpt := client.SolveOpt{
 		Exporter: "image",
@@ -220,25 +248,81 @@ func build(w http.ResponseWriter, r *http.Request, buildArgs map[string]string)
 		solveOpt.ExporterAttrs["registry.insecure"] = insecure
 	}
 
+	build := buildLog{
+		Line: []string{},
+		Sync: &sync.Mutex{},
+	}
+
+	ctx := context.Background()
+	var running *runningBuild
+	if cfg.Build != nil && len(cfg.Build.SHA) > 0 {
+		buildCtx, runningBuild, startErr := builds.start(ctx, *cfg.Build)
+		if startErr != nil {
+			return supersededResult(cfg, &build), startErr
+		}
+
+		ctx, running = buildCtx, runningBuild
+		defer builds.finish(*cfg.Build, running)
+	}
+
 	c, err := client.New(buildkitURL, client.WithBlock())
 	if err != nil {
 		return nil, err
 	}
 
+	started := time.Now()
+	if err := solve(ctx, c, solveOpt, &build); err != nil {
+		if builds.isSuperseded(running) {
+			return supersededResult(cfg, &build), errSuperseded
+		}
+
+		buildResult := BuildResult{
+			ImageName: cfg.Ref,
+			Log:       build.Line,
+			Status:    fmt.Sprintf("failure: %s", err.Error()),
+			Stages:    buildStages(started, build.Exported, time.Now()),
+		}
+
+		bytesOut, _ := json.Marshal(buildResult)
+		return bytesOut, err
+	}
+
+	buildResult := BuildResult{
+		ImageName: cfg.Ref,
+		Log:       build.Line,
+		Status:    "success",
+		Stages:    buildStages(started, build.Exported, time.Now()),
+	}
+
+	if cfg.Test != nil {
+		buildResult.Test = runTests(ctx, c, cfg, solveOpt, contextDir, &build)
+		buildResult.Log = build.Line
+
+		if builds.isSuperseded(running) {
+			return supersededResult(cfg, &build), errSuperseded
+		}
+	}
+
+	bytesOut, _ := json.Marshal(buildResult)
+
+	return bytesOut, nil
+}
+
+// solve runs a build with buildkit and appends its progress to the log
+func solve(ctx context.Context, c *client.Client, solveOpt client.SolveOpt, build *buildLog) error {
 	ch := make(chan *client.SolveStatus)
-	eg, ctx := errgroup.WithContext(context.Background())
+	eg, ctx := errgroup.WithContext(ctx)
 	eg.Go(func() error {
 		return c.Solve(ctx, nil, solveOpt, ch)
 	})
 
-	build := buildLog{
-		Line: []string{},
-		Sync: &sync.Mutex{},
-	}
-
 	eg.Go(func() error {
 		for s := range ch {
 			for _, v := range s.Vertexes {
+				if strings.HasPrefix(v.Name, exportImageVertex) && v.Started != nil {
+					build.MarkExported(*v.Started)
+				}
+
 				var msg string
 				if v.Completed != nil {
 					msg = fmt.Sprintf("v: %s %s %.2fs", v.Started.Format(time.RFC3339), v.Name, v.Completed.Sub(*v.Started).Seconds())
@@ -274,40 +358,29 @@ func build(w http.ResponseWriter, r *http.Request, buildArgs map[string]string)
 		return nil
 	})
 
-	if err := eg.Wait(); err != nil {
-
-		buildResult := BuildResult{
-			ImageName: cfg.Ref,
-			Log:       build.Line,
-			Status:    fmt.Sprintf("failure: %s", err.Error()),
-		}
-
-		bytesOut, _ := json.Marshal(buildResult)
-		return bytesOut, err
-	}
-
-	buildResult := BuildResult{
-		ImageName: cfg.Ref,
-		Log:       build.Line,
-		Status:    "success",
-	}
-
-	bytesOut, _ := json.Marshal(buildResult)
-
-	return bytesOut, nil
+	return eg.Wait()
 }
 
+// exportImageVertex is the name buildkit gives to exporting the image,
+// which includes pushing it to the registry
+const exportImageVertex = "exporting to image"
+
 // BuildResult represents a successful Docker build and
 // push operation to a remote registry
 type BuildResult struct {
-	Log       []string `json:"log"`
-	ImageName string   `json:"imageName"`
-	Status    string   `json:"status"`
+	Log       []string          `json:"log"`
+	ImageName string            `json:"imageName"`
+	Status    string            `json:"status"`
+	Test      *sdk.TestReport   `json:"test,omitempty"`
+	Stages    []sdk.StageTiming `json:"stages,omitempty"`
 }
 
 type buildLog struct {
 	Line []string
 	Sync *sync.Mutex
+
+	// Exported is when buildkit started to export and push the image
+	Exported time.Time
 }
 
 func (b *buildLog) Append(msg string) {
@@ -318,6 +391,31 @@ func (b *buildLog) Append(msg string) {
 
 }
 
+// MarkExported records the earliest time at which the image was exported
+func (b *buildLog) MarkExported(started time.Time) {
+	b.Sync.Lock()
+	defer b.Sync.Unlock()
+
+	if b.Exported.IsZero() || started.Before(b.Exported) {
+		b.Exported = started
+	}
+}
+
+// buildStages splits the time taken by a solve into the build of the image
+// and its push, the push stage is left out if the image was never exported
+func buildStages(started, exported, completed time.Time) []sdk.StageTiming {
+	if exported.IsZero() || exported.Before(started) || exported.After(completed) {
+		return []sdk.StageTiming{
+			{Stage: sdk.StageBuild, Started: started, Completed: completed},
+		}
+	}
+
+	return []sdk.StageTiming{
+		{Stage: sdk.StageBuild, Started: started, Completed: exported},
+		{Stage: sdk.StagePush, Started: exported, Completed: completed},
+	}
+}
+
 func validateRequest(req *[]byte, r *http.Request) (err error) {
 	payloadSecret, err := sdk.ReadSecret("payload-secret")
 
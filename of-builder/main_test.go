@@ -0,0 +1,55 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+func Test_buildStages_SplitsBuildAndPush(t *testing.T) {
+	started := time.Unix(1586952000, 0)
+	exported := started.Add(30 * time.Second)
+	completed := started.Add(40 * time.Second)
+
+	stages := buildStages(started, exported, completed)
+	if len(stages) != 2 {
+		t.Fatalf("want 2 stages, got %d", len(stages))
+	}
+
+	if stages[0].Stage != sdk.StageBuild || stages[0].Duration() != 30*time.Second {
+		t.Errorf("want build stage of 30s, got %s of %s", stages[0].Stage, stages[0].Duration())
+	}
+
+	if stages[1].Stage != sdk.StagePush || stages[1].Duration() != 10*time.Second {
+		t.Errorf("want push stage of 10s, got %s of %s", stages[1].Stage, stages[1].Duration())
+	}
+}
+
+func Test_buildStages_NotExported(t *testing.T) {
+	started := time.Unix(1586952000, 0)
+	completed := started.Add(40 * time.Second)
+
+	stages := buildStages(started, time.Time{}, completed)
+	if len(stages) != 1 {
+		t.Fatalf("want 1 stage, got %d", len(stages))
+	}
+
+	if stages[0].Stage != sdk.StageBuild || stages[0].Duration() != 40*time.Second {
+		t.Errorf("want build stage of 40s, got %s of %s", stages[0].Stage, stages[0].Duration())
+	}
+}
+
+func Test_MarkExported_KeepsEarliest(t *testing.T) {
+	build := buildLog{Sync: &sync.Mutex{}}
+
+	first := time.Unix(1586952000, 0)
+	build.MarkExported(first.Add(time.Second))
+	build.MarkExported(first)
+	build.MarkExported(first.Add(2 * time.Second))
+
+	if !build.Exported.Equal(first) {
+		t.Errorf("want %s, got %s", first, build.Exported)
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// buildRef identifies the build of a commit of a repo to an environment, it
+// matches sdk.BuildRef
+type buildRef struct {
+	Owner       string `json:"owner"`
+	Repo        string `json:"repo"`
+	SHA         string `json:"sha"`
+	Environment string `json:"environment"`
+	Before      string `json:"before,omitempty"`
+}
+
+func (r buildRef) repoKey() string {
+	return strings.ToLower(fmt.Sprintf("%s/%s:%s", r.Owner, r.Repo, r.Environment))
+}
+
+// errSuperseded is returned for a build of a commit when a newer commit was
+// pushed to the repo
+var errSuperseded = fmt.Errorf("superseded by a newer commit")
+
+// maxBranchHistory is the number of commits remembered for each branch to
+// order pushes which arrive late
+const maxBranchHistory = 100
+
+type runningBuild struct {
+	sha        string
+	cancel     context.CancelFunc
+	superseded bool
+}
+
+// branchState is the head of a branch along with the commits which were
+// pushed to it and the commits which a push replaced
+type branchState struct {
+	Head       string   `json:"head"`
+	Seen       []string `json:"seen,omitempty"`
+	Superseded []string `json:"superseded,omitempty"`
+}
+
+// accepts is true when a push of sha over before moves the head of the
+// branch. A push which arrives after a newer push of the branch is refused,
+// a push over the head is always accepted so that the branch can be forced
+// back to an older commit.
+func (b *branchState) accepts(sha, before string) bool {
+	if len(b.Head) == 0 || (knownCommit(before) && before == b.Head) {
+		return true
+	}
+	if sha == b.Head {
+		return false
+	}
+	if contains(b.Superseded, sha) {
+		return false
+	}
+	return !knownCommit(before) || !contains(b.Seen, before)
+}
+
+// record remembers the commits of a push, the oldest are forgotten once
+// maxBranchHistory is reached
+func (b *branchState) record(sha, before string) {
+	b.Seen = appendCapped(b.Seen, sha)
+	if knownCommit(before) {
+		b.Seen = appendCapped(b.Seen, before)
+		b.Superseded = appendCapped(b.Superseded, before)
+	}
+}
+
+// knownCommit is false for the empty or zero SHA given as the head before
+// the first push to a branch
+func knownCommit(sha string) bool {
+	return len(strings.Trim(sha, "0")) > 0
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func appendCapped(values []string, value string) []string {
+	if contains(values, value) {
+		return values
+	}
+	values = append(values, value)
+	if len(values) > maxBranchHistory {
+		values = values[len(values)-maxBranchHistory:]
+	}
+	return values
+}
+
+// buildRegistry keeps the head of each branch and the builds which are
+// running, so that builds of older commits can be cancelled. The branches
+// are saved to statePath when it is set so that they survive a restart.
+type buildRegistry struct {
+	mutex     sync.Mutex
+	branches  map[string]*branchState
+	running   map[string]map[*runningBuild]struct{}
+	statePath string
+}
+
+func newBuildRegistry() *buildRegistry {
+	return &buildRegistry{
+		branches: map[string]*branchState{},
+		running:  map[string]map[*runningBuild]struct{}{},
+	}
+}
+
+// loadBuildRegistry reads the branches saved at statePath, a registry with
+// no branches is returned when nothing was saved yet
+func loadBuildRegistry(statePath string) (*buildRegistry, error) {
+	registry := newBuildRegistry()
+	registry.statePath = statePath
+
+	data, err := ioutil.ReadFile(statePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return registry, nil
+		}
+		return registry, err
+	}
+
+	if err := json.Unmarshal(data, &registry.branches); err != nil {
+		return registry, fmt.Errorf("unable to parse %s: %s", statePath, err.Error())
+	}
+	if registry.branches == nil {
+		registry.branches = map[string]*branchState{}
+	}
+	return registry, nil
+}
+
+// save writes the branches through a temporary file so that a restart never
+// reads a partial write, the mutex must be held
+func (r *buildRegistry) save() error {
+	if len(r.statePath) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(r.branches)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := r.statePath + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, r.statePath)
+}
+
+var builds = newBuildRegistry()
+
+// supersede records ref as the newest commit of its repo and cancels the
+// running builds of other commits, the commits cancelled are returned. A
+// push which arrives after a newer push of the branch cancels nothing.
+func (r *buildRegistry) supersede(ref buildRef) []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := ref.repoKey()
+	branch, ok := r.branches[key]
+	if !ok {
+		branch = &branchState{}
+		r.branches[key] = branch
+	}
+
+	accepted := branch.accepts(ref.SHA, ref.Before)
+	if accepted {
+		branch.Head = ref.SHA
+	}
+	branch.record(ref.SHA, ref.Before)
+
+	if err := r.save(); err != nil {
+		log.Printf("unable to save build registry: %s", err.Error())
+	}
+
+	cancelled := []string{}
+	if !accepted {
+		return cancelled
+	}
+
+	for build := range r.running[key] {
+		if build.sha == ref.SHA || build.superseded {
+			continue
+		}
+
+		build.superseded = true
+		build.cancel()
+		cancelled = append(cancelled, build.sha)
+	}
+	return cancelled
+}
+
+// start registers a build of ref, the build is cancelled through the
+// context when a newer commit is pushed. errSuperseded is returned when a
+// newer commit was already pushed.
+func (r *buildRegistry) start(ctx context.Context, ref buildRef) (context.Context, *runningBuild, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := ref.repoKey()
+	if branch, ok := r.branches[key]; ok && len(branch.Head) > 0 && branch.Head != ref.SHA {
+		return nil, nil, errSuperseded
+	}
+
+	buildCtx, cancel := context.WithCancel(ctx)
+	build := &runningBuild{sha: ref.SHA, cancel: cancel}
+
+	if r.running[key] == nil {
+		r.running[key] = map[*runningBuild]struct{}{}
+	}
+	r.running[key][build] = struct{}{}
+
+	return buildCtx, build, nil
+}
+
+// finish removes a build once it has completed
+func (r *buildRegistry) finish(ref buildRef, build *runningBuild) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	build.cancel()
+
+	key := ref.repoKey()
+	delete(r.running[key], build)
+	if len(r.running[key]) == 0 {
+		delete(r.running, key)
+	}
+}
+
+func (r *buildRegistry) getLatest(ref buildRef) string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if branch, ok := r.branches[ref.repoKey()]; ok {
+		return branch.Head
+	}
+	return ""
+}
+
+// isSuperseded is true when the build was cancelled for a newer commit
+func (r *buildRegistry) isSuperseded(build *runningBuild) bool {
+	if build == nil {
+		return false
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return build.superseded
+}
+
+// supersedeHandler records the newest commit of a repo, builds of older
+// commits are cancelled
+func supersedeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.Body == nil {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	defer r.Body.Close()
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if val, ok := os.LookupEnv("disable_hmac"); !ok || val != "true" {
+		if hmacErr := validateRequest(&body, r); hmacErr != nil {
+			http.Error(w, hmacErr.Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	ref := buildRef{}
+	if err := json.Unmarshal(body, &ref); err != nil || len(ref.Owner) == 0 || len(ref.Repo) == 0 || len(ref.SHA) == 0 {
+		http.Error(w, "owner, repo and sha are required", http.StatusBadRequest)
+		return
+	}
+
+	cancelled := builds.supersede(ref)
+	if len(cancelled) > 0 {
+		fmt.Printf("%s superseded builds of: %s\n", ref.SHA, strings.Join(cancelled, ", "))
+	}
+
+	bytesOut, _ := json.Marshal(map[string][]string{"cancelled": cancelled})
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(bytesOut)
+}
+
+// latestHandler returns the newest commit of a repo, the sha is empty when
+// none is known
+func latestHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	ref := buildRef{
+		Owner:       query.Get("owner"),
+		Repo:        query.Get("repo"),
+		Environment: query.Get("environment"),
+	}
+	ref.SHA = builds.getLatest(ref)
+
+	bytesOut, _ := json.Marshal(ref)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(bytesOut)
+}
+
+// supersededResult is returned for a build which was cancelled, or never
+// started, because a newer commit was pushed
+func supersededResult(cfg buildConfig, build *buildLog) []byte {
+	buildResult := BuildResult{
+		ImageName: cfg.Ref,
+		Log:       build.Line,
+		Status:    fmt.Sprintf("superseded: %s", errSuperseded.Error()),
+	}
+
+	bytesOut, _ := json.Marshal(buildResult)
+	return bytesOut
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_buildRegistry_SupersedeCancelsOlderBuilds(t *testing.T) {
+	registry := newBuildRegistry()
+	older := buildRef{Owner: "alexellis", Repo: "super-pancake", SHA: "af6db1234567", Environment: "production"}
+
+	ctx, running, err := registry.start(context.Background(), older)
+	if err != nil {
+		t.Fatalf("want build to start, got: %s", err.Error())
+	}
+
+	newer := older
+	newer.SHA = "bf6db1234567"
+	cancelled := registry.supersede(newer)
+
+	if len(cancelled) != 1 || cancelled[0] != older.SHA {
+		t.Errorf("want %s to be cancelled, got: %v", older.SHA, cancelled)
+	}
+	if ctx.Err() == nil {
+		t.Errorf("want context of the older build to be cancelled")
+	}
+	if !registry.isSuperseded(running) {
+		t.Errorf("want older build to be superseded")
+	}
+
+	registry.finish(older, running)
+
+	if _, _, err := registry.start(context.Background(), older); err != errSuperseded {
+		t.Errorf("want older commit to be refused, got: %v", err)
+	}
+
+	ctx, running, err = registry.start(context.Background(), newer)
+	if err != nil {
+		t.Fatalf("want newer commit to start, got: %s", err.Error())
+	}
+	if ctx.Err() != nil || registry.isSuperseded(running) {
+		t.Errorf("want newer build to keep running")
+	}
+	registry.finish(newer, running)
+}
+
+func Test_buildRegistry_KeepsOtherReposAndEnvironments(t *testing.T) {
+	registry := newBuildRegistry()
+	ref := buildRef{Owner: "alexellis", Repo: "super-pancake", SHA: "af6db1234567", Environment: "production"}
+
+	ctx, running, err := registry.start(context.Background(), ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer registry.finish(ref, running)
+
+	otherRepo := buildRef{Owner: "alexellis", Repo: "bread", SHA: "bf6db1234567", Environment: "production"}
+	otherEnvironment := buildRef{Owner: "alexellis", Repo: "super-pancake", SHA: "bf6db1234567", Environment: "staging"}
+	registry.supersede(otherRepo)
+	registry.supersede(otherEnvironment)
+
+	if ctx.Err() != nil {
+		t.Errorf("want build to keep running")
+	}
+	if got := registry.getLatest(ref); got != "" {
+		t.Errorf("want no newest commit, got: %s", got)
+	}
+	if got := registry.getLatest(buildRef{Owner: "AlexEllis", Repo: "bread", Environment: "production"}); got != otherRepo.SHA {
+		t.Errorf("want newest commit %s, got: %s", otherRepo.SHA, got)
+	}
+}
+
+func Test_buildRegistry_LatePushIsIgnored(t *testing.T) {
+	registry := newBuildRegistry()
+	first := buildRef{Owner: "alexellis", Repo: "super-pancake", SHA: "bf6db1234567", Before: "af6db1234567", Environment: "production"}
+	second := buildRef{Owner: "alexellis", Repo: "super-pancake", SHA: "cf6db1234567", Before: first.SHA, Environment: "production"}
+
+	ctx, running, err := registry.start(context.Background(), second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer registry.finish(second, running)
+
+	registry.supersede(second)
+	cancelled := registry.supersede(first)
+
+	if len(cancelled) != 0 {
+		t.Errorf("want no builds cancelled by the late push, got: %v", cancelled)
+	}
+	if ctx.Err() != nil {
+		t.Errorf("want build of the newest commit to keep running")
+	}
+	if got := registry.getLatest(first); got != second.SHA {
+		t.Errorf("want newest commit %s, got: %s", second.SHA, got)
+	}
+	if _, _, err := registry.start(context.Background(), first); err != errSuperseded {
+		t.Errorf("want commit of the late push to be refused, got: %v", err)
+	}
+}
+
+func Test_buildRegistry_ForcePushBackToOlderCommit(t *testing.T) {
+	registry := newBuildRegistry()
+	first := buildRef{Owner: "alexellis", Repo: "super-pancake", SHA: "bf6db1234567", Before: "0000000000000000000000000000000000000000", Environment: "production"}
+	second := buildRef{Owner: "alexellis", Repo: "super-pancake", SHA: "cf6db1234567", Before: first.SHA, Environment: "production"}
+	reset := buildRef{Owner: "alexellis", Repo: "super-pancake", SHA: first.SHA, Before: second.SHA, Environment: "production"}
+
+	registry.supersede(first)
+	registry.supersede(second)
+
+	ctx, running, err := registry.start(context.Background(), second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer registry.finish(second, running)
+
+	cancelled := registry.supersede(reset)
+
+	if len(cancelled) != 1 || cancelled[0] != second.SHA {
+		t.Errorf("want %s to be cancelled, got: %v", second.SHA, cancelled)
+	}
+	if ctx.Err() == nil {
+		t.Errorf("want context of the replaced build to be cancelled")
+	}
+	if got := registry.getLatest(reset); got != first.SHA {
+		t.Errorf("want newest commit %s, got: %s", first.SHA, got)
+	}
+}
+
+func Test_loadBuildRegistry_KeepsBranchesAcrossRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "builds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	statePath := filepath.Join(dir, "builds.json")
+
+	registry, err := loadBuildRegistry(statePath)
+	if err != nil {
+		t.Fatalf("want an empty registry when nothing was saved, got: %s", err.Error())
+	}
+
+	ref := buildRef{Owner: "alexellis", Repo: "super-pancake", SHA: "cf6db1234567", Before: "bf6db1234567", Environment: "production"}
+	registry.supersede(ref)
+
+	restarted, err := loadBuildRegistry(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := restarted.getLatest(ref); got != ref.SHA {
+		t.Errorf("want newest commit %s after a restart, got: %s", ref.SHA, got)
+	}
+
+	late := buildRef{Owner: "alexellis", Repo: "super-pancake", SHA: ref.Before, Before: "af6db1234567", Environment: "production"}
+	restarted.supersede(late)
+	if got := restarted.getLatest(ref); got != ref.SHA {
+		t.Errorf("want late push to be ignored after a restart, got: %s", got)
+	}
+}
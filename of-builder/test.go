@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/moby/buildkit/client"
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+// testResultsStage is added to the Dockerfile for the test stage and holds
+// only the reports so that they can be exported
+const testResultsStage = "ofc-test-results"
+
+// exitCodeFile is written to the reports by a test command
+const exitCodeFile = ".exit-code"
+
+// runTests runs the test stage of a function after its image has been
+// built and collects the JUnit XML reports it writes
+func runTests(ctx context.Context, c *client.Client, cfg buildConfig, buildOpt client.SolveOpt, contextDir string, build *buildLog) *sdk.TestReport {
+	testDir, err := ioutil.TempDir("", "testctx")
+	if err != nil {
+		return &sdk.TestReport{Error: err.Error()}
+	}
+	defer os.RemoveAll(testDir)
+
+	dockerfileDir := filepath.Join(testDir, "dockerfile")
+	outDir := filepath.Join(testDir, "out")
+	for _, dir := range []string{dockerfileDir, outDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return &sdk.TestReport{Error: err.Error()}
+		}
+	}
+
+	dockerfile, err := makeTestDockerfile(cfg, contextDir)
+	if err != nil {
+		return &sdk.TestReport{Error: err.Error()}
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dockerfileDir, "Dockerfile"), []byte(dockerfile), 0600); err != nil {
+		return &sdk.TestReport{Error: err.Error()}
+	}
+
+	frontendAttrs := map[string]string{
+		"source": buildOpt.FrontendAttrs["source"],
+	}
+
+	localDirs := map[string]string{
+		"context":    dockerfileDir,
+		"dockerfile": dockerfileDir,
+	}
+
+	// A target stage is built from the function's own context and build-args
+	if len(cfg.Test.Target) > 0 {
+		for k, v := range buildOpt.FrontendAttrs {
+			frontendAttrs[k] = v
+		}
+		localDirs["context"] = contextDir
+	}
+	frontendAttrs["target"] = testResultsStage
+
+	solveOpt := client.SolveOpt{
+		Exporter:          client.ExporterLocal,
+		ExporterOutputDir: outDir,
+		LocalDirs:         localDirs,
+		Frontend:          "dockerfile.v0",
+		FrontendAttrs:     frontendAttrs,
+		Session:           buildOpt.Session,
+	}
+
+	if err := solve(ctx, c, solveOpt, build); err != nil {
+		return &sdk.TestReport{Error: fmt.Sprintf("unable to run tests: %s", err.Error())}
+	}
+
+	report, err := sdk.ReadJUnitReports(outDir)
+	if err != nil {
+		report.Error = err.Error()
+	}
+
+	if code, readErr := ioutil.ReadFile(filepath.Join(outDir, exitCodeFile)); readErr == nil {
+		exitCode := strings.TrimSpace(string(code))
+		if exitCode != "0" && report.Failed == 0 && len(report.Error) == 0 {
+			report.Error = fmt.Sprintf("test command exited with code %s", exitCode)
+		}
+	}
+
+	return report
+}
+
+// makeTestDockerfile either runs the test command in the built image, or
+// extends the function's Dockerfile so that only the reports written by its
+// test target are exported
+func makeTestDockerfile(cfg buildConfig, contextDir string) (string, error) {
+	reports := strings.TrimSuffix(cfg.Test.Reports, "/")
+
+	if len(cfg.Test.Target) > 0 {
+		dockerfile, err := ioutil.ReadFile(filepath.Join(contextDir, "Dockerfile"))
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("%s\nFROM scratch AS %s\nCOPY --from=%s %s/ /\n",
+			string(dockerfile), testResultsStage, cfg.Test.Target, reports), nil
+	}
+
+	// The exit code is kept so that a failing command without reports
+	// is still a failure
+	return fmt.Sprintf("FROM %s AS ofc-test\nRUN mkdir -p %s; %s; echo $? > %s/%s\n\nFROM scratch AS %s\nCOPY --from=ofc-test %s/ /\n",
+		strings.ToLower(cfg.Ref), reports, cfg.Test.Command, reports, exitCodeFile, testResultsStage, reports), nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+func Test_makeTestDockerfile_Command(t *testing.T) {
+	cfg := buildConfig{
+		Ref:  "registry:5000/Alexellis-fn1:latest-af6db12",
+		Test: &sdk.TestConfig{Command: "npm test", Reports: "/tmp/test-results/"},
+	}
+
+	got, err := makeTestDockerfile(cfg, "")
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err.Error())
+	}
+
+	want := `FROM registry:5000/alexellis-fn1:latest-af6db12 AS ofc-test
+RUN mkdir -p /tmp/test-results; npm test; echo $? > /tmp/test-results/.exit-code
+
+FROM scratch AS ofc-test-results
+COPY --from=ofc-test /tmp/test-results/ /
+`
+	if got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func Test_makeTestDockerfile_Target(t *testing.T) {
+	contextDir, _ := ioutil.TempDir("", "context")
+	defer os.RemoveAll(contextDir)
+
+	ioutil.WriteFile(filepath.Join(contextDir, "Dockerfile"), []byte("FROM golang AS test\nRUN go test ./...\n"), 0600)
+
+	cfg := buildConfig{
+		Test: &sdk.TestConfig{Target: "test", Reports: "/reports"},
+	}
+
+	got, err := makeTestDockerfile(cfg, contextDir)
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err.Error())
+	}
+
+	if !strings.HasPrefix(got, "FROM golang AS test\n") {
+		t.Errorf("want the function's Dockerfile to be kept, got:\n%s", got)
+	}
+	if !strings.HasSuffix(got, "FROM scratch AS ofc-test-results\nCOPY --from=test /reports/ /\n") {
+		t.Errorf("want a stage with the reports, got:\n%s", got)
+	}
+}
@@ -0,0 +1,256 @@
+package function
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexellis/hmac"
+	faasSDK "github.com/openfaas/faas-cli/proxy"
+	"github.com/openfaas/faas-cli/stack"
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+const (
+	Source = "rollback"
+)
+
+// deploymentStatusRolledBack is recorded by buildshiprun for a deploy which
+// was reverted after its smoke test failed
+const deploymentStatusRolledBack = "rolled-back"
+
+var timeout = 3 * time.Second
+
+//FaaSAuth Authentication type for OpenFaaS
+type FaaSAuth struct {
+}
+
+//Set add basic authentication to the request
+func (auth *FaaSAuth) Set(req *http.Request) error {
+	return sdk.AddBasicAuth(req)
+}
+
+// RollbackRequest names the function to roll back, when SHA is empty
+// the function is rolled back to the previous successful deployment.
+type RollbackRequest struct {
+	Owner    string `json:"owner"`
+	Function string `json:"function"`
+	SHA      string `json:"sha"`
+	Actor    string `json:"actor"`
+}
+
+// Handle redeploys a prior image of a function along with the env-vars,
+// secrets and labels recorded in its deployment history.
+func Handle(req []byte) string {
+	validateErr := validateRequestSigning(req)
+	if validateErr != nil {
+		log.Fatal(validateErr)
+	}
+
+	rollbackReq := RollbackRequest{}
+	if err := json.Unmarshal(req, &rollbackReq); err != nil {
+		log.Fatal(err)
+	}
+
+	if len(rollbackReq.Owner) == 0 || len(rollbackReq.Function) == 0 {
+		log.Fatal("owner and function are required")
+	}
+
+	gatewayURL := os.Getenv("gateway_url")
+
+	history, err := sdk.GetDeploymentHistory(gatewayURL, rollbackReq.Owner, rollbackReq.Function)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	target, err := findTarget(history, rollbackReq.SHA)
+	if err != nil {
+		auditEvent := sdk.AuditEvent{
+			Message: fmt.Sprintf("Unable to roll back %s/%s: %s", rollbackReq.Owner, rollbackReq.Function, err.Error()),
+			Owner:   rollbackReq.Owner,
+			Source:  Source,
+		}
+		sdk.PostAudit(auditEvent)
+		log.Fatal(err)
+	}
+
+	deploySpec := buildDeploySpec(target)
+
+	ctx := context.Background()
+	client := faasSDK.NewClient(&FaaSAuth{}, gatewayURL, nil, &timeout)
+	resStatus := client.DeployFunction(ctx, deploySpec)
+
+	status := "success"
+	var failure string
+	if resStatus < 200 || resStatus > 299 {
+		status = "failure"
+		failure = fmt.Sprintf("http status code %d", resStatus)
+	} else {
+		healthURL := sdk.BuildHealthURL(gatewayURL, deploySpec.FunctionName, deploySpec.Annotations)
+		readyErr := sdk.WaitForReadiness(ctx, gatewayURL, deploySpec.FunctionName, deploySpec.Image, healthURL, sdk.GetReadinessConfig())
+		if readyErr != nil {
+			status = "failure"
+			failure = readyErr.Error()
+		}
+	}
+
+	payloadSecret, keyErr := sdk.ReadSecret("payload-secret")
+	if keyErr != nil {
+		log.Printf("failed to load hmac key, error %s", keyErr.Error())
+	} else {
+		record := *target
+		record.Action = sdk.DeploymentActionRollback
+		record.Actor = rollbackReq.Actor
+		record.Status = status
+		record.Time = time.Now().UTC()
+		record.Labels = deploySpec.Labels
+
+		if recordErr := sdk.PostDeploymentRecord(gatewayURL, payloadSecret, record); recordErr != nil {
+			log.Printf("deployment-history: error: %s", recordErr.Error())
+		}
+	}
+
+	msg := fmt.Sprintf("Rolled back %s to %s (%s), requested by: %s",
+		deploySpec.FunctionName, target.Image, sdk.FormatShortSHA(target.SHA), actorName(rollbackReq.Actor))
+	if status != "success" {
+		msg = fmt.Sprintf("Unable to roll back %s to %s, %s",
+			deploySpec.FunctionName, target.Image, failure)
+	}
+
+	auditEvent := sdk.AuditEvent{
+		Message: msg,
+		Owner:   target.Owner,
+		Repo:    target.Repo,
+		Source:  Source,
+	}
+	sdk.PostAudit(auditEvent)
+
+	if status != "success" {
+		log.Fatal(msg)
+	}
+
+	return msg
+}
+
+// findTarget picks the deployment to roll back to from a history which is
+// ordered with the most recent deployment first. Only deploys and promoted
+// canaries are rolled back to, as a canary runs as a function of its own.
+func findTarget(history []sdk.DeploymentRecord, sha string) (*sdk.DeploymentRecord, error) {
+	if len(history) == 0 {
+		return nil, fmt.Errorf("no deployment history found")
+	}
+
+	if len(sha) > 0 {
+		for i, record := range history {
+			if isRollbackTarget(record) && strings.HasPrefix(record.SHA, sha) {
+				return &history[i], nil
+			}
+		}
+		return nil, fmt.Errorf("no successful deployment found for SHA: %s", sha)
+	}
+
+	current := getCurrentImage(history)
+	for i, record := range history {
+		if isRollbackTarget(record) && record.Image != current {
+			return &history[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("no previous successful deployment found")
+}
+
+func isRollbackTarget(record sdk.DeploymentRecord) bool {
+	return record.Status == "success" &&
+		(record.Action == sdk.DeploymentActionDeploy || record.Action == sdk.DeploymentActionPromote)
+}
+
+// getCurrentImage returns the image of the most recent record which left
+// its image running on the function itself
+func getCurrentImage(history []sdk.DeploymentRecord) string {
+	for _, record := range history {
+		switch record.Action {
+		case sdk.DeploymentActionDeploy, sdk.DeploymentActionPromote:
+			if record.Status != deploymentStatusRolledBack {
+				return record.Image
+			}
+		case sdk.DeploymentActionRollback:
+			if record.Status == "success" {
+				return record.Image
+			}
+		}
+	}
+	return ""
+}
+
+func buildDeploySpec(record *sdk.DeploymentRecord) *faasSDK.DeployFunctionSpec {
+	labels := map[string]string{}
+	for k, v := range record.Labels {
+		labels[k] = v
+	}
+	delete(labels, sdk.CanaryOfLabel)
+	labels[sdk.FunctionLabelPrefix+"git-deploytime"] = strconv.FormatInt(time.Now().Unix(), 10)
+
+	deploySpec := &faasSDK.DeployFunctionSpec{
+		FunctionName: sdk.FormatServiceName(record.Owner, record.Function),
+		Image:        record.Image,
+		Network:      "func_functions",
+		Update:       true,
+		EnvVars:      record.Environment,
+		Secrets:      record.Secrets,
+		Labels:       labels,
+		Annotations:  record.Annotations,
+		FunctionResourceRequest: faasSDK.FunctionResourceRequest{
+			Limits:   &stack.FunctionResources{},
+			Requests: &stack.FunctionResources{},
+		},
+	}
+
+	// the pull secret is only used on Swarm, Kubernetes reads it from the
+	// service account
+	if registryAuth, err := sdk.ReadSecret("swarm-pull-secret"); err == nil && len(registryAuth) > 0 {
+		deploySpec.RegistryAuth = registryAuth
+	}
+
+	if record.Limits != nil {
+		deploySpec.FunctionResourceRequest.Limits.Memory = record.Limits.Memory
+		deploySpec.FunctionResourceRequest.Limits.CPU = record.Limits.CPU
+	}
+
+	if record.Requests != nil {
+		deploySpec.FunctionResourceRequest.Requests.Memory = record.Requests.Memory
+		deploySpec.FunctionResourceRequest.Requests.CPU = record.Requests.CPU
+	}
+
+	return deploySpec
+}
+
+func actorName(actor string) string {
+	if len(actor) == 0 {
+		return "unknown"
+	}
+	return actor
+}
+
+func validateRequestSigning(req []byte) (err error) {
+	payloadSecret, err := sdk.ReadSecret("payload-secret")
+
+	if err != nil {
+		return fmt.Errorf("couldn't get payload-secret: %t", err)
+	}
+
+	xCloudSignature := os.Getenv("Http_X_Cloud_Signature")
+
+	err = hmac.Validate(req, xCloudSignature, payloadSecret)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,175 @@
+package function
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openfaas/openfaas-cloud/sdk"
+)
+
+func Test_findTarget_Previous(t *testing.T) {
+	history := []sdk.DeploymentRecord{
+		{Action: sdk.DeploymentActionDeploy, Image: "alexellis/fn1:latest-ccc", SHA: "ccc", Status: "success"},
+		{Action: sdk.DeploymentActionDeploy, Image: "alexellis/fn1:latest-bbb", SHA: "bbb", Status: "failure"},
+		{Action: sdk.DeploymentActionDeploy, Image: "alexellis/fn1:latest-ccc", SHA: "ccc", Status: "success"},
+		{Action: sdk.DeploymentActionDeploy, Image: "alexellis/fn1:latest-aaa", SHA: "aaa", Status: "success"},
+	}
+
+	target, err := findTarget(history, "")
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err.Error())
+	}
+
+	if target.SHA != "aaa" {
+		t.Errorf("want SHA: %s, got: %s", "aaa", target.SHA)
+	}
+}
+
+func Test_findTarget_BySHA(t *testing.T) {
+	history := []sdk.DeploymentRecord{
+		{Action: sdk.DeploymentActionDeploy, Image: "alexellis/fn1:latest-ccc", SHA: "ccc123", Status: "success"},
+		{Action: sdk.DeploymentActionDeploy, Image: "alexellis/fn1:latest-bbb", SHA: "bbb123", Status: "success"},
+	}
+
+	target, err := findTarget(history, "bbb")
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err.Error())
+	}
+
+	if target.SHA != "bbb123" {
+		t.Errorf("want SHA: %s, got: %s", "bbb123", target.SHA)
+	}
+}
+
+func Test_findTarget_NoneFound(t *testing.T) {
+	history := []sdk.DeploymentRecord{
+		{Action: sdk.DeploymentActionDeploy, Image: "alexellis/fn1:latest-ccc", SHA: "ccc", Status: "success"},
+		{Action: sdk.DeploymentActionDeploy, Image: "alexellis/fn1:latest-bbb", SHA: "bbb", Status: "failure"},
+	}
+
+	_, err := findTarget(history, "")
+	if err == nil {
+		t.Fatalf("want error when no previous successful deployment exists")
+	}
+}
+
+func Test_findTarget_SkipsCanaries(t *testing.T) {
+	history := []sdk.DeploymentRecord{
+		{Action: sdk.DeploymentActionCanary, Image: "alexellis/fn1:latest-ddd", SHA: "ddd", Status: "success"},
+		{Action: sdk.DeploymentActionDeploy, Image: "alexellis/fn1:latest-ccc", SHA: "ccc", Status: "success"},
+		{Action: sdk.DeploymentActionCanary, Image: "alexellis/fn1:latest-bbb", SHA: "bbb", Status: "success"},
+		{Action: sdk.DeploymentActionDeploy, Image: "alexellis/fn1:latest-aaa", SHA: "aaa", Status: "success"},
+	}
+
+	target, err := findTarget(history, "")
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err.Error())
+	}
+	if target.SHA != "aaa" {
+		t.Errorf("want SHA: %s, got: %s", "aaa", target.SHA)
+	}
+
+	if _, err := findTarget(history, "bbb"); err == nil {
+		t.Errorf("want no target for the SHA of a canary")
+	}
+}
+
+func Test_findTarget_AfterRollback(t *testing.T) {
+	history := []sdk.DeploymentRecord{
+		{Action: sdk.DeploymentActionRollback, Image: "alexellis/fn1:latest-bbb", SHA: "bbb", Status: "success"},
+		{Action: sdk.DeploymentActionDeploy, Image: "alexellis/fn1:latest-ccc", SHA: "ccc", Status: "success"},
+		{Action: sdk.DeploymentActionPromote, Image: "alexellis/fn1:latest-bbb", SHA: "bbb", Status: "success"},
+		{Action: sdk.DeploymentActionCanary, Image: "alexellis/fn1:latest-bbb", SHA: "bbb", Status: "success"},
+		{Action: sdk.DeploymentActionDeploy, Image: "alexellis/fn1:latest-aaa", SHA: "aaa", Status: "success"},
+	}
+
+	target, err := findTarget(history, "")
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err.Error())
+	}
+	if target.SHA != "ccc" || target.Action != sdk.DeploymentActionDeploy {
+		t.Errorf("want the deploy of SHA: %s, got: %s %s", "ccc", target.Action, target.SHA)
+	}
+
+	target, err = findTarget(history, "bbb")
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err.Error())
+	}
+	if target.Action != sdk.DeploymentActionPromote {
+		t.Errorf("want the promoted canary, got: %s", target.Action)
+	}
+}
+
+func Test_findTarget_SkipsRolledBackDeploy(t *testing.T) {
+	history := []sdk.DeploymentRecord{
+		{Action: sdk.DeploymentActionDeploy, Image: "alexellis/fn1:latest-ccc", SHA: "ccc", Status: deploymentStatusRolledBack},
+		{Action: sdk.DeploymentActionDeploy, Image: "alexellis/fn1:latest-bbb", SHA: "bbb", Status: "success"},
+		{Action: sdk.DeploymentActionDeploy, Image: "alexellis/fn1:latest-aaa", SHA: "aaa", Status: "success"},
+	}
+
+	target, err := findTarget(history, "")
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err.Error())
+	}
+	if target.SHA != "aaa" {
+		t.Errorf("want SHA: %s, got: %s", "aaa", target.SHA)
+	}
+}
+
+func Test_buildDeploySpec(t *testing.T) {
+	record := &sdk.DeploymentRecord{
+		Owner:       "alexellis",
+		Function:    "fn1",
+		Image:       "alexellis/fn1:latest-aaa",
+		Environment: map[string]string{"debug": "true"},
+		Secrets:     []string{"alexellis-token"},
+		Labels:      map[string]string{sdk.FunctionLabelPrefix + "git-sha": "aaa", sdk.CanaryOfLabel: "alexellis-fn1"},
+		Limits:      &sdk.DeploymentResources{Memory: "128m"},
+	}
+
+	spec := buildDeploySpec(record)
+
+	if spec.FunctionName != "alexellis-fn1" {
+		t.Errorf("want function name: %s, got: %s", "alexellis-fn1", spec.FunctionName)
+	}
+	if !spec.Update {
+		t.Errorf("want update to be set")
+	}
+	if spec.Labels[sdk.FunctionLabelPrefix+"git-sha"] != "aaa" {
+		t.Errorf("want git-sha label: %s, got: %s", "aaa", spec.Labels[sdk.FunctionLabelPrefix+"git-sha"])
+	}
+	if _, ok := spec.Labels[sdk.CanaryOfLabel]; ok {
+		t.Errorf("want %s label to be removed", sdk.CanaryOfLabel)
+	}
+	if len(spec.Labels[sdk.FunctionLabelPrefix+"git-deploytime"]) == 0 {
+		t.Errorf("want git-deploytime label to be set")
+	}
+	if spec.FunctionResourceRequest.Limits.Memory != "128m" {
+		t.Errorf("want memory limit: %s, got: %s", "128m", spec.FunctionResourceRequest.Limits.Memory)
+	}
+	if _, ok := record.Labels[sdk.FunctionLabelPrefix+"git-deploytime"]; ok {
+		t.Errorf("want recorded labels to be left unchanged")
+	}
+}
+
+func Test_buildDeploySpec_RegistryAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "swarm-pull-secret"), []byte("dXNlcjpwYXNz\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("secret_mount_path", dir)
+	defer os.Unsetenv("secret_mount_path")
+
+	spec := buildDeploySpec(&sdk.DeploymentRecord{Owner: "alexellis", Function: "fn1", Image: "alexellis/fn1:latest-aaa"})
+
+	if spec.RegistryAuth != "dXNlcjpwYXNz" {
+		t.Errorf("want registry auth: %s, got: %q", "dXNlcjpwYXNz", spec.RegistryAuth)
+	}
+}
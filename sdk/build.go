@@ -6,4 +6,11 @@ type BuildResult struct {
 	Log       []string `json:"log"`
 	ImageName string   `json:"imageName"`
 	Status    string   `json:"status"`
+
+	// Test is the outcome of the test stage, when one was run
+	Test *TestReport `json:"test,omitempty"`
+
+	// Stages records the timing of the build and push of the image,
+	// when the build fails the last stage is the one which failed
+	Stages []StageTiming `json:"stages,omitempty"`
 }
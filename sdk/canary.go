@@ -0,0 +1,24 @@
+package sdk
+
+// CanarySuffix is appended to the name of a function to give the name of
+// the parallel function which runs the canary version
+const CanarySuffix = "-canary"
+
+// Annotations used in stack.yml to opt a function into progressive
+// delivery through a canary
+const (
+	CanaryAnnotation             = FunctionLabelPrefix + "canary"
+	CanaryStepsAnnotation        = FunctionLabelPrefix + "canary.steps"
+	CanaryIntervalAnnotation     = FunctionLabelPrefix + "canary.interval"
+	CanaryMaxErrorRateAnnotation = FunctionLabelPrefix + "canary.max-error-rate"
+	CanaryMaxLatencyAnnotation   = FunctionLabelPrefix + "canary.max-latency"
+)
+
+// CanaryOfLabel is set on a canary function with the name of the function
+// which it will replace when promoted
+const CanaryOfLabel = FunctionLabelPrefix + "canary-of"
+
+// FormatCanaryName gives the name of the canary for a function
+func FormatCanaryName(functionName string) string {
+	return functionName + CanarySuffix
+}
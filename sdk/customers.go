@@ -40,29 +40,50 @@ func ValidateCustomerList(customers []string) bool {
 // customerCacheExpiry matches the CDN value of GitHub for "RAW" files
 const customerCacheExpiry = time.Minute * 5
 
-// Customers checks whether users are customers of OpenFaaS Cloud
+// Customers checks whether users are customers of OpenFaaS Cloud, either
+// by login or by membership of an organization, team or group given as a
+// rule such as org:openfaas in the list
 type Customers struct {
 	Usernames *map[string]string
+	Rules     []CustomerRule
 	Sync      *sync.Mutex
 	Expires   time.Time
 
 	CustomersURL  string
 	CustomersPath string
+
+	// Membership resolves the rules for Get, without it a rule only
+	// matches the organization or group it names
+	Membership MembershipChecker
+
+	members    *ExpiringSet
+	nonMembers *ExpiringSet
 }
 
 // NewCustomers creates a Customers struct to be used to query
 // valid users.
 func NewCustomers(customersPath, customersURL string) *Customers {
+	members, nonMembers := NewMembershipCache()
+
 	return &Customers{
 		Sync:          &sync.Mutex{},
 		Expires:       time.Now().Add(time.Minute * -1),
 		CustomersPath: customersPath,
 		CustomersURL:  customersURL,
+		members:       members,
+		nonMembers:    nonMembers,
 	}
 }
 
 // Get returns whether a customer is found
 func (c *Customers) Get(login string) (bool, error) {
+	return c.GetWithMembership(login, c.Membership)
+}
+
+// GetWithMembership returns whether a customer is found, resolving the rules
+// with membership. Resolved memberships are cached, an error is only returned
+// when no rule matched and a rule could not be resolved.
+func (c *Customers) GetWithMembership(login string, membership MembershipChecker) (bool, error) {
 	found := false
 
 	log.Printf("CUSTOMERS cache expires in: %fs", c.Expires.Sub(time.Now()).Seconds())
@@ -71,28 +92,85 @@ func (c *Customers) Get(login string) (bool, error) {
 	}
 
 	c.Sync.Lock()
-	defer c.Sync.Unlock()
 
-	lookup := *c.Usernames
+	lookup := map[string]string{}
+	if c.Usernames != nil {
+		lookup = *c.Usernames
+	}
 
 	if _, ok := lookup[strings.ToLower(login)]; ok {
 		found = true
 	}
+	rules := c.Rules
+	c.Sync.Unlock()
+
+	if found {
+		return found, nil
+	}
+	return c.matchRules(rules, formatUsername(login), membership)
+}
+
+func (c *Customers) matchRules(rules []CustomerRule, login string, membership MembershipChecker) (bool, error) {
+	var lastErr error
+
+	for _, rule := range rules {
+		// The organization or group itself owns repositories of its members
+		if rule.Kind != CustomerRuleTeam && rule.Name == login {
+			return true, nil
+		}
+
+		// The path of a subgroup only matches its own rule, it isn't a user
+		if membership == nil || strings.Contains(login, "/") {
+			continue
+		}
+
+		key := rule.String() + "@" + login
+		if c.members != nil && c.members.Contains(key) {
+			return true, nil
+		}
+		if c.nonMembers != nil && c.nonMembers.Contains(key) {
+			continue
+		}
+
+		member, err := membership.IsMember(rule, login)
+		if err != nil {
+			log.Printf("unable to resolve %s for %s: %s", rule, login, err.Error())
+			lastErr = err
+			continue
+		}
+
+		cache := c.nonMembers
+		if member {
+			cache = c.members
+		}
+		if cache != nil {
+			if _, err := cache.Add(key); err != nil {
+				log.Printf("unable to cache %s for %s: %s", rule, login, err.Error())
+			}
+		}
+
+		if member {
+			return true, nil
+		}
+	}
 
-	return found, nil
+	return false, lastErr
 }
 
 // Fetch refreshes cache of customers which is valid for
 // `customerCacheExpiry` duration.
 func (c *Customers) Fetch() error {
 	usernames := map[string]string{}
+	rules := []CustomerRule{}
 
 	if len(c.CustomersPath) > 0 {
 		if out, err := ioutil.ReadFile(c.CustomersPath); err == nil {
 			values := string(out)
 
 			for _, customer := range strings.Split(values, "\n") {
-				if formatted := formatUsername(customer); len(formatted) > 0 {
+				if rule, ok := ParseCustomerRule(customer); ok {
+					rules = append(rules, rule)
+				} else if formatted := formatUsername(customer); len(formatted) > 0 {
 					usernames[formatted] = "true"
 				}
 			}
@@ -100,7 +178,7 @@ func (c *Customers) Fetch() error {
 	} else {
 		customersURL := os.Getenv("customers_url")
 		if len(customersURL) == 0 {
-			customersURL = "https://raw.githubusercontent.com/openfaas/openfaas-cloud/master/CUSTOMERS"
+			customersURL = GetGitHubURLs().RawFileURL("openfaas", "openfaas-cloud", "master", "CUSTOMERS")
 		}
 
 		log.Printf("Fetching customers from %s", customersURL)
@@ -111,16 +189,21 @@ func (c *Customers) Fetch() error {
 		}
 
 		for _, customer := range customers {
-			usernames[customer] = "true"
+			if rule, ok := ParseCustomerRule(customer); ok {
+				rules = append(rules, rule)
+			} else {
+				usernames[customer] = "true"
+			}
 		}
 	}
 
 	c.Sync.Lock()
 	defer c.Sync.Unlock()
 
-	log.Printf("%d customers found", len(usernames))
+	log.Printf("%d customers and %d membership rules found", len(usernames), len(rules))
 
 	c.Usernames = &usernames
+	c.Rules = rules
 	c.Expires = time.Now().Add(customerCacheExpiry)
 
 	return nil
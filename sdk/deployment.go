@@ -0,0 +1,107 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/alexellis/hmac"
+)
+
+// Actions recorded in the deployment history
+const (
+	DeploymentActionDeploy   = "deploy"
+	DeploymentActionRollback = "rollback"
+	DeploymentActionCanary   = "canary"
+	DeploymentActionPromote  = "promote"
+)
+
+// DeploymentResources are the limits or requests given to a
+// deployed function
+type DeploymentResources struct {
+	Memory string `json:"memory,omitempty"`
+	CPU    string `json:"cpu,omitempty"`
+}
+
+// DeploymentRecord is an entry in the deployment history of a function
+// and holds enough of the deployment to be able to redeploy it
+type DeploymentRecord struct {
+	Owner    string    `json:"owner"`
+	Repo     string    `json:"repo"`
+	Function string    `json:"function"`
+	Image    string    `json:"image"`
+	SHA      string    `json:"sha"`
+	Actor    string    `json:"actor"`
+	Status   string    `json:"status"`
+	Action   string    `json:"action"`
+	Time     time.Time `json:"time"`
+
+	Environment map[string]string    `json:"environment"`
+	Secrets     []string             `json:"secrets"`
+	Labels      map[string]string    `json:"labels"`
+	Annotations map[string]string    `json:"annotations"`
+	Limits      *DeploymentResources `json:"limits,omitempty"`
+	Requests    *DeploymentResources `json:"requests,omitempty"`
+}
+
+// PostDeploymentRecord stores a record in the deployment history via
+// the deployment-history function
+func PostDeploymentRecord(gatewayURL, payloadSecret string, record DeploymentRecord) error {
+	bytesOut, _ := json.Marshal(&record)
+
+	req, _ := http.NewRequest(http.MethodPost, gatewayURL+"function/deployment-history", bytes.NewReader(bytesOut))
+
+	digest := hmac.Sign(bytesOut, []byte(payloadSecret))
+	req.Header.Add(CloudSignatureHeader, "sha1="+hex.EncodeToString(digest))
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	if res.Body != nil {
+		defer res.Body.Close()
+	}
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("unexpected status from deployment-history: %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// GetDeploymentHistory returns the deployment history for a function
+// with the most recent deployment first
+func GetDeploymentHistory(gatewayURL, owner, function string) ([]DeploymentRecord, error) {
+	query := url.Values{}
+	query.Set("owner", owner)
+	query.Set("function", function)
+
+	req, _ := http.NewRequest(http.MethodGet, gatewayURL+"function/deployment-history?"+query.Encode(), nil)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Body != nil {
+		defer res.Body.Close()
+	}
+
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status from deployment-history: %d, body: %s", res.StatusCode, string(body))
+	}
+
+	records := []DeploymentRecord{}
+	if err := json.Unmarshal(body, &records); err != nil {
+		return nil, fmt.Errorf("unable to parse deployment history: %s", err.Error())
+	}
+
+	return records, nil
+}
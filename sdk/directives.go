@@ -0,0 +1,94 @@
+package sdk
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	skipDirective    = regexp.MustCompile(`(?i)\[\s*(skip\s+ci|ci\s+skip)\s*\]`)
+	rebuildDirective = regexp.MustCompile(`(?i)\[\s*ofc\s+rebuild\s*\]`)
+	onlyDirective    = regexp.MustCompile(`(?i)\[\s*ofc\s+only\s*:([^\]]*)\]`)
+)
+
+// HeadCommit is the commit a push moved the branch to
+type HeadCommit struct {
+	ID      string `json:"id"`
+	Message string `json:"message"`
+}
+
+// CommitDirectives are read from the message of the head commit of a push
+type CommitDirectives struct {
+	// Skip is set by [skip ci] or [ci skip], the commit isn't built
+	Skip bool
+
+	// Rebuild is set by [ofc rebuild], the commit is built again without
+	// the build cache
+	Rebuild bool
+
+	// Functions is set by [ofc only: fn1,fn2], only the named functions
+	// are built
+	Functions []string
+}
+
+// ParseCommitDirectives reads the directives from the message of the head
+// commit, none are found when there is no head commit
+func ParseCommitDirectives(headCommit *HeadCommit) CommitDirectives {
+	directives := CommitDirectives{}
+	if headCommit == nil {
+		return directives
+	}
+
+	message := headCommit.Message
+	directives.Skip = skipDirective.MatchString(message)
+	directives.Rebuild = rebuildDirective.MatchString(message)
+
+	for _, match := range onlyDirective.FindAllStringSubmatch(message, -1) {
+		for _, name := range strings.Split(match[1], ",") {
+			name = strings.TrimSpace(name)
+			if len(name) > 0 && !contains(directives.Functions, name) {
+				directives.Functions = append(directives.Functions, name)
+			}
+		}
+	}
+
+	return directives
+}
+
+// Apply sets the fields of the push event which git-tar reads to build
+// the commit as the directives ask
+func (d CommitDirectives) Apply(pushEvent *PushEvent) {
+	if d.Rebuild {
+		pushEvent.Rebuild = true
+		pushEvent.NoCache = true
+	}
+
+	if len(d.Functions) > 0 {
+		pushEvent.Functions = d.Functions
+	}
+}
+
+// Describe explains how the directives change the build, it is empty when
+// the commit is built as usual
+func (d CommitDirectives) Describe() string {
+	parts := []string{}
+	if d.Rebuild {
+		parts = append(parts, "rebuilding without the build cache")
+	}
+
+	if len(d.Functions) > 0 {
+		parts = append(parts, fmt.Sprintf("building only: %s", strings.Join(d.Functions, ", ")))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package sdk
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_ParseCommitDirectives(t *testing.T) {
+	cases := []struct {
+		message string
+		want    CommitDirectives
+	}{
+		{message: "Fix typo in README", want: CommitDirectives{}},
+		{message: "Fix typo in README [skip ci]", want: CommitDirectives{Skip: true}},
+		{message: "Update docs\n\n[CI SKIP]", want: CommitDirectives{Skip: true}},
+		{message: "Bump base image [ofc rebuild]", want: CommitDirectives{Rebuild: true}},
+		{message: "Fix fn1 [ofc only: fn1, fn2]", want: CommitDirectives{Functions: []string{"fn1", "fn2"}}},
+		{message: "[ofc only: fn1,fn1] [ofc only:fn3]", want: CommitDirectives{Functions: []string{"fn1", "fn3"}}},
+		{message: "[ofc only: ]", want: CommitDirectives{}},
+		{message: "skip ci without brackets", want: CommitDirectives{}},
+	}
+
+	for _, c := range cases {
+		got := ParseCommitDirectives(&HeadCommit{Message: c.message})
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q want: %+v, got: %+v", c.message, c.want, got)
+		}
+	}
+
+	if got := ParseCommitDirectives(nil); !reflect.DeepEqual(got, CommitDirectives{}) {
+		t.Errorf("want no directives without a head commit, got: %+v", got)
+	}
+}
+
+func Test_CommitDirectives_Apply(t *testing.T) {
+	pushEvent := PushEvent{}
+	directives := ParseCommitDirectives(&HeadCommit{Message: "[ofc rebuild] [ofc only: fn1]"})
+	directives.Apply(&pushEvent)
+
+	if !pushEvent.Rebuild || !pushEvent.NoCache {
+		t.Errorf("want a rebuild without the cache, got rebuild: %t, no cache: %t", pushEvent.Rebuild, pushEvent.NoCache)
+	}
+
+	if !reflect.DeepEqual(pushEvent.Functions, []string{"fn1"}) {
+		t.Errorf("want functions: [fn1], got: %v", pushEvent.Functions)
+	}
+
+	want := "rebuilding without the build cache, building only: fn1"
+	if got := directives.Describe(); got != want {
+		t.Errorf("want: %q, got: %q", want, got)
+	}
+}
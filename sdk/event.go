@@ -11,6 +11,7 @@ type Event struct {
 	Owner          string            `json:"owner"`
 	OwnerID        int               `json:"owner-id"`
 	Repository     string            `json:"repository"`
+	RepositoryID   int64             `json:"repository-id,omitempty"`
 	Image          string            `json:"image"`
 	SHA            string            `json:"sha"`
 	URL            string            `json:"url"`
@@ -22,6 +23,9 @@ type Event struct {
 	RepoURL        string            `json:"repourl"`
 	Labels         map[string]string `json:"labels"`
 	Annotations    map[string]string `json:"annotations"`
+	Actor          string            `json:"actor"`
+	Handler        string            `json:"handler"`
+	ImageSize      int64             `json:"image-size,omitempty"`
 }
 
 // BuildEventFromPushEvent function to build Event from PushEvent
@@ -38,11 +42,13 @@ func BuildEventFromPushEvent(pushEvent PushEvent) *Event {
 	info.EventKey = pushEvent.Repository.Name + "-" + shortRef
 	info.Owner = pushEvent.Repository.Owner.Login
 	info.Repository = pushEvent.Repository.Name
+	info.RepositoryID = pushEvent.Repository.ID
 	info.URL = pushEvent.Repository.CloneURL
 	info.Private = pushEvent.Repository.Private
 
 	info.SHA = pushEvent.AfterCommitID
 	info.InstallationID = pushEvent.Installation.ID
+	info.Actor = pushEvent.Sender.Login
 
 	return &info
 }
@@ -20,3 +20,33 @@ func Test_BuildEventFromPushEvent_ForEventKey(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func Test_GitLabPushEvent_GetProjectID(t *testing.T) {
+	event := GitLabPushEvent{ProjectID: 15, GitLabProject: GitLabProject{ID: 15}}
+	if got := event.GetProjectID(); got != 15 {
+		t.Errorf("want 15, got %d", got)
+	}
+
+	event = GitLabPushEvent{ProjectID: 15}
+	if got := event.GetProjectID(); got != 15 {
+		t.Errorf("want project_id when the project has no ID, got %d", got)
+	}
+}
+
+func Test_BuildEventFromPushEvent_ForActor(t *testing.T) {
+	p := PushEvent{
+		Ref: "refs/heads/master",
+		Repository: PushEventRepository{
+			Name:  "svc",
+			Owner: Owner{Login: "openfaas"},
+		},
+		Sender: Sender{Login: "alexellis"},
+	}
+
+	event := BuildEventFromPushEvent(p)
+
+	want := "alexellis"
+	if event.Actor != want {
+		t.Errorf("want %s, got %s", want, event.Actor)
+	}
+}
@@ -14,11 +14,47 @@ type PushEventRepository struct {
 
 // PushEvent is received from GitHub's push event subscription
 type PushEvent struct {
-	Ref           string `json:"ref"`
-	Repository    PushEventRepository
-	AfterCommitID string `json:"after"`
-	Installation  PushEventInstallation
-	SCM           string // SCM field is for internal use and not provided by GitHub
+	Ref            string `json:"ref"`
+	Repository     PushEventRepository
+	AfterCommitID  string `json:"after"`
+	BeforeCommitID string `json:"before,omitempty"`
+	Installation   PushEventInstallation
+	Sender         Sender `json:"sender"`
+	SCM            string // SCM field is for internal use and not provided by GitHub
+
+	// HeadCommit is read for the directives in its message
+	HeadCommit *HeadCommit `json:"head_commit,omitempty"`
+
+	// Functions limits the build to some of the functions in the stack, it
+	// is for internal use and not provided by GitHub
+	Functions []string `json:"functions,omitempty"`
+
+	// Rebuild builds the commit even when it was already built, it is for
+	// internal use and not provided by GitHub
+	Rebuild bool `json:"rebuild,omitempty"`
+
+	// NoCache builds the images without the build cache, it is for internal
+	// use and not provided by GitHub
+	NoCache bool `json:"no_cache,omitempty"`
+
+	// AuthToken is the installation token used to report the statuses of
+	// the push, it is passed on so that git-tar, buildshiprun and
+	// github-status reuse it. It is for internal use and not provided by
+	// GitHub.
+	AuthToken string `json:"auth_token,omitempty"`
+
+	// MovedFrom is set when the build follows a rename or transfer, it is
+	// for internal use and not provided by GitHub
+	MovedFrom *RepositoryMove `json:"moved_from,omitempty"`
+}
+
+// RepositoryMove is where a repository was before it was renamed or
+// transferred, or before its owner was renamed. Functions deployed from
+// there are removed once the repository has been built in its new place.
+type RepositoryMove struct {
+	Owner   string `json:"owner"`
+	OwnerID int64  `json:"owner_id,omitempty"`
+	Repo    string `json:"repo"`
 }
 
 // Owner is the owner of a GitHub repo
@@ -32,14 +68,31 @@ type PushEventInstallation struct {
 	ID int `json:"id"`
 }
 
-// GitLabPushEvent as received from GitLab's system hook event
+// GitLabPushEvent as received from GitLab's system hook event, or from the
+// push and tag push events of project and group webhooks
 type GitLabPushEvent struct {
+	ObjectKind       string           `json:"object_kind"`
 	Ref              string           `json:"ref"`
 	UserUsername     string           `json:"user_username"`
 	UserEmail        string           `json:"user_email"`
 	GitLabProject    GitLabProject    `json:"project"`
 	GitLabRepository GitLabRepository `json:"repository"`
 	AfterCommitID    string           `json:"after"`
+	BeforeCommitID   string           `json:"before"`
+	Commits          []GitLabCommit   `json:"commits"`
+	ProjectID        int              `json:"project_id"`
+
+	// MovedFrom is for internal use and not provided by GitLab
+	MovedFrom *RepositoryMove `json:"moved_from,omitempty"`
+}
+
+// GetProjectID returns the ID of the project, which webhooks of older GitLab
+// versions only send as project_id
+func (e *GitLabPushEvent) GetProjectID() int {
+	if e.GitLabProject.ID > 0 {
+		return e.GitLabProject.ID
+	}
+	return e.ProjectID
 }
 
 type GitLabProject struct {
@@ -51,6 +104,12 @@ type GitLabProject struct {
 	VisibilityLevel   int    `json:"visibility_level"`
 }
 
+// GitLabCommit is one of the commits of a push, GitLab sends at most 20
+type GitLabCommit struct {
+	ID      string `json:"id"`
+	Message string `json:"message"`
+}
+
 type GitLabRepository struct {
 	CloneURL string `json:"git_http_url"`
 }
@@ -0,0 +1,140 @@
+package sdk
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// defaultDeliveryTTL is how long a webhook delivery is remembered, GitHub
+// and GitLab redeliver within minutes when a webhook times out
+const defaultDeliveryTTL = time.Hour
+
+// ExpiringSet remembers keys for TTL. Functions run in a new process for
+// each request, so keys are kept as files under Path to share them between
+// requests. Only processes which share Path see the same keys, use a
+// SharedSet for keys which every replica must see.
+type ExpiringSet struct {
+	Path string
+	TTL  time.Duration
+
+	now func() time.Time
+}
+
+// NewExpiringSet creates a set which keeps keys under path for ttl
+func NewExpiringSet(path string, ttl time.Duration) *ExpiringSet {
+	return &ExpiringSet{
+		Path: path,
+		TTL:  ttl,
+		now:  time.Now,
+	}
+}
+
+// NewDeliverySet creates a shared set of webhook delivery IDs which are
+// kept for the duration given by delivery_ttl
+func NewDeliverySet(gatewayURL string) *SharedSet {
+	return NewSharedSet(gatewayURL, "deliveries", getDuration("delivery_ttl", defaultDeliveryTTL))
+}
+
+// Add records key, it returns false when key was already recorded and has
+// not expired
+func (s *ExpiringSet) Add(key string) (bool, error) {
+	if err := os.MkdirAll(s.Path, 0700); err != nil {
+		return false, err
+	}
+
+	s.prune()
+
+	keyPath := s.keyPath(key)
+	if s.expired(keyPath) {
+		os.Remove(keyPath)
+	}
+
+	file, err := os.OpenFile(keyPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	_, writeErr := file.WriteString(strconv.FormatInt(s.now().Unix(), 10))
+	closeErr := file.Close()
+	if writeErr != nil || closeErr != nil {
+		os.Remove(keyPath)
+		return false, fmt.Errorf("unable to record key: %v %v", writeErr, closeErr)
+	}
+	return true, nil
+}
+
+// Contains is true when key was recorded and has not expired
+func (s *ExpiringSet) Contains(key string) bool {
+	keyPath := s.keyPath(key)
+	if _, err := os.Stat(keyPath); err != nil {
+		return false
+	}
+	return !s.expired(keyPath)
+}
+
+// Remove forgets key so that it can be added again
+func (s *ExpiringSet) Remove(key string) error {
+	err := os.Remove(s.keyPath(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// keyPath hashes the key so that any key can be used as a file name
+func (s *ExpiringSet) keyPath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.Path, hex.EncodeToString(sum[:]))
+}
+
+// expired reads the time the key was added from the file, a file which
+// can't be read counts as expired
+func (s *ExpiringSet) expired(keyPath string) bool {
+	data, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return true
+	}
+
+	added, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return true
+	}
+	return !s.now().Before(time.Unix(added, 0).Add(s.TTL))
+}
+
+// prune removes expired keys so that the directory doesn't keep growing
+func (s *ExpiringSet) prune() {
+	files, err := ioutil.ReadDir(s.Path)
+	if err != nil {
+		return
+	}
+
+	for _, file := range files {
+		if file.IsDir() || !s.now().After(file.ModTime().Add(s.TTL)) {
+			continue
+		}
+
+		keyPath := filepath.Join(s.Path, file.Name())
+		if s.expired(keyPath) {
+			os.Remove(keyPath)
+		}
+	}
+}
+
+// getDuration reads a duration such as 30m from the environment variable
+// key, falling back to fallback when it is not set or not valid
+func getDuration(key string, fallback time.Duration) time.Duration {
+	if val, err := time.ParseDuration(os.Getenv(key)); err == nil && val > 0 {
+		return val
+	}
+	return fallback
+}
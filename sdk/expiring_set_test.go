@@ -0,0 +1,82 @@
+package sdk
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_ExpiringSet_Add(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deliveries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	set := NewExpiringSet(dir, time.Hour)
+
+	added, err := set.Add("72d3162e-cc78-11e3-81ab-4c9367dc0958")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !added {
+		t.Errorf("want first delivery to be added")
+	}
+
+	// Another process sees the same keys through the path
+	added, _ = NewExpiringSet(dir, time.Hour).Add("72d3162e-cc78-11e3-81ab-4c9367dc0958")
+	if added {
+		t.Errorf("want redelivery to be a duplicate")
+	}
+
+	added, _ = set.Add("a5fbbf2e-cc78-11e3-81ab-4c9367dc0958")
+	if !added {
+		t.Errorf("want another delivery to be added")
+	}
+}
+
+func Test_ExpiringSet_Expires(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deliveries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	set := NewExpiringSet(dir, time.Hour)
+	set.Add("delivery")
+
+	if !set.Contains("delivery") {
+		t.Errorf("want delivery to be recorded")
+	}
+
+	set.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+
+	if set.Contains("delivery") {
+		t.Errorf("want delivery to have expired")
+	}
+	if added, _ := set.Add("delivery"); !added {
+		t.Errorf("want expired delivery to be added again")
+	}
+}
+
+func Test_ExpiringSet_Remove(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deliveries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	set := NewExpiringSet(dir, time.Hour)
+	set.Add("delivery")
+
+	if err := set.Remove("delivery"); err != nil {
+		t.Fatal(err)
+	}
+	if added, _ := set.Add("delivery"); !added {
+		t.Errorf("want removed delivery to be added again")
+	}
+	if err := set.Remove("missing"); err != nil {
+		t.Errorf("want no error removing a missing key, got: %s", err.Error())
+	}
+}
@@ -0,0 +1,175 @@
+package sdk
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const minutesPerDay = 24 * 60
+const minutesPerWeek = 7 * minutesPerDay
+
+// FreezeWindow is a period of time during which deploys are queued
+type FreezeWindow struct {
+	Description string
+
+	// Start and End of a one-off window such as a release freeze
+	Start time.Time
+	End   time.Time
+
+	// Weekly windows such as "Sat-Sun" are held as minutes since Sunday 00:00 UTC
+	Weekly      bool
+	StartMinute int
+	EndMinute   int
+}
+
+// Contains is true when t falls inside the window
+func (w FreezeWindow) Contains(t time.Time) bool {
+	if !w.Weekly {
+		return !t.Before(w.Start) && t.Before(w.End)
+	}
+
+	t = t.UTC()
+	minute := int(t.Weekday())*minutesPerDay + t.Hour()*60 + t.Minute()
+
+	if w.StartMinute < w.EndMinute {
+		return minute >= w.StartMinute && minute < w.EndMinute
+	}
+	return minute >= w.StartMinute || minute < w.EndMinute
+}
+
+// ParseFreezeWindows reads a comma separated list of windows where each
+// window is either weekly such as "Fri", "Sat-Sun" or "Fri 18:00-Mon 08:00" in UTC,
+// or a one-off RFC3339 range such as "2020-12-24T00:00:00Z/2021-01-04T00:00:00Z"
+func ParseFreezeWindows(val string) ([]FreezeWindow, error) {
+	windows := []FreezeWindow{}
+
+	for _, part := range strings.Split(val, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) == 0 {
+			continue
+		}
+
+		var window FreezeWindow
+		var err error
+		if strings.Contains(part, "/") {
+			window, err = parseOneOffWindow(part)
+		} else {
+			window, err = parseWeeklyWindow(part)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid freeze window %q: %s", part, err.Error())
+		}
+		windows = append(windows, window)
+	}
+
+	return windows, nil
+}
+
+// ActiveFreezeWindow returns the first window which contains t
+func ActiveFreezeWindow(windows []FreezeWindow, t time.Time) (FreezeWindow, bool) {
+	for _, window := range windows {
+		if window.Contains(t) {
+			return window, true
+		}
+	}
+	return FreezeWindow{}, false
+}
+
+func parseOneOffWindow(val string) (FreezeWindow, error) {
+	parts := strings.SplitN(val, "/", 2)
+
+	start, err := time.Parse(time.RFC3339, strings.TrimSpace(parts[0]))
+	if err != nil {
+		return FreezeWindow{}, err
+	}
+
+	end, err := time.Parse(time.RFC3339, strings.TrimSpace(parts[1]))
+	if err != nil {
+		return FreezeWindow{}, err
+	}
+
+	if !end.After(start) {
+		return FreezeWindow{}, fmt.Errorf("end must be after start")
+	}
+
+	return FreezeWindow{Description: val, Start: start, End: end}, nil
+}
+
+func parseWeeklyWindow(val string) (FreezeWindow, error) {
+	parts := strings.SplitN(val, "-", 2)
+	if len(parts) == 1 {
+		// A single day such as "Fri"
+		parts = append(parts, parts[0])
+	}
+
+	start, err := parseWeekMinute(parts[0], false)
+	if err != nil {
+		return FreezeWindow{}, err
+	}
+
+	end, err := parseWeekMinute(parts[1], true)
+	if err != nil {
+		return FreezeWindow{}, err
+	}
+
+	return FreezeWindow{Description: val, Weekly: true, StartMinute: start, EndMinute: end}, nil
+}
+
+// parseWeekMinute reads "Sat" or "Sat 18:00", a day without a time is the
+// start of the day, or the end of the day when end is set
+func parseWeekMinute(val string, end bool) (int, error) {
+	fields := strings.Fields(val)
+	if len(fields) == 0 || len(fields) > 2 {
+		return 0, fmt.Errorf("want a day such as Sat or Sat 18:00")
+	}
+
+	day := -1
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(fields[0], d.String()[:3]) || strings.EqualFold(fields[0], d.String()) {
+			day = int(d)
+		}
+	}
+	if day < 0 {
+		return 0, fmt.Errorf("unknown day: %s", fields[0])
+	}
+
+	if len(fields) == 1 {
+		if end {
+			return ((day + 1) * minutesPerDay) % minutesPerWeek, nil
+		}
+		return day * minutesPerDay, nil
+	}
+
+	clock := strings.SplitN(fields[1], ":", 2)
+	if len(clock) != 2 {
+		return 0, fmt.Errorf("want a time such as 18:00")
+	}
+
+	hour, hourErr := strconv.Atoi(clock[0])
+	minute, minuteErr := strconv.Atoi(clock[1])
+	if hourErr != nil || minuteErr != nil || hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return 0, fmt.Errorf("invalid time: %s", fields[1])
+	}
+
+	return day*minutesPerDay + hour*60 + minute, nil
+}
+
+// ActiveFreeze returns the freeze window in effect at t from the windows set
+// by the administrator and those set in the annotations of a function
+func ActiveFreeze(adminWindows string, annotations map[string]string, t time.Time) (FreezeWindow, bool, error) {
+	windows, err := ParseFreezeWindows(adminWindows)
+	if err != nil {
+		return FreezeWindow{}, false, err
+	}
+
+	ownerWindows, err := ParseFreezeWindows(annotations[DeployFreezeAnnotation])
+	if err != nil {
+		return FreezeWindow{}, false, err
+	}
+
+	window, frozen := ActiveFreezeWindow(append(windows, ownerWindows...), t)
+	return window, frozen, nil
+}
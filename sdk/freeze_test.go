@@ -0,0 +1,169 @@
+package sdk
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_ParseFreezeWindows_Weekend(t *testing.T) {
+	windows, err := ParseFreezeWindows("Sat-Sun")
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err.Error())
+	}
+
+	cases := []struct {
+		time time.Time
+		want bool
+	}{
+		{time: time.Date(2020, 4, 17, 23, 59, 0, 0, time.UTC), want: false}, // Friday
+		{time: time.Date(2020, 4, 18, 0, 0, 0, 0, time.UTC), want: true},    // Saturday
+		{time: time.Date(2020, 4, 19, 23, 59, 0, 0, time.UTC), want: true},  // Sunday
+		{time: time.Date(2020, 4, 20, 0, 0, 0, 0, time.UTC), want: false},   // Monday
+	}
+
+	for _, c := range cases {
+		_, got := ActiveFreezeWindow(windows, c.time)
+		if got != c.want {
+			t.Errorf("%s want frozen: %t, got: %t", c.time, c.want, got)
+		}
+	}
+}
+
+func Test_ParseFreezeWindows_WeeklyWithTimes(t *testing.T) {
+	windows, err := ParseFreezeWindows("Fri 18:00-Mon 08:00")
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err.Error())
+	}
+
+	cases := []struct {
+		time time.Time
+		want bool
+	}{
+		{time: time.Date(2020, 4, 17, 17, 59, 0, 0, time.UTC), want: false},
+		{time: time.Date(2020, 4, 17, 18, 0, 0, 0, time.UTC), want: true},
+		{time: time.Date(2020, 4, 20, 7, 59, 0, 0, time.UTC), want: true},
+		{time: time.Date(2020, 4, 20, 8, 0, 0, 0, time.UTC), want: false},
+		{time: time.Date(2020, 4, 22, 12, 0, 0, 0, time.UTC), want: false},
+	}
+
+	for _, c := range cases {
+		_, got := ActiveFreezeWindow(windows, c.time)
+		if got != c.want {
+			t.Errorf("%s want frozen: %t, got: %t", c.time, c.want, got)
+		}
+	}
+}
+
+func Test_ParseFreezeWindows_OneOff(t *testing.T) {
+	windows, err := ParseFreezeWindows("Sat-Sun, 2020-12-24T00:00:00Z/2021-01-04T00:00:00Z")
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err.Error())
+	}
+
+	if len(windows) != 2 {
+		t.Fatalf("want 2 windows, got: %d", len(windows))
+	}
+
+	window, frozen := ActiveFreezeWindow(windows, time.Date(2020, 12, 30, 12, 0, 0, 0, time.UTC))
+	if !frozen {
+		t.Fatalf("want frozen during release freeze")
+	}
+	if window.Description != "2020-12-24T00:00:00Z/2021-01-04T00:00:00Z" {
+		t.Errorf("want release freeze window, got: %s", window.Description)
+	}
+
+	if _, frozen := ActiveFreezeWindow(windows, time.Date(2021, 1, 4, 12, 0, 0, 0, time.UTC)); frozen {
+		t.Errorf("want not frozen after release freeze")
+	}
+}
+
+func Test_ParseFreezeWindows_ConfigExample(t *testing.T) {
+	// The example given for freeze_windows in gateway_config.yml
+	windows, err := ParseFreezeWindows("Fri 18:00-Mon 08:00, 2026-12-24T00:00:00Z/2027-01-02T00:00:00Z")
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err.Error())
+	}
+
+	if len(windows) != 2 {
+		t.Fatalf("want 2 windows, got: %d", len(windows))
+	}
+
+	if _, frozen := ActiveFreezeWindow(windows, time.Date(2026, 12, 29, 12, 0, 0, 0, time.UTC)); !frozen {
+		t.Errorf("want frozen during release freeze")
+	}
+	if _, frozen := ActiveFreezeWindow(windows, time.Date(2027, 1, 6, 12, 0, 0, 0, time.UTC)); frozen {
+		t.Errorf("want not frozen on a Wednesday after release freeze")
+	}
+}
+
+func Test_ParseFreezeWindows_Invalid(t *testing.T) {
+	for _, val := range []string{"Someday-Sun", "Sat 18:00 UTC", "Fri 25:00-Sat", "2021-01-04T00:00:00Z/2020-12-24T00:00:00Z"} {
+		if _, err := ParseFreezeWindows(val); err == nil {
+			t.Errorf("want error for %q", val)
+		}
+	}
+}
+
+func Test_PendingDeployment_CanApprove(t *testing.T) {
+	canApprove := func(p PendingDeployment, user string) bool {
+		ok, err := p.CanApprove(user, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+
+	owner := PendingDeployment{Event: Event{Owner: "alexellis"}}
+	if !canApprove(owner, "AlexEllis") {
+		t.Errorf("want the repository owner to approve when no approvers are named")
+	}
+	if canApprove(owner, "someone") {
+		t.Errorf("want other users to be refused when no approvers are named")
+	}
+	if canApprove(owner, "") {
+		t.Errorf("want an empty user to be refused")
+	}
+
+	unowned := PendingDeployment{}
+	if canApprove(unowned, "") {
+		t.Errorf("want an empty user to be refused without an owner")
+	}
+
+	named := PendingDeployment{Approvers: ParseApprovers("alexellis, rgee0")}
+	if !canApprove(named, "RGee0") {
+		t.Errorf("want named approver to approve")
+	}
+	if canApprove(named, "someone") {
+		t.Errorf("want other users to be refused")
+	}
+}
+
+func Test_PendingDeployment_CanApprove_OrgMembers(t *testing.T) {
+	membership := &fakeMembership{members: map[string]bool{
+		"org:openfaas@alexellis":    true,
+		"group:acme/platform@rgee0": true,
+	}}
+
+	org := PendingDeployment{Event: Event{Owner: "openfaas", SCM: "github"}}
+	if ok, _ := org.CanApprove("alexellis", membership); !ok {
+		t.Errorf("want a member of the organization to approve when no approvers are named")
+	}
+	if ok, _ := org.CanApprove("someone", membership); ok {
+		t.Errorf("want users outside of the organization to be refused")
+	}
+
+	group := PendingDeployment{Event: Event{
+		Owner:   FormatGitLabOwner("acme/platform"),
+		SCM:     "gitlab",
+		RepoURL: "https://gitlab.example.com/acme/platform/api",
+	}}
+	if ok, _ := group.CanApprove("rgee0", membership); !ok {
+		t.Errorf("want a member of the subgroup to approve")
+	}
+
+	membership.checks = 0
+	named := PendingDeployment{Event: Event{Owner: "openfaas"}, Approvers: []string{"rgee0"}}
+	if ok, _ := named.CanApprove("alexellis", membership); ok || membership.checks > 0 {
+		t.Errorf("want only the named approvers when approvers are given")
+	}
+}
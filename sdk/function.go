@@ -1,10 +1,11 @@
 package sdk
 
 type Function struct {
-	Name            string            `json:"name"`
-	Image           string            `json:"image"`
-	InvocationCount float64           `json:"invocationCount"`
-	Replicas        uint64            `json:"replicas"`
-	Labels          map[string]string `json:"labels"`
-	Annotations     map[string]string `json:"annotations"`
+	Name              string            `json:"name"`
+	Image             string            `json:"image"`
+	InvocationCount   float64           `json:"invocationCount"`
+	Replicas          uint64            `json:"replicas"`
+	AvailableReplicas uint64            `json:"availableReplicas,omitempty"`
+	Labels            map[string]string `json:"labels"`
+	Annotations       map[string]string `json:"annotations"`
 }
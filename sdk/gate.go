@@ -0,0 +1,201 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/alexellis/hmac"
+)
+
+// Annotations used in stack.yml to gate the deploy of a function
+const (
+	DeployApprovalAnnotation  = FunctionLabelPrefix + "deploy.approval"
+	DeployApproversAnnotation = FunctionLabelPrefix + "deploy.approvers"
+	DeployFreezeAnnotation    = FunctionLabelPrefix + "deploy.freeze"
+
+	DeployRequiredChecksAnnotation = FunctionLabelPrefix + "deploy.required-checks"
+)
+
+// Actions accepted by the deployment-gate function
+const (
+	GateActionQueue   = "queue"
+	GateActionApprove = "approve"
+	GateActionReject  = "reject"
+	GateActionCheck   = "check"
+	GateActionList    = "list"
+)
+
+// GateReleaseHeader marks a request from the deployment-gate which asks
+// buildshiprun to deploy a released deploy instead of building a tar
+const GateReleaseHeader = "Release"
+
+// PendingDeployment is a deploy held by the deployment-gate function until
+// it is approved and outside of any freeze window
+type PendingDeployment struct {
+	Event            Event             `json:"event"`
+	Record           DeploymentRecord  `json:"record"`
+	FunctionName     string            `json:"functionName"`
+	RequiresApproval bool              `json:"requiresApproval"`
+	Approvers        []string          `json:"approvers"`
+	ApprovedBy       string            `json:"approvedBy"`
+	RequiredChecks   []string          `json:"requiredChecks"`
+	Checks           map[string]string `json:"checks"`
+	Queued           time.Time         `json:"queued"`
+}
+
+// Approved is true when the deployment does not need approval or has
+// been approved
+func (p *PendingDeployment) Approved() bool {
+	return !p.RequiresApproval || len(p.ApprovedBy) > 0
+}
+
+// SetChecks records the state of external CI checks for the commit
+func (p *PendingDeployment) SetChecks(checks map[string]string) {
+	if p.Checks == nil {
+		p.Checks = map[string]string{}
+	}
+
+	for name, state := range checks {
+		p.Checks[strings.ToLower(name)] = state
+	}
+}
+
+// WaitingChecks returns the required checks which have not yet succeeded
+func (p *PendingDeployment) WaitingChecks() []string {
+	waiting := []string{}
+	for _, name := range p.RequiredChecks {
+		if p.Checks[strings.ToLower(name)] != StatusSuccess {
+			waiting = append(waiting, name)
+		}
+	}
+	return waiting
+}
+
+// FailedCheck returns the first required check which has failed
+func (p *PendingDeployment) FailedCheck() string {
+	for _, name := range p.RequiredChecks {
+		if p.Checks[strings.ToLower(name)] == StatusFailure {
+			return name
+		}
+	}
+	return ""
+}
+
+// CanApprove is true when the user is one of the approvers for the
+// deployment. When no approvers were named the owner of the repository can
+// approve, and so can the members of the organization or group which owns
+// it, as resolved by membership.
+func (p *PendingDeployment) CanApprove(user string, membership MembershipChecker) (bool, error) {
+	if len(user) == 0 {
+		return false, nil
+	}
+
+	if len(p.Approvers) > 0 {
+		for _, approver := range p.Approvers {
+			if strings.EqualFold(approver, user) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	if strings.EqualFold(p.Event.Owner, user) {
+		return true, nil
+	}
+
+	rule, ok := p.ownerRule()
+	if !ok || membership == nil {
+		return false, nil
+	}
+	return membership.IsMember(rule, user)
+}
+
+// ownerRule gives the organization or group which owns the repository, the
+// owner of a GitLab project in a subgroup is not the path of the group so
+// the path is read from the URL of the project
+func (p *PendingDeployment) ownerRule() (CustomerRule, bool) {
+	if p.Event.SCM == "gitlab" {
+		projectURL, err := url.Parse(p.Event.RepoURL)
+		if err != nil {
+			return CustomerRule{}, false
+		}
+
+		namespace, err := GetGitLabNamespace(projectURL.Path)
+		if err != nil {
+			return CustomerRule{}, false
+		}
+		return CustomerRule{Kind: CustomerRuleGroup, Name: namespace}, true
+	}
+
+	if len(p.Event.Owner) == 0 {
+		return CustomerRule{}, false
+	}
+	return CustomerRule{Kind: CustomerRuleOrg, Name: p.Event.Owner}, true
+}
+
+// GateRequest is sent to the deployment-gate function to queue, approve
+// or reject a deployment
+type GateRequest struct {
+	Action     string             `json:"action"`
+	Deployment *PendingDeployment `json:"deployment,omitempty"`
+	Owner      string             `json:"owner"`
+	Repo       string             `json:"repo"`
+	Function   string             `json:"function"`
+	SHA        string             `json:"sha"`
+	User       string             `json:"user"`
+	Checks     map[string]string  `json:"checks,omitempty"`
+}
+
+// ParseApprovers reads a comma-separated list of approvers
+func ParseApprovers(val string) []string {
+	return parseList(val)
+}
+
+// ParseRequiredChecks reads a comma-separated list of the names of CI
+// checks which must pass before a function is deployed
+func ParseRequiredChecks(val string) []string {
+	return parseList(val)
+}
+
+func parseList(val string) []string {
+	items := []string{}
+	for _, item := range strings.Split(val, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// PostGateRequest sends a signed request to the deployment-gate function
+func PostGateRequest(gatewayURL, payloadSecret string, gateReq GateRequest) (string, error) {
+	bytesOut, _ := json.Marshal(&gateReq)
+
+	req, _ := http.NewRequest(http.MethodPost, gatewayURL+"function/deployment-gate", bytes.NewReader(bytesOut))
+
+	digest := hmac.Sign(bytesOut, []byte(payloadSecret))
+	req.Header.Add(CloudSignatureHeader, "sha1="+hex.EncodeToString(digest))
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	if res.Body != nil {
+		defer res.Body.Close()
+	}
+
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusAccepted {
+		return "", fmt.Errorf("unexpected status from deployment-gate: %d, body: %s", res.StatusCode, string(body))
+	}
+
+	return string(body), nil
+}
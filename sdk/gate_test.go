@@ -0,0 +1,38 @@
+package sdk
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_PendingDeployment_WaitingChecks(t *testing.T) {
+	pending := PendingDeployment{RequiredChecks: []string{"Test", "lint"}}
+
+	want := []string{"Test", "lint"}
+	if got := pending.WaitingChecks(); !reflect.DeepEqual(got, want) {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+
+	pending.SetChecks(map[string]string{"test": StatusSuccess, "lint": StatusPending})
+
+	want = []string{"lint"}
+	if got := pending.WaitingChecks(); !reflect.DeepEqual(got, want) {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+	if got := pending.FailedCheck(); got != "" {
+		t.Errorf("want no failed check, got: %s", got)
+	}
+
+	pending.SetChecks(map[string]string{"LINT": StatusFailure})
+
+	if got := pending.FailedCheck(); got != "lint" {
+		t.Errorf("want failed check: lint, got: %s", got)
+	}
+}
+
+func Test_ParseRequiredChecks(t *testing.T) {
+	want := []string{"test", "lint"}
+	if got := ParseRequiredChecks(" test, lint,,"); !reflect.DeepEqual(got, want) {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+}
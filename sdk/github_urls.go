@@ -0,0 +1,73 @@
+package sdk
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	defaultGitHubURL    = "https://github.com"
+	defaultGitHubAPIURL = "https://api.github.com"
+	defaultGitHubRawURL = "https://raw.githubusercontent.com"
+
+	// enterpriseAPIPath and enterpriseRawPath are where a GitHub Enterprise
+	// Server serves its API and the raw content of repos
+	enterpriseAPIPath = "/api/v3"
+	enterpriseRawPath = "/raw"
+)
+
+// GitHubURLs are the URLs of GitHub, or of a GitHub Enterprise Server, which
+// are used to talk to it. None of them end with a slash.
+type GitHubURLs struct {
+	// URL is the web URL, i.e. https://github.com
+	URL string
+
+	// APIURL is the base URL of the REST API, i.e. https://api.github.com
+	APIURL string
+
+	// RawURL serves the content of files in repos, i.e.
+	// https://raw.githubusercontent.com
+	RawURL string
+}
+
+// GetGitHubURLs reads the web URL from github_url and the API URL from
+// github_api_url, GitHub is used when they are not set
+func GetGitHubURLs() GitHubURLs {
+	return MakeGitHubURLs(os.Getenv("github_url"), os.Getenv("github_api_url"))
+}
+
+// MakeGitHubURLs works out the URLs of GitHub from its web URL. The API URL
+// of a GitHub Enterprise Server is found under the web URL when apiURL is
+// empty.
+func MakeGitHubURLs(webURL, apiURL string) GitHubURLs {
+	webURL = strings.TrimSuffix(strings.TrimSpace(webURL), "/")
+	apiURL = strings.TrimSuffix(strings.TrimSpace(apiURL), "/")
+
+	if len(webURL) == 0 || webURL == defaultGitHubURL {
+		if len(apiURL) == 0 {
+			apiURL = defaultGitHubAPIURL
+		}
+
+		return GitHubURLs{
+			URL:    defaultGitHubURL,
+			APIURL: apiURL,
+			RawURL: defaultGitHubRawURL,
+		}
+	}
+
+	if len(apiURL) == 0 {
+		apiURL = webURL + enterpriseAPIPath
+	}
+
+	return GitHubURLs{
+		URL:    webURL,
+		APIURL: apiURL,
+		RawURL: webURL + enterpriseRawPath,
+	}
+}
+
+// RawFileURL is the URL of a file of a repo at ref
+func (u GitHubURLs) RawFileURL(owner, repo, ref, filePath string) string {
+	return fmt.Sprintf("%s/%s/%s/%s/%s", u.RawURL, owner, repo, ref, strings.TrimPrefix(filePath, "/"))
+}
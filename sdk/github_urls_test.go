@@ -0,0 +1,62 @@
+package sdk
+
+import (
+	"os"
+	"testing"
+)
+
+func Test_MakeGitHubURLs(t *testing.T) {
+	cases := []struct {
+		title  string
+		webURL string
+		apiURL string
+		want   GitHubURLs
+	}{
+		{
+			title: "GitHub by default",
+			want: GitHubURLs{
+				URL:    "https://github.com",
+				APIURL: "https://api.github.com",
+				RawURL: "https://raw.githubusercontent.com",
+			},
+		},
+		{
+			title:  "GitHub Enterprise Server",
+			webURL: "https://github.example.com/",
+			want: GitHubURLs{
+				URL:    "https://github.example.com",
+				APIURL: "https://github.example.com/api/v3",
+				RawURL: "https://github.example.com/raw",
+			},
+		},
+		{
+			title:  "GitHub Enterprise Server with its own API URL",
+			webURL: "https://github.example.com",
+			apiURL: "https://api.github.example.com/",
+			want: GitHubURLs{
+				URL:    "https://github.example.com",
+				APIURL: "https://api.github.example.com",
+				RawURL: "https://github.example.com/raw",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.title, func(t *testing.T) {
+			got := MakeGitHubURLs(c.webURL, c.apiURL)
+			if got != c.want {
+				t.Errorf("want: %+v, got: %+v", c.want, got)
+			}
+		})
+	}
+}
+
+func Test_GetGitHubURLs_RawFileURL(t *testing.T) {
+	os.Setenv("github_url", "https://github.example.com")
+	defer os.Unsetenv("github_url")
+
+	want := "https://github.example.com/raw/alexellis/repo1/master/stack.yml"
+	if got := GetGitHubURLs().RawFileURL("alexellis", "repo1", "master", "stack.yml"); got != want {
+		t.Errorf("want: %s, got: %s", want, got)
+	}
+}
@@ -0,0 +1,127 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alexellis/hmac"
+)
+
+const (
+	// GitLabCredentialsHeader asks import-secrets for a GitLab token of a
+	// project instead of importing secrets
+	GitLabCredentialsHeader = "X-Gitlab-Credentials"
+
+	gitlabTokenSecret = "gitlab-token"
+)
+
+// GitLabCredential is a token for the GitLab API and for cloning. Deploy
+// tokens are given with their username and can only clone, project and
+// group access tokens are given without one.
+type GitLabCredential struct {
+	// Secret the token was read from, empty for gitlab-api-token
+	Secret   string `json:"secret,omitempty"`
+	Username string `json:"username,omitempty"`
+	Token    string `json:"token"`
+}
+
+// CanCallAPI is false for deploy tokens
+func (c *GitLabCredential) CanCallAPI() bool {
+	return len(c.Username) == 0
+}
+
+// GitLabCredentialsRequest asks import-secrets for the first of Secrets
+// which holds a token, API skips deploy tokens
+type GitLabCredentialsRequest struct {
+	Secrets []string `json:"secrets"`
+	API     bool     `json:"api"`
+}
+
+// GitLabCredentialSecrets returns the names of the secrets owners can seal
+// a token for the project under: the secret of the project itself, then the
+// secret of its namespace and of each parent group
+func GitLabCredentialSecrets(pathWithNamespace string) []string {
+	namespace, err := GetGitLabNamespace(pathWithNamespace)
+	if err != nil {
+		return []string{}
+	}
+
+	project := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '.' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(strings.Trim(pathWithNamespace, "/")[len(namespace)+1:]))
+
+	secrets := []string{FormatGitLabOwner(namespace) + "-" + gitlabTokenSecret + "-" + project}
+	for _, path := range GitLabNamespacePaths(namespace) {
+		secrets = append(secrets, FormatGitLabOwner(path)+"-"+gitlabTokenSecret)
+	}
+	return secrets
+}
+
+// IsGitLabTokenSecret is true for the names GitLabCredentialSecrets gives,
+// import-secrets only reads these secrets for a GitLabCredentialsRequest
+func IsGitLabTokenSecret(name string) bool {
+	return strings.HasSuffix(name, "-"+gitlabTokenSecret) || strings.Contains(name, "-"+gitlabTokenSecret+"-")
+}
+
+// GetGitLabCredential returns the most specific token the owners of the
+// project sealed, or the gitlab-api-token secret when they sealed none or
+// it could not be looked up. Set api when the token is used for the API.
+func GetGitLabCredential(gatewayURL, payloadSecret, pathWithNamespace string, api bool) (*GitLabCredential, error) {
+	credential, err := lookupGitLabCredential(gatewayURL, payloadSecret, GitLabCredentialsRequest{
+		Secrets: GitLabCredentialSecrets(pathWithNamespace),
+		API:     api,
+	})
+	if err != nil {
+		log.Printf("unable to look up a GitLab token for %s, using gitlab-api-token: %s", pathWithNamespace, err.Error())
+	}
+	if credential != nil && len(credential.Token) > 0 {
+		return credential, nil
+	}
+
+	token, err := ReadSecret("gitlab-api-token")
+	if err != nil {
+		return nil, fmt.Errorf("cannot read api token from GitLab in secret `gitlab-api-token`: %s", err.Error())
+	}
+	return &GitLabCredential{Token: token}, nil
+}
+
+func lookupGitLabCredential(gatewayURL, payloadSecret string, request GitLabCredentialsRequest) (*GitLabCredential, error) {
+	if len(request.Secrets) == 0 {
+		return nil, nil
+	}
+
+	body, _ := json.Marshal(request)
+	req, _ := http.NewRequest(http.MethodPost, gatewayURL+"function/import-secrets", bytes.NewReader(body))
+
+	digest := hmac.Sign(body, []byte(payloadSecret))
+	req.Header.Add(CloudSignatureHeader, "sha1="+hex.EncodeToString(digest))
+	req.Header.Add(GitLabCredentialsHeader, "true")
+
+	c := http.Client{Timeout: 10 * time.Second}
+	res, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	resBody, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status from import-secrets: %d", res.StatusCode)
+	}
+
+	credential := GitLabCredential{}
+	if err := json.Unmarshal(resBody, &credential); err != nil {
+		return nil, fmt.Errorf("unable to parse GitLab token from import-secrets: %s", err.Error())
+	}
+	return &credential, nil
+}
@@ -0,0 +1,101 @@
+package sdk
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_GitLabCredentialSecrets(t *testing.T) {
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{
+			path: "alexellis/Go_API",
+			want: []string{"alexellis-gitlab-token-go-api", "alexellis-gitlab-token"},
+		},
+		{
+			path: "acme/platform/api",
+			want: []string{
+				FormatGitLabOwner("acme/platform") + "-gitlab-token-api",
+				FormatGitLabOwner("acme/platform") + "-gitlab-token",
+				"acme-gitlab-token",
+			},
+		},
+		{
+			path: "api",
+			want: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			got := GitLabCredentialSecrets(test.path)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("want: %v, got: %v", test.want, got)
+			}
+			for _, name := range got {
+				if !IsGitLabTokenSecret(name) {
+					t.Errorf("want %s to be a GitLab token secret", name)
+				}
+			}
+		})
+	}
+
+	if IsGitLabTokenSecret("alexellis-payload-secret") {
+		t.Errorf("want other secrets not to be GitLab token secrets")
+	}
+}
+
+func Test_GetGitLabCredential(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/function/import-secrets" || r.Header.Get(GitLabCredentialsHeader) != "true" || len(r.Header.Get(CloudSignatureHeader)) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		request := GitLabCredentialsRequest{}
+		json.Unmarshal(body, &request)
+
+		switch request.Secrets[0] {
+		case "alexellis-gitlab-token-api":
+			w.Write([]byte(`{"secret": "alexellis-gitlab-token", "token": "scoped"}`))
+		case "rgee0-gitlab-token-api":
+			w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	dir := t.TempDir()
+	ioutil.WriteFile(filepath.Join(dir, "gitlab-api-token"), []byte("global"), 0600)
+	t.Setenv("secret_mount_path", dir)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "alexellis/api", want: "scoped"},
+		{path: "rgee0/api", want: "global"},
+		{path: "stranger/api", want: "global"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			credential, err := GetGitLabCredential(s.URL+"/", "secret", test.path, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if credential.Token != test.want {
+				t.Errorf("want: %s, got: %s", test.want, credential.Token)
+			}
+		})
+	}
+}
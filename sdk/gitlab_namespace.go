@@ -0,0 +1,100 @@
+package sdk
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// gitlabOwnerMaxPrefix keeps the readable part of the owner of a
+	// subgroup short, as function names are limited to 63 characters
+	gitlabOwnerMaxPrefix = 24
+
+	gitlabOwnerHashLength = 8
+)
+
+// gitlabSubgroupOwner matches the owner of a subgroup at the start of a name
+var gitlabSubgroupOwner = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*--[0-9a-f]{8}-`)
+
+// GetGitLabNamespace returns the full path of the namespace of a project,
+// such as acme/platform/payments for acme/platform/payments/api
+func GetGitLabNamespace(pathWithNamespace string) (string, error) {
+	index := strings.LastIndex(strings.Trim(pathWithNamespace, "/"), "/")
+	if index <= 0 {
+		return "", fmt.Errorf("no namespace in project path: %q", pathWithNamespace)
+	}
+	return strings.Trim(pathWithNamespace, "/")[:index], nil
+}
+
+// FormatGitLabOwner gives the owner functions of a project in the namespace
+// are deployed under, and which prefixes their names and secrets. A user or
+// top-level group is its own owner. A subgroup is given a readable prefix
+// of its path, a double dash and a hash of the whole path, so that
+// subgroups never share an owner even when their paths only differ by a
+// slash or are truncated. GitLab doesn't allow a double dash in a path, so
+// no user or top-level group can take the owner of a subgroup.
+func FormatGitLabOwner(namespace string) string {
+	namespace = strings.ToLower(strings.Trim(namespace, "/"))
+	if !strings.Contains(namespace, "/") && !strings.Contains(namespace, "--") {
+		return namespace
+	}
+
+	prefix := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '-'
+	}, namespace)
+
+	for strings.Contains(prefix, "--") {
+		prefix = strings.Replace(prefix, "--", "-", -1)
+	}
+
+	if len(prefix) > gitlabOwnerMaxPrefix {
+		prefix = prefix[:gitlabOwnerMaxPrefix]
+	}
+
+	prefix = strings.Trim(prefix, "-")
+	if len(prefix) == 0 {
+		prefix = "group"
+	}
+
+	sum := sha256.Sum256([]byte(namespace))
+	return prefix + "--" + hex.EncodeToString(sum[:])[:gitlabOwnerHashLength]
+}
+
+// HasOwnerPrefix is true when a name such as that of a secret belongs to
+// the owner. Checking for the owner and a dash is not enough, as the owner
+// of a subgroup such as acme-platform--1a2b3c4d starts with acme-platform-.
+func HasOwnerPrefix(name, owner string) bool {
+	if !strings.HasPrefix(name, owner+"-") {
+		return false
+	}
+
+	if match := gitlabSubgroupOwner.FindString(name); len(match) > 0 {
+		return strings.TrimSuffix(match, "-") == owner
+	}
+	return true
+}
+
+// GitLabNamespacePaths returns the path of the namespace followed by the
+// path of each of its parent groups, so acme/platform gives acme/platform
+// and acme
+func GitLabNamespacePaths(namespace string) []string {
+	namespace = strings.Trim(namespace, "/")
+
+	paths := []string{}
+	for len(namespace) > 0 {
+		paths = append(paths, namespace)
+
+		index := strings.LastIndex(namespace, "/")
+		if index < 0 {
+			break
+		}
+		namespace = namespace[:index]
+	}
+	return paths
+}
@@ -0,0 +1,117 @@
+package sdk
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_GetGitLabNamespace(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+		err  bool
+	}{
+		{path: "alexellis/pancake", want: "alexellis"},
+		{path: "acme/platform/payments/api", want: "acme/platform/payments"},
+		{path: "pancake", err: true},
+		{path: "", err: true},
+	}
+
+	for _, test := range tests {
+		got, err := GetGitLabNamespace(test.path)
+		if (err != nil) != test.err {
+			t.Errorf("%q want error: %t, got: %v", test.path, test.err, err)
+		}
+		if got != test.want {
+			t.Errorf("%q want: %s, got: %s", test.path, test.want, got)
+		}
+	}
+}
+
+func Test_FormatGitLabOwner(t *testing.T) {
+	if got := FormatGitLabOwner("AlexEllis"); got != "alexellis" {
+		t.Errorf("want a top-level namespace to keep its name, got: %s", got)
+	}
+
+	owner := FormatGitLabOwner("acme/platform/payments")
+	if !strings.HasPrefix(owner, "acme-platform-payments--") || len(owner) != len("acme-platform-payments--")+gitlabOwnerHashLength {
+		t.Errorf("want a readable prefix and a hash, got: %s", owner)
+	}
+
+	if FormatGitLabOwner("ACME/Platform") != FormatGitLabOwner("acme/platform/") {
+		t.Errorf("want the owner not to depend on case or a trailing slash")
+	}
+
+	collisions := [][2]string{
+		{"acme/platform-payments", "acme-platform/payments"},
+		{"acme/platform.payments", "acme/platform_payments"},
+		{"acme/" + strings.Repeat("a", 40) + "/one", "acme/" + strings.Repeat("a", 40) + "/two"},
+	}
+	for _, paths := range collisions {
+		if FormatGitLabOwner(paths[0]) == FormatGitLabOwner(paths[1]) {
+			t.Errorf("want different owners for %s and %s", paths[0], paths[1])
+		}
+	}
+
+	long := FormatGitLabOwner("acme/" + strings.Repeat("platform/", 10))
+	if len(long) > gitlabOwnerMaxPrefix+2+gitlabOwnerHashLength {
+		t.Errorf("want the owner to be short, got %d characters: %s", len(long), long)
+	}
+}
+
+func Test_FormatGitLabOwner_TopLevelCollision(t *testing.T) {
+	subgroup := FormatGitLabOwner("acme/platform")
+
+	// A top-level group named after the owner of a subgroup
+	if got := FormatGitLabOwner(subgroup); got == subgroup {
+		t.Errorf("want a top-level group not to take the owner of a subgroup: %s", got)
+	}
+
+	// The old form of <prefix>-<hash> is a valid top-level name
+	if got := FormatGitLabOwner("acme-platform-1a2b3c4d"); got != "acme-platform-1a2b3c4d" {
+		t.Errorf("want a top-level group to keep its name, got: %s", got)
+	}
+	if strings.Count(subgroup, "--") != 1 {
+		t.Errorf("want one double dash in the owner of a subgroup, got: %s", subgroup)
+	}
+
+	if strings.Contains(FormatGitLabOwner("acme/platform--payments/api"), "---") {
+		t.Errorf("want dashes in a subgroup path to be collapsed")
+	}
+}
+
+func Test_HasOwnerPrefix(t *testing.T) {
+	subgroup := FormatGitLabOwner("acme-platform/payments")
+
+	tests := []struct {
+		name  string
+		owner string
+		want  bool
+	}{
+		{name: "alexellis-api-key", owner: "alexellis", want: true},
+		{name: "alexellisx-api-key", owner: "alexellis", want: false},
+		{name: subgroup + "-api-key", owner: subgroup, want: true},
+		{name: subgroup + "-api-key", owner: "acme-platform", want: false},
+		{name: subgroup + "-api-key", owner: "acme", want: false},
+		{name: "acme-platform-api-key", owner: subgroup, want: false},
+		{name: "acme-platform-api-key", owner: "acme-platform", want: true},
+	}
+
+	for _, test := range tests {
+		if got := HasOwnerPrefix(test.name, test.owner); got != test.want {
+			t.Errorf("%s for %s want: %t, got: %t", test.name, test.owner, test.want, got)
+		}
+	}
+}
+
+func Test_GitLabNamespacePaths(t *testing.T) {
+	want := []string{"acme/platform/payments", "acme/platform", "acme"}
+	if got := GitLabNamespacePaths("acme/platform/payments"); !reflect.DeepEqual(got, want) {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+
+	if got := GitLabNamespacePaths("alexellis"); !reflect.DeepEqual(got, []string{"alexellis"}) {
+		t.Errorf("want only the user, got: %v", got)
+	}
+}
@@ -0,0 +1,273 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/alexellis/derek/auth"
+)
+
+const (
+	// installationTokenExpiryMargin is how long before its expiry a token is
+	// replaced, so that it doesn't expire while it is being used
+	installationTokenExpiryMargin = 5 * time.Minute
+
+	defaultTokenCacheDir = "openfaas-cloud-tokens"
+)
+
+// InstallationToken is an access token for an installation of the GitHub App
+type InstallationToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Valid is true when the token can still be used for a while at now
+func (t *InstallationToken) Valid(now time.Time) bool {
+	return t != nil && len(t.Token) > 0 && now.Add(installationTokenExpiryMargin).Before(t.ExpiresAt)
+}
+
+// MintInstallationToken creates a new token for an installation
+type MintInstallationToken func(installationID int) (*InstallationToken, error)
+
+// InstallationTokenCache hands out installation tokens, minting a new token
+// only when there isn't one which is still valid. Functions run in a new
+// process for each request, so tokens are also kept in files under Path to
+// share them between requests. It is safe for concurrent use.
+type InstallationTokenCache struct {
+	// Path is the directory tokens are kept in, tokens are only kept in
+	// memory when it is empty
+	Path string
+
+	Mint MintInstallationToken
+
+	now    func() time.Time
+	mutex  sync.Mutex
+	tokens map[int]*InstallationToken
+	locks  map[int]*sync.Mutex
+}
+
+// NewInstallationTokenCache creates a cache which keeps tokens under path
+func NewInstallationTokenCache(path string, mint MintInstallationToken) *InstallationTokenCache {
+	return &InstallationTokenCache{
+		Path:   path,
+		Mint:   mint,
+		now:    time.Now,
+		tokens: map[int]*InstallationToken{},
+		locks:  map[int]*sync.Mutex{},
+	}
+}
+
+// NewGitHubAppTokenCache creates a cache of tokens for the app given in
+// github_app_id using the private key secret, the tokens are kept in the
+// directory given by token_cache_path or in the temporary directory
+func NewGitHubAppTokenCache() *InstallationTokenCache {
+	path := os.Getenv("token_cache_path")
+	if len(path) == 0 {
+		path = filepath.Join(os.TempDir(), defaultTokenCacheDir)
+	}
+
+	appID := GetGitHubAppID()
+	return NewInstallationTokenCache(path, func(installationID int) (*InstallationToken, error) {
+		privateKey, err := ioutil.ReadFile(GetPrivateKeyPath())
+		if err != nil {
+			return nil, fmt.Errorf("unable to read private key: %s", err.Error())
+		}
+		return MakeInstallationToken(appID, installationID, string(privateKey))
+	})
+}
+
+// GetToken returns a valid token for the installation
+func (c *InstallationTokenCache) GetToken(installationID int) (string, error) {
+	lock := c.lock(installationID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	now := c.now()
+
+	c.mutex.Lock()
+	token := c.tokens[installationID]
+	c.mutex.Unlock()
+
+	if !token.Valid(now) {
+		token = c.read(installationID)
+	}
+
+	if !token.Valid(now) {
+		var err error
+		token, err = c.Mint(installationID)
+		if err != nil {
+			return "", err
+		}
+		if len(token.Token) == 0 {
+			return "", fmt.Errorf("empty token for installation: %d", installationID)
+		}
+
+		if writeErr := c.write(installationID, token); writeErr != nil {
+			fmt.Fprintf(os.Stderr, "unable to keep token for installation %d: %s\n", installationID, writeErr.Error())
+		}
+	}
+
+	c.mutex.Lock()
+	c.tokens[installationID] = token
+	c.mutex.Unlock()
+
+	return token.Token, nil
+}
+
+// lock returns the lock for an installation so that concurrent callers
+// wait for a single token to be minted
+func (c *InstallationTokenCache) lock(installationID int) *sync.Mutex {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.locks[installationID]; !ok {
+		c.locks[installationID] = &sync.Mutex{}
+	}
+	return c.locks[installationID]
+}
+
+func (c *InstallationTokenCache) tokenPath(installationID int) string {
+	return filepath.Join(c.Path, fmt.Sprintf("installation-%d.json", installationID))
+}
+
+func (c *InstallationTokenCache) read(installationID int) *InstallationToken {
+	if len(c.Path) == 0 {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(c.tokenPath(installationID))
+	if err != nil {
+		return nil
+	}
+
+	token := InstallationToken{}
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil
+	}
+	return &token
+}
+
+// write saves the token through a rename so that other processes never
+// read a partly written token
+func (c *InstallationTokenCache) write(installationID int, token *InstallationToken) error {
+	if len(c.Path) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(c.Path, 0700); err != nil {
+		return err
+	}
+
+	data, _ := json.Marshal(token)
+	tmp, err := ioutil.TempFile(c.Path, "token")
+	if err != nil {
+		return err
+	}
+
+	_, writeErr := tmp.Write(data)
+	closeErr := tmp.Close()
+	if writeErr != nil || closeErr != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("unable to write token: %v %v", writeErr, closeErr)
+	}
+
+	if err := os.Rename(tmp.Name(), c.tokenPath(installationID)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// NewGitHubAppMembership checks membership with a token of the installation
+// of the GitHub App on the organization of each rule, the app needs read
+// access to members
+func NewGitHubAppMembership() *GitHubMembership {
+	tokens := NewGitHubAppTokenCache()
+	appID := GetGitHubAppID()
+
+	membership := NewGitHubUserMembership(GetGitHubURLs().APIURL, "")
+	membership.Token = func(org string) (string, error) {
+		privateKey, err := ioutil.ReadFile(GetPrivateKeyPath())
+		if err != nil {
+			return "", fmt.Errorf("unable to read private key: %s", err.Error())
+		}
+
+		installationID, err := GetOrgInstallationID(appID, org, string(privateKey))
+		if err != nil {
+			return "", err
+		}
+		return tokens.GetToken(installationID)
+	}
+	return membership
+}
+
+// GetOrgInstallationID finds the installation of the GitHub App on an
+// organization
+func GetOrgInstallationID(appID, org, privateKey string) (int, error) {
+	signed, err := auth.GetSignedJwtToken(appID, privateKey)
+	if err != nil {
+		return 0, fmt.Errorf("unable to sign token for app_id: %s, error: %s", appID, err.Error())
+	}
+
+	installationURL := fmt.Sprintf("%s/orgs/%s/installation", GetGitHubURLs().APIURL, url.PathEscape(org))
+	req, _ := http.NewRequest(http.MethodGet, installationURL, nil)
+	req.Header.Set("Authorization", "Bearer "+signed)
+	req.Header.Set("Accept", "application/vnd.github.machine-man-preview+json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("unable to find installation for org: %s, error: %s", org, err.Error())
+	}
+	defer res.Body.Close()
+
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unable to find installation for org: %s, status: %d", org, res.StatusCode)
+	}
+
+	installation := struct {
+		ID int `json:"id"`
+	}{}
+	if err := json.Unmarshal(body, &installation); err != nil {
+		return 0, fmt.Errorf("unable to parse installation for org: %s, error: %s", org, err.Error())
+	}
+	return installation.ID, nil
+}
+
+// MakeInstallationToken mints an access token for an installation of the
+// GitHub App, signing the request with the private key of the app
+func MakeInstallationToken(appID string, installationID int, privateKey string) (*InstallationToken, error) {
+	signed, err := auth.GetSignedJwtToken(appID, privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to sign token for app_id: %s, error: %s", appID, err.Error())
+	}
+
+	tokenURL := fmt.Sprintf("%s/app/installations/%d/access_tokens", GetGitHubURLs().APIURL, installationID)
+	req, _ := http.NewRequest(http.MethodPost, tokenURL, nil)
+	req.Header.Set("Authorization", "Bearer "+signed)
+	req.Header.Set("Accept", "application/vnd.github.machine-man-preview+json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get token for installation: %d, error: %s", installationID, err.Error())
+	}
+	defer res.Body.Close()
+
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("unable to get token for installation: %d, status: %d, body: %s", installationID, res.StatusCode, string(body))
+	}
+
+	token := InstallationToken{}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("unable to parse token for installation: %d, error: %s", installationID, err.Error())
+	}
+	return &token, nil
+}
@@ -0,0 +1,162 @@
+package sdk
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+type countingMinter struct {
+	mutex   sync.Mutex
+	minted  int
+	expires time.Time
+}
+
+func (m *countingMinter) mint(installationID int) (*InstallationToken, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.minted++
+	return &InstallationToken{
+		Token:     fmt.Sprintf("token-%d-%d", installationID, m.minted),
+		ExpiresAt: m.expires,
+	}, nil
+}
+
+func Test_InstallationToken_Valid(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		title string
+		token *InstallationToken
+		want  bool
+	}{
+		{title: "no token", token: nil, want: false},
+		{title: "empty token", token: &InstallationToken{ExpiresAt: now.Add(time.Hour)}, want: false},
+		{title: "expires in an hour", token: &InstallationToken{Token: "t", ExpiresAt: now.Add(time.Hour)}, want: true},
+		{title: "expires within the margin", token: &InstallationToken{Token: "t", ExpiresAt: now.Add(time.Minute)}, want: false},
+		{title: "expired", token: &InstallationToken{Token: "t", ExpiresAt: now.Add(-time.Minute)}, want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			if got := test.token.Valid(now); got != test.want {
+				t.Errorf("want %t, got %t", test.want, got)
+			}
+		})
+	}
+}
+
+func Test_InstallationTokenCache_ReusesToken(t *testing.T) {
+	minter := &countingMinter{expires: time.Now().Add(time.Hour)}
+	cache := NewInstallationTokenCache("", minter.mint)
+
+	first, err := cache.GetToken(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := cache.GetToken(1)
+	other, _ := cache.GetToken(2)
+
+	if first != second {
+		t.Errorf("want token to be reused, got %s and %s", first, second)
+	}
+	if other == first {
+		t.Errorf("want a token for each installation, got %s", other)
+	}
+	if minter.minted != 2 {
+		t.Errorf("want 2 tokens minted, got %d", minter.minted)
+	}
+}
+
+func Test_InstallationTokenCache_RefreshesExpiringToken(t *testing.T) {
+	minter := &countingMinter{expires: time.Now().Add(time.Hour)}
+	cache := NewInstallationTokenCache("", minter.mint)
+
+	first, _ := cache.GetToken(1)
+
+	cache.now = func() time.Time { return time.Now().Add(58 * time.Minute) }
+	second, _ := cache.GetToken(1)
+
+	if first == second {
+		t.Errorf("want a new token once it is about to expire, got %s", second)
+	}
+}
+
+func Test_InstallationTokenCache_SharesTokenThroughPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tokens")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	minter := &countingMinter{expires: time.Now().Add(time.Hour)}
+
+	first, _ := NewInstallationTokenCache(dir, minter.mint).GetToken(1)
+	second, _ := NewInstallationTokenCache(dir, minter.mint).GetToken(1)
+
+	if first != second {
+		t.Errorf("want token to be shared, got %s and %s", first, second)
+	}
+	if minter.minted != 1 {
+		t.Errorf("want 1 token minted, got %d", minter.minted)
+	}
+}
+
+func Test_InstallationTokenCache_ConcurrentCallersMintOnce(t *testing.T) {
+	minter := &countingMinter{expires: time.Now().Add(time.Hour)}
+	cache := NewInstallationTokenCache("", minter.mint)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cache.GetToken(1); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if minter.minted != 1 {
+		t.Errorf("want 1 token minted, got %d", minter.minted)
+	}
+}
+
+func Test_MakeInstallationToken_EnterpriseServer(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/app/installations/42/access_tokens" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"token": "v1.token", "expires_at": "2030-01-01T00:00:00Z"}`))
+	}))
+	defer s.Close()
+
+	os.Setenv("github_url", s.URL)
+	defer os.Unsetenv("github_url")
+
+	token, err := MakeInstallationToken("1234", 42, string(privateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token.Token != "v1.token" {
+		t.Errorf("want token: %s, got: %s", "v1.token", token.Token)
+	}
+}
@@ -0,0 +1,224 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// CustomerRuleOrg grants access to the members of a GitHub organization
+	CustomerRuleOrg = "org"
+
+	// CustomerRuleTeam grants access to the members of a GitHub team, given
+	// as org/team-slug
+	CustomerRuleTeam = "team"
+
+	// CustomerRuleGroup grants access to the members of a GitLab group
+	CustomerRuleGroup = "group"
+
+	defaultMembershipDir = "openfaas-cloud-memberships"
+)
+
+// CustomerRule is an entry of the customers list such as org:openfaas which
+// grants access to the members of an organization, team or group instead of
+// to a single login
+type CustomerRule struct {
+	Kind string
+	Name string
+}
+
+func (r CustomerRule) String() string {
+	return r.Kind + ":" + r.Name
+}
+
+// ParseCustomerRule reads an entry of the customers list, it returns false
+// when the entry is a login
+func ParseCustomerRule(entry string) (CustomerRule, bool) {
+	parts := strings.SplitN(formatUsername(entry), ":", 2)
+	if len(parts) != 2 || len(parts[1]) == 0 {
+		return CustomerRule{}, false
+	}
+
+	rule := CustomerRule{Kind: parts[0], Name: strings.Trim(parts[1], "/")}
+	switch rule.Kind {
+	case CustomerRuleOrg, CustomerRuleGroup:
+		return rule, len(rule.Name) > 0
+	case CustomerRuleTeam:
+		return rule, strings.Count(rule.Name, "/") == 1
+	}
+	return CustomerRule{}, false
+}
+
+// MembershipChecker resolves whether a login belongs to the organization,
+// team or group of a rule. Rules of a kind which the checker doesn't know
+// are not a match.
+type MembershipChecker interface {
+	IsMember(rule CustomerRule, login string) (bool, error)
+}
+
+// NewMembershipCache creates the sets which remember resolved memberships in
+// the directory given by membership_store_path or in the temporary directory,
+// for the duration given by membership_cache_ttl
+func NewMembershipCache() (members *ExpiringSet, nonMembers *ExpiringSet) {
+	path := os.Getenv("membership_store_path")
+	if len(path) == 0 {
+		path = filepath.Join(os.TempDir(), defaultMembershipDir)
+	}
+
+	ttl := getDuration("membership_cache_ttl", customerCacheExpiry)
+	return NewExpiringSet(filepath.Join(path, "members"), ttl),
+		NewExpiringSet(filepath.Join(path, "non-members"), ttl)
+}
+
+// GitHubMembership checks organization and team membership with the GitHub
+// API, Token gives the token used for the organization of a rule
+type GitHubMembership struct {
+	APIURL string
+	Client *http.Client
+	Token  func(org string) (string, error)
+}
+
+// NewGitHubUserMembership checks membership with the token of a user, which
+// needs the read:org scope to see teams and private memberships
+func NewGitHubUserMembership(apiURL, token string) *GitHubMembership {
+	return &GitHubMembership{
+		APIURL: apiURL,
+		Client: &http.Client{Timeout: 10 * time.Second},
+		Token: func(org string) (string, error) {
+			return token, nil
+		},
+	}
+}
+
+// IsMember is true for active members of the organization or team
+func (m *GitHubMembership) IsMember(rule CustomerRule, login string) (bool, error) {
+	var memberURL string
+	switch rule.Kind {
+	case CustomerRuleOrg:
+		memberURL = fmt.Sprintf("%s/orgs/%s/members/%s", m.APIURL, url.PathEscape(rule.Name), url.PathEscape(login))
+	case CustomerRuleTeam:
+		parts := strings.SplitN(rule.Name, "/", 2)
+		memberURL = fmt.Sprintf("%s/orgs/%s/teams/%s/memberships/%s", m.APIURL, url.PathEscape(parts[0]), url.PathEscape(parts[1]), url.PathEscape(login))
+	default:
+		return false, nil
+	}
+
+	org := strings.SplitN(rule.Name, "/", 2)[0]
+	token, err := m.Token(org)
+	if err != nil {
+		return false, fmt.Errorf("unable to get a token for %s: %s", org, err.Error())
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, memberURL, nil)
+	req.Header.Set("Authorization", "token "+token)
+
+	// Users who aren't members of the organization are redirected to its
+	// public members, which the client follows
+	res, err := m.Client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("unable to check %s for %s: %s", rule, login, err.Error())
+	}
+	defer res.Body.Close()
+
+	body, _ := ioutil.ReadAll(res.Body)
+	switch res.StatusCode {
+	case http.StatusNoContent:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	case http.StatusOK:
+		membership := struct {
+			State string `json:"state"`
+		}{}
+		if err := json.Unmarshal(body, &membership); err != nil {
+			return false, fmt.Errorf("unable to parse membership of %s for %s: %s", rule, login, err.Error())
+		}
+		return membership.State == "active", nil
+	}
+	return false, fmt.Errorf("unable to check %s for %s, status: %d", rule, login, res.StatusCode)
+}
+
+// GitLabMembership checks group membership, including inherited membership,
+// with the GitLab API. APIURL ends in /api/v4. OAuth tokens of users need the
+// read_api scope, other tokens are sent as a private token.
+type GitLabMembership struct {
+	APIURL string
+	Token  string
+	OAuth  bool
+	Client *http.Client
+}
+
+// NewGitLabMembership checks membership with an API token
+func NewGitLabMembership(apiURL, token string) *GitLabMembership {
+	return &GitLabMembership{
+		APIURL: strings.TrimSuffix(apiURL, "/"),
+		Token:  token,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// IsMember is true when the user is a member of the group or of one of its
+// parent groups
+func (m *GitLabMembership) IsMember(rule CustomerRule, login string) (bool, error) {
+	if rule.Kind != CustomerRuleGroup {
+		return false, nil
+	}
+
+	users := []struct {
+		ID int `json:"id"`
+	}{}
+	status, err := m.get(fmt.Sprintf("%s/users?username=%s", m.APIURL, url.QueryEscape(login)), &users)
+	if err != nil {
+		return false, err
+	}
+	if status != http.StatusOK {
+		return false, fmt.Errorf("unable to find GitLab user %s, status: %d", login, status)
+	}
+	if len(users) == 0 {
+		return false, nil
+	}
+
+	memberURL := fmt.Sprintf("%s/groups/%s/members/all/%d", m.APIURL, url.PathEscape(rule.Name), users[0].ID)
+	status, err = m.get(memberURL, nil)
+	if err != nil {
+		return false, err
+	}
+
+	switch status {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("unable to check %s for %s, status: %d", rule, login, status)
+}
+
+func (m *GitLabMembership) get(getURL string, out interface{}) (int, error) {
+	req, _ := http.NewRequest(http.MethodGet, getURL, nil)
+	if m.OAuth {
+		req.Header.Set("Authorization", "Bearer "+m.Token)
+	} else {
+		req.Header.Set("PRIVATE-TOKEN", m.Token)
+	}
+
+	res, err := m.Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("error while requesting GitLab: %s", err.Error())
+	}
+	defer res.Body.Close()
+
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode == http.StatusOK && out != nil {
+		if err := json.Unmarshal(body, out); err != nil {
+			return res.StatusCode, fmt.Errorf("unable to parse GitLab response: %s", err.Error())
+		}
+	}
+	return res.StatusCode, nil
+}
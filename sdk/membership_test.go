@@ -0,0 +1,240 @@
+package sdk
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeMembership struct {
+	members map[string]bool
+	err     error
+	checks  int
+}
+
+func (f *fakeMembership) IsMember(rule CustomerRule, login string) (bool, error) {
+	f.checks++
+	return f.members[rule.String()+"@"+login], f.err
+}
+
+func Test_ParseCustomerRule(t *testing.T) {
+	tests := []struct {
+		entry string
+		want  CustomerRule
+		rule  bool
+	}{
+		{entry: "alexellis", rule: false},
+		{entry: "org:openfaas", want: CustomerRule{Kind: CustomerRuleOrg, Name: "openfaas"}, rule: true},
+		{entry: " Team:OpenFaaS/Core\r", want: CustomerRule{Kind: CustomerRuleTeam, Name: "openfaas/core"}, rule: true},
+		{entry: "team:openfaas", rule: false},
+		{entry: "group:openfaas/cloud/", want: CustomerRule{Kind: CustomerRuleGroup, Name: "openfaas/cloud"}, rule: true},
+		{entry: "org:", rule: false},
+		{entry: "user:alexellis", rule: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.entry, func(t *testing.T) {
+			got, rule := ParseCustomerRule(test.entry)
+			if rule != test.rule {
+				t.Fatalf("want rule: %t, got: %t", test.rule, rule)
+			}
+			if rule && got != test.want {
+				t.Errorf("want: %v, got: %v", test.want, got)
+			}
+		})
+	}
+}
+
+func Test_Customers_FetchReadsRules(t *testing.T) {
+	customersPath := filepath.Join(t.TempDir(), "customers")
+	ioutil.WriteFile(customersPath, []byte("alexellis\norg:openfaas\nteam:openfaas/core\n"), 0600)
+
+	customers := NewCustomers(customersPath, "")
+	customers.members, customers.nonMembers = nil, nil
+	if err := customers.Fetch(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*customers.Usernames) != 1 || len(customers.Rules) != 2 {
+		t.Errorf("want 1 login and 2 rules, got: %v and %v", *customers.Usernames, customers.Rules)
+	}
+
+	if found, _ := customers.Get("org:openfaas"); found {
+		t.Errorf("want a rule not to be a login")
+	}
+}
+
+func Test_Customers_GetWithMembership(t *testing.T) {
+	customers := newTestCustomers(t, []string{"alexellis"}, []CustomerRule{
+		{Kind: CustomerRuleOrg, Name: "openfaas"},
+		{Kind: CustomerRuleTeam, Name: "acme/builders"},
+	})
+	membership := &fakeMembership{members: map[string]bool{"team:acme/builders@rgee0": true}}
+
+	tests := []struct {
+		login string
+		want  bool
+	}{
+		{login: "alexellis", want: true},
+		{login: "openfaas", want: true},
+		{login: "rgee0", want: true},
+		{login: "acme", want: false},
+		{login: "stranger", want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.login, func(t *testing.T) {
+			found, err := customers.GetWithMembership(test.login, membership)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found != test.want {
+				t.Errorf("want: %t, got: %t", test.want, found)
+			}
+		})
+	}
+
+	if found, _ := customers.GetWithMembership("rgee0", nil); found {
+		t.Errorf("want rules to be ignored without a membership checker")
+	}
+}
+
+func Test_Customers_GetWithMembershipCachesResults(t *testing.T) {
+	customers := newTestCustomers(t, nil, []CustomerRule{{Kind: CustomerRuleOrg, Name: "openfaas"}})
+	membership := &fakeMembership{members: map[string]bool{"org:openfaas@rgee0": true}}
+
+	for i := 0; i < 2; i++ {
+		customers.GetWithMembership("rgee0", membership)
+		customers.GetWithMembership("stranger", membership)
+	}
+
+	if membership.checks != 2 {
+		t.Errorf("want each login to be resolved once, got: %d checks", membership.checks)
+	}
+}
+
+func Test_Customers_GetWithMembershipReturnsErrors(t *testing.T) {
+	customers := newTestCustomers(t, nil, []CustomerRule{{Kind: CustomerRuleOrg, Name: "openfaas"}})
+	membership := &fakeMembership{err: fmt.Errorf("no installation")}
+
+	found, err := customers.GetWithMembership("rgee0", membership)
+	if found || err == nil {
+		t.Errorf("want not found with an error, got: %t, %v", found, err)
+	}
+
+	membership.err = nil
+	if found, _ := customers.GetWithMembership("rgee0", membership); found || membership.checks != 2 {
+		t.Errorf("want errors not to be cached, got %d checks", membership.checks)
+	}
+}
+
+func Test_GitHubMembership_IsMember(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token user-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/orgs/openfaas/members/alexellis":
+			w.WriteHeader(http.StatusNoContent)
+		case "/orgs/openfaas/members/stranger":
+			http.Redirect(w, r, "/orgs/openfaas/public_members/stranger", http.StatusFound)
+		case "/orgs/openfaas/teams/core/memberships/alexellis":
+			w.Write([]byte(`{"state": "active", "role": "member"}`))
+		case "/orgs/openfaas/teams/core/memberships/invited":
+			w.Write([]byte(`{"state": "pending", "role": "member"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	membership := NewGitHubUserMembership(s.URL, "user-token")
+
+	tests := []struct {
+		rule  CustomerRule
+		login string
+		want  bool
+	}{
+		{rule: CustomerRule{Kind: CustomerRuleOrg, Name: "openfaas"}, login: "alexellis", want: true},
+		{rule: CustomerRule{Kind: CustomerRuleOrg, Name: "openfaas"}, login: "stranger", want: false},
+		{rule: CustomerRule{Kind: CustomerRuleTeam, Name: "openfaas/core"}, login: "alexellis", want: true},
+		{rule: CustomerRule{Kind: CustomerRuleTeam, Name: "openfaas/core"}, login: "invited", want: false},
+		{rule: CustomerRule{Kind: CustomerRuleGroup, Name: "openfaas"}, login: "alexellis", want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.rule.String()+"@"+test.login, func(t *testing.T) {
+			got, err := membership.IsMember(test.rule, test.login)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("want: %t, got: %t", test.want, got)
+			}
+		})
+	}
+}
+
+func Test_GitLabMembership_IsMember(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "api-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.RequestURI() {
+		case "/api/v4/users?username=alexellis":
+			w.Write([]byte(`[{"id": 7, "username": "alexellis"}]`))
+		case "/api/v4/users?username=stranger":
+			w.Write([]byte(`[{"id": 8, "username": "stranger"}]`))
+		case "/api/v4/users?username=nobody":
+			w.Write([]byte(`[]`))
+		case "/api/v4/groups/openfaas%2Fcloud/members/all/7":
+			w.Write([]byte(`{"id": 7, "access_level": 30}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	membership := NewGitLabMembership(s.URL+"/api/v4/", "api-token")
+	rule := CustomerRule{Kind: CustomerRuleGroup, Name: "openfaas/cloud"}
+
+	for login, want := range map[string]bool{"alexellis": true, "stranger": false, "nobody": false} {
+		got, err := membership.IsMember(rule, login)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s want: %t, got: %t", login, want, got)
+		}
+	}
+
+	if got, _ := membership.IsMember(CustomerRule{Kind: CustomerRuleOrg, Name: "openfaas"}, "alexellis"); got {
+		t.Errorf("want GitHub rules not to match on GitLab")
+	}
+}
+
+func newTestCustomers(t *testing.T, logins []string, rules []CustomerRule) *Customers {
+	usernames := map[string]string{}
+	for _, login := range logins {
+		usernames[login] = "true"
+	}
+
+	dir := t.TempDir()
+	return &Customers{
+		Usernames:  &usernames,
+		Rules:      rules,
+		Sync:       &sync.Mutex{},
+		Expires:    time.Now().Add(time.Hour),
+		members:    NewExpiringSet(filepath.Join(dir, "members"), time.Hour),
+		nonMembers: NewExpiringSet(filepath.Join(dir, "non-members"), time.Hour),
+	}
+}
@@ -0,0 +1,159 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// HealthPathAnnotation gives the path of a function which is probed once
+// its replicas are available
+const HealthPathAnnotation = "com.openfaas.health.http.path"
+
+// ReadinessConfig controls how long to wait for a function to become
+// ready after the gateway has accepted its deployment.
+type ReadinessConfig struct {
+	Timeout  time.Duration
+	Interval time.Duration
+	Probe    bool
+}
+
+// GetReadinessConfig reads readiness_timeout, readiness_interval and
+// readiness_probe from the environment.
+func GetReadinessConfig() ReadinessConfig {
+	return ReadinessConfig{
+		Timeout:  parseDuration(os.Getenv("readiness_timeout"), time.Minute*2),
+		Interval: parseDuration(os.Getenv("readiness_interval"), time.Second*2),
+		Probe:    os.Getenv("readiness_probe") == "true" || os.Getenv("readiness_probe") == "1",
+	}
+}
+
+func parseDuration(val string, fallback time.Duration) time.Duration {
+	if len(val) == 0 {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(val)
+	if err != nil || duration <= 0 {
+		log.Printf("unable to parse duration %q, using: %s", val, fallback)
+		return fallback
+	}
+	return duration
+}
+
+// WaitForReadiness polls the gateway until the function reports the desired
+// number of available replicas with the expected image, then optionally
+// runs the health probe. An error is returned describing the last state seen
+// when the timeout expires.
+func WaitForReadiness(ctx context.Context, gatewayURL, functionName, image string, healthURL string, cfg ReadinessConfig) error {
+	deadline := time.Now().Add(cfg.Timeout)
+
+	reason := "no status received from provider"
+	ready := false
+
+	for {
+		status, err := getFunctionStatus(ctx, gatewayURL, functionName)
+		if err != nil {
+			reason = err.Error()
+		} else if len(image) > 0 && status.Image != image {
+			reason = fmt.Sprintf("image %s is not yet rolled out", image)
+		} else if status.Replicas == 0 {
+			log.Printf("%s is scaled to zero, skipping replica check", functionName)
+			ready = true
+		} else if status.AvailableReplicas < status.Replicas {
+			reason = fmt.Sprintf("%d/%d replicas available", status.AvailableReplicas, status.Replicas)
+		} else {
+			ready = true
+		}
+
+		if ready && cfg.Probe && len(healthURL) > 0 {
+			if probeErr := probeHealth(healthURL); probeErr != nil {
+				reason = probeErr.Error()
+				ready = false
+			}
+		}
+
+		if ready {
+			return nil
+		}
+
+		log.Printf("%s not ready: %s", functionName, reason)
+
+		if time.Now().Add(cfg.Interval).After(deadline) {
+			return fmt.Errorf("not ready after %s: %s", cfg.Timeout, reason)
+		}
+
+		time.Sleep(cfg.Interval)
+	}
+}
+
+// getFunctionStatus reads the image and replicas of a function from the
+// gateway
+func getFunctionStatus(ctx context.Context, gatewayURL, functionName string) (*Function, error) {
+	req, _ := http.NewRequest(http.MethodGet, strings.TrimSuffix(gatewayURL, "/")+"/system/function/"+functionName, nil)
+	req = req.WithContext(ctx)
+
+	if err := AddBasicAuth(req); err != nil {
+		return nil, err
+	}
+
+	c := http.Client{Timeout: time.Second * 3}
+	res, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status from gateway: %d", res.StatusCode)
+	}
+
+	status := Function{}
+	if err := json.Unmarshal(body, &status); err != nil {
+		return nil, fmt.Errorf("unable to parse function status: %s", err.Error())
+	}
+	return &status, nil
+}
+
+// BuildHealthURL returns the URL to probe on the gateway for a function using
+// its com.openfaas.health.http.path annotation, or an empty string when
+// the annotation is not set.
+func BuildHealthURL(gatewayURL, functionName string, annotations map[string]string) string {
+	healthPath, ok := annotations[HealthPathAnnotation]
+	if !ok || len(healthPath) == 0 {
+		return ""
+	}
+
+	if !strings.HasSuffix(gatewayURL, "/") {
+		gatewayURL = gatewayURL + "/"
+	}
+
+	return gatewayURL + "function/" + functionName + "/" + strings.TrimPrefix(healthPath, "/")
+}
+
+func probeHealth(healthURL string) error {
+	c := http.Client{Timeout: time.Second * 3}
+
+	req, _ := http.NewRequest(http.MethodGet, healthURL, nil)
+	res, err := c.Do(req)
+	if err != nil {
+		return fmt.Errorf("health probe failed: %s", err.Error())
+	}
+
+	if res.Body != nil {
+		defer res.Body.Close()
+	}
+
+	if res.StatusCode < http.StatusOK || res.StatusCode > 299 {
+		return fmt.Errorf("health probe returned status: %d", res.StatusCode)
+	}
+
+	return nil
+}
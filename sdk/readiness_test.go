@@ -0,0 +1,154 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func makeStatusServer(statuses []Function) *httptest.Server {
+	calls := 0
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := statuses[len(statuses)-1]
+		if calls < len(statuses) {
+			status = statuses[calls]
+		}
+		calls++
+
+		bytesOut, _ := json.Marshal(status)
+		w.Write(bytesOut)
+	}))
+}
+
+func Test_WaitForReadiness_BecomesReady(t *testing.T) {
+	srv := makeStatusServer([]Function{
+		{Name: "alexellis-fn1", Image: "fn1:latest", Replicas: 1, AvailableReplicas: 0},
+		{Name: "alexellis-fn1", Image: "fn1:latest", Replicas: 1, AvailableReplicas: 1},
+	})
+	defer srv.Close()
+
+	cfg := ReadinessConfig{Timeout: time.Second, Interval: time.Millisecond}
+
+	err := WaitForReadiness(context.Background(), srv.URL, "alexellis-fn1", "fn1:latest", "", cfg)
+	if err != nil {
+		t.Errorf("want no error, got: %s", err.Error())
+	}
+}
+
+func Test_WaitForReadiness_TimesOutWithReason(t *testing.T) {
+	srv := makeStatusServer([]Function{
+		{Name: "alexellis-fn1", Image: "fn1:latest", Replicas: 2, AvailableReplicas: 1},
+	})
+	defer srv.Close()
+
+	cfg := ReadinessConfig{Timeout: time.Millisecond * 20, Interval: time.Millisecond * 5}
+
+	err := WaitForReadiness(context.Background(), srv.URL, "alexellis-fn1", "fn1:latest", "", cfg)
+	if err == nil {
+		t.Fatalf("want error, got nil")
+	}
+
+	want := "1/2 replicas available"
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("want error to contain %q, got: %q", want, err.Error())
+	}
+}
+
+func Test_WaitForReadiness_WaitsForNewImage(t *testing.T) {
+	srv := makeStatusServer([]Function{
+		{Name: "alexellis-fn1", Image: "fn1:old", Replicas: 1, AvailableReplicas: 1},
+	})
+	defer srv.Close()
+
+	cfg := ReadinessConfig{Timeout: time.Millisecond * 20, Interval: time.Millisecond * 5}
+
+	err := WaitForReadiness(context.Background(), srv.URL, "alexellis-fn1", "fn1:new", "", cfg)
+	if err == nil {
+		t.Fatalf("want error, got nil")
+	}
+
+	want := "image fn1:new is not yet rolled out"
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("want error to contain %q, got: %q", want, err.Error())
+	}
+}
+
+func Test_WaitForReadiness_FailingProbe(t *testing.T) {
+	srv := makeStatusServer([]Function{
+		{Name: "alexellis-fn1", Image: "fn1:latest", Replicas: 1, AvailableReplicas: 1},
+	})
+	defer srv.Close()
+
+	health := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer health.Close()
+
+	cfg := ReadinessConfig{Timeout: time.Millisecond * 20, Interval: time.Millisecond * 5, Probe: true}
+
+	err := WaitForReadiness(context.Background(), srv.URL, "alexellis-fn1", "fn1:latest", health.URL, cfg)
+	if err == nil {
+		t.Fatalf("want error, got nil")
+	}
+
+	want := "health probe returned status: 503"
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("want error to contain %q, got: %q", want, err.Error())
+	}
+}
+
+func Test_BuildHealthURL(t *testing.T) {
+	annotations := map[string]string{
+		HealthPathAnnotation: "/_/health",
+	}
+
+	got := BuildHealthURL("http://gateway:8080", "alexellis-fn1", annotations)
+	want := "http://gateway:8080/function/alexellis-fn1/_/health"
+	if got != want {
+		t.Errorf("want: %q, got: %q", want, got)
+	}
+}
+
+func Test_BuildHealthURL_NoAnnotation(t *testing.T) {
+	got := BuildHealthURL("http://gateway:8080/", "alexellis-fn1", map[string]string{})
+	if got != "" {
+		t.Errorf("want empty URL, got: %q", got)
+	}
+}
+
+func Test_GetReadinessConfig_Defaults(t *testing.T) {
+	os.Unsetenv("readiness_timeout")
+	os.Unsetenv("readiness_interval")
+	os.Unsetenv("readiness_probe")
+
+	cfg := GetReadinessConfig()
+	if cfg.Timeout != time.Minute*2 {
+		t.Errorf("want timeout: %s, got: %s", time.Minute*2, cfg.Timeout)
+	}
+	if cfg.Interval != time.Second*2 {
+		t.Errorf("want interval: %s, got: %s", time.Second*2, cfg.Interval)
+	}
+	if cfg.Probe {
+		t.Errorf("want probe to be disabled by default")
+	}
+}
+
+func Test_GetReadinessConfig_Override(t *testing.T) {
+	os.Setenv("readiness_timeout", "30s")
+	os.Setenv("readiness_probe", "true")
+	defer os.Unsetenv("readiness_timeout")
+	defer os.Unsetenv("readiness_probe")
+
+	cfg := GetReadinessConfig()
+	if cfg.Timeout != time.Second*30 {
+		t.Errorf("want timeout: %s, got: %s", time.Second*30, cfg.Timeout)
+	}
+	if !cfg.Probe {
+		t.Errorf("want probe to be enabled")
+	}
+}
@@ -24,3 +24,15 @@ func ReadSecret(key string) (string, error) {
 	val := strings.TrimSpace(string(secretBytes))
 	return val, nil
 }
+
+// GetGitHubAppID reads the ID of the GitHub App from github_app_id, or from
+// the github-app-id secret which is written when the app is created by the
+// setup of edge-auth
+func GetGitHubAppID() string {
+	if appID := os.Getenv("github_app_id"); len(appID) > 0 {
+		return appID
+	}
+
+	appID, _ := ReadSecret("github-app-id")
+	return appID
+}
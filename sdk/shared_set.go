@@ -0,0 +1,124 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/alexellis/hmac"
+)
+
+// Actions accepted by the expiring-set function
+const (
+	SetActionAdd      = "add"
+	SetActionContains = "contains"
+	SetActionRemove   = "remove"
+)
+
+// KeySet remembers keys for a time, it is implemented by ExpiringSet for a
+// single pod and by SharedSet for all replicas of all functions
+type KeySet interface {
+	Add(key string) (bool, error)
+	Contains(key string) bool
+	Remove(key string) error
+}
+
+// SetRequest is sent to the expiring-set function to add, look up or
+// remove a key of a set
+type SetRequest struct {
+	Action string `json:"action"`
+	Set    string `json:"set"`
+	Key    string `json:"key"`
+	TTL    string `json:"ttl,omitempty"`
+}
+
+// SetResponse is true in Member when the key was in the set before the
+// request
+type SetResponse struct {
+	Member bool `json:"member"`
+}
+
+// SharedSet is a set kept in the S3 bucket by the expiring-set function,
+// so that every replica sees the same keys. Requests are signed with the
+// payload-secret.
+type SharedSet struct {
+	GatewayURL string
+	Name       string
+	TTL        time.Duration
+}
+
+// NewSharedSet creates a set called name which keeps keys for ttl
+func NewSharedSet(gatewayURL, name string, ttl time.Duration) *SharedSet {
+	return &SharedSet{
+		GatewayURL: gatewayURL,
+		Name:       name,
+		TTL:        ttl,
+	}
+}
+
+// Add records key, it returns false when key was already recorded and has
+// not expired
+func (s *SharedSet) Add(key string) (bool, error) {
+	res, err := s.post(SetActionAdd, key)
+	if err != nil {
+		return false, err
+	}
+	return !res.Member, nil
+}
+
+// Contains is true when key was recorded and has not expired, it is false
+// when the set can't be reached
+func (s *SharedSet) Contains(key string) bool {
+	res, err := s.post(SetActionContains, key)
+	if err != nil {
+		return false
+	}
+	return res.Member
+}
+
+// Remove forgets key so that it can be added again
+func (s *SharedSet) Remove(key string) error {
+	_, err := s.post(SetActionRemove, key)
+	return err
+}
+
+func (s *SharedSet) post(action, key string) (*SetResponse, error) {
+	payloadSecret, err := ReadSecret("payload-secret")
+	if err != nil {
+		return nil, err
+	}
+
+	bytesOut, _ := json.Marshal(SetRequest{
+		Action: action,
+		Set:    s.Name,
+		Key:    key,
+		TTL:    s.TTL.String(),
+	})
+
+	req, _ := http.NewRequest(http.MethodPost, s.GatewayURL+"function/expiring-set", bytes.NewReader(bytesOut))
+
+	digest := hmac.Sign(bytesOut, []byte(payloadSecret))
+	req.Header.Add(CloudSignatureHeader, "sha1="+hex.EncodeToString(digest))
+
+	c := http.Client{Timeout: 10 * time.Second}
+	res, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status from expiring-set: %d, body: %s", res.StatusCode, string(body))
+	}
+
+	setRes := SetResponse{}
+	if err := json.Unmarshal(body, &setRes); err != nil {
+		return nil, fmt.Errorf("unable to parse response from expiring-set: %s", err.Error())
+	}
+	return &setRes, nil
+}
@@ -0,0 +1,80 @@
+package sdk
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_SharedSet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ioutil.WriteFile(filepath.Join(dir, "payload-secret"), []byte("secret"), 0600)
+	os.Setenv("secret_mount_path", dir)
+	defer os.Unsetenv("secret_mount_path")
+
+	keys := map[string]bool{}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := validHMACWithSecretKey(&body, "secret", r.Header.Get(CloudSignatureHeader)); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		setReq := SetRequest{}
+		json.Unmarshal(body, &setReq)
+		if r.URL.Path != "/function/expiring-set" || setReq.Set != "deliveries" || setReq.TTL != "1h0m0s" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		member := keys[setReq.Key]
+		switch setReq.Action {
+		case SetActionAdd:
+			keys[setReq.Key] = true
+		case SetActionRemove:
+			delete(keys, setReq.Key)
+		}
+		json.NewEncoder(w).Encode(SetResponse{Member: member})
+	}))
+	defer s.Close()
+
+	set := NewSharedSet(s.URL+"/", "deliveries", time.Hour)
+
+	if added, err := set.Add("delivery"); err != nil || !added {
+		t.Errorf("want first delivery to be added, error: %v", err)
+	}
+	if added, _ := set.Add("delivery"); added {
+		t.Errorf("want redelivery to be a duplicate")
+	}
+	if !set.Contains("delivery") {
+		t.Errorf("want delivery to be recorded")
+	}
+
+	if err := set.Remove("delivery"); err != nil {
+		t.Fatal(err)
+	}
+	if set.Contains("delivery") {
+		t.Errorf("want removed delivery to be forgotten")
+	}
+}
+
+func Test_SharedSet_Unavailable(t *testing.T) {
+	set := NewSharedSet("http://127.0.0.1:1/", "deliveries", time.Hour)
+
+	if _, err := set.Add("delivery"); err == nil {
+		t.Errorf("want an error when the set can't be reached")
+	}
+	if set.Contains("delivery") {
+		t.Errorf("want no key when the set can't be reached")
+	}
+}
@@ -0,0 +1,64 @@
+package sdk
+
+import (
+	"fmt"
+	"time"
+)
+
+// Stages of the pipeline which are reported with their own context
+const (
+	StageClone      = "clone"
+	StageTemplates  = "templates"
+	StageShrinkwrap = "shrinkwrap"
+	StageBuild      = "build"
+	StagePush       = "push"
+	StageDeploy     = "deploy"
+	StageReadiness  = "readiness"
+)
+
+// StageTiming records when a stage of the pipeline started and completed
+type StageTiming struct {
+	Stage     string    `json:"stage"`
+	Started   time.Time `json:"started"`
+	Completed time.Time `json:"completed"`
+}
+
+// StartStage records the start of a stage at the current time
+func StartStage(stage string) StageTiming {
+	return StageTiming{Stage: stage, Started: time.Now()}
+}
+
+// Complete records the end of the stage at the current time
+func (t StageTiming) Complete() StageTiming {
+	t.Completed = time.Now()
+	return t
+}
+
+// Duration is the time taken by the stage, or zero when it has
+// not completed
+func (t StageTiming) Duration() time.Duration {
+	if t.Started.IsZero() || t.Completed.IsZero() {
+		return 0
+	}
+	return t.Completed.Sub(t.Started)
+}
+
+// Description describes the stage for a commit status in the given
+// state, i.e. "build took 12.3s"
+func (t StageTiming) Description(state string) string {
+	switch state {
+	case StatusSuccess:
+		return fmt.Sprintf("%s took %s", t.Stage, FormatDuration(t.Duration()))
+	case StatusFailure:
+		return fmt.Sprintf("%s failed after %s", t.Stage, FormatDuration(t.Duration()))
+	}
+	return fmt.Sprintf("%s started", t.Stage)
+}
+
+// FormatDuration rounds a duration for display, i.e. 1.2s or 350ms
+func FormatDuration(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(100 * time.Millisecond).String()
+}
@@ -0,0 +1,75 @@
+package sdk
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_StageTiming_Description(t *testing.T) {
+	started := time.Unix(1586952000, 0)
+	timing := StageTiming{
+		Stage:     StageBuild,
+		Started:   started,
+		Completed: started.Add(12345 * time.Millisecond),
+	}
+
+	cases := map[string]string{
+		StatusSuccess: "build took 12.3s",
+		StatusFailure: "build failed after 12.3s",
+		StatusPending: "build started",
+	}
+
+	for state, want := range cases {
+		if got := timing.Description(state); got != want {
+			t.Errorf("%s: want %q, got %q", state, want, got)
+		}
+	}
+}
+
+func Test_StageTiming_DurationNotCompleted(t *testing.T) {
+	timing := StartStage(StageClone)
+
+	if got := timing.Duration(); got != 0 {
+		t.Errorf("want zero duration, got %s", got)
+	}
+}
+
+func Test_FormatDuration(t *testing.T) {
+	cases := map[time.Duration]string{
+		350400 * time.Microsecond: "350ms",
+		1250 * time.Millisecond:   "1.3s",
+		61 * time.Second:          "1m1s",
+	}
+
+	for d, want := range cases {
+		if got := FormatDuration(d); got != want {
+			t.Errorf("%s: want %q, got %q", d, want, got)
+		}
+	}
+}
+
+func Test_AddStageStatus(t *testing.T) {
+	status := BuildStatus(&Event{}, EmptyAuthToken)
+
+	started := time.Unix(1586952000, 0)
+	timing := StageTiming{Stage: StagePush, Started: started, Completed: started.Add(time.Second)}
+
+	status.AddStageStatus(StatusSuccess, "pushed", "fn1", timing)
+
+	commitStatus, ok := status.CommitStatuses["fn1/push"]
+	if !ok {
+		t.Fatalf("want a status for context fn1/push, got: %v", status.CommitStatuses)
+	}
+
+	if commitStatus.Stage != StagePush {
+		t.Errorf("want stage %s, got %s", StagePush, commitStatus.Stage)
+	}
+
+	if commitStatus.Started == nil || !commitStatus.Started.Equal(started) {
+		t.Errorf("want started %s, got %v", started, commitStatus.Started)
+	}
+
+	if commitStatus.Completed == nil || !commitStatus.Completed.Equal(timing.Completed) {
+		t.Errorf("want completed %s, got %v", timing.Completed, commitStatus.Completed)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"log"
 	"net/http"
 	"regexp"
+	"time"
 
 	hmac "github.com/alexellis/hmac"
 )
@@ -18,14 +19,24 @@ const (
 	StatusSuccess = "success"
 	StatusFailure = "failure"
 	StatusPending = "pending"
+	// StatusActionRequired is reported while a deploy waits for approval
+	StatusActionRequired = "action_required"
+	// StatusSuperseded is reported for a commit which was not built or
+	// deployed because a newer commit was pushed
+	StatusSuperseded = "superseded"
+	// StatusSkipped is reported for a commit which asked not to be built
+	StatusSkipped = "skipped"
 )
 
 // context constant
 const (
-	FunctionContext = "%s"
-	StackContext    = "stack-deploy"
-	EmptyAuthToken  = ""
-	tokenKey        = "token"
+	FunctionContext  = "%s"
+	SmokeTestContext = "%s/smoke-test"
+	TestContext      = "%s/test"
+	StageContext     = "%s/%s"
+	StackContext     = "stack-deploy"
+	EmptyAuthToken   = ""
+	tokenKey         = "token"
 )
 
 const authTokenPattern = "^[A-Za-z0-9-_.]*"
@@ -37,6 +48,15 @@ type CommitStatus struct {
 	Status      string `json:"status"`
 	Description string `json:"description"`
 	Context     string `json:"context"`
+
+	// Summary is markdown shown in the GitHub Checks UI
+	Summary string `json:"summary,omitempty"`
+
+	// Stage of the pipeline with its start and end time, when the
+	// status is for a single stage
+	Stage     string     `json:"stage,omitempty"`
+	Started   *time.Time `json:"started,omitempty"`
+	Completed *time.Time `json:"completed,omitempty"`
 }
 
 // Status to post status to github-status function
@@ -84,6 +104,35 @@ func (status *Status) AddStatus(state string, desc string, context string) {
 	status.CommitStatuses[context] = CommitStatus{Status: state, Description: desc, Context: context}
 }
 
+// AddStatusWithSummary adds a commit status with a markdown summary for
+// the GitHub Checks UI
+func (status *Status) AddStatusWithSummary(state string, desc string, summary string, context string) {
+	status.AddStatus(state, desc, context)
+
+	commitStatus := status.CommitStatuses[context]
+	commitStatus.Summary = summary
+	status.CommitStatuses[context] = commitStatus
+}
+
+// AddStageStatus adds a commit status for a stage of the pipeline within
+// a context, i.e. the "build" stage of a function, along with its timing
+func (status *Status) AddStageStatus(state string, desc string, context string, timing StageTiming) {
+	stageContext := BuildStageContext(context, timing.Stage)
+	status.AddStatus(state, desc, stageContext)
+
+	commitStatus := status.CommitStatuses[stageContext]
+	commitStatus.Stage = timing.Stage
+	if !timing.Started.IsZero() {
+		started := timing.Started
+		commitStatus.Started = &started
+	}
+	if !timing.Completed.IsZero() {
+		completed := timing.Completed
+		commitStatus.Completed = &completed
+	}
+	status.CommitStatuses[stageContext] = commitStatus
+}
+
 // Marshal marshals a status into json
 func (status *Status) Marshal() ([]byte, error) {
 	return json.Marshal(status)
@@ -170,3 +219,24 @@ func (status *Status) Report(gateway string, payloadSecret string) (string, erro
 func BuildFunctionContext(function string) string {
 	return fmt.Sprintf(FunctionContext, function)
 }
+
+// BuildSmokeTestContext build a github context for the smoke test of a function
+//                      Example:
+//                        sdk.BuildSmokeTestContext(functionName)
+func BuildSmokeTestContext(function string) string {
+	return fmt.Sprintf(SmokeTestContext, function)
+}
+
+// BuildTestContext build a github context for the test stage of a function
+//                      Example:
+//                        sdk.BuildTestContext(functionName)
+func BuildTestContext(function string) string {
+	return fmt.Sprintf(TestContext, function)
+}
+
+// BuildStageContext build a github context for a stage of a function or of the stack
+//                      Example:
+//                        sdk.BuildStageContext(functionName, sdk.StageBuild)
+func BuildStageContext(context string, stage string) string {
+	return fmt.Sprintf(StageContext, context, stage)
+}
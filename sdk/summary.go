@@ -0,0 +1,355 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	summaryMarker     = "<!-- openfaas-cloud:summary -->"
+	summaryDataPrefix = "<!-- openfaas-cloud:data "
+	summaryDataSuffix = " -->"
+
+	// summaryAttempts is how many times the summary comment is read and
+	// written before giving up when other builds keep changing it
+	summaryAttempts = 3
+)
+
+// FunctionSummary is the row for a function in the summary of a build
+// which is kept in a comment on a pull request or merge request
+type FunctionSummary struct {
+	Name         string `json:"name"`
+	Status       string `json:"status"`
+	Description  string `json:"description"`
+	Image        string `json:"image,omitempty"`
+	ImageSize    int64  `json:"image_size,omitempty"`
+	URL          string `json:"url,omitempty"`
+	DashboardURL string `json:"dashboard_url,omitempty"`
+
+	// DeployStarted and DeployCompleted are in unix milliseconds
+	DeployStarted   int64 `json:"deploy_started,omitempty"`
+	DeployCompleted int64 `json:"deploy_completed,omitempty"`
+}
+
+// BuildSummary is the state of each function built for a commit
+type BuildSummary struct {
+	SHA       string            `json:"sha"`
+	Functions []FunctionSummary `json:"functions"`
+}
+
+// SummaryComment is a comment or note which may hold a build summary
+type SummaryComment struct {
+	ID   int64
+	Body string
+}
+
+// SummaryComments reads and writes the comments on a pull request or
+// merge request
+type SummaryComments interface {
+	List() ([]SummaryComment, error)
+	Create(body string) error
+	Update(id int64, body string) error
+	Delete(id int64) error
+}
+
+// GetFunctionSummary builds the row for the function from the statuses
+// reported together, false is returned when they do not include the
+// status of the function itself
+func GetFunctionSummary(status *Status, gatewayPublicURL string) (FunctionSummary, bool) {
+	event := &status.EventInfo
+	if len(event.Service) == 0 {
+		return FunctionSummary{}, false
+	}
+
+	functionStatus, ok := status.CommitStatuses[BuildFunctionContext(event.Service)]
+	if !ok {
+		return FunctionSummary{}, false
+	}
+
+	summary := FunctionSummary{
+		Name:        event.Service,
+		Status:      functionStatus.Status,
+		Description: functionStatus.Description,
+		Image:       event.Image,
+		ImageSize:   event.ImageSize,
+	}
+
+	if len(gatewayPublicURL) > 0 {
+		if functionStatus.Status == StatusSuccess {
+			summary.URL, _ = FormatEndpointURL(gatewayPublicURL, event)
+		}
+		summary.DashboardURL, _ = FormatDashboardURL(gatewayPublicURL, event)
+	}
+
+	if deploy, ok := status.CommitStatuses[BuildStageContext(event.Service, StageDeploy)]; ok {
+		if deploy.Started != nil {
+			summary.DeployStarted = toMillis(*deploy.Started)
+		}
+		if deploy.Completed != nil {
+			summary.DeployCompleted = toMillis(*deploy.Completed)
+		}
+	}
+
+	// A deploy is complete once the function is ready
+	if readiness, ok := status.CommitStatuses[BuildStageContext(event.Service, StageReadiness)]; ok && readiness.Completed != nil {
+		summary.DeployCompleted = toMillis(*readiness.Completed)
+	}
+
+	return summary, true
+}
+
+// DeployDuration is the time from the start of the deploy until the
+// function was ready, or zero when it is not known
+func (f FunctionSummary) DeployDuration() time.Duration {
+	if f.DeployStarted == 0 || f.DeployCompleted < f.DeployStarted {
+		return 0
+	}
+	return time.Duration(f.DeployCompleted-f.DeployStarted) * time.Millisecond
+}
+
+// merge returns the row with the fields set in update applied to it
+func (f FunctionSummary) merge(update FunctionSummary) FunctionSummary {
+	f.Status = update.Status
+	f.Description = update.Description
+
+	if len(update.Image) > 0 {
+		f.Image = update.Image
+	}
+	if update.ImageSize > 0 {
+		f.ImageSize = update.ImageSize
+	}
+	if len(update.URL) > 0 || update.Status != StatusSuccess {
+		f.URL = update.URL
+	}
+	if len(update.DashboardURL) > 0 {
+		f.DashboardURL = update.DashboardURL
+	}
+	if update.DeployStarted > 0 {
+		f.DeployStarted = update.DeployStarted
+	}
+	if update.DeployCompleted > 0 {
+		f.DeployCompleted = update.DeployCompleted
+	}
+	return f
+}
+
+// Update sets the row for a function, keeping anything already known
+// about it which is missing from the update
+func (s *BuildSummary) Update(update FunctionSummary) {
+	for i, function := range s.Functions {
+		if function.Name == update.Name {
+			s.Functions[i] = function.merge(update)
+			return
+		}
+	}
+
+	s.Functions = append(s.Functions, FunctionSummary{Name: update.Name}.merge(update))
+	sort.Slice(s.Functions, func(i, j int) bool {
+		return s.Functions[i].Name < s.Functions[j].Name
+	})
+}
+
+// Has is true when the summary already holds the update
+func (s *BuildSummary) Has(update FunctionSummary) bool {
+	for _, function := range s.Functions {
+		if function.Name == update.Name {
+			return function.merge(update) == function
+		}
+	}
+	return false
+}
+
+func (s *BuildSummary) hasFunction(name string) bool {
+	for _, function := range s.Functions {
+		if function.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBuildSummary is true when the body of a comment holds a build summary
+func IsBuildSummary(body string) bool {
+	return strings.Contains(body, summaryMarker)
+}
+
+// ParseBuildSummary reads the build summary held in the body of a comment
+func ParseBuildSummary(body string) (*BuildSummary, error) {
+	start := strings.Index(body, summaryDataPrefix)
+	if start < 0 {
+		return nil, fmt.Errorf("no build summary found")
+	}
+
+	data := body[start+len(summaryDataPrefix):]
+	end := strings.Index(data, summaryDataSuffix)
+	if end < 0 {
+		return nil, fmt.Errorf("build summary is incomplete")
+	}
+
+	summary := BuildSummary{}
+	if err := json.Unmarshal([]byte(data[:end]), &summary); err != nil {
+		return nil, fmt.Errorf("unable to parse build summary: %s", err.Error())
+	}
+	return &summary, nil
+}
+
+// Markdown renders the summary as the body of a comment, the summary is
+// also kept in the body so that it can be updated by later builds
+func (s *BuildSummary) Markdown() string {
+	sb := strings.Builder{}
+	sb.WriteString(summaryMarker + "\n")
+	sb.WriteString(fmt.Sprintf("### OpenFaaS Cloud build of %s\n\n", FormatShortSHA(s.SHA)))
+	sb.WriteString("| Function | Result | Image | Size | Deploy | Links |\n")
+	sb.WriteString("|----------|--------|-------|------|--------|-------|\n")
+
+	for _, f := range s.Functions {
+		image, size, deploy := "", "", ""
+		if len(f.Image) > 0 {
+			image = "`" + f.Image + "`"
+		}
+		if f.ImageSize > 0 {
+			size = FormatSize(f.ImageSize)
+		}
+		if duration := f.DeployDuration(); duration > 0 {
+			deploy = FormatDuration(duration)
+		}
+
+		links := []string{}
+		if len(f.URL) > 0 {
+			links = append(links, fmt.Sprintf("[endpoint](%s)", f.URL))
+		}
+		if len(f.DashboardURL) > 0 {
+			links = append(links, fmt.Sprintf("[dashboard](%s)", f.DashboardURL))
+		}
+
+		sb.WriteString(fmt.Sprintf("| %s | %s %s | %s | %s | %s | %s |\n",
+			f.Name,
+			statusEmoji(f.Status),
+			escapeTableCell(f.Description),
+			image,
+			size,
+			deploy,
+			strings.Join(links, " · ")))
+	}
+
+	data, _ := json.Marshal(s)
+	sb.WriteString("\n" + summaryDataPrefix + string(data) + summaryDataSuffix + "\n")
+	return sb.String()
+}
+
+// UpdateSummaryComment sets the row for a function in the summary comment,
+// creating the comment if there isn't one yet. Builds of each function
+// update the comment at the same time, so the comment is read back until
+// it holds the update and any duplicate comments are merged and removed.
+func UpdateSummaryComment(comments SummaryComments, sha string, update FunctionSummary) error {
+	for attempt := 0; attempt < summaryAttempts; attempt++ {
+		existing, err := listSummaryComments(comments)
+		if err != nil {
+			return err
+		}
+
+		if len(existing) == 0 {
+			summary := BuildSummary{SHA: sha}
+			summary.Update(update)
+			if err := comments.Create(summary.Markdown()); err != nil {
+				return fmt.Errorf("unable to create summary comment: %s", err.Error())
+			}
+			continue
+		}
+
+		summary, parseErr := ParseBuildSummary(existing[0].Body)
+		if parseErr != nil || summary.SHA != sha {
+			// A new push starts a new summary
+			summary = &BuildSummary{SHA: sha}
+		}
+
+		for _, duplicate := range existing[1:] {
+			if other, err := ParseBuildSummary(duplicate.Body); err == nil && other.SHA == sha {
+				for _, function := range other.Functions {
+					if !summary.hasFunction(function.Name) {
+						summary.Update(function)
+					}
+				}
+			}
+			if err := comments.Delete(duplicate.ID); err != nil {
+				return fmt.Errorf("unable to remove duplicate summary comment: %s", err.Error())
+			}
+		}
+
+		if summary.Has(update) && len(existing) == 1 {
+			return nil
+		}
+
+		summary.Update(update)
+		if err := comments.Update(existing[0].ID, summary.Markdown()); err != nil {
+			return fmt.Errorf("unable to update summary comment: %s", err.Error())
+		}
+	}
+
+	return fmt.Errorf("summary comment for %s was changed by another build", update.Name)
+}
+
+// listSummaryComments returns the comments holding a build summary, oldest first
+func listSummaryComments(comments SummaryComments) ([]SummaryComment, error) {
+	all, err := comments.List()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list comments: %s", err.Error())
+	}
+
+	found := []SummaryComment{}
+	for _, comment := range all {
+		if IsBuildSummary(comment.Body) {
+			found = append(found, comment)
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].ID < found[j].ID
+	})
+	return found, nil
+}
+
+// FormatSize formats a size in bytes for display, i.e. 23.4MB
+func FormatSize(size int64) string {
+	const unit = 1000
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+
+	value := float64(size)
+	for _, suffix := range []string{"kB", "MB", "GB"} {
+		value = value / unit
+		if value < unit || suffix == "GB" {
+			return fmt.Sprintf("%.1f%s", value, suffix)
+		}
+	}
+	return ""
+}
+
+func statusEmoji(status string) string {
+	switch status {
+	case StatusSuccess:
+		return ":white_check_mark:"
+	case StatusFailure:
+		return ":x:"
+	case StatusActionRequired:
+		return ":pause_button:"
+	case StatusSuperseded:
+		return ":fast_forward:"
+	case StatusSkipped:
+		return ":next_track_button:"
+	}
+	return ":hourglass:"
+}
+
+func escapeTableCell(value string) string {
+	value = strings.Replace(value, "\n", " ", -1)
+	return strings.Replace(value, "|", "\\|", -1)
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
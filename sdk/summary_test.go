@@ -0,0 +1,200 @@
+package sdk
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeComments struct {
+	comments []SummaryComment
+	nextID   int64
+}
+
+func (f *fakeComments) List() ([]SummaryComment, error) {
+	return append([]SummaryComment{}, f.comments...), nil
+}
+
+func (f *fakeComments) Create(body string) error {
+	f.nextID++
+	f.comments = append(f.comments, SummaryComment{ID: f.nextID, Body: body})
+	return nil
+}
+
+func (f *fakeComments) Update(id int64, body string) error {
+	for i, comment := range f.comments {
+		if comment.ID == id {
+			f.comments[i].Body = body
+			return nil
+		}
+	}
+	return fmt.Errorf("comment %d not found", id)
+}
+
+func (f *fakeComments) Delete(id int64) error {
+	for i, comment := range f.comments {
+		if comment.ID == id {
+			f.comments = append(f.comments[:i], f.comments[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("comment %d not found", id)
+}
+
+func Test_GetFunctionSummary(t *testing.T) {
+	started := time.Unix(1586952000, 0)
+	completed := started.Add(1500 * time.Millisecond)
+	ready := started.Add(4 * time.Second)
+
+	status := BuildStatus(&Event{
+		Service:   "fn1",
+		Owner:     "alexellis",
+		Image:     "registry:5000/alexellis-fn1:latest-af6db12",
+		ImageSize: 23400000,
+	}, EmptyAuthToken)
+
+	status.AddStageStatus(StatusSuccess, "deploy took 1.5s", "fn1", StageTiming{Stage: StageDeploy, Started: started, Completed: completed})
+	status.AddStageStatus(StatusSuccess, "readiness took 2.5s", "fn1", StageTiming{Stage: StageReadiness, Started: completed, Completed: ready})
+	status.AddStatus(StatusSuccess, "deployed: alexellis-fn1", "fn1")
+
+	summary, ok := GetFunctionSummary(status, "https://system.o6s.io/")
+	if !ok {
+		t.Fatalf("want a summary for fn1")
+	}
+
+	if summary.URL != "https://alexellis.o6s.io/fn1" {
+		t.Errorf("want endpoint URL, got: %s", summary.URL)
+	}
+
+	if summary.DashboardURL != "https://system.o6s.io/dashboard/alexellis" {
+		t.Errorf("want dashboard URL, got: %s", summary.DashboardURL)
+	}
+
+	if got := summary.DeployDuration(); got != 4*time.Second {
+		t.Errorf("want deploy duration of 4s, got: %s", got)
+	}
+}
+
+func Test_GetFunctionSummary_StackOnly(t *testing.T) {
+	status := BuildStatus(&Event{Service: "fn1"}, EmptyAuthToken)
+	status.AddStatus(StatusSuccess, "stack is successfully deployed", StackContext)
+
+	if _, ok := GetFunctionSummary(status, ""); ok {
+		t.Errorf("want no summary without the status of the function")
+	}
+}
+
+func Test_BuildSummary_MarkdownRoundTrip(t *testing.T) {
+	summary := BuildSummary{SHA: "af6db1234567"}
+	summary.Update(FunctionSummary{Name: "fn2", Status: StatusFailure, Description: "a | b"})
+	summary.Update(FunctionSummary{Name: "fn1", Status: StatusSuccess, Description: "deployed", Image: "fn1:latest", ImageSize: 23400000, URL: "https://alexellis.o6s.io/fn1"})
+
+	body := summary.Markdown()
+
+	for _, want := range []string{
+		"build of af6db12",
+		"| fn1 | :white_check_mark: deployed | `fn1:latest` | 23.4MB |  | [endpoint](https://alexellis.o6s.io/fn1) |",
+		"| fn2 | :x: a \\| b |",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("want %q in:\n%s", want, body)
+		}
+	}
+
+	if !IsBuildSummary(body) {
+		t.Fatalf("want the body to be a build summary")
+	}
+
+	parsed, err := ParseBuildSummary(body)
+	if err != nil {
+		t.Fatalf("unable to parse: %s", err.Error())
+	}
+
+	if parsed.SHA != summary.SHA || len(parsed.Functions) != 2 || parsed.Functions[0] != summary.Functions[0] {
+		t.Errorf("want %+v, got %+v", summary, parsed)
+	}
+}
+
+func Test_BuildSummary_UpdateKeepsKnownFields(t *testing.T) {
+	summary := BuildSummary{SHA: "af6db12"}
+	summary.Update(FunctionSummary{Name: "fn1", Status: StatusPending, Image: "fn1:latest", DeployStarted: 1000, DeployCompleted: 2000})
+	summary.Update(FunctionSummary{Name: "fn1", Status: StatusSuccess, DeployCompleted: 5000})
+
+	got := summary.Functions[0]
+	if got.Status != StatusSuccess || got.Image != "fn1:latest" || got.DeployDuration() != 4*time.Second {
+		t.Errorf("want known fields to be kept, got %+v", got)
+	}
+}
+
+func Test_UpdateSummaryComment_CreatesThenUpdates(t *testing.T) {
+	comments := &fakeComments{comments: []SummaryComment{{ID: 100, Body: "LGTM"}}}
+	comments.nextID = 100
+
+	if err := UpdateSummaryComment(comments, "af6db12", FunctionSummary{Name: "fn1", Status: StatusPending}); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if err := UpdateSummaryComment(comments, "af6db12", FunctionSummary{Name: "fn2", Status: StatusSuccess}); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if len(comments.comments) != 2 {
+		t.Fatalf("want a single summary comment, got %d comments", len(comments.comments))
+	}
+
+	summary, _ := ParseBuildSummary(comments.comments[1].Body)
+	if len(summary.Functions) != 2 {
+		t.Errorf("want both functions in the summary, got %+v", summary.Functions)
+	}
+}
+
+func Test_UpdateSummaryComment_NewPushResets(t *testing.T) {
+	comments := &fakeComments{}
+	UpdateSummaryComment(comments, "aaaaaaa", FunctionSummary{Name: "fn1", Status: StatusSuccess})
+	UpdateSummaryComment(comments, "bbbbbbb", FunctionSummary{Name: "fn2", Status: StatusPending})
+
+	summary, _ := ParseBuildSummary(comments.comments[0].Body)
+	if summary.SHA != "bbbbbbb" || len(summary.Functions) != 1 || summary.Functions[0].Name != "fn2" {
+		t.Errorf("want only fn2 for the new push, got %+v", summary)
+	}
+}
+
+func Test_UpdateSummaryComment_MergesDuplicates(t *testing.T) {
+	first := BuildSummary{SHA: "af6db12"}
+	first.Update(FunctionSummary{Name: "fn1", Status: StatusSuccess})
+	second := BuildSummary{SHA: "af6db12"}
+	second.Update(FunctionSummary{Name: "fn2", Status: StatusSuccess})
+
+	comments := &fakeComments{
+		comments: []SummaryComment{{ID: 2, Body: second.Markdown()}, {ID: 1, Body: first.Markdown()}},
+		nextID:   2,
+	}
+
+	if err := UpdateSummaryComment(comments, "af6db12", FunctionSummary{Name: "fn3", Status: StatusPending}); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if len(comments.comments) != 1 || comments.comments[0].ID != 1 {
+		t.Fatalf("want only the first comment to be kept, got %+v", comments.comments)
+	}
+
+	summary, _ := ParseBuildSummary(comments.comments[0].Body)
+	if len(summary.Functions) != 3 {
+		t.Errorf("want 3 functions, got %+v", summary.Functions)
+	}
+}
+
+func Test_FormatSize(t *testing.T) {
+	cases := map[int64]string{
+		512:        "512B",
+		23400000:   "23.4MB",
+		1500000000: "1.5GB",
+	}
+
+	for size, want := range cases {
+		if got := FormatSize(size); got != want {
+			t.Errorf("%d: want %s, got %s", size, want, got)
+		}
+	}
+}
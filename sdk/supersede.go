@@ -0,0 +1,119 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/alexellis/hmac"
+)
+
+const defaultBuildEnvironment = "production"
+
+// BuildRef identifies the build of a commit of a repo to an environment,
+// the of-builder keeps the newest commit of each repo and environment
+type BuildRef struct {
+	Owner       string `json:"owner"`
+	Repo        string `json:"repo"`
+	SHA         string `json:"sha"`
+	Environment string `json:"environment"`
+
+	// Before is the head of the branch before the push of SHA, it orders
+	// pushes which arrive out of order
+	Before string `json:"before,omitempty"`
+}
+
+// NewBuildRef creates a BuildRef for the environment given in
+// deployment_environment
+func NewBuildRef(owner, repo, sha string) BuildRef {
+	environment := os.Getenv("deployment_environment")
+	if len(environment) == 0 {
+		environment = defaultBuildEnvironment
+	}
+
+	return BuildRef{
+		Owner:       strings.ToLower(owner),
+		Repo:        strings.ToLower(repo),
+		SHA:         sha,
+		Environment: environment,
+	}
+}
+
+// SupersedeResult lists the builds of older commits which were cancelled
+type SupersedeResult struct {
+	Cancelled []string `json:"cancelled"`
+}
+
+// SupersedeBuilds records ref as the newest commit of its repo with the
+// of-builder, which cancels builds of older commits
+func SupersedeBuilds(builderURL, payloadSecret string, ref BuildRef) (*SupersedeResult, error) {
+	bytesOut, _ := json.Marshal(&ref)
+
+	req, _ := http.NewRequest(http.MethodPost, builderURL+"supersede", bytes.NewReader(bytesOut))
+
+	digest := hmac.Sign(bytesOut, []byte(payloadSecret))
+	req.Header.Add(CloudSignatureHeader, "sha1="+hex.EncodeToString(digest))
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status from of-builder: %d, body: %s", res.StatusCode, string(body))
+	}
+
+	result := SupersedeResult{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("unable to parse supersede result: %s", err.Error())
+	}
+	return &result, nil
+}
+
+// GetLatestBuild returns the newest commit of the repo of ref known to the
+// of-builder, it is empty when none is known
+func GetLatestBuild(builderURL string, ref BuildRef) (string, error) {
+	query := url.Values{}
+	query.Set("owner", ref.Owner)
+	query.Set("repo", ref.Repo)
+	query.Set("environment", ref.Environment)
+
+	res, err := http.Get(builderURL + "latest?" + query.Encode())
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status from of-builder: %d, body: %s", res.StatusCode, string(body))
+	}
+
+	latest := BuildRef{}
+	if err := json.Unmarshal(body, &latest); err != nil {
+		return "", fmt.Errorf("unable to parse latest build: %s", err.Error())
+	}
+	return latest.SHA, nil
+}
+
+// GetSupersedingSHA returns the newer commit which supersedes ref, it is
+// empty when ref is the newest commit or the newest commit isn't known
+func GetSupersedingSHA(builderURL string, ref BuildRef) (string, error) {
+	latest, err := GetLatestBuild(builderURL, ref)
+	if err != nil {
+		return "", err
+	}
+
+	if len(latest) == 0 || latest == ref.SHA {
+		return "", nil
+	}
+	return latest, nil
+}
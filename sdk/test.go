@@ -0,0 +1,205 @@
+package sdk
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Annotations used in stack.yml to run a test stage after the build
+const (
+	TestCommandAnnotation = FunctionLabelPrefix + "test.command"
+	TestTargetAnnotation  = FunctionLabelPrefix + "test.target"
+	TestReportsAnnotation = FunctionLabelPrefix + "test.reports"
+)
+
+// DefaultTestReportsPath is where JUnit XML files are collected from
+// when no path is given
+const DefaultTestReportsPath = "/tmp/test-results"
+
+// maxTestDescription keeps test descriptions within the length allowed
+// for a commit status
+const maxTestDescription = 140
+
+// TestConfig is the test stage for a function, either a command to run
+// in the built image or a stage of the Dockerfile
+type TestConfig struct {
+	Command string `json:"command,omitempty"`
+	Target  string `json:"target,omitempty"`
+	Reports string `json:"reports,omitempty"`
+}
+
+// GetTestConfig reads the test stage from the annotations of a function,
+// nil is returned when no test stage has been set
+func GetTestConfig(annotations map[string]string) *TestConfig {
+	config := TestConfig{
+		Command: strings.TrimSpace(annotations[TestCommandAnnotation]),
+		Target:  strings.TrimSpace(annotations[TestTargetAnnotation]),
+		Reports: strings.TrimSpace(annotations[TestReportsAnnotation]),
+	}
+
+	if len(config.Command) == 0 && len(config.Target) == 0 {
+		return nil
+	}
+
+	if len(config.Reports) == 0 {
+		config.Reports = DefaultTestReportsPath
+	}
+
+	return &config
+}
+
+// TestReport is the outcome of the test stage of a function
+type TestReport struct {
+	Passed   int      `json:"passed"`
+	Failed   int      `json:"failed"`
+	Skipped  int      `json:"skipped"`
+	Failures []string `json:"failures,omitempty"`
+
+	// Error is set when the tests could not be run or reported
+	Error string `json:"error,omitempty"`
+}
+
+// Succeeded is true when tests were found and none of them failed
+func (r *TestReport) Succeeded() bool {
+	return len(r.Error) == 0 && r.Failed == 0 && r.Passed+r.Skipped > 0
+}
+
+// Description gives the counts and the first failing tests within the
+// length of a commit status
+func (r *TestReport) Description() string {
+	if len(r.Error) > 0 && r.Passed+r.Failed+r.Skipped == 0 {
+		return truncateDescription("tests failed: " + r.Error)
+	}
+
+	if r.Passed+r.Failed+r.Skipped == 0 {
+		return "no test results found"
+	}
+
+	desc := fmt.Sprintf("%d passed, %d failed, %d skipped", r.Passed, r.Failed, r.Skipped)
+	if len(r.Failures) > 0 {
+		desc = fmt.Sprintf("%s: %s", desc, strings.Join(r.Failures, ", "))
+	}
+	return truncateDescription(desc)
+}
+
+// Markdown gives a summary of the report for the GitHub Checks UI
+func (r *TestReport) Markdown() string {
+	sb := strings.Builder{}
+
+	sb.WriteString("| Passed | Failed | Skipped |\n|---|---|---|\n")
+	sb.WriteString(fmt.Sprintf("| %d | %d | %d |\n", r.Passed, r.Failed, r.Skipped))
+
+	if len(r.Failures) > 0 {
+		sb.WriteString("\n**Failing tests**\n\n")
+		for _, name := range r.Failures {
+			sb.WriteString(fmt.Sprintf("* `%s`\n", name))
+		}
+	}
+
+	if len(r.Error) > 0 {
+		sb.WriteString(fmt.Sprintf("\n%s\n", r.Error))
+	}
+
+	return sb.String()
+}
+
+func truncateDescription(desc string) string {
+	if len(desc) > maxTestDescription {
+		return desc[:maxTestDescription-3] + "..."
+	}
+	return desc
+}
+
+type junitTestSuites struct {
+	Suites []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name   string           `xml:"name,attr"`
+	Cases  []junitTestCase  `xml:"testcase"`
+	Suites []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestCase struct {
+	Name      string    `xml:"name,attr"`
+	ClassName string    `xml:"classname,attr"`
+	Failure   *struct{} `xml:"failure"`
+	Error     *struct{} `xml:"error"`
+	Skipped   *struct{} `xml:"skipped"`
+}
+
+// ParseJUnit adds the test cases from a JUnit XML document to the report,
+// the root element may be either <testsuites> or <testsuite>
+func (r *TestReport) ParseJUnit(data []byte) error {
+	suites := junitTestSuites{}
+	if err := xml.Unmarshal(data, &suites); err != nil {
+		return err
+	}
+
+	if len(suites.Suites) == 0 {
+		suite := junitTestSuite{}
+		if err := xml.Unmarshal(data, &suite); err != nil {
+			return err
+		}
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	for _, suite := range suites.Suites {
+		r.addSuite(suite)
+	}
+	return nil
+}
+
+func (r *TestReport) addSuite(suite junitTestSuite) {
+	for _, testCase := range suite.Cases {
+		switch {
+		case testCase.Failure != nil || testCase.Error != nil:
+			r.Failed++
+
+			name := testCase.Name
+			if len(testCase.ClassName) > 0 {
+				name = testCase.ClassName + "." + testCase.Name
+			}
+			r.Failures = append(r.Failures, name)
+		case testCase.Skipped != nil:
+			r.Skipped++
+		default:
+			r.Passed++
+		}
+	}
+
+	for _, child := range suite.Suites {
+		r.addSuite(child)
+	}
+}
+
+// ReadJUnitReports parses every .xml file found under dir
+func ReadJUnitReports(dir string) (*TestReport, error) {
+	report := TestReport{}
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+
+		if info.IsDir() || !strings.HasSuffix(strings.ToLower(info.Name()), ".xml") {
+			return nil
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		if err := report.ParseJUnit(data); err != nil {
+			return fmt.Errorf("unable to parse %s: %s", info.Name(), err.Error())
+		}
+		return nil
+	})
+
+	return &report, err
+}
@@ -0,0 +1,71 @@
+package sdk
+
+import (
+	"strings"
+	"testing"
+)
+
+const junitSuites = `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="handler" tests="4">
+    <testcase classname="handler" name="TestHandle"></testcase>
+    <testcase classname="handler" name="TestEmpty"><failure message="want 1, got 0"></failure></testcase>
+    <testcase classname="handler" name="TestSlow"><skipped/></testcase>
+    <testcase classname="handler" name="TestPanic"><error message="panic"></error></testcase>
+  </testsuite>
+</testsuites>`
+
+const junitSuite = `<testsuite name="api"><testcase name="returns 200"></testcase></testsuite>`
+
+func Test_ParseJUnit(t *testing.T) {
+	report := TestReport{}
+	if err := report.ParseJUnit([]byte(junitSuites)); err != nil {
+		t.Fatalf("want no error, got: %s", err.Error())
+	}
+	if err := report.ParseJUnit([]byte(junitSuite)); err != nil {
+		t.Fatalf("want no error, got: %s", err.Error())
+	}
+
+	if report.Passed != 2 || report.Failed != 2 || report.Skipped != 1 {
+		t.Errorf("want 2 passed, 2 failed, 1 skipped, got: %d, %d, %d", report.Passed, report.Failed, report.Skipped)
+	}
+
+	want := "2 passed, 2 failed, 1 skipped: handler.TestEmpty, handler.TestPanic"
+	if got := report.Description(); got != want {
+		t.Errorf("want: %q, got: %q", want, got)
+	}
+
+	if report.Succeeded() {
+		t.Errorf("want report with failures not to succeed")
+	}
+}
+
+func Test_TestReport_Succeeded(t *testing.T) {
+	if (&TestReport{}).Succeeded() {
+		t.Errorf("want a report without tests not to succeed")
+	}
+	if !(&TestReport{Passed: 1}).Succeeded() {
+		t.Errorf("want a passing report to succeed")
+	}
+	if (&TestReport{Passed: 1, Error: "exit code 1"}).Succeeded() {
+		t.Errorf("want a report with an error not to succeed")
+	}
+}
+
+func Test_TestReport_DescriptionTruncated(t *testing.T) {
+	report := TestReport{Failed: 1, Failures: []string{strings.Repeat("a", 200)}}
+	if got := report.Description(); len(got) != maxTestDescription {
+		t.Errorf("want description of %d chars, got: %d", maxTestDescription, len(got))
+	}
+}
+
+func Test_GetTestConfig(t *testing.T) {
+	if got := GetTestConfig(map[string]string{}); got != nil {
+		t.Errorf("want no test config, got: %v", got)
+	}
+
+	got := GetTestConfig(map[string]string{TestCommandAnnotation: "npm test"})
+	if got == nil || got.Command != "npm test" || got.Reports != DefaultTestReportsPath {
+		t.Errorf("want command with default reports path, got: %v", got)
+	}
+}